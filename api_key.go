@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+)
+
+// APIKeyFn authenticates a request, returning its principal. A nil principal
+// or an error rejects the request with a 401.
+type APIKeyFn func(ctx context.Context, r *http.Request) (principal interface{}, err error)
+
+// APIKeyHeader is the header StaticAPIKeys reads the API key from.
+const APIKeyHeader = "X-API-Key"
+
+var (
+	ErrMissingAPIKey = errors.New("missing API key")
+	ErrInvalidAPIKey = errors.New("invalid API key")
+)
+
+// StaticAPIKeys maps API keys to their principal, its Authenticate method
+// being an APIKeyFn reading the key from the APIKeyHeader.
+type StaticAPIKeys map[string]interface{}
+
+// Authenticate returns the principal of the API key of the request, comparing
+// the keys in constant time.
+func (k StaticAPIKeys) Authenticate(ctx context.Context, r *http.Request) (interface{}, error) {
+	key := r.Header.Get(APIKeyHeader)
+	if key == "" {
+		return nil, ErrMissingAPIKey
+	}
+	var principal interface{}
+	for candidate, p := range k {
+		if subtle.ConstantTimeCompare([]byte(candidate), []byte(key)) == 1 {
+			principal = p
+		}
+	}
+	if principal == nil {
+		return nil, ErrInvalidAPIKey
+	}
+	return principal, nil
+}
+
+type principalKey struct{}
+
+// PrincipalFromContext returns the principal the Config.APIKeyFn
+// authenticated the request as.
+func PrincipalFromContext(ctx context.Context) (interface{}, bool) {
+	principal := ctx.Value(principalKey{})
+	return principal, principal != nil
+}
+
+// authenticateAPIKey authenticates the request with the Config.APIKeyFn,
+// returning the context holding its principal.
+func (h *Handler) authenticateAPIKey(ctx context.Context, r *http.Request) (context.Context, *requestError) {
+	if h.apiKeyFn == nil {
+		return ctx, nil
+	}
+	principal, err := h.apiKeyFn(ctx, r)
+	if err != nil {
+		return ctx, newRequestError(http.StatusUnauthorized, CodeUnauthenticated, "Unauthenticated: "+err.Error())
+	}
+	if principal == nil {
+		return ctx, newRequestError(http.StatusUnauthorized, CodeUnauthenticated, "Unauthenticated")
+	}
+	return context.WithValue(ctx, principalKey{}, principal), nil
+}
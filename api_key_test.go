@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_APIKey(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"principal": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						principal, _ := PrincipalFromContext(p.Context)
+						return principal, nil
+					},
+				},
+			},
+		}),
+	})
+	keys := StaticAPIKeys{"k1": "mobile", "k2": "web"}
+	h := New(&Config{Schema: &schema, APIKeyFn: keys.Authenticate})
+	query := func(key string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/graphql?query={principal}", nil)
+		if key != "" {
+			req.Header.Set(APIKeyHeader, key)
+		}
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := query("k2"); resp.Code != http.StatusOK || resp.Body.String() != `{"data":{"principal":"web"}}` {
+		t.Errorf("unexpected response %d %s", resp.Code, resp.Body.String())
+	}
+	for key, message := range map[string]string{"": ErrMissingAPIKey.Error(), "k3": ErrInvalidAPIKey.Error()} {
+		resp := query(key)
+		if resp.Code != http.StatusUnauthorized || !strings.Contains(resp.Body.String(), message) || !strings.Contains(resp.Body.String(), string(CodeUnauthenticated)) {
+			t.Errorf("unexpected response for %q: %d %s", key, resp.Code, resp.Body.String())
+		}
+	}
+
+	h = New(&Config{Schema: &schema, APIKeyFn: func(ctx context.Context, r *http.Request) (interface{}, error) {
+		return nil, nil
+	}})
+	if resp := query("k1"); resp.Code != http.StatusUnauthorized {
+		t.Errorf("expected a nil principal to be rejected, got %d", resp.Code)
+	}
+}
+
+func TestWebSocket_APIKey(t *testing.T) {
+	keys := StaticAPIKeys{"secret": "svc"}
+	h := New(&Config{Schema: newSubscriptionSchema(t), Subscriptions: true, APIKeyFn: keys.Authenticate})
+
+	conn := dialWebSocket(t, h, ProtocolGraphQLTransportWS)
+	conn.WriteJSON(wsMessage{Type: wsConnectionInit})
+	_, _, err := conn.ReadMessage()
+	if closeErr, ok := err.(*websocket.CloseError); !ok || closeErr.Code != wsCloseUnauthorized {
+		t.Fatalf("expected close %v without a key, got %v", wsCloseUnauthorized, err)
+	}
+
+	conn = dialWebSocket(t, h, ProtocolGraphQLTransportWS)
+	conn.WriteJSON(wsMessage{Type: wsConnectionInit, Payload: []byte(`{"X-API-Key":"wrong"}`)})
+	_, _, err = conn.ReadMessage()
+	if closeErr, ok := err.(*websocket.CloseError); !ok || closeErr.Code != wsCloseUnauthorized {
+		t.Fatalf("expected close %v with a wrong key, got %v", wsCloseUnauthorized, err)
+	}
+
+	conn = dialWebSocket(t, h, ProtocolGraphQLTransportWS)
+	conn.WriteJSON(wsMessage{Type: wsConnectionInit, Payload: []byte(`{"X-API-Key":"secret"}`)})
+	if msg := readMessage(t, conn); msg.Type != wsConnectionAck {
+		t.Fatalf("expected connection_ack with the key, got %+v", msg)
+	}
+	conn.WriteJSON(wsMessage{ID: "1", Type: wsSubscribe, Payload: []byte(`{"query":"subscription { counter }"}`)})
+	if msg := readMessage(t, conn); msg.Type != wsNext {
+		t.Fatalf("expected next, got %+v", msg)
+	}
+}
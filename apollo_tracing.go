@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apolloTracing collects the timings of a request in the Apollo Tracing
+// format, reported in the "tracing" extension of its response.
+type apolloTracing struct {
+	start time.Time
+
+	mu         sync.Mutex
+	parsing    *apolloTracingPhase
+	validation *apolloTracingPhase
+	resolvers  []apolloTracingResolver
+}
+
+type apolloTracingPhase struct {
+	StartOffset int64 `json:"startOffset"`
+	Duration    int64 `json:"duration"`
+}
+
+type apolloTracingResolver struct {
+	Path        []interface{} `json:"path"`
+	ParentType  string        `json:"parentType"`
+	FieldName   string        `json:"fieldName"`
+	ReturnType  string        `json:"returnType"`
+	StartOffset int64         `json:"startOffset"`
+	Duration    int64         `json:"duration"`
+}
+
+type apolloTracingKey struct{}
+
+func apolloTracingFromContext(ctx context.Context) *apolloTracing {
+	tracing, _ := ctx.Value(apolloTracingKey{}).(*apolloTracing)
+	return tracing
+}
+
+func (h *Handler) apolloTracingEnabled() bool {
+	return h.apolloTracing || h.apolloTracingHeader != ""
+}
+
+// withApolloTracing starts collecting the timings of the request when
+// Config.ApolloTracing applies to it.
+func (h *Handler) withApolloTracing(ctx context.Context, r *http.Request) context.Context {
+	if !h.apolloTracing {
+		if h.apolloTracingHeader == "" {
+			return ctx
+		}
+		switch strings.ToLower(r.Header.Get(h.apolloTracingHeader)) {
+		case "", "0", "false":
+			return ctx
+		}
+	}
+	return context.WithValue(ctx, apolloTracingKey{}, &apolloTracing{start: time.Now()})
+}
+
+// startParsing and startValidation start timing a phase, returning the
+// function ending it.
+func (t *apolloTracing) startParsing() func() {
+	if t == nil {
+		return func() {}
+	}
+	return t.phase(&t.parsing)
+}
+
+func (t *apolloTracing) startValidation() func() {
+	if t == nil {
+		return func() {}
+	}
+	return t.phase(&t.validation)
+}
+
+func (t *apolloTracing) phase(phase **apolloTracingPhase) func() {
+	start := time.Now()
+	return func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		*phase = &apolloTracingPhase{StartOffset: start.Sub(t.start).Nanoseconds(), Duration: time.Since(start).Nanoseconds()}
+	}
+}
+
+func (t *apolloTracing) addResolver(timing ResolverTiming) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	path := timing.Path
+	if path == nil {
+		path = []interface{}{}
+	}
+	t.resolvers = append(t.resolvers, apolloTracingResolver{
+		Path:        path,
+		ParentType:  timing.ParentType,
+		FieldName:   timing.FieldName,
+		ReturnType:  timing.ReturnType,
+		StartOffset: timing.Start.Sub(t.start).Nanoseconds(),
+		Duration:    timing.Duration.Nanoseconds(),
+	})
+}
+
+// result returns the "tracing" extension, ending the request now.
+func (t *apolloTracing) result() map[string]interface{} {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	end := time.Now()
+	resolvers := t.resolvers
+	if resolvers == nil {
+		resolvers = []apolloTracingResolver{}
+	}
+	tracing := map[string]interface{}{
+		"version":   1,
+		"startTime": t.start.UTC().Format(time.RFC3339Nano),
+		"endTime":   end.UTC().Format(time.RFC3339Nano),
+		"duration":  end.Sub(t.start).Nanoseconds(),
+		"execution": map[string]interface{}{"resolvers": resolvers},
+	}
+	if t.parsing != nil {
+		tracing["parsing"] = t.parsing
+	}
+	if t.validation != nil {
+		tracing["validation"] = t.validation
+	}
+	return tracing
+}
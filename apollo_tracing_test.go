@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_ApolloTracing(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"name": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.String),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return "Alice", nil
+					},
+				},
+			},
+		}),
+	})
+	h := New(&Config{Schema: &schema, ApolloTracingHeader: "X-Apollo-Tracing"})
+	query := func(header string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ name }"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if header != "" {
+			req.Header.Set("X-Apollo-Tracing", header)
+		}
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := query(""); resp.Body.String() != `{"data":{"name":"Alice"}}` {
+		t.Errorf("expected no tracing without the header, got %s", resp.Body.String())
+	}
+	if resp := query("0"); strings.Contains(resp.Body.String(), "tracing") {
+		t.Errorf("expected no tracing with the header disabled, got %s", resp.Body.String())
+	}
+
+	resp := query("1")
+	var result struct {
+		Extensions struct {
+			Tracing struct {
+				Version    int       `json:"version"`
+				StartTime  time.Time `json:"startTime"`
+				EndTime    time.Time `json:"endTime"`
+				Duration   int64     `json:"duration"`
+				Parsing    *apolloTracingPhase
+				Validation *apolloTracingPhase
+				Execution  struct {
+					Resolvers []apolloTracingResolver `json:"resolvers"`
+				} `json:"execution"`
+			} `json:"tracing"`
+		} `json:"extensions"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	tracing := result.Extensions.Tracing
+	if tracing.Version != 1 || tracing.StartTime.IsZero() || tracing.EndTime.Before(tracing.StartTime) || tracing.Duration <= 0 {
+		t.Errorf("unexpected tracing %s", resp.Body.String())
+	}
+	if tracing.Parsing == nil || tracing.Validation == nil || tracing.Validation.StartOffset < tracing.Parsing.StartOffset {
+		t.Errorf("unexpected phases %s", resp.Body.String())
+	}
+	if len(tracing.Execution.Resolvers) != 1 {
+		t.Fatalf("unexpected resolvers %s", resp.Body.String())
+	}
+	resolver := tracing.Execution.Resolvers[0]
+	if !reflect.DeepEqual(resolver.Path, []interface{}{"name"}) || resolver.ParentType != "Query" || resolver.FieldName != "name" || resolver.ReturnType != "String!" {
+		t.Errorf("unexpected resolver %+v", resolver)
+	}
+}
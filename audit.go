@@ -0,0 +1,157 @@
+package handler
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// AuditRecord describes an executed mutation. Records are chained: the Hash
+// of a record covers its fields and the Hash of the previous one, so a
+// record removed or altered in the trail breaks the chain.
+type AuditRecord struct {
+	Time          time.Time              `json:"time"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Principal     string                 `json:"principal,omitempty"`
+	// Status is the HTTP status of the response, and Errors the messages of
+	// the errors of the result.
+	Status       int           `json:"status"`
+	Errors       []string      `json:"errors,omitempty"`
+	Duration     time.Duration `json:"duration"`
+	PreviousHash string        `json:"previousHash"`
+	Hash         string        `json:"-"`
+}
+
+// AuditSink receives an AuditRecord for every executed mutation, in the
+// order of the chain.
+type AuditSink interface {
+	RecordAudit(ctx context.Context, record AuditRecord)
+}
+
+// AuditSinkFunc adapts a function to the AuditSink interface.
+type AuditSinkFunc func(ctx context.Context, record AuditRecord)
+
+func (f AuditSinkFunc) RecordAudit(ctx context.Context, record AuditRecord) {
+	f(ctx, record)
+}
+
+// AuditPrincipalFn identifies who performs a mutation.
+type AuditPrincipalFn func(ctx context.Context, r *http.Request) string
+
+// auditRedacted replaces the values of the redacted variables.
+const auditRedacted = "[REDACTED]"
+
+type auditLog struct {
+	sink        AuditSink
+	principalFn AuditPrincipalFn
+	redact      map[string]bool
+
+	mu   sync.Mutex
+	hash string
+}
+
+func newAuditLog(p *Config) *auditLog {
+	if p.AuditSink == nil {
+		return nil
+	}
+	redact := make(map[string]bool, len(p.AuditRedactVariables))
+	for _, name := range p.AuditRedactVariables {
+		redact[strings.ToLower(name)] = true
+	}
+	return &auditLog{sink: p.AuditSink, principalFn: p.AuditPrincipalFn, redact: redact}
+}
+
+// VerifyAuditChain reports whether the records, in order, form an unbroken
+// chain, the first one following the previous hash given.
+func VerifyAuditChain(previousHash string, records []AuditRecord) bool {
+	for _, record := range records {
+		if record.PreviousHash != previousHash || record.Hash != auditHash(record) {
+			return false
+		}
+		previousHash = record.Hash
+	}
+	return true
+}
+
+func auditHash(record AuditRecord) string {
+	encoded, _ := json.Marshal(record)
+	sum := sha256.Sum256(encoded)
+	return hex.EncodeToString(sum[:])
+}
+
+// redactVariables copies the variables, replacing the values of the keys to
+// redact, at any depth.
+func (a *auditLog) redactVariables(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		redacted := make(map[string]interface{}, len(value))
+		for key, v := range value {
+			if a.redact[strings.ToLower(key)] {
+				redacted[key] = auditRedacted
+			} else {
+				redacted[key] = a.redactVariables(v)
+			}
+		}
+		return redacted
+	case []interface{}:
+		redacted := make([]interface{}, len(value))
+		for i, v := range value {
+			redacted[i] = a.redactVariables(v)
+		}
+		return redacted
+	}
+	return value
+}
+
+func (a *auditLog) principal(ctx context.Context, r *http.Request) string {
+	if a.principalFn != nil {
+		return a.principalFn(ctx, r)
+	}
+	if claims, ok := JWTClaimsFromContext(ctx); ok {
+		if subject, ok := claims["sub"].(string); ok {
+			return subject
+		}
+	}
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		return fmt.Sprint(principal)
+	}
+	return ""
+}
+
+// recordAudit sends an AuditRecord of the mutation to the Config.AuditSink.
+func (h *Handler) recordAudit(ctx context.Context, r *http.Request, op *operation, variables map[string]interface{}, status int, result *graphql.Result, duration time.Duration) {
+	a := h.auditLog
+	if a == nil || op == nil || op.Type() != ast.OperationTypeMutation {
+		return
+	}
+	record := AuditRecord{
+		Time:          time.Now(),
+		OperationName: op.Name(),
+		Principal:     a.principal(ctx, r),
+		Status:        status,
+		Duration:      duration,
+	}
+	for _, err := range result.Errors {
+		record.Errors = append(record.Errors, err.Message)
+	}
+	if variables != nil {
+		record.Variables = a.redactVariables(variables).(map[string]interface{})
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	record.PreviousHash = a.hash
+	record.Hash = auditHash(record)
+	a.hash = record.Hash
+	a.sink.RecordAudit(ctx, record)
+}
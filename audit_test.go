@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_AuditSink(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   "Query",
+			Fields: graphql.Fields{"name": &graphql.Field{Type: graphql.String}},
+		}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Mutation",
+			Fields: graphql.Fields{
+				"login": &graphql.Field{
+					Type: graphql.Boolean,
+					Args: graphql.FieldConfigArgument{"input": &graphql.ArgumentConfig{Type: graphql.NewInputObject(graphql.InputObjectConfig{
+						Name: "LoginInput",
+						Fields: graphql.InputObjectConfigFieldMap{
+							"user":     &graphql.InputObjectFieldConfig{Type: graphql.String},
+							"password": &graphql.InputObjectFieldConfig{Type: graphql.String},
+						},
+					})}},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						input := p.Args["input"].(map[string]interface{})
+						if input["password"] != "secret" {
+							return nil, errors.New("wrong password")
+						}
+						return true, nil
+					},
+				},
+			},
+		}),
+	})
+	var records []AuditRecord
+	h := New(&Config{
+		Schema: &schema,
+		AuditSink: AuditSinkFunc(func(ctx context.Context, record AuditRecord) {
+			records = append(records, record)
+		}),
+		AuditPrincipalFn: func(ctx context.Context, r *http.Request) string {
+			return r.Header.Get("X-User")
+		},
+		AuditRedactVariables: []string{"Password"},
+	})
+	send := func(body string) {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("X-User", "alice")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	send(`{"query":"mutation Login($input: LoginInput) { login(input: $input) }","variables":{"input":{"user":"alice","password":"secret"}}}`)
+	send(`{"query":"{ name }"}`)
+	send(`{"query":"mutation Login($input: LoginInput) { login(input: $input) }","variables":{"input":{"user":"alice","password":"guess"}}}`)
+
+	if len(records) != 2 {
+		t.Fatalf("expected a record per mutation, got %+v", records)
+	}
+	record := records[0]
+	if record.OperationName != "Login" || record.Principal != "alice" || record.Status != http.StatusOK || len(record.Errors) != 0 {
+		t.Errorf("unexpected record %+v", record)
+	}
+	if !reflect.DeepEqual(record.Variables, map[string]interface{}{"input": map[string]interface{}{"user": "alice", "password": "[REDACTED]"}}) {
+		t.Errorf("unexpected variables %v", record.Variables)
+	}
+	if !reflect.DeepEqual(records[1].Errors, []string{"wrong password"}) {
+		t.Errorf("unexpected errors %v", records[1].Errors)
+	}
+
+	if !VerifyAuditChain("", records) {
+		t.Errorf("expected the chain to be unbroken")
+	}
+	records[0].Principal = "bob"
+	if VerifyAuditChain("", records) {
+		t.Errorf("expected the altered record to break the chain")
+	}
+	if VerifyAuditChain("", records[1:]) {
+		t.Errorf("expected the removed record to break the chain")
+	}
+}
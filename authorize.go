@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+)
+
+// AuthorizeFn authorizes an operation once parsed, before its execution. The
+// errors it returns reject the operation, with their status when they have a
+// StatusCode method like StatusError, 403 otherwise.
+type AuthorizeFn func(ctx context.Context, r *http.Request, opts *RequestOptions) error
+
+// StatusError is an error carrying the HTTP status and the code of the
+// response it rejects a request with.
+type StatusError struct {
+	Status  int
+	Code    ErrorCode
+	Message string
+}
+
+func (e *StatusError) Error() string {
+	return e.Message
+}
+
+// StatusCode returns the HTTP status of the error.
+func (e *StatusError) StatusCode() int {
+	return e.Status
+}
+
+// checkAuthorization rejects the operations the Config.AuthorizeFn does not
+// authorize.
+func (h *Handler) checkAuthorization(ctx context.Context, r *http.Request, opts *RequestOptions) *requestError {
+	if h.authorizeFn == nil {
+		return nil
+	}
+	err := h.authorizeFn(ctx, r, opts)
+	if err == nil {
+		return nil
+	}
+	return statusRequestError(err, http.StatusForbidden, CodeForbidden)
+}
+
+// statusRequestError converts an error to a request error, with the status
+// and code of the error when it carries them, the given ones otherwise.
+func statusRequestError(err error, status int, code ErrorCode) *requestError {
+	if statusErr, ok := err.(interface{ StatusCode() int }); ok && statusErr.StatusCode() != 0 {
+		status = statusErr.StatusCode()
+	}
+	if statusErr, ok := err.(*StatusError); ok && statusErr.Code != "" {
+		code = statusErr.Code
+	}
+	return newRequestError(status, code, err.Error())
+}
@@ -0,0 +1,57 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_AuthorizeFn(t *testing.T) {
+	h := New(&Config{
+		Schema: &testutil.StarWarsSchema,
+		AuthorizeFn: func(ctx context.Context, r *http.Request, opts *RequestOptions) error {
+			switch opts.OperationName {
+			case "Secret":
+				return errors.New("not allowed")
+			case "Paid":
+				return &StatusError{Status: http.StatusPaymentRequired, Code: "PAYMENT_REQUIRED", Message: "upgrade your plan"}
+			}
+			return nil
+		},
+	})
+	tests := []struct {
+		operationName string
+		status        int
+		code          string
+		message       string
+	}{
+		{"Public", http.StatusOK, "", ""},
+		{"Secret", http.StatusForbidden, string(CodeForbidden), "not allowed"},
+		{"Paid", http.StatusPaymentRequired, "PAYMENT_REQUIRED", "upgrade your plan"},
+	}
+	for _, test := range tests {
+		t.Run(test.operationName, func(t *testing.T) {
+			req, _ := http.NewRequest("GET", "/graphql?query=query+"+test.operationName+"{hero{name}}&operationName="+test.operationName, nil)
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+			var result struct {
+				Errors []struct {
+					Message    string            `json:"message"`
+					Extensions map[string]string `json:"extensions"`
+				} `json:"errors"`
+			}
+			json.Unmarshal(resp.Body.Bytes(), &result)
+			if resp.Code != test.status {
+				t.Errorf("expected %d, got %d %s", test.status, resp.Code, resp.Body.String())
+			}
+			if test.code != "" && (len(result.Errors) != 1 || result.Errors[0].Message != test.message || result.Errors[0].Extensions["code"] != test.code) {
+				t.Errorf("unexpected response %s", resp.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type baggageKey struct{}
+
+// BaggageFromContext returns the W3C Baggage entries of the request listed
+// in Config.BaggageKeys, nil when none is configured.
+func BaggageFromContext(ctx context.Context) map[string]string {
+	baggage, _ := ctx.Value(baggageKey{}).(map[string]string)
+	return baggage
+}
+
+// BaggageValue returns the W3C Baggage entry of the request with the key,
+// when listed in Config.BaggageKeys.
+func BaggageValue(ctx context.Context, key string) (string, bool) {
+	value, ok := BaggageFromContext(ctx)[key]
+	return value, ok
+}
+
+// withBaggage keeps the allowed entries of the Baggage headers of the
+// request in the context. Entries without a valid key or value are skipped,
+// their properties ignored.
+func (h *Handler) withBaggage(ctx context.Context, r *http.Request) context.Context {
+	if len(h.baggageKeys) == 0 {
+		return ctx
+	}
+	baggage := make(map[string]string)
+	for _, header := range r.Header["Baggage"] {
+		for _, member := range strings.Split(header, ",") {
+			if i := strings.Index(member, ";"); i >= 0 {
+				member = member[:i]
+			}
+			i := strings.Index(member, "=")
+			if i < 0 {
+				continue
+			}
+			key := strings.TrimSpace(member[:i])
+			if !h.baggageKeys[key] {
+				continue
+			}
+			value, err := url.PathUnescape(strings.TrimSpace(member[i+1:]))
+			if err != nil {
+				continue
+			}
+			baggage[key] = value
+		}
+	}
+	return context.WithValue(ctx, baggageKey{}, baggage)
+}
@@ -0,0 +1,33 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_Baggage(t *testing.T) {
+	var baggage map[string]string
+	h := New(&Config{
+		Schema:      &testutil.StarWarsSchema,
+		BaggageKeys: []string{"tenant.tier", "experiment"},
+		RootObjectFn: func(ctx context.Context, r *http.Request) map[string]interface{} {
+			baggage = BaggageFromContext(ctx)
+			return nil
+		},
+	})
+
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+	req.Header.Add("Baggage", "tenant.tier=gold;ttl=60, secret=1")
+	req.Header.Add("Baggage", "experiment=new%20checkout")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	expected := map[string]string{"tenant.tier": "gold", "experiment": "new checkout"}
+	if !reflect.DeepEqual(baggage, expected) {
+		t.Fatalf("expected %v, got %v", expected, baggage)
+	}
+}
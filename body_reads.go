@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+func newBodyReads(max int) chan struct{} {
+	if max <= 0 {
+		return nil
+	}
+	return make(chan struct{}, max)
+}
+
+// limitedBody is a request body limited by Config.MaxBodyBytes with
+// http.MaxBytesReader, recording whether the limit was exceeded so that the
+// request is answered 413 whichever step read it.
+type limitedBody struct {
+	io.ReadCloser
+	max      int64
+	read     int64
+	exceeded bool
+}
+
+func (b *limitedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.read += int64(n)
+	if err != nil && err != io.EOF && b.read >= b.max {
+		b.exceeded = true
+	}
+	return n, err
+}
+
+// limitBody limits the body of the request to Config.MaxBodyBytes, the
+// returned error rejecting the bodies announced larger at once.
+func (h *Handler) limitBody(w http.ResponseWriter, r *http.Request) (*http.Request, *limitedBody, *requestError) {
+	if h.maxBodyBytes <= 0 || r.Body == nil || r.Body == http.NoBody {
+		return r, nil, nil
+	}
+	body := &limitedBody{max: h.maxBodyBytes}
+	if r.ContentLength > h.maxBodyBytes {
+		body.exceeded = true
+		return r, body, body.check(nil)
+	}
+	body.ReadCloser = http.MaxBytesReader(w, r.Body, h.maxBodyBytes)
+	limited := r.WithContext(r.Context())
+	limited.Body = body
+	return limited, body, nil
+}
+
+// check replaces the result of a step reading the body by a 413 error once
+// the limit was exceeded, the step failing or seeing a truncated body.
+func (b *limitedBody) check(reqErr *requestError) *requestError {
+	if b == nil || !b.exceeded {
+		return reqErr
+	}
+	return newRequestError(http.StatusRequestEntityTooLarge, CodeRequestTooLarge, "Request body exceeds "+strconv.FormatInt(b.max, 10)+" bytes")
+}
+
+// bufferBody reads the body of POST requests ahead of parsing, within
+// Config.BodyReadTimeout and with at most Config.MaxConcurrentBodyReads
+// bodies read at once. A read past the timeout cannot be interrupted: it
+// keeps its slot until the client sends the body or the server ReadTimeout
+// closes the connection, the request being answered 408 in the meantime.
+func (h *Handler) bufferBody(ctx context.Context, r *http.Request) (*http.Request, *requestError) {
+	if h.bodyReadTimeout <= 0 && h.bodyReads == nil {
+		return r, nil
+	}
+	if r.Method != http.MethodPost || r.Body == nil || r.Body == http.NoBody {
+		return r, nil
+	}
+
+	var deadline <-chan time.Time
+	if h.bodyReadTimeout > 0 {
+		timer := time.NewTimer(h.bodyReadTimeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	if h.bodyReads != nil {
+		if deadline == nil {
+			select {
+			case h.bodyReads <- struct{}{}:
+			default:
+				return r, newRequestError(http.StatusServiceUnavailable, CodeTooManyConcurrentRequests, "Too many concurrent request body reads")
+			}
+		} else {
+			select {
+			case h.bodyReads <- struct{}{}:
+			case <-deadline:
+				return r, newRequestError(http.StatusServiceUnavailable, CodeTooManyConcurrentRequests, "Too many concurrent request body reads")
+			case <-ctx.Done():
+				return r, newRequestError(http.StatusServiceUnavailable, CodeTooManyConcurrentRequests, "Too many concurrent request body reads")
+			}
+		}
+	}
+
+	type read struct {
+		body []byte
+		err  error
+	}
+	done := make(chan read, 1)
+	go func() {
+		body, err := ioutil.ReadAll(r.Body)
+		if h.bodyReads != nil {
+			<-h.bodyReads
+		}
+		done <- read{body: body, err: err}
+	}()
+
+	select {
+	case read := <-done:
+		if read.err != nil {
+			return r, newRequestError(http.StatusBadRequest, CodeInvalidRequest, "Invalid request body: "+read.err.Error())
+		}
+		buffered := r.Clone(r.Context())
+		buffered.Body = ioutil.NopCloser(bytes.NewReader(read.body))
+		buffered.ContentLength = int64(len(read.body))
+		return buffered, nil
+	case <-deadline:
+		return r, newRequestError(http.StatusRequestTimeout, CodeRequestTimeout, "Request body not received within "+h.bodyReadTimeout.String())
+	case <-ctx.Done():
+		return r, newRequestError(http.StatusRequestTimeout, CodeRequestTimeout, "Request body not received")
+	}
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestHandler_BodyReadTimeout(t *testing.T) {
+	schema := limitsSchema(t, new(int))
+	h := New(&Config{Schema: &schema, BodyReadTimeout: 20 * time.Millisecond})
+
+	body, writer := io.Pipe()
+	defer writer.Close()
+	req, _ := http.NewRequest("POST", "/graphql", body)
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != http.StatusRequestTimeout || resp.Header().Get("Connection") != "close" {
+		t.Errorf("expected 408 closing the connection, got %d %v", resp.Code, resp.Header())
+	}
+
+	req, _ = http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ name }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if expected := `{"data":{"name":"name"}}`; resp.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, resp.Body.String())
+	}
+}
+
+func TestHandler_MaxConcurrentBodyReads(t *testing.T) {
+	schema := limitsSchema(t, new(int))
+	h := New(&Config{Schema: &schema, MaxConcurrentBodyReads: 1})
+
+	body, writer := io.Pipe()
+	done := make(chan *httptest.ResponseRecorder)
+	go func() {
+		req, _ := http.NewRequest("POST", "/graphql", body)
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		done <- resp
+	}()
+	writer.Write([]byte(`{"query":`))
+
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ name }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503, got %d", resp.Code)
+	}
+
+	writer.Write([]byte(`"{ name }"}`))
+	writer.Close()
+	if resp := <-done; resp.Body.String() != `{"data":{"name":"name"}}` {
+		t.Errorf("unexpected response %s", resp.Body.String())
+	}
+
+	req, _ = http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ name }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Errorf("expected the slot to be released, got %d", resp.Code)
+	}
+}
+
+func TestHandler_MaxBodyBytes(t *testing.T) {
+	schema := limitsSchema(t, new(int))
+	body := `{"query":"{ name }","variables":{"padding":"` + strings.Repeat("x", 100) + `"}}`
+	for _, config := range []*Config{
+		{Schema: &schema, MaxBodyBytes: 64},
+		{Schema: &schema, MaxBodyBytes: 64, BodyReadTimeout: time.Second},
+	} {
+		for _, contentLength := range []int64{int64(len(body)), -1} {
+			h := New(config)
+			req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			req.ContentLength = contentLength
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+			if resp.Code != http.StatusRequestEntityTooLarge || !strings.Contains(resp.Body.String(), string(CodeRequestTooLarge)) {
+				t.Errorf("expected 413 with Content-Length %d, got %d %s", contentLength, resp.Code, resp.Body.String())
+			}
+		}
+	}
+
+	h := New(&Config{Schema: &schema, MaxBodyBytes: 64})
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ name }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if expected := `{"data":{"name":"name"}}`; resp.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, resp.Body.String())
+	}
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+)
+
+// Caches whose changes are broadcast.
+const (
+	CachePersistedQueries = "persistedQueries"
+	CacheResponses        = "responses"
+)
+
+// Cache event types.
+const (
+	CacheEventRegister = "register"
+	CacheEventPurge    = "purge"
+)
+
+// CacheEvent describes a change made to a handler cache by one instance of
+// a service, to be applied by the other ones.
+type CacheEvent struct {
+	// Origin identifies the handler that published the event.
+	Origin        string
+	Cache         string
+	Type          string
+	Key           string
+	OperationName string
+	Query         string
+	Version       float64
+	Tag           string
+	Variables     map[string]interface{}
+}
+
+// CacheBroadcaster propagates cache changes between the instances of a
+// service, e.g. over a pub/sub channel shared by all regions, so that their
+// in-memory caches stay coherent.
+type CacheBroadcaster interface {
+	// Publish sends the event to all the instances.
+	Publish(ctx context.Context, event CacheEvent) error
+	// Subscribe registers fn to receive the events published by any
+	// instance, until ctx is done. It must not block.
+	Subscribe(ctx context.Context, fn func(event CacheEvent)) error
+}
+
+func newInstanceID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// publishCacheEvent broadcasts a change made by this handler.
+func (h *Handler) publishCacheEvent(event CacheEvent) {
+	if h.cacheBroadcaster == nil {
+		return
+	}
+	event.Origin = h.instanceID
+	go func() {
+		ctx := context.Background()
+		defer h.recoverCallback(ctx, "CacheBroadcaster.Publish")
+		if err := h.cacheBroadcaster.Publish(ctx, event); err != nil {
+			h.log(ctx, LevelWarn, "cache event broadcast failed", "cache", event.Cache, "error", err.Error())
+		}
+	}()
+}
+
+// subscribeCacheEvents applies the changes broadcast by the other instances
+// until the handler is closed.
+func (h *Handler) subscribeCacheEvents() {
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := h.cacheBroadcaster.Subscribe(ctx, h.applyCacheEvent); err != nil {
+		cancel()
+		h.log(ctx, LevelWarn, "cache event subscription failed, the changes of the other instances are not applied", "error", err.Error())
+		return
+	}
+	h.cacheSubscription = cancel
+}
+
+// applyCacheEvent applies a change broadcast by another instance.
+func (h *Handler) applyCacheEvent(event CacheEvent) {
+	if event.Origin == h.instanceID {
+		return
+	}
+	switch event.Cache {
+	case CachePersistedQueries:
+		switch event.Type {
+		case CacheEventRegister:
+			if !matchesHash(event.Query, event.Key) {
+				return
+			}
+			h.persistedQueries.set(CacheEntry{
+				operationName: event.OperationName,
+				query:         event.Query,
+				sha256Hash:    event.Key,
+				version:       event.Version,
+			})
+		case CacheEventPurge:
+			h.persistedQueries.delete(event.Key)
+		}
+	case CacheResponses:
+		if event.Type == CacheEventPurge && h.responseCache != nil {
+			h.responseCache.purge(ResponseCachePurge{
+				OperationName: event.OperationName,
+				Tag:           event.Tag,
+				Variables:     event.Variables,
+			}.normalized())
+		}
+	}
+}
+
+// PurgePersistedQuery removes a persisted query by hash, on all instances
+// when a CacheBroadcaster is configured.
+func (h *Handler) PurgePersistedQuery(sha256Hash string) {
+	h.persistedQueries.delete(sha256Hash)
+	h.publishCacheEvent(CacheEvent{
+		Cache: CachePersistedQueries,
+		Type:  CacheEventPurge,
+		Key:   sha256Hash,
+	})
+}
@@ -0,0 +1,150 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+// memoryBroadcaster delivers the events to all subscribers of the process.
+type memoryBroadcaster struct {
+	mu          sync.Mutex
+	subscribers []func(event CacheEvent)
+	published   chan CacheEvent
+}
+
+func (b *memoryBroadcaster) Publish(ctx context.Context, event CacheEvent) error {
+	b.mu.Lock()
+	subscribers := b.subscribers
+	b.mu.Unlock()
+	for _, fn := range subscribers {
+		fn(event)
+	}
+	b.published <- event
+	return nil
+}
+
+func (b *memoryBroadcaster) Subscribe(ctx context.Context, fn func(event CacheEvent)) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subscribers = append(b.subscribers, fn)
+	return nil
+}
+
+// heroNameHash is the SHA-256 hash of heroNameQuery.
+const (
+	heroNameQuery = "{hero{name}}"
+	heroNameHash  = "993f8cd4f05bd4830617ad3e781cec9d68ac28b92a8a35eb38485702e2ca9348"
+)
+
+func persistedQueryRequest(h *Handler, sha, query string) *httptest.ResponseRecorder {
+	extensions := `{"persistedQuery":{"version":1,"sha256Hash":"` + sha + `"}}`
+	target := "/graphql?extensions=" + url.QueryEscape(extensions)
+	if query != "" {
+		target += "&query=" + url.QueryEscape(query)
+	}
+	req, _ := http.NewRequest("GET", target, nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	return resp
+}
+
+func waitCacheEvent(t *testing.T, b *memoryBroadcaster, eventType string) {
+	select {
+	case event := <-b.published:
+		if event.Type != eventType {
+			t.Fatalf("expected a %s event, got %+v", eventType, event)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("no %s event published", eventType)
+	}
+}
+
+func TestHandler_CacheBroadcaster(t *testing.T) {
+	broadcaster := &memoryBroadcaster{published: make(chan CacheEvent, 1)}
+	first := New(&Config{Schema: &testutil.StarWarsSchema, CacheBroadcaster: broadcaster})
+	second := New(&Config{Schema: &testutil.StarWarsSchema, CacheBroadcaster: broadcaster})
+
+	persistedQueryRequest(first, heroNameHash, heroNameQuery)
+	waitCacheEvent(t, broadcaster, CacheEventRegister)
+
+	resp := persistedQueryRequest(second, heroNameHash, "")
+	var result map[string]interface{}
+	json.Unmarshal(resp.Body.Bytes(), &result)
+	if _, ok := result["data"]; !ok {
+		t.Fatalf("expected the query registered in another instance, got %s", resp.Body.String())
+	}
+
+	second.PurgePersistedQuery(heroNameHash)
+	waitCacheEvent(t, broadcaster, CacheEventPurge)
+
+	resp = persistedQueryRequest(first, heroNameHash, "")
+	if !strings.Contains(resp.Body.String(), "PERSISTED_QUERY_NOT_FOUND") {
+		t.Fatalf("expected the query purged in all instances, got %s", resp.Body.String())
+	}
+}
+
+func TestHandler_PersistedQueryHashMismatch(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema})
+	persistedQueryRequest(h, heroNameHash, heroNameQuery)
+
+	resp := persistedQueryRequest(h, heroNameHash, "{hero{id}}")
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected a hash mismatch to be rejected, got %d %s", resp.Code, resp.Body.String())
+	}
+	resp = persistedQueryRequest(h, heroNameHash, "")
+	if !strings.Contains(resp.Body.String(), "R2-D2") {
+		t.Fatalf("expected the registered query to be kept, got %s", resp.Body.String())
+	}
+}
+
+// subscriptionBroadcaster records the context of its subscription.
+type subscriptionBroadcaster struct {
+	ctx context.Context
+	err error
+}
+
+func (b *subscriptionBroadcaster) Publish(ctx context.Context, event CacheEvent) error {
+	return nil
+}
+
+func (b *subscriptionBroadcaster) Subscribe(ctx context.Context, fn func(event CacheEvent)) error {
+	b.ctx = ctx
+	return b.err
+}
+
+func TestHandler_CacheBroadcasterSubscription(t *testing.T) {
+	broadcaster := &subscriptionBroadcaster{}
+	h := New(&Config{Schema: &testutil.StarWarsSchema, CacheBroadcaster: broadcaster})
+	if broadcaster.ctx.Err() != nil {
+		t.Fatalf("expected the subscription to be active")
+	}
+	h.Close()
+	if broadcaster.ctx.Err() == nil {
+		t.Fatalf("expected Close to cancel the subscription")
+	}
+
+	var warnings []string
+	New(&Config{
+		Schema:           &testutil.StarWarsSchema,
+		CacheBroadcaster: &subscriptionBroadcaster{err: errors.New("connection refused")},
+		Logger: LoggerFunc(func(ctx context.Context, level LogLevel, msg string, args ...interface{}) {
+			if level == LevelWarn {
+				warnings = append(warnings, msg+" "+fmt.Sprint(args...))
+			}
+		}),
+	})
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "connection refused") {
+		t.Fatalf("expected the subscription failure to be logged, got %v", warnings)
+	}
+}
@@ -0,0 +1,61 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+)
+
+// CachePurgeAuthFn authorizes a request to the cache purge endpoint.
+type CachePurgeAuthFn func(r *http.Request) bool
+
+// PurgeResponses removes the cached responses selected by the filter, on all
+// instances when a CacheBroadcaster is configured, and returns the number of
+// responses purged locally.
+func (h *Handler) PurgeResponses(filter ResponseCachePurge) int {
+	if h.responseCache == nil {
+		return 0
+	}
+	filter = filter.normalized()
+	purged := h.responseCache.purge(filter)
+	h.publishCacheEvent(CacheEvent{
+		Cache:         CacheResponses,
+		Type:          CacheEventPurge,
+		OperationName: filter.OperationName,
+		Tag:           filter.Tag,
+		Variables:     filter.Variables,
+	})
+	h.emit(context.Background(), EventCachePurge, SeverityInfo, "", map[string]interface{}{
+		"graphql.operation.name": filter.OperationName,
+		"graphql.cache.tag":      filter.Tag,
+		"graphql.cache.purged":   purged,
+	})
+	return purged
+}
+
+// PurgeHandler serves the cache purge API: POST requests with a JSON
+// ResponseCachePurge body, authorized by Config.CachePurgeAuthFn, purge the
+// selected responses and get back the number of responses purged.
+func (h *Handler) PurgeHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.cachePurgeAuthFn == nil || !h.cachePurgeAuthFn(r) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		var filter ResponseCachePurge
+		if err := json.NewDecoder(r.Body).Decode(&filter); err != nil {
+			http.Error(w, "invalid purge request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		purged := h.PurgeResponses(filter)
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string]int{"purged": purged})
+	})
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+func newCountingSchema(t *testing.T, executions *int32) *graphql.Schema {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"product": &graphql.Field{
+					Type: graphql.String,
+					Args: graphql.FieldConfigArgument{
+						"id": &graphql.ArgumentConfig{Type: graphql.Int},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						atomic.AddInt32(executions, 1)
+						AddCacheTags(p.Context, fmt.Sprintf("product:%v", p.Args["id"]))
+						return "product", nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &schema
+}
+
+func queryProduct(h *Handler, id string) {
+	query := url.QueryEscape(`query Product($id: Int) { product(id: $id) }`)
+	variables := url.QueryEscape(`{"id":` + id + `}`)
+	req, _ := http.NewRequest("GET", "/graphql?query="+query+"&variables="+variables, nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+}
+
+func TestHandler_PurgeResponses(t *testing.T) {
+	var executions int32
+	h := New(&Config{Schema: newCountingSchema(t, &executions), ResponseCacheTTL: time.Minute, ResponseCacheKeyFn: publicScope})
+
+	queryProduct(h, "1")
+	queryProduct(h, "2")
+	queryProduct(h, "1")
+	if executions != 2 {
+		t.Fatalf("expected 2 executions, got %d", executions)
+	}
+
+	if purged := h.PurgeResponses(ResponseCachePurge{Variables: map[string]interface{}{"id": 1}}); purged != 1 {
+		t.Fatalf("expected 1 response purged, got %d", purged)
+	}
+	queryProduct(h, "1")
+	queryProduct(h, "2")
+	if executions != 3 {
+		t.Fatalf("expected only the purged response executed again, got %d executions", executions)
+	}
+
+	if purged := h.PurgeResponses(ResponseCachePurge{OperationName: "Product"}); purged != 2 {
+		t.Fatalf("expected 2 responses purged, got %d", purged)
+	}
+}
+
+func TestHandler_PurgeHandler(t *testing.T) {
+	var executions int32
+	h := New(&Config{
+		Schema:             newCountingSchema(t, &executions),
+		ResponseCacheTTL:   time.Minute,
+		ResponseCacheKeyFn: publicScope,
+		CachePurgeAuthFn: func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "Bearer admin"
+		},
+	})
+	queryProduct(h, "1")
+
+	req, _ := http.NewRequest("POST", "/purge", strings.NewReader(`{"operationName":"Product"}`))
+	resp := httptest.NewRecorder()
+	h.PurgeHandler().ServeHTTP(resp, req)
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected unauthorized purge to be rejected, got %d", resp.Code)
+	}
+
+	req, _ = http.NewRequest("POST", "/purge", strings.NewReader(`{"operationName":"Product"}`))
+	req.Header.Set("Authorization", "Bearer admin")
+	resp = httptest.NewRecorder()
+	h.PurgeHandler().ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK || strings.TrimSpace(resp.Body.String()) != `{"purged":1}` {
+		t.Fatalf("unexpected purge response %d %s", resp.Code, resp.Body.String())
+	}
+}
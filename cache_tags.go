@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"context"
+	"sync"
+)
+
+// cacheTags collects the cache tags attached while executing a request.
+type cacheTags struct {
+	mu   sync.Mutex
+	tags map[string]bool
+}
+
+type cacheTagsKey struct{}
+
+func withCacheTags(ctx context.Context) (context.Context, *cacheTags) {
+	tags := &cacheTags{tags: make(map[string]bool)}
+	return context.WithValue(ctx, cacheTagsKey{}, tags), tags
+}
+
+// AddCacheTags attaches tags, e.g. "product:123", to the response of the
+// current request, so that purging one of them from the response cache
+// purges the response. It does nothing when the response is not cached.
+func AddCacheTags(ctx context.Context, tags ...string) {
+	collected, ok := ctx.Value(cacheTagsKey{}).(*cacheTags)
+	if !ok {
+		return
+	}
+	collected.mu.Lock()
+	defer collected.mu.Unlock()
+	for _, tag := range tags {
+		collected.tags[tag] = true
+	}
+}
+
+func (c *cacheTags) list() map[string]bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	tags := make(map[string]bool, len(c.tags))
+	for tag := range c.tags {
+		tags[tag] = true
+	}
+	return tags
+}
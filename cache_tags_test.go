@@ -0,0 +1,23 @@
+package handler
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHandler_PurgeResponsesByTag(t *testing.T) {
+	var executions int32
+	h := New(&Config{Schema: newCountingSchema(t, &executions), ResponseCacheTTL: time.Minute, ResponseCacheKeyFn: publicScope})
+
+	queryProduct(h, "1")
+	queryProduct(h, "2")
+	if purged := h.PurgeResponses(ResponseCachePurge{Tag: "product:2"}); purged != 1 {
+		t.Fatalf("expected 1 response purged, got %d", purged)
+	}
+
+	queryProduct(h, "1")
+	queryProduct(h, "2")
+	if executions != 3 {
+		t.Fatalf("expected only the tagged response executed again, got %d executions", executions)
+	}
+}
@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// TranscodeFn converts a UTF-8 encoded JSON response body to the charset
+// declared with Config.ResponseCharset.
+type TranscodeFn func(body []byte) []byte
+
+// TranscodeLatin1 encodes a JSON body in ISO-8859-1, escaping the characters
+// outside of it.
+func TranscodeLatin1(body []byte) []byte {
+	return transcodeJSON(body, 0xFF)
+}
+
+// TranscodeASCII encodes a JSON body in US-ASCII, escaping the non ASCII
+// characters.
+func TranscodeASCII(body []byte) []byte {
+	return transcodeJSON(body, 0x7F)
+}
+
+// transcodeJSON encodes the runes up to max as single bytes and escapes the
+// others, which only occur in JSON strings.
+func transcodeJSON(body []byte, max rune) []byte {
+	transcoded := make([]byte, 0, len(body))
+	for len(body) > 0 {
+		r, size := utf8.DecodeRune(body)
+		body = body[size:]
+		switch {
+		case r <= max:
+			transcoded = append(transcoded, byte(r))
+		case r > 0xFFFF:
+			r -= 0x10000
+			transcoded = append(transcoded, fmt.Sprintf(`\u%04x\u%04x`, 0xD800+(r>>10), 0xDC00+(r&0x3FF))...)
+		default:
+			transcoded = append(transcoded, fmt.Sprintf(`\u%04x`, r)...)
+		}
+	}
+	return transcoded
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestTranscodeLatin1(t *testing.T) {
+	body, _ := json.Marshal(map[string]string{"name": "Padmé 日本 😀"})
+	transcoded := TranscodeLatin1(body)
+
+	expected := "{\"name\":\"Padm\xe9 \\u65e5\\u672c \\ud83d\\ude00\"}"
+	if string(transcoded) != expected {
+		t.Fatalf("unexpected transcoding %q", transcoded)
+	}
+}
+
+func TestHandler_ResponseCharset(t *testing.T) {
+	h := New(&Config{
+		Schema:              &testutil.StarWarsSchema,
+		ResponseCharset:     "us-ascii",
+		ResponseTranscodeFn: TranscodeASCII,
+	})
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	if contentType := resp.Header().Get("Content-Type"); contentType != "application/json; charset=us-ascii" {
+		t.Fatalf("unexpected Content-Type %q", contentType)
+	}
+}
@@ -0,0 +1,233 @@
+package handler
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+// CircuitBreaker configures the circuits opened per operation when their
+// executions fail too often, rejecting them at once until a probe succeeds.
+type CircuitBreaker struct {
+	// FailureRate is the rate of failed executions, with execution errors,
+	// internal errors or timing out, from which the circuit of an operation
+	// opens, 0.5 by default. Invalid requests don't count.
+	FailureRate float64
+	// MinExecutions is the number of executions within the Window needed to
+	// open the circuit, 20 by default.
+	MinExecutions int
+	// Window is the period the failure rate is measured over, a minute by
+	// default.
+	Window time.Duration
+	// OpenDuration is how long an open circuit rejects the operation before
+	// letting a probe execution through, 30 seconds by default.
+	OpenDuration time.Duration
+}
+
+// CircuitState is the state of the circuit of an operation.
+type CircuitState string
+
+const (
+	CircuitClosed   CircuitState = "closed"
+	CircuitOpen     CircuitState = "open"
+	CircuitHalfOpen CircuitState = "half-open"
+)
+
+// CircuitStateFn is called when the circuit of an operation changes state,
+// the operation name being empty for the global circuit.
+type CircuitStateFn func(operationName string, state CircuitState)
+
+const (
+	defaultCircuitFailureRate   = 0.5
+	defaultCircuitMinExecutions = 20
+	defaultCircuitWindow        = time.Minute
+	defaultCircuitOpenDuration  = 30 * time.Second
+)
+
+type circuitBreaker struct {
+	CircuitBreaker
+	stateFn  CircuitStateFn
+	mu       sync.Mutex
+	circuits map[string]*circuit
+}
+
+type circuit struct {
+	name        string
+	state       CircuitState
+	windowStart time.Time
+	executions  int
+	failures    int
+	openedAt    time.Time
+	probing     bool
+	probeStart  time.Time
+}
+
+func newCircuitBreaker(p *CircuitBreaker, stateFn CircuitStateFn) *circuitBreaker {
+	if p == nil {
+		return nil
+	}
+	b := &circuitBreaker{CircuitBreaker: *p, stateFn: stateFn, circuits: make(map[string]*circuit)}
+	if b.FailureRate <= 0 {
+		b.FailureRate = defaultCircuitFailureRate
+	}
+	if b.MinExecutions <= 0 {
+		b.MinExecutions = defaultCircuitMinExecutions
+	}
+	if b.Window <= 0 {
+		b.Window = defaultCircuitWindow
+	}
+	if b.OpenDuration <= 0 {
+		b.OpenDuration = defaultCircuitOpenDuration
+	}
+	return b
+}
+
+// allow reports whether the operation may be executed, and otherwise how long
+// its circuit stays open. A half-open circuit lets one probe execution
+// through at a time.
+func (b *circuitBreaker) allow(key, name string, now time.Time) (bool, time.Duration) {
+	b.mu.Lock()
+	c, ok := b.circuits[key]
+	if !ok {
+		c = &circuit{name: name, state: CircuitClosed, windowStart: now}
+		b.circuits[key] = c
+	}
+	switch c.state {
+	case CircuitOpen:
+		if remaining := c.openedAt.Add(b.OpenDuration).Sub(now); remaining > 0 {
+			b.mu.Unlock()
+			return false, remaining
+		}
+		c.state = CircuitHalfOpen
+		c.probing, c.probeStart = true, now
+		b.mu.Unlock()
+		b.notify(name, CircuitHalfOpen)
+		return true, 0
+	case CircuitHalfOpen:
+		// a probe not recorded within OpenDuration was not executed
+		if c.probing && now.Sub(c.probeStart) < b.OpenDuration {
+			b.mu.Unlock()
+			return false, time.Second
+		}
+		c.probing, c.probeStart = true, now
+	}
+	b.mu.Unlock()
+	return true, 0
+}
+
+// record counts an execution of the operation, opening or closing its
+// circuit.
+func (b *circuitBreaker) record(key string, failed bool, now time.Time) {
+	b.mu.Lock()
+	c, ok := b.circuits[key]
+	if !ok {
+		b.mu.Unlock()
+		return
+	}
+	state := c.state
+	switch c.state {
+	case CircuitHalfOpen:
+		c.probing = false
+		if failed {
+			c.state, c.openedAt = CircuitOpen, now
+		} else {
+			c.state = CircuitClosed
+			c.windowStart, c.executions, c.failures = now, 0, 0
+		}
+	case CircuitClosed:
+		if now.Sub(c.windowStart) > b.Window {
+			c.windowStart, c.executions, c.failures = now, 0, 0
+		}
+		c.executions++
+		if failed {
+			c.failures++
+		}
+		if c.executions >= b.MinExecutions && float64(c.failures)/float64(c.executions) >= b.FailureRate {
+			c.state, c.openedAt = CircuitOpen, now
+		}
+	}
+	changed, name := c.state != state, c.name
+	b.mu.Unlock()
+	if changed {
+		b.notify(name, c.state)
+	}
+}
+
+func (b *circuitBreaker) notify(name string, state CircuitState) {
+	if b.stateFn != nil {
+		b.stateFn(name, state)
+	}
+}
+
+// sweep forgets the closed circuits of the operations not executed for ten
+// windows.
+func (b *circuitBreaker) sweep(now time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for key, c := range b.circuits {
+		if c.state == CircuitClosed && now.Sub(c.windowStart) > 10*b.Window {
+			delete(b.circuits, key)
+		}
+	}
+}
+
+// globalCircuitKey is the key of the circuit of Config.GlobalCircuitBreaker.
+const globalCircuitKey = ""
+
+// checkCircuit rejects the operation while the global circuit or its own is
+// open, setting the Retry-After header of the response when given. The
+// returned function records the outcome of the allowed executions.
+func (h *Handler) checkCircuit(w http.ResponseWriter, op *operation) (func(failed bool), *requestError) {
+	now := time.Now()
+	var records []func(failed bool)
+	if h.globalCircuit != nil {
+		if allowed, retryAfter := h.globalCircuit.allow(globalCircuitKey, "", now); !allowed {
+			return nil, circuitOpenError(w, retryAfter, "The server is failing, retry later")
+		}
+		records = append(records, func(failed bool) {
+			h.globalCircuit.record(globalCircuitKey, failed, time.Now())
+		})
+	}
+	if h.circuitBreaker != nil && op != nil {
+		key := op.fingerprint()
+		if allowed, retryAfter := h.circuitBreaker.allow(key, op.Name(), now); !allowed {
+			return nil, circuitOpenError(w, retryAfter, "The operation is failing, retry later")
+		}
+		records = append(records, func(failed bool) {
+			h.circuitBreaker.record(key, failed, time.Now())
+		})
+	}
+	return func(failed bool) {
+		for _, record := range records {
+			record(failed)
+		}
+	}, nil
+}
+
+// circuitFailed reports whether a result counts as a failure for the
+// circuit breakers: it has execution errors, with a path, internal errors
+// or timed out. The errors of the request itself, like validation errors
+// or invalid variables, are the client's fault and would let a single
+// client open the circuits of everyone.
+func circuitFailed(result *graphql.Result) bool {
+	for _, err := range result.Errors {
+		if len(err.Path) > 0 || errorCode(err) == string(CodeInternalServerError) || err.OriginalError() == context.DeadlineExceeded {
+			return true
+		}
+	}
+	return false
+}
+
+func circuitOpenError(w http.ResponseWriter, retryAfter time.Duration, message string) *requestError {
+	if w != nil {
+		w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+	}
+	reqErr := newRequestError(http.StatusServiceUnavailable, CodeCircuitOpen, message)
+	reqErr.retryable = true
+	return reqErr
+}
@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestCircuitBreaker_States(t *testing.T) {
+	var states []CircuitState
+	b := newCircuitBreaker(&CircuitBreaker{MinExecutions: 4, OpenDuration: time.Minute}, func(name string, state CircuitState) {
+		states = append(states, state)
+	})
+	now := time.Now()
+	for i := 0; i < 4; i++ {
+		if ok, _ := b.allow("a", "A", now); !ok {
+			t.Fatalf("expected execution %d to be allowed", i)
+		}
+		b.record("a", i%2 == 0, now)
+	}
+	if ok, retryAfter := b.allow("a", "A", now.Add(time.Second)); ok || retryAfter != 59*time.Second {
+		t.Errorf("expected the circuit to be open, got %v %v", ok, retryAfter)
+	}
+
+	later := now.Add(time.Minute)
+	if ok, _ := b.allow("a", "A", later); !ok {
+		t.Fatalf("expected a probe to be allowed")
+	}
+	if ok, _ := b.allow("a", "A", later); ok {
+		t.Errorf("expected a single probe at a time")
+	}
+	b.record("a", true, later)
+	if ok, _ := b.allow("a", "A", later); ok {
+		t.Errorf("expected the failed probe to open the circuit again")
+	}
+
+	later = later.Add(time.Minute)
+	b.allow("a", "A", later)
+	b.record("a", false, later)
+	if ok, _ := b.allow("a", "A", later); !ok {
+		t.Errorf("expected the successful probe to close the circuit")
+	}
+
+	expected := []CircuitState{CircuitOpen, CircuitHalfOpen, CircuitOpen, CircuitHalfOpen, CircuitClosed}
+	if len(states) != len(expected) {
+		t.Fatalf("unexpected states %v", states)
+	}
+	for i := range expected {
+		if states[i] != expected[i] {
+			t.Errorf("unexpected states %v", states)
+		}
+	}
+}
+
+func TestHandler_CircuitBreaker(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"broken": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return nil, errors.New("downstream unavailable")
+					},
+				},
+				"name": &graphql.Field{Type: graphql.String},
+			},
+		}),
+	})
+	h := New(&Config{Schema: &schema, CircuitBreaker: &CircuitBreaker{MinExecutions: 2}})
+	query := func(q string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"`+q+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+
+	query("{ broken }")
+	query("{ broken }")
+	resp := query("{ broken }")
+	var result struct {
+		Errors []struct {
+			Extensions map[string]interface{} `json:"extensions"`
+		} `json:"errors"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &result)
+	if resp.Code != http.StatusServiceUnavailable || len(result.Errors) != 1 || result.Errors[0].Extensions["code"] != string(CodeCircuitOpen) || result.Errors[0].Extensions["retryable"] != true {
+		t.Errorf("unexpected response %d %s", resp.Code, resp.Body.String())
+	}
+	if resp.Header().Get("Retry-After") != "30" {
+		t.Errorf("unexpected Retry-After %q", resp.Header().Get("Retry-After"))
+	}
+	if resp := query("{ name }"); resp.Code != http.StatusOK {
+		t.Errorf("expected other operations to be executed, got %d", resp.Code)
+	}
+}
+
+func TestHandler_GlobalCircuitBreaker(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"broken": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return nil, errors.New("downstream unavailable")
+					},
+				},
+				"name": &graphql.Field{Type: graphql.String},
+			},
+		}),
+	})
+	var states []string
+	h := New(&Config{
+		Schema:               &schema,
+		GlobalCircuitBreaker: &CircuitBreaker{MinExecutions: 4, OpenDuration: 5 * time.Second},
+		CircuitStateFn: func(operationName string, state CircuitState) {
+			states = append(states, operationName+":"+string(state))
+		},
+	})
+	query := func(q string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/graphql?query="+q, nil)
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+
+	query("{name}")
+	query("{broken}")
+	query("{broken}")
+	query("{name}")
+	resp := query("{name}")
+	if resp.Code != http.StatusServiceUnavailable || !strings.Contains(resp.Body.String(), "The server is failing") || resp.Header().Get("Retry-After") != "5" {
+		t.Errorf("unexpected response %d %s", resp.Code, resp.Body.String())
+	}
+	if len(states) != 1 || states[0] != ":open" {
+		t.Errorf("unexpected states %v", states)
+	}
+}
+
+func TestHandler_GlobalCircuitBreakerIgnoresInvalidQueries(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   "Query",
+			Fields: graphql.Fields{"name": &graphql.Field{Type: graphql.String}},
+		}),
+	})
+	h := New(&Config{Schema: &schema, GlobalCircuitBreaker: &CircuitBreaker{MinExecutions: 4}})
+	for i := 0; i < 25; i++ {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/graphql?query={nope}", nil))
+	}
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, httptest.NewRequest("GET", "/graphql?query={name}", nil))
+	if resp.Code != http.StatusOK {
+		t.Errorf("expected invalid queries to leave the circuit closed, got %d %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestHandler_CircuitBreakerIgnoresInvalidVariables(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"double": &graphql.Field{
+					Type: graphql.Int,
+					Args: graphql.FieldConfigArgument{"n": &graphql.ArgumentConfig{Type: graphql.Int}},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						n, _ := p.Args["n"].(int)
+						return 2 * n, nil
+					},
+				},
+			},
+		}),
+	})
+	h := New(&Config{Schema: &schema, CircuitBreaker: &CircuitBreaker{MinExecutions: 4}})
+	query := func(variables string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"query Double($n: Int) { double(n: $n) }","variables":`+variables+`}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+	for i := 0; i < 25; i++ {
+		query(`{"n":"bad"}`)
+	}
+	if resp := query(`{"n":2}`); resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), `"double":4`) {
+		t.Errorf("expected invalid variables to leave the circuit closed, got %d %s", resp.Code, resp.Body.String())
+	}
+}
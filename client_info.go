@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// OtherClients labels the metrics of the clients seen after the first
+// Config.MaxClientLabels ones.
+const OtherClients = "other"
+
+const defaultMaxClientLabels = 100
+
+// ClientInfo identifies the client application of a request, from its
+// Config.ClientNameHeader and Config.ClientVersionHeader headers.
+type ClientInfo struct {
+	Name    string
+	Version string
+}
+
+type clientInfoKey struct{}
+
+// ClientInfoFromContext returns the client application of the request,
+// false when it sent no identification header. It is available to the hooks
+// receiving the context of the request, like the ResultCallbackFn.
+func ClientInfoFromContext(ctx context.Context) (ClientInfo, bool) {
+	info, ok := ctx.Value(clientInfoKey{}).(ClientInfo)
+	return info, ok
+}
+
+func (h *Handler) withClientInfo(ctx context.Context, r *http.Request) context.Context {
+	info := ClientInfo{
+		Name:    r.Header.Get(h.clientNameHeader),
+		Version: r.Header.Get(h.clientVersionHeader),
+	}
+	if info == (ClientInfo{}) {
+		return ctx
+	}
+	return context.WithValue(ctx, clientInfoKey{}, info)
+}
+
+// clientLabels bounds the clients labelling the metrics and the usage
+// reports to the first ones seen, as the headers are set by the clients.
+type clientLabels struct {
+	max  int
+	mu   sync.Mutex
+	seen map[ClientInfo]struct{}
+}
+
+func newClientLabels(max int) *clientLabels {
+	if max <= 0 {
+		max = defaultMaxClientLabels
+	}
+	return &clientLabels{max: max, seen: make(map[ClientInfo]struct{})}
+}
+
+// label returns the client, or the OtherClients one once the maximum of
+// distinct clients is reached.
+func (c *clientLabels) label(client ClientInfo) ClientInfo {
+	if client == (ClientInfo{}) {
+		return client
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.seen[client]; !ok {
+		if len(c.seen) >= c.max {
+			return ClientInfo{Name: OtherClients, Version: OtherClients}
+		}
+		c.seen[client] = struct{}{}
+	}
+	return client
+}
@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_ClientInfo(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   "Query",
+			Fields: graphql.Fields{"name": &graphql.Field{Type: graphql.String}},
+		}),
+	})
+	var callbackInfo ClientInfo
+	var logged map[string]interface{}
+	metrics := NewMetrics("")
+	h := New(&Config{
+		Schema:              &schema,
+		ClientNameHeader:    "X-Client-Name",
+		ClientVersionHeader: "X-Client-Version",
+		Metrics:             metrics,
+		ResultCallbackFn: func(ctx context.Context, params *graphql.Params, result *graphql.Result, responseBody []byte) {
+			callbackInfo, _ = ClientInfoFromContext(ctx)
+		},
+		Logger: LoggerFunc(func(ctx context.Context, level LogLevel, msg string, args ...interface{}) {
+			logged = make(map[string]interface{})
+			for i := 0; i+1 < len(args); i += 2 {
+				logged[args[i].(string)] = args[i+1]
+			}
+		}),
+	})
+
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ name }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Client-Name", "ios")
+	req.Header.Set("X-Client-Version", "1.2.3")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if callbackInfo != (ClientInfo{Name: "ios", Version: "1.2.3"}) {
+		t.Errorf("unexpected client in the callback %+v", callbackInfo)
+	}
+	if logged["client_name"] != "ios" || logged["client_version"] != "1.2.3" {
+		t.Errorf("unexpected client in the log %+v", logged)
+	}
+	resp := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(resp, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(resp.Body.String(), `graphql_client_requests_total{client_name="ios",client_version="1.2.3"} 1`) {
+		t.Errorf("expected the client requests in\n%s", resp.Body.String())
+	}
+
+	req, _ = http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ name }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ClientNameHeader, "ignored")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if callbackInfo != (ClientInfo{}) || logged["client_name"] != "" {
+		t.Errorf("unexpected client %+v %+v", callbackInfo, logged)
+	}
+}
+
+func TestHandler_MaxClientLabels(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   "Query",
+			Fields: graphql.Fields{"name": &graphql.Field{Type: graphql.String}},
+		}),
+	})
+	metrics := NewMetrics("")
+	h := New(&Config{Schema: &schema, Metrics: metrics, MaxClientLabels: 1})
+	for _, name := range []string{"ios", "random-1", "random-2", "ios"} {
+		req, _ := http.NewRequest("GET", "/graphql?query={name}", nil)
+		req.Header.Set(ClientNameHeader, name)
+		req.Header.Set(ClientVersionHeader, "1")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	resp := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(resp, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range []string{
+		`graphql_client_requests_total{client_name="ios",client_version="1"} 2`,
+		`graphql_client_requests_total{client_name="other",client_version="other"} 2`,
+	} {
+		if !strings.Contains(resp.Body.String(), line+"\n") {
+			t.Errorf("expected %s in\n%s", line, resp.Body.String())
+		}
+	}
+	if strings.Contains(resp.Body.String(), "random") {
+		t.Errorf("unexpected client label in\n%s", resp.Body.String())
+	}
+}
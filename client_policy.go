@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"path"
+	"strconv"
+)
+
+// The headers identifying the client applications, as sent by the Apollo
+// clients.
+const (
+	ClientNameHeader    = "apollographql-client-name"
+	ClientVersionHeader = "apollographql-client-version"
+)
+
+// ClientAction is what a ClientRule does with the requests of the clients it
+// matches.
+type ClientAction int
+
+const (
+	// ClientAllow executes the requests.
+	ClientAllow ClientAction = iota
+	// ClientBlock rejects the requests with a 403.
+	ClientBlock
+	// ClientThrottle rate limits the requests with the Limiter of the rule.
+	ClientThrottle
+)
+
+// ClientRule matches the clients by name and version, as path.Match
+// patterns, e.g. "ios" and "1.2.*". An empty pattern matches any value,
+// including a missing header.
+type ClientRule struct {
+	Name    string
+	Version string
+	Action  ClientAction
+	// Limiter rate limits the requests of the ClientThrottle rules, all the
+	// requests of the clients matched by the rule sharing the key
+	// "name/version".
+	Limiter RateLimiter
+	// Message replaces the default message of the errors.
+	Message string
+}
+
+// ClientPolicy blocks or throttles the client applications identified by
+// their name and version headers, e.g. to cut off an old mobile build. The
+// first rule matching a client applies.
+type ClientPolicy struct {
+	// NameHeader and VersionHeader default to ClientNameHeader and
+	// ClientVersionHeader.
+	NameHeader    string
+	VersionHeader string
+	Rules         []ClientRule
+	// DenyUnmatched blocks the clients no rule matches, making the
+	// ClientAllow rules an allowlist.
+	DenyUnmatched bool
+}
+
+func (p *ClientPolicy) client(r *http.Request) (string, string) {
+	nameHeader, versionHeader := p.NameHeader, p.VersionHeader
+	if nameHeader == "" {
+		nameHeader = ClientNameHeader
+	}
+	if versionHeader == "" {
+		versionHeader = ClientVersionHeader
+	}
+	return r.Header.Get(nameHeader), r.Header.Get(versionHeader)
+}
+
+func (p *ClientPolicy) match(name, version string) *ClientRule {
+	for i := range p.Rules {
+		rule := &p.Rules[i]
+		if matchClientPattern(rule.Name, name) && matchClientPattern(rule.Version, version) {
+			return rule
+		}
+	}
+	return nil
+}
+
+func matchClientPattern(pattern, value string) bool {
+	if pattern == "" {
+		return true
+	}
+	ok, _ := path.Match(pattern, value)
+	return ok
+}
+
+// checkClientPolicy rejects the requests of the clients blocked or
+// throttled by Config.ClientPolicy, setting the Retry-After header of the
+// response when given.
+func (h *Handler) checkClientPolicy(ctx context.Context, w http.ResponseWriter, r *http.Request) *requestError {
+	if h.clientPolicy == nil {
+		return nil
+	}
+	name, version := h.clientPolicy.client(r)
+	rule := h.clientPolicy.match(name, version)
+	if rule == nil {
+		if h.clientPolicy.DenyUnmatched {
+			return newRequestError(http.StatusForbidden, CodeClientBlocked, "The client is not allowed")
+		}
+		return nil
+	}
+
+	switch rule.Action {
+	case ClientBlock:
+		message := rule.Message
+		if message == "" {
+			message = "The client is not allowed"
+		}
+		return newRequestError(http.StatusForbidden, CodeClientBlocked, message)
+	case ClientThrottle:
+		if rule.Limiter == nil {
+			return nil
+		}
+		allowed, retryAfter := rule.Limiter.Allow(ctx, name+"/"+version)
+		if allowed {
+			return nil
+		}
+		seconds := int(math.Ceil(retryAfter.Seconds()))
+		if seconds < 1 {
+			seconds = 1
+		}
+		if w != nil {
+			w.Header().Set("Retry-After", strconv.Itoa(seconds))
+		}
+		message := rule.Message
+		if message == "" {
+			message = "Rate limit exceeded for the client, retry in " + strconv.Itoa(seconds) + "s"
+		}
+		return newRequestError(http.StatusTooManyRequests, CodeRateLimited, message)
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_ClientPolicy(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   "Query",
+			Fields: graphql.Fields{"name": &graphql.Field{Type: graphql.String}},
+		}),
+	})
+	h := New(&Config{
+		Schema: &schema,
+		ClientPolicy: &ClientPolicy{
+			Rules: []ClientRule{
+				{Name: "ios", Version: "1.*", Action: ClientBlock, Message: "Upgrade the app"},
+				{Name: "ios", Version: "2.0.?", Action: ClientThrottle, Limiter: NewTokenBucketLimiter(0.001, 1)},
+				{Name: "ios"},
+				{Name: "web"},
+			},
+			DenyUnmatched: true,
+		},
+	})
+	query := func(name, version string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ name }"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(ClientNameHeader, name)
+		req.Header.Set(ClientVersionHeader, version)
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := query("ios", "1.4.2"); resp.Code != http.StatusForbidden || !strings.Contains(resp.Body.String(), "Upgrade the app") || !strings.Contains(resp.Body.String(), string(CodeClientBlocked)) {
+		t.Errorf("expected the old build to be blocked, got %d %s", resp.Code, resp.Body.String())
+	}
+	if resp := query("ios", "2.0.1"); resp.Code != http.StatusOK {
+		t.Errorf("expected the throttled build to be executed, got %d %s", resp.Code, resp.Body.String())
+	}
+	if resp := query("ios", "2.0.1"); resp.Code != http.StatusTooManyRequests || resp.Header().Get("Retry-After") == "" {
+		t.Errorf("expected the throttled build to be rate limited, got %d %s", resp.Code, resp.Body.String())
+	}
+	if resp := query("ios", "3.0.0"); resp.Code != http.StatusOK {
+		t.Errorf("expected the new build to be allowed, got %d %s", resp.Code, resp.Body.String())
+	}
+	if resp := query("web", ""); resp.Code != http.StatusOK {
+		t.Errorf("expected the web client to be allowed, got %d %s", resp.Code, resp.Body.String())
+	}
+	if resp := query("", ""); resp.Code != http.StatusForbidden {
+		t.Errorf("expected the unknown client to be denied, got %d %s", resp.Code, resp.Body.String())
+	}
+}
@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"encoding/json"
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// defaultCostMultiplierArguments are the arguments usually bounding the size
+// of the lists returned by fields.
+var defaultCostMultiplierArguments = []string{"first", "last", "limit"}
+
+// costAnalysis computes the cost of operations before they are executed.
+type costAnalysis struct {
+	max         int
+	fieldCosts  map[string]int
+	multipliers []string
+}
+
+func newCostAnalysis(p *Config) *costAnalysis {
+	if p.MaxComplexity <= 0 {
+		return nil
+	}
+	multipliers := p.CostMultiplierArguments
+	if multipliers == nil {
+		multipliers = defaultCostMultiplierArguments
+	}
+	return &costAnalysis{max: p.MaxComplexity, fieldCosts: p.FieldCosts, multipliers: multipliers}
+}
+
+// cost returns the cost of the operation: every field costs 1 unless listed
+// in the field costs, and the cost of the selections of a field is
+// multiplied by the value of its multiplier argument, when set.
+func (c *costAnalysis) cost(schema *graphql.Schema, op *operation, variables map[string]interface{}) int {
+	root := op.rootType(schema)
+	if root == nil {
+		return 0
+	}
+	return c.selectionSetCost(schema, op, root, op.definition.SelectionSet, variables, map[string]bool{})
+}
+
+func (c *costAnalysis) selectionSetCost(schema *graphql.Schema, op *operation, parent graphql.Type, set *ast.SelectionSet, variables map[string]interface{}, spreads map[string]bool) int {
+	if set == nil || parent == nil {
+		return 0
+	}
+	total := 0
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			if selection.Name == nil {
+				continue
+			}
+			cost := 1
+			if fieldCost, ok := c.fieldCosts[parent.Name()+"."+selection.Name.Value]; ok {
+				cost = fieldCost
+			}
+			def := graphql.DefaultTypeInfoFieldDef(schema, parent, selection)
+			if def != nil && selection.SelectionSet != nil {
+				if named, ok := graphql.GetNamed(def.Type).(graphql.Type); ok {
+					cost += c.multiplier(selection, variables) * c.selectionSetCost(schema, op, named, selection.SelectionSet, variables, spreads)
+				}
+			}
+			total += cost
+		case *ast.InlineFragment:
+			total += c.selectionSetCost(schema, op, op.conditionType(schema, parent, selection.TypeCondition), selection.SelectionSet, variables, spreads)
+		case *ast.FragmentSpread:
+			if selection.Name == nil || spreads[selection.Name.Value] {
+				continue
+			}
+			fragment, ok := op.fragments[selection.Name.Value]
+			if !ok {
+				continue
+			}
+			spreads[selection.Name.Value] = true
+			total += c.selectionSetCost(schema, op, op.conditionType(schema, parent, fragment.TypeCondition), fragment.SelectionSet, variables, spreads)
+			delete(spreads, selection.Name.Value)
+		}
+	}
+	return total
+}
+
+// multiplier returns the value of the first multiplier argument of the
+// field, 1 when it has none.
+func (c *costAnalysis) multiplier(field *ast.Field, variables map[string]interface{}) int {
+	for _, name := range c.multipliers {
+		for _, arg := range field.Arguments {
+			if arg.Name == nil || arg.Name.Value != name {
+				continue
+			}
+			if n := intValue(arg.Value, variables); n > 1 {
+				return n
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// intValue resolves an integer argument literal or variable, 0 when it is
+// not an integer.
+func intValue(value ast.Value, variables map[string]interface{}) int {
+	switch value := value.(type) {
+	case *ast.IntValue:
+		n, _ := strconv.Atoi(value.Value)
+		return n
+	case *ast.Variable:
+		if value.Name == nil {
+			return 0
+		}
+		switch n := variables[value.Name.Value].(type) {
+		case float64:
+			return int(n)
+		case int:
+			return n
+		case json.Number:
+			i, _ := n.Int64()
+			return int(i)
+		}
+	}
+	return 0
+}
+
+// checkCost rejects the operations over Config.MaxComplexity, returning
+// their cost otherwise.
+func (h *Handler) checkCost(op *operation, variables map[string]interface{}, strict bool) (int, *requestError) {
+	if h.costAnalysis == nil || op == nil {
+		return 0, nil
+	}
+	cost := h.costAnalysis.cost(h.schema(), op, variables)
+	if cost <= h.costAnalysis.max {
+		return cost, nil
+	}
+	return cost, validationError(strict, CodeQueryTooComplex, "Operation cost "+strconv.Itoa(cost)+" exceeds the maximum cost "+strconv.Itoa(h.costAnalysis.max))
+}
+
+// estimateCost returns the cost of the operation, computed with the default
+// field costs when Config.MaxComplexity is not set.
+func (h *Handler) estimateCost(op *operation, variables map[string]interface{}) int {
+	c := h.costAnalysis
+	if c == nil {
+		c = &costAnalysis{multipliers: defaultCostMultiplierArguments}
+	}
+	return c.cost(h.schema(), op, variables)
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func complexitySchema(t *testing.T, resolved *int) graphql.Schema {
+	item := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Item",
+		Fields: graphql.Fields{
+			"id":    &graphql.Field{Type: graphql.String},
+			"price": &graphql.Field{Type: graphql.Int},
+		},
+	})
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"items": &graphql.Field{
+					Type: graphql.NewList(item),
+					Args: graphql.FieldConfigArgument{
+						"first": &graphql.ArgumentConfig{Type: graphql.Int},
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						*resolved++
+						return []interface{}{}, nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+func TestHandler_MaxComplexity(t *testing.T) {
+	resolved := 0
+	schema := complexitySchema(t, &resolved)
+	h := New(&Config{
+		Schema:        &schema,
+		MaxComplexity: 50,
+		FieldCosts:    map[string]int{"Item.price": 3},
+	})
+
+	tests := []struct {
+		query     string
+		variables string
+		cost      int
+		rejected  bool
+	}{
+		{query: "{ items { id } }", cost: 2},
+		{query: "{ items(first: 10) { id price } }", cost: 41},
+		{query: "{ items(first: 20) { ...f } } fragment f on Item { id }", cost: 21},
+		{query: "query($n: Int) { items(first: $n) { id price } }", variables: `{"n": 20}`, cost: 81, rejected: true},
+	}
+	for _, test := range tests {
+		resolved = 0
+		values := url.Values{"query": {test.query}}
+		if test.variables != "" {
+			values.Set("variables", test.variables)
+		}
+		req, _ := http.NewRequest("GET", "/graphql?"+values.Encode(), nil)
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+
+		var result struct {
+			Errors []struct {
+				Message string `json:"message"`
+			} `json:"errors"`
+			Extensions struct {
+				Cost map[string]int `json:"cost"`
+			} `json:"extensions"`
+		}
+		if err := json.Unmarshal(resp.Body.Bytes(), &result); err != nil {
+			t.Fatal(err)
+		}
+		if test.rejected {
+			if resolved != 0 {
+				t.Errorf("%s: expected resolvers not to run", test.query)
+			}
+			if len(result.Errors) != 1 || !strings.Contains(result.Errors[0].Message, "cost 81 exceeds the maximum cost 50") {
+				t.Errorf("%s: unexpected errors %+v", test.query, result.Errors)
+			}
+			continue
+		}
+		if len(result.Errors) != 0 {
+			t.Errorf("%s: unexpected errors %+v", test.query, result.Errors)
+		}
+		if result.Extensions.Cost["requested"] != test.cost || result.Extensions.Cost["maximum"] != 50 {
+			t.Errorf("%s: expected cost %d, got %v", test.query, test.cost, result.Extensions.Cost)
+		}
+	}
+}
+
+func TestHandler_MaxComplexityStatusCodes(t *testing.T) {
+	resolved := 0
+	schema := complexitySchema(t, &resolved)
+	h := New(&Config{
+		Schema:        &schema,
+		MaxComplexity: 5,
+		StatusCodes:   true,
+	})
+	req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape("{ items(first: 10) { id } }"), nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", resp.Code)
+	}
+}
+
+func TestWebSocket_MaxComplexity(t *testing.T) {
+	h := New(&Config{
+		Schema:        newSubscriptionSchema(t),
+		Subscriptions: true,
+		MaxComplexity: 5,
+		FieldCosts:    map[string]int{"Subscription.counter": 10},
+	})
+	conn := dialWebSocket(t, h, ProtocolGraphQLTransportWS)
+	conn.WriteJSON(wsMessage{Type: wsConnectionInit})
+	readMessage(t, conn)
+
+	conn.WriteJSON(wsMessage{ID: "1", Type: wsSubscribe, Payload: []byte(`{"query":"subscription { counter }"}`)})
+	if msg := readMessage(t, conn); msg.Type != wsError || !strings.Contains(string(msg.Payload), string(CodeQueryTooComplex)) {
+		t.Fatalf("expected the subscription over budget to be rejected, got %+v %s", msg, msg.Payload)
+	}
+}
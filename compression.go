@@ -0,0 +1,130 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+)
+
+// Compressor compresses what is written to the returned writer into w.
+type Compressor func(w io.Writer) io.WriteCloser
+
+// DictionaryCompressor compresses what is written to the returned writer into
+// w with a dictionary, e.g. a zstd encoder with the dictionary.
+type DictionaryCompressor func(w io.Writer, dictionary []byte) io.WriteCloser
+
+// CompressionDictionary is a dictionary pre-shared with the clients, e.g.
+// trained on a corpus of responses.
+type CompressionDictionary struct {
+	Dictionary []byte
+	Compress   DictionaryCompressor
+}
+
+// CompressionConfig compresses the JSON responses of the clients accepting
+// it with gzip or one of the Encoders.
+type CompressionConfig struct {
+	// MinSize is the size from which responses are compressed, 1 KB by
+	// default.
+	MinSize int
+	// Level is the gzip compression level, gzip.DefaultCompression by
+	// default.
+	Level int
+	// Encoders adds content codings preferred over gzip, e.g. "br" with a
+	// brotli encoder.
+	Encoders map[string]Compressor
+	// Dictionaries adds custom content codings compressing with a
+	// pre-shared dictionary, preferred over the Encoders and gzip. Clients
+	// having the dictionary accept its coding, whose name should change
+	// along with the dictionary, e.g. "zstd-d1" then "zstd-d2".
+	Dictionaries map[string]CompressionDictionary
+}
+
+const defaultCompressionMinSize = 1024
+
+// writeBody writes the response body, compressed when configured and
+// accepted by the client.
+func (h *Handler) writeBody(w http.ResponseWriter, r *http.Request, status int, body []byte) {
+	if c := h.compression; c != nil {
+		minSize := c.MinSize
+		if minSize <= 0 {
+			minSize = defaultCompressionMinSize
+		}
+		w.Header().Add("Vary", "Accept-Encoding")
+		if len(body) >= minSize {
+			if coding, compress := c.negotiate(r.Header.Get("Accept-Encoding")); compress != nil {
+				var buf bytes.Buffer
+				cw := compress(&buf)
+				_, err := cw.Write(body)
+				if err == nil {
+					err = cw.Close()
+				}
+				if err == nil {
+					w.Header().Set("Content-Encoding", coding)
+					body = buf.Bytes()
+				}
+			}
+		}
+	}
+	w.Header().Set("Content-Length", strconv.Itoa(len(body)))
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		w.Write(body)
+	}
+}
+
+// negotiate chooses the content coding of a response among the accepted
+// ones, returning no compressor when none is.
+func (c *CompressionConfig) negotiate(acceptEncoding string) (string, Compressor) {
+	accepted := acceptedEncodings(acceptEncoding)
+
+	dictionaryCodings := make([]string, 0, len(c.Dictionaries))
+	for coding := range c.Dictionaries {
+		dictionaryCodings = append(dictionaryCodings, coding)
+	}
+	sort.Strings(dictionaryCodings)
+	for _, coding := range dictionaryCodings {
+		if dictionary := c.Dictionaries[coding]; accepted[coding] && dictionary.Compress != nil {
+			return coding, func(w io.Writer) io.WriteCloser {
+				return dictionary.Compress(w, dictionary.Dictionary)
+			}
+		}
+	}
+
+	codings := make([]string, 0, len(c.Encoders))
+	for coding := range c.Encoders {
+		codings = append(codings, coding)
+	}
+	sort.Strings(codings)
+	for _, coding := range codings {
+		if accepted[coding] {
+			return coding, c.Encoders[coding]
+		}
+	}
+	if accepted["gzip"] {
+		level := c.Level
+		if level == 0 {
+			level = gzip.DefaultCompression
+		}
+		return "gzip", func(w io.Writer) io.WriteCloser {
+			gw, err := gzip.NewWriterLevel(w, level)
+			if err != nil {
+				gw = gzip.NewWriter(w)
+			}
+			return gw
+		}
+	}
+	return "", nil
+}
+
+// acceptedEncodings parses an Accept-Encoding header, leaving out the
+// codings with a zero quality.
+func acceptedEncodings(header string) map[string]bool {
+	accepted := make(map[string]bool)
+	for _, coding := range acceptList(header) {
+		accepted[coding] = true
+	}
+	return accepted
+}
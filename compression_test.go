@@ -0,0 +1,108 @@
+package handler
+
+import (
+	"compress/flate"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+type nopWriteCloser struct {
+	io.Writer
+}
+
+func (nopWriteCloser) Close() error { return nil }
+
+func compressionRequest(h *Handler, acceptEncoding string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+	req.Header.Set("Accept-Encoding", acceptEncoding)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	return resp
+}
+
+func TestHandler_Compression(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema, Compression: &CompressionConfig{MinSize: 1}})
+
+	resp := compressionRequest(h, "gzip, deflate")
+	if resp.Header().Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected a gzip response, got %v", resp.Header())
+	}
+	reader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := ioutil.ReadAll(reader)
+	if !strings.Contains(string(body), "R2-D2") {
+		t.Fatalf("unexpected body %s", body)
+	}
+
+	resp = compressionRequest(h, "gzip;q=0")
+	if resp.Header().Get("Content-Encoding") != "" || !strings.Contains(resp.Body.String(), "R2-D2") {
+		t.Fatalf("expected an uncompressed response, got %v", resp.Header())
+	}
+}
+
+func TestHandler_Compression_MinSizeAndEncoders(t *testing.T) {
+	h := New(&Config{
+		Schema: &testutil.StarWarsSchema,
+		Compression: &CompressionConfig{
+			MinSize: 1,
+			Encoders: map[string]Compressor{
+				"br": func(w io.Writer) io.WriteCloser { return nopWriteCloser{w} },
+			},
+		},
+	})
+	if resp := compressionRequest(h, "gzip, br"); resp.Header().Get("Content-Encoding") != "br" {
+		t.Fatalf("expected the configured encoder to be preferred, got %v", resp.Header())
+	}
+
+	h = New(&Config{Schema: &testutil.StarWarsSchema, Compression: &CompressionConfig{}})
+	if resp := compressionRequest(h, "gzip"); resp.Header().Get("Content-Encoding") != "" {
+		t.Fatalf("expected a small response to be left uncompressed, got %v", resp.Header())
+	}
+}
+
+func TestHandler_Compression_Dictionaries(t *testing.T) {
+	dictionary := []byte(`{"data":{"hero":{"name":"R2-D2"}}}`)
+	h := New(&Config{
+		Schema: &testutil.StarWarsSchema,
+		Compression: &CompressionConfig{
+			MinSize: 1,
+			Encoders: map[string]Compressor{
+				"br": func(w io.Writer) io.WriteCloser { return nopWriteCloser{w} },
+			},
+			Dictionaries: map[string]CompressionDictionary{
+				"deflate-d1": {
+					Dictionary: dictionary,
+					Compress: func(w io.Writer, dictionary []byte) io.WriteCloser {
+						fw, _ := flate.NewWriterDict(w, flate.BestCompression, dictionary)
+						return fw
+					},
+				},
+			},
+		},
+	})
+
+	resp := compressionRequest(h, "gzip, br, deflate-d1")
+	if resp.Header().Get("Content-Encoding") != "deflate-d1" {
+		t.Fatalf("expected the dictionary coding to be preferred, got %v", resp.Header())
+	}
+	body, err := ioutil.ReadAll(flate.NewReaderDict(resp.Body, dictionary))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(body) != string(dictionary) {
+		t.Errorf("unexpected body %s", body)
+	}
+
+	if resp := compressionRequest(h, "gzip, br"); resp.Header().Get("Content-Encoding") != "br" {
+		t.Fatalf("expected clients without the dictionary to get br, got %v", resp.Header())
+	}
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+)
+
+// ClientIDFn identifies the client of a request, the client IP being used
+// when it returns an empty string.
+type ClientIDFn func(ctx context.Context, r *http.Request) string
+
+// fairLimiter bounds the number of requests executed concurrently. Requests
+// over the bound are queued per client, and the clients served in turn so
+// that one client cannot monopolize the queue.
+type fairLimiter struct {
+	max       int
+	maxQueued int
+
+	mu      sync.Mutex
+	running int
+	queued  int
+	queues  map[string][]chan struct{}
+	// order lists the clients having queued requests, next to be served
+	// first
+	order []string
+}
+
+func newFairLimiter(max, maxQueued int) *fairLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &fairLimiter{max: max, maxQueued: maxQueued, queues: make(map[string][]chan struct{})}
+}
+
+// acquire reserves an execution slot for the client, waiting in its queue
+// until the context is done. It returns false when no slot was reserved.
+func (l *fairLimiter) acquire(ctx context.Context, client string) bool {
+	l.mu.Lock()
+	if l.running < l.max && l.queued == 0 {
+		l.running++
+		l.mu.Unlock()
+		return true
+	}
+	if l.maxQueued > 0 && l.queued >= l.maxQueued {
+		l.mu.Unlock()
+		return false
+	}
+	ready := make(chan struct{})
+	if len(l.queues[client]) == 0 {
+		l.order = append(l.order, client)
+	}
+	l.queues[client] = append(l.queues[client], ready)
+	l.queued++
+	l.mu.Unlock()
+
+	select {
+	case <-ready:
+		return true
+	case <-ctx.Done():
+	}
+
+	l.mu.Lock()
+	if l.dequeue(client, ready) {
+		l.mu.Unlock()
+		return false
+	}
+	l.mu.Unlock()
+	// the slot was handed over meanwhile
+	l.release()
+	return false
+}
+
+// dequeue removes a waiting request, returning false when it is not queued
+// anymore.
+func (l *fairLimiter) dequeue(client string, ready chan struct{}) bool {
+	queue := l.queues[client]
+	for i, waiting := range queue {
+		if waiting != ready {
+			continue
+		}
+		l.queued--
+		if len(queue) == 1 {
+			delete(l.queues, client)
+			l.removeFromOrder(client)
+		} else {
+			l.queues[client] = append(queue[:i:i], queue[i+1:]...)
+		}
+		return true
+	}
+	return false
+}
+
+func (l *fairLimiter) removeFromOrder(client string) {
+	for i, c := range l.order {
+		if c == client {
+			l.order = append(l.order[:i:i], l.order[i+1:]...)
+			return
+		}
+	}
+}
+
+// release hands the slot over to the next client in turn, or frees it.
+func (l *fairLimiter) release() {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.queued == 0 {
+		l.running--
+		return
+	}
+	client := l.order[0]
+	l.order = l.order[1:]
+	queue := l.queues[client]
+	if len(queue) > 1 {
+		l.queues[client] = queue[1:]
+		l.order = append(l.order, client)
+	} else {
+		delete(l.queues, client)
+	}
+	l.queued--
+	close(queue[0])
+}
+
+// clientID identifies the client of the request for the limiter.
+func (h *Handler) clientID(ctx context.Context, r *http.Request) string {
+	if h.clientIDFn != nil {
+		if id := h.clientIDFn(ctx, r); id != "" {
+			return id
+		}
+	}
+	return clientIP(r)
+}
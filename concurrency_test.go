@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func waitQueued(t *testing.T, l *fairLimiter, n int) {
+	for start := time.Now(); time.Since(start) < time.Second; time.Sleep(time.Millisecond) {
+		l.mu.Lock()
+		queued := l.queued
+		l.mu.Unlock()
+		if queued == n {
+			return
+		}
+	}
+	t.Fatalf("expected %d queued requests", n)
+}
+
+func TestFairLimiter_ServesClientsInTurn(t *testing.T) {
+	l := newFairLimiter(1, 0)
+	l.acquire(context.Background(), "holder")
+
+	served := make(chan string)
+	for i, client := range []string{"a", "a", "a", "b"} {
+		go func(client string) {
+			l.acquire(context.Background(), client)
+			served <- client
+		}(client)
+		waitQueued(t, l, i+1)
+	}
+
+	var order []string
+	for i := 0; i < 4; i++ {
+		l.release()
+		order = append(order, <-served)
+	}
+	if expected := "a b a a"; strings.Join(order, " ") != expected {
+		t.Fatalf("expected the clients served in turn %q, got %q", expected, strings.Join(order, " "))
+	}
+}
+
+func TestFairLimiter_Cancel(t *testing.T) {
+	l := newFairLimiter(1, 1)
+	l.acquire(context.Background(), "holder")
+
+	if !func() bool {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		return !l.acquire(ctx, "a")
+	}() {
+		t.Fatal("expected the waiting request to give up")
+	}
+	if l.queued != 0 || len(l.order) != 0 {
+		t.Fatalf("expected an empty queue, got %d %v", l.queued, l.order)
+	}
+	l.release()
+	if l.running != 0 {
+		t.Fatalf("expected the slot freed, got %d running", l.running)
+	}
+}
+
+func TestHandler_MaxQueuedRequests(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema, MaxConcurrentRequests: 1, MaxQueuedRequests: 1})
+	h.requests.acquire(context.Background(), "holder")
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go h.requests.acquire(ctx, "waiting")
+	waitQueued(t, h.requests, 1)
+
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != http.StatusServiceUnavailable {
+		t.Fatalf("expected 503, got %d", resp.Code)
+	}
+}
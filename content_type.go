@@ -0,0 +1,72 @@
+package handler
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// MediaType is the parsed Content-Type of a request, its type and parameter
+// names lowercased.
+type MediaType struct {
+	Type   string
+	Params map[string]string
+}
+
+type mediaTypeKey struct{}
+
+// MediaTypeFromContext returns the parsed Content-Type of the request, false
+// when it has none.
+func MediaTypeFromContext(ctx context.Context) (MediaType, bool) {
+	mediaType, ok := ctx.Value(mediaTypeKey{}).(MediaType)
+	return mediaType, ok
+}
+
+func withMediaType(ctx context.Context, r *http.Request) context.Context {
+	mediaType := requestMediaType(r)
+	if mediaType.Type == "" {
+		return ctx
+	}
+	return context.WithValue(ctx, mediaTypeKey{}, mediaType)
+}
+
+// requestMediaType parses the Content-Type of the request. Of several
+// Content-Type headers, as some proxies append, the first one with a type
+// is used.
+func requestMediaType(r *http.Request) MediaType {
+	for _, header := range r.Header["Content-Type"] {
+		if mediaType := parseMediaType(header); mediaType.Type != "" {
+			return mediaType
+		}
+	}
+	return MediaType{}
+}
+
+// parseMediaType parses a Content-Type like mime.ParseMediaType, falling
+// back to a lenient parsing keeping the first of duplicate parameters and
+// skipping the malformed ones.
+func parseMediaType(header string) MediaType {
+	mediaType, params, err := mime.ParseMediaType(header)
+	if err == nil {
+		return MediaType{Type: mediaType, Params: params}
+	}
+
+	tokens := strings.Split(header, ";")
+	parsed := MediaType{
+		Type:   strings.ToLower(strings.TrimSpace(tokens[0])),
+		Params: make(map[string]string),
+	}
+	for _, token := range tokens[1:] {
+		i := strings.Index(token, "=")
+		if i < 0 {
+			continue
+		}
+		name := strings.ToLower(strings.TrimSpace(token[:i]))
+		if _, ok := parsed.Params[name]; ok || name == "" {
+			continue
+		}
+		parsed.Params[name] = strings.Trim(strings.TrimSpace(token[i+1:]), `"`)
+	}
+	return parsed
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseMediaType(t *testing.T) {
+	tests := []struct {
+		header   string
+		expected MediaType
+	}{
+		{"application/json", MediaType{Type: "application/json", Params: map[string]string{}}},
+		{"Application/JSON; Charset=UTF-8", MediaType{Type: "application/json", Params: map[string]string{"charset": "UTF-8"}}},
+		{`multipart/form-data; boundary="a b"`, MediaType{Type: "multipart/form-data", Params: map[string]string{"boundary": "a b"}}},
+		{"application/json; charset=utf-8; charset=latin1", MediaType{Type: "application/json", Params: map[string]string{"charset": "utf-8"}}},
+		{"application/json;; charset=utf-8;", MediaType{Type: "application/json", Params: map[string]string{"charset": "utf-8"}}},
+		{"application/json; odd", MediaType{Type: "application/json", Params: map[string]string{}}},
+	}
+	for _, test := range tests {
+		if mediaType := parseMediaType(test.header); !reflect.DeepEqual(mediaType, test.expected) {
+			t.Errorf("%s: expected %+v, got %+v", test.header, test.expected, mediaType)
+		}
+	}
+}
+
+func TestHandler_ContentTypeHeaders(t *testing.T) {
+	schema := limitsSchema(t, new(int))
+	var mediaType MediaType
+	h := New(&Config{
+		Schema: &schema,
+		RootObjectFn: func(ctx context.Context, r *http.Request) map[string]interface{} {
+			mediaType, _ = MediaTypeFromContext(ctx)
+			return nil
+		},
+		StrictContentType: true,
+	})
+
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader("{ name }"))
+	req.Header.Add("Content-Type", "")
+	req.Header.Add("Content-Type", "Application/GraphQL; charset=utf-8; charset=utf-8")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	if expected := `{"data":{"name":"name"}}`; resp.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, resp.Body.String())
+	}
+	if mediaType.Type != ContentTypeGraphQL || mediaType.Params["charset"] != "utf-8" {
+		t.Errorf("unexpected media type %+v", mediaType)
+	}
+}
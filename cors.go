@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig lets browsers call the handler from other origins.
+type CORSConfig struct {
+	// AllowedOrigins lists the origins allowed, "*" allowing any, which
+	// cannot be combined with AllowCredentials.
+	AllowedOrigins []string
+	// AllowedMethods defaults to GET, POST and OPTIONS.
+	AllowedMethods []string
+	// AllowedHeaders defaults to Content-Type.
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	// MaxAge is how long browsers may cache the preflight responses.
+	MaxAge time.Duration
+}
+
+func (c *CORSConfig) allowedOrigin(origin string) bool {
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" || strings.EqualFold(allowed, origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// cors writes the CORS headers of the request, and answers the preflight
+// requests, returning whether it did.
+func (h *Handler) cors(w http.ResponseWriter, r *http.Request) bool {
+	c := h.corsConfig
+	if c == nil {
+		return false
+	}
+	origin := r.Header.Get("Origin")
+	w.Header().Add("Vary", "Origin")
+	if origin == "" || !c.allowedOrigin(origin) {
+		return false
+	}
+
+	w.Header().Set("Access-Control-Allow-Origin", origin)
+	if c.AllowCredentials {
+		w.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+
+	if r.Method != http.MethodOptions || r.Header.Get("Access-Control-Request-Method") == "" {
+		if len(c.ExposedHeaders) > 0 {
+			w.Header().Set("Access-Control-Expose-Headers", strings.Join(c.ExposedHeaders, ", "))
+		}
+		return false
+	}
+
+	methods := c.AllowedMethods
+	if len(methods) == 0 {
+		methods = []string{http.MethodGet, http.MethodPost, http.MethodOptions}
+	}
+	headers := c.AllowedHeaders
+	if len(headers) == 0 {
+		headers = []string{"Content-Type"}
+	}
+	w.Header().Add("Vary", "Access-Control-Request-Method")
+	w.Header().Add("Vary", "Access-Control-Request-Headers")
+	w.Header().Set("Access-Control-Allow-Methods", strings.Join(methods, ", "))
+	w.Header().Set("Access-Control-Allow-Headers", strings.Join(headers, ", "))
+	if c.MaxAge > 0 {
+		w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge/time.Second)))
+	}
+	w.WriteHeader(http.StatusNoContent)
+	return true
+}
+
+// options answers the OPTIONS requests which are not CORS preflight ones.
+func (h *Handler) options(w http.ResponseWriter) {
+	w.Header().Set("Allow", allowedMethods)
+	for name, values := range h.optionsHeaders {
+		w.Header()[http.CanonicalHeaderKey(name)] = append([]string(nil), values...)
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
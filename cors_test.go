@@ -0,0 +1,81 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_CORS(t *testing.T) {
+	h := New(&Config{
+		Schema: &testutil.StarWarsSchema,
+		CORS: &CORSConfig{
+			AllowedOrigins:   []string{"https://app.example.com"},
+			AllowedHeaders:   []string{"Content-Type", "Authorization"},
+			AllowCredentials: true,
+			MaxAge:           time.Hour,
+		},
+	})
+
+	req, _ := http.NewRequest("OPTIONS", "/graphql", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "POST")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	expected := map[string]string{
+		"Access-Control-Allow-Origin":      "https://app.example.com",
+		"Access-Control-Allow-Credentials": "true",
+		"Access-Control-Allow-Methods":     "GET, POST, OPTIONS",
+		"Access-Control-Allow-Headers":     "Content-Type, Authorization",
+		"Access-Control-Max-Age":           "3600",
+	}
+	if resp.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", resp.Code)
+	}
+	for name, value := range expected {
+		if resp.Header().Get(name) != value {
+			t.Fatalf("expected %s %q, got %q", name, value, resp.Header().Get(name))
+		}
+	}
+
+	req, _ = http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+	req.Header.Set("Origin", "https://evil.example.com")
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Fatal("expected other origins to be denied")
+	}
+}
+
+func TestHandler_Options(t *testing.T) {
+	h := New(&Config{
+		Schema:         &testutil.StarWarsSchema,
+		OptionsHeaders: http.Header{"Cache-Control": []string{"max-age=600"}},
+	})
+
+	// the body is neither read nor checked against persisted queries
+	req, _ := http.NewRequest("OPTIONS", `/graphql?extensions={"persistedQuery":{"version":1,"sha256Hash":"unknown"}}`, nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != http.StatusNoContent || resp.Body.Len() != 0 {
+		t.Fatalf("expected 204, got %d %s", resp.Code, resp.Body.String())
+	}
+	if resp.Header().Get("Cache-Control") != "max-age=600" || resp.Header().Get("Allow") == "" {
+		t.Fatalf("unexpected headers %v", resp.Header())
+	}
+}
+
+func TestNew_CORSAnyOriginWithCredentials(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected New to reject credentials allowed for any origin")
+		}
+	}()
+	New(&Config{
+		Schema: &testutil.StarWarsSchema,
+		CORS:   &CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true},
+	})
+}
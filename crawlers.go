@@ -0,0 +1,36 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+)
+
+// defaultCrawlerUserAgents are substrings of the user agents of common
+// crawlers.
+var defaultCrawlerUserAgents = []string{
+	"googlebot", "bingbot", "slurp", "duckduckbot", "baiduspider", "yandexbot",
+	"applebot", "facebookexternalhit", "twitterbot", "ahrefsbot", "semrushbot",
+	"petalbot", "gptbot", "ccbot",
+}
+
+// blockCrawlers asks search engines not to index the endpoint and rejects
+// the crawlers, returning whether it did.
+func (h *Handler) blockCrawlers(w http.ResponseWriter, r *http.Request) bool {
+	if !h.crawlerBlocking {
+		return false
+	}
+	w.Header().Set("X-Robots-Tag", "noindex, nofollow")
+
+	agents := h.crawlerUserAgents
+	if agents == nil {
+		agents = defaultCrawlerUserAgents
+	}
+	userAgent := strings.ToLower(r.Header.Get("User-Agent"))
+	for _, agent := range agents {
+		if agent != "" && strings.Contains(userAgent, strings.ToLower(agent)) {
+			http.Error(w, http.StatusText(http.StatusForbidden), http.StatusForbidden)
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_BlockCrawlers(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema, GraphiQL: true, BlockCrawlers: true})
+
+	req, _ := http.NewRequest("GET", "/graphql", nil)
+	req.Header.Set("Accept", "text/html")
+	req.Header.Set("User-Agent", "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != http.StatusForbidden {
+		t.Fatalf("expected crawlers to be rejected, got %d", resp.Code)
+	}
+
+	req.Header.Set("User-Agent", "Mozilla/5.0 (X11; Linux x86_64; rv:120.0) Gecko/20100101 Firefox/120.0")
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK || resp.Header().Get("X-Robots-Tag") != "noindex, nofollow" {
+		t.Fatalf("expected the IDE with X-Robots-Tag, got %d %v", resp.Code, resp.Header())
+	}
+}
@@ -0,0 +1,38 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+)
+
+// DefaultCSRFRequiredHeaders are the headers marking requests as sent by a
+// client able to trigger a CORS preflight, those sent by Apollo clients.
+var DefaultCSRFRequiredHeaders = []string{"X-Apollo-Operation-Name", "Apollo-Require-Preflight"}
+
+// csrfSimpleContentTypes are the content types browsers send cross-site
+// without a CORS preflight.
+var csrfSimpleContentTypes = map[string]bool{
+	"application/x-www-form-urlencoded": true,
+	"multipart/form-data":               true,
+	"text/plain":                        true,
+}
+
+// checkCSRF rejects the requests a browser could send cross-site without a
+// CORS preflight: those without a Content-Type or with one of a form, that
+// have none of the Config.CSRFRequiredHeaders.
+func (h *Handler) checkCSRF(r *http.Request) *requestError {
+	if !h.csrfPrevention {
+		return nil
+	}
+	if contentType := requestContentType(r); contentType != "" && !csrfSimpleContentTypes[contentType] {
+		return nil
+	}
+	for _, header := range h.csrfRequiredHeaders {
+		if r.Header.Get(header) != "" {
+			return nil
+		}
+	}
+	return newRequestError(http.StatusBadRequest, CodeCSRFPrevented, "This operation has been blocked as a potential Cross-Site Request Forgery (CSRF). "+
+		"Please either specify a Content-Type header that is not one of application/x-www-form-urlencoded, multipart/form-data or text/plain, "+
+		"or provide a non-empty value for one of the following headers: "+strings.Join(h.csrfRequiredHeaders, ", "))
+}
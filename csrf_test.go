@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_CSRFPrevention(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema, CSRFPrevention: true})
+	tests := []struct {
+		name        string
+		method      string
+		contentType string
+		header      string
+		body        string
+		status      int
+	}{
+		{"GET without header", "GET", "", "", "", http.StatusBadRequest},
+		{"GET with preflight header", "GET", "", "Apollo-Require-Preflight", "", http.StatusOK},
+		{"form POST", "POST", "application/x-www-form-urlencoded", "", "query={hero{name}}", http.StatusBadRequest},
+		{"text POST", "POST", "text/plain", "", `{"query":"{hero{name}}"}`, http.StatusBadRequest},
+		{"form POST with operation name header", "POST", "application/x-www-form-urlencoded", "X-Apollo-Operation-Name", "query={hero{name}}", http.StatusOK},
+		{"JSON POST", "POST", "application/json", "", `{"query":"{hero{name}}"}`, http.StatusOK},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest(test.method, "/graphql", strings.NewReader(test.body))
+			if test.method == "GET" {
+				req.URL.RawQuery = "query={hero{name}}"
+			}
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+			if test.header != "" {
+				req.Header.Set(test.header, "1")
+			}
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+			if resp.Code != test.status {
+				t.Errorf("expected %d, got %d %s", test.status, resp.Code, resp.Body.String())
+			}
+			if test.status == http.StatusBadRequest && !strings.Contains(resp.Body.String(), string(CodeCSRFPrevented)) {
+				t.Errorf("unexpected body %s", resp.Body.String())
+			}
+		})
+	}
+}
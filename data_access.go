@@ -0,0 +1,98 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// DataAccessRecord describes an executed operation that selected personal
+// data fields.
+type DataAccessRecord struct {
+	Time          time.Time
+	Actor         string
+	OperationName string
+	SubjectIDs    []string
+	Fields        []string
+}
+
+// DataAccessSink receives a DataAccessRecord for every operation touching
+// fields listed in Config.PersonalDataFields.
+type DataAccessSink interface {
+	RecordDataAccess(ctx context.Context, record DataAccessRecord)
+}
+
+// DataAccessSinkFunc adapts a function to the DataAccessSink interface.
+type DataAccessSinkFunc func(ctx context.Context, record DataAccessRecord)
+
+func (f DataAccessSinkFunc) RecordDataAccess(ctx context.Context, record DataAccessRecord) {
+	f(ctx, record)
+}
+
+// DataAccessActorFn identifies who performs a request, e.g. from its session.
+type DataAccessActorFn func(ctx context.Context, r *http.Request) string
+
+// personalFieldsAccessed returns the "Type.field" coordinates of the
+// personal data fields the operation selects, sorted and deduplicated. A
+// field selected on an interface or union may resolve on any of its possible
+// types, so their coordinates are matched too.
+func personalFieldsAccessed(schema *graphql.Schema, op *operation, personal map[string]bool) []string {
+	seen := make(map[string]bool)
+	op.walkFields(schema, func(parent graphql.Type, field *ast.Field, def *graphql.FieldDefinition, depth int) {
+		if parent == nil || field.Name == nil {
+			return
+		}
+		types := []graphql.Type{parent}
+		if abstract, ok := parent.(graphql.Abstract); ok {
+			for _, object := range schema.PossibleTypes(abstract) {
+				types = append(types, object)
+			}
+		}
+		for _, t := range types {
+			coordinate := t.Name() + "." + field.Name.Value
+			if personal[coordinate] {
+				seen[coordinate] = true
+			}
+		}
+	})
+
+	fields := make([]string, 0, len(seen))
+	for coordinate := range seen {
+		fields = append(fields, coordinate)
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// recordDataAccess emits a DataAccessRecord when the operation selected any
+// personal data field.
+func (h *Handler) recordDataAccess(ctx context.Context, r *http.Request, op *operation, opts *RequestOptions) {
+	if h.dataAccessSink == nil || op == nil || len(h.personalDataFields) == 0 {
+		return
+	}
+
+	fields := personalFieldsAccessed(h.schema(), op, h.personalDataFields)
+	if len(fields) == 0 {
+		return
+	}
+
+	record := DataAccessRecord{
+		Time:          time.Now(),
+		OperationName: op.Name(),
+		Fields:        fields,
+	}
+	if h.dataAccessActorFn != nil {
+		record.Actor = h.dataAccessActorFn(ctx, r)
+	}
+	for _, name := range h.dataAccessSubjectVariables {
+		if value, ok := opts.Variables[name]; ok && value != nil {
+			record.SubjectIDs = append(record.SubjectIDs, fmt.Sprint(value))
+		}
+	}
+	h.dataAccessSink.RecordDataAccess(ctx, record)
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_DataAccessSink_RecordsPersonalFields(t *testing.T) {
+	var records []DataAccessRecord
+	h := New(&Config{
+		Schema:                     &testutil.StarWarsSchema,
+		PersonalDataFields:         []string{"Human.homePlanet", "Droid.primaryFunction"},
+		DataAccessSubjectVariables: []string{"id"},
+		DataAccessActorFn: func(ctx context.Context, r *http.Request) string {
+			return r.Header.Get("X-User")
+		},
+		DataAccessSink: DataAccessSinkFunc(func(ctx context.Context, record DataAccessRecord) {
+			records = append(records, record)
+		}),
+	})
+
+	query := url.QueryEscape(`query HumanQuery($id: String!) { human(id: $id) { name ...Planet } } fragment Planet on Human { homePlanet }`)
+	variables := url.QueryEscape(`{"id": "1000"}`)
+	req, _ := http.NewRequest("GET", "/graphql?query="+query+"&variables="+variables, nil)
+	req.Header.Set("X-User", "admin")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(records) != 1 {
+		t.Fatalf("expected one record, got %v", len(records))
+	}
+	record := records[0]
+	if record.Actor != "admin" || record.OperationName != "HumanQuery" {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+	if !reflect.DeepEqual(record.Fields, []string{"Human.homePlanet"}) {
+		t.Fatalf("unexpected fields: %v", record.Fields)
+	}
+	if !reflect.DeepEqual(record.SubjectIDs, []string{"1000"}) {
+		t.Fatalf("unexpected subject IDs: %v", record.SubjectIDs)
+	}
+}
+
+func TestHandler_DataAccessSink_IgnoresOtherFields(t *testing.T) {
+	called := false
+	h := New(&Config{
+		Schema:             &testutil.StarWarsSchema,
+		PersonalDataFields: []string{"Human.homePlanet"},
+		DataAccessSink: DataAccessSinkFunc(func(ctx context.Context, record DataAccessRecord) {
+			called = true
+		}),
+	})
+
+	req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape(`{ hero { name } }`), nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if called {
+		t.Fatalf("sink should not be called for operations without personal data")
+	}
+}
+
+func TestHandler_DataAccessSink_PossibleTypes(t *testing.T) {
+	var records []DataAccessRecord
+	h := New(&Config{
+		Schema:             &testutil.StarWarsSchema,
+		PersonalDataFields: []string{"Human.name"},
+		DataAccessSink: DataAccessSinkFunc(func(ctx context.Context, record DataAccessRecord) {
+			records = append(records, record)
+		}),
+	})
+
+	req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape(`{ hero { name } }`), nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(records) != 1 || !reflect.DeepEqual(records[0].Fields, []string{"Human.name"}) {
+		t.Fatalf("expected the interface field to be recorded, got %+v", records)
+	}
+}
+
+func TestWebSocket_DataAccessSink(t *testing.T) {
+	records := make(chan DataAccessRecord, 1)
+	h := New(&Config{
+		Schema:             newSubscriptionSchema(t),
+		Subscriptions:      true,
+		PersonalDataFields: []string{"Subscription.counter"},
+		DataAccessSink: DataAccessSinkFunc(func(ctx context.Context, record DataAccessRecord) {
+			records <- record
+		}),
+	})
+	conn := dialWebSocket(t, h, ProtocolGraphQLTransportWS)
+
+	conn.WriteJSON(wsMessage{Type: wsConnectionInit})
+	readMessage(t, conn)
+	conn.WriteJSON(wsMessage{ID: "1", Type: wsSubscribe, Payload: []byte(`{"query":"subscription Counter { counter }"}`)})
+
+	select {
+	case record := <-records:
+		if record.OperationName != "Counter" || !reflect.DeepEqual(record.Fields, []string{"Subscription.counter"}) {
+			t.Fatalf("unexpected record: %+v", record)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the subscription to be recorded")
+	}
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"fmt"
+	"runtime/debug"
+	"strings"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// resolverPanic is the error a resolver panicked with in debug mode, holding
+// the stack of the panic.
+type resolverPanic struct {
+	value interface{}
+	stack []byte
+}
+
+func (p *resolverPanic) Error() string {
+	return fmt.Sprint(p.value)
+}
+
+type debugErrors struct {
+	mu      sync.Mutex
+	wrapped map[*graphql.FieldDefinition]bool
+}
+
+func newDebugErrors(enabled bool) *debugErrors {
+	if !enabled {
+		return nil
+	}
+	return &debugErrors{wrapped: make(map[*graphql.FieldDefinition]bool)}
+}
+
+// apply wraps the resolvers of the schema to capture the stack of their
+// panics.
+func (d *debugErrors) apply(schema *graphql.Schema) {
+	if schema == nil {
+		return
+	}
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	for name, t := range schema.TypeMap() {
+		object, ok := t.(*graphql.Object)
+		if !ok || strings.HasPrefix(name, "__") {
+			continue
+		}
+		for _, def := range object.Fields() {
+			if d.wrapped[def] {
+				continue
+			}
+			d.wrapped[def] = true
+			resolve := def.Resolve
+			if resolve == nil {
+				resolve = graphql.DefaultResolveFn
+			}
+			def.Resolve = func(p graphql.ResolveParams) (result interface{}, err error) {
+				defer func() {
+					if r := recover(); r != nil {
+						result, err = nil, &resolverPanic{value: r, stack: debug.Stack()}
+					}
+				}()
+				return resolve(p)
+			}
+		}
+	}
+}
+
+// addDebugExtensions adds the resolver path, the original error and the
+// stack of the panics to the "debug" extension of the execution errors.
+func addDebugExtensions(original, errs []gqlerrors.FormattedError) {
+	for i, err := range original {
+		located, ok := err.OriginalError().(*gqlerrors.Error)
+		if !ok || located.OriginalError == nil || len(err.Path) == 0 {
+			continue
+		}
+		path := make([]string, len(err.Path))
+		for j, segment := range err.Path {
+			path[j] = fmt.Sprint(segment)
+		}
+		info := map[string]interface{}{
+			"path":  strings.Join(path, "."),
+			"error": fmt.Sprintf("%+v", located.OriginalError),
+		}
+		if panicked, ok := located.OriginalError.(*resolverPanic); ok {
+			info["stack"] = strings.Split(strings.TrimSpace(string(panicked.stack)), "\n")
+		}
+
+		extensions := make(map[string]interface{}, len(errs[i].Extensions)+1)
+		for key, value := range errs[i].Extensions {
+			extensions[key] = value
+		}
+		extensions["debug"] = info
+		errs[i].Extensions = extensions
+	}
+}
@@ -0,0 +1,73 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_Debug(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"failing": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return nil, errors.New("pq: connection refused")
+					},
+				},
+				"panicking": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						panic("nil map")
+					},
+				},
+			},
+		}),
+	})
+	h := New(&Config{Schema: &schema, Debug: true, MaskInternalErrors: true})
+
+	req, _ := http.NewRequest("GET", "/graphql?query={failing+panicking}", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	var result struct {
+		Errors []struct {
+			Message    string `json:"message"`
+			Extensions struct {
+				Code  string `json:"code"`
+				Debug struct {
+					Path  string   `json:"path"`
+					Error string   `json:"error"`
+					Stack []string `json:"stack"`
+				} `json:"debug"`
+			} `json:"extensions"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &result); err != nil || len(result.Errors) != 2 {
+		t.Fatalf("unexpected response %s", resp.Body.String())
+	}
+	for _, err := range result.Errors {
+		if err.Message != internalErrorMessage || err.Extensions.Code != string(CodeInternalServerError) {
+			t.Errorf("expected the error to stay masked, got %+v", err)
+		}
+		debug := err.Extensions.Debug
+		switch debug.Path {
+		case "failing":
+			if debug.Error != "pq: connection refused" || debug.Stack != nil {
+				t.Errorf("unexpected debug %+v", debug)
+			}
+		case "panicking":
+			if debug.Error != "nil map" || !strings.Contains(strings.Join(debug.Stack, "\n"), "debug_errors_test.go") {
+				t.Errorf("unexpected debug %+v", debug)
+			}
+		default:
+			t.Errorf("unexpected debug %+v", debug)
+		}
+	}
+}
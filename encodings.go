@@ -0,0 +1,278 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Media types of the built-in response encoders.
+const (
+	ContentTypeMessagePack = "application/msgpack"
+	ContentTypeCBOR        = "application/cbor"
+)
+
+// ResponseEncoder encodes a GraphQL response, decoded from its JSON form with
+// json.Number numbers, into an alternative media type.
+type ResponseEncoder func(response interface{}) ([]byte, error)
+
+// responseEncoder chooses the encoder of the media type the client prefers,
+// nil when it prefers JSON.
+func (h *Handler) responseEncoder(r *http.Request) (string, ResponseEncoder) {
+	if len(h.responseEncoders) == 0 {
+		return "", nil
+	}
+	for _, mediaType := range acceptList(r.Header.Get("Accept")) {
+		if encoder, ok := h.responseEncoders[mediaType]; ok {
+			return mediaType, encoder
+		}
+		if mediaType == ContentTypeJSON || mediaType == ContentTypeGraphQLResponse || mediaType == "*/*" {
+			break
+		}
+	}
+	return "", nil
+}
+
+// acceptList lists the values of an Accept or Accept-Encoding header by
+// decreasing quality, leaving out the ones with a zero quality.
+func acceptList(header string) []string {
+	type accepted struct {
+		mediaType string
+		q         float64
+	}
+	var mediaTypes []accepted
+	for _, part := range strings.Split(header, ",") {
+		tokens := strings.Split(part, ";")
+		mediaType := strings.ToLower(strings.TrimSpace(tokens[0]))
+		if mediaType == "" {
+			continue
+		}
+		q := 1.0
+		for _, param := range tokens[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				q, _ = strconv.ParseFloat(param[2:], 64)
+			}
+		}
+		if q > 0 {
+			mediaTypes = append(mediaTypes, accepted{mediaType, q})
+		}
+	}
+	sort.SliceStable(mediaTypes, func(i, j int) bool {
+		return mediaTypes[i].q > mediaTypes[j].q
+	})
+	list := make([]string, len(mediaTypes))
+	for i, accepted := range mediaTypes {
+		list[i] = accepted.mediaType
+	}
+	return list
+}
+
+// encodeResponse encodes the response with an alternative encoder.
+func encodeResponse(encoder ResponseEncoder, response interface{}) ([]byte, error) {
+	encoded, err := json.Marshal(response)
+	if err != nil {
+		return nil, err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(encoded))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, err
+	}
+	return encoder(value)
+}
+
+// EncodeMessagePack encodes a decoded JSON value in MessagePack.
+func EncodeMessagePack(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeMessagePack(&buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeMessagePack(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteByte(0xc0)
+	case bool:
+		if v {
+			buf.WriteByte(0xc3)
+		} else {
+			buf.WriteByte(0xc2)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			encodeMessagePackInt(buf, i)
+			return nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		buf.WriteByte(0xcb)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+	case string:
+		n := len(v)
+		switch {
+		case n < 32:
+			buf.WriteByte(0xa0 | byte(n))
+		case n <= math.MaxUint8:
+			buf.WriteByte(0xd9)
+			buf.WriteByte(byte(n))
+		case n <= math.MaxUint16:
+			buf.WriteByte(0xda)
+			binary.Write(buf, binary.BigEndian, uint16(n))
+		default:
+			buf.WriteByte(0xdb)
+			binary.Write(buf, binary.BigEndian, uint32(n))
+		}
+		buf.WriteString(v)
+	case []interface{}:
+		writeMessagePackLength(buf, len(v), 0x90, 0xdc, 0xdd)
+		for _, item := range v {
+			if err := encodeMessagePack(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		writeMessagePackLength(buf, len(v), 0x80, 0xde, 0xdf)
+		for _, key := range sortedKeys(v) {
+			encodeMessagePack(buf, key)
+			if err := encodeMessagePack(buf, v[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cannot encode %T in MessagePack", value)
+	}
+	return nil
+}
+
+func encodeMessagePackInt(buf *bytes.Buffer, i int64) {
+	switch {
+	case i >= 0 && i <= 0x7f:
+		buf.WriteByte(byte(i))
+	case i < 0 && i >= -32:
+		buf.WriteByte(byte(i))
+	case i >= math.MinInt8 && i <= math.MaxInt8:
+		buf.WriteByte(0xd0)
+		buf.WriteByte(byte(i))
+	case i >= math.MinInt16 && i <= math.MaxInt16:
+		buf.WriteByte(0xd1)
+		binary.Write(buf, binary.BigEndian, int16(i))
+	case i >= math.MinInt32 && i <= math.MaxInt32:
+		buf.WriteByte(0xd2)
+		binary.Write(buf, binary.BigEndian, int32(i))
+	default:
+		buf.WriteByte(0xd3)
+		binary.Write(buf, binary.BigEndian, i)
+	}
+}
+
+func writeMessagePackLength(buf *bytes.Buffer, n int, fix, b16, b32 byte) {
+	switch {
+	case n < 16:
+		buf.WriteByte(fix | byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(b16)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	default:
+		buf.WriteByte(b32)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	}
+}
+
+// EncodeCBOR encodes a decoded JSON value in CBOR (RFC 8949).
+func EncodeCBOR(value interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := encodeCBOR(&buf, value); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func encodeCBOR(buf *bytes.Buffer, value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		buf.WriteByte(0xf6)
+	case bool:
+		if v {
+			buf.WriteByte(0xf5)
+		} else {
+			buf.WriteByte(0xf4)
+		}
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			if i >= 0 {
+				writeCBORHead(buf, 0, uint64(i))
+			} else {
+				writeCBORHead(buf, 1, uint64(-(i + 1)))
+			}
+			return nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return err
+		}
+		buf.WriteByte(0xfb)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(f))
+	case string:
+		writeCBORHead(buf, 3, uint64(len(v)))
+		buf.WriteString(v)
+	case []interface{}:
+		writeCBORHead(buf, 4, uint64(len(v)))
+		for _, item := range v {
+			if err := encodeCBOR(buf, item); err != nil {
+				return err
+			}
+		}
+	case map[string]interface{}:
+		writeCBORHead(buf, 5, uint64(len(v)))
+		for _, key := range sortedKeys(v) {
+			encodeCBOR(buf, key)
+			if err := encodeCBOR(buf, v[key]); err != nil {
+				return err
+			}
+		}
+	default:
+		return fmt.Errorf("cannot encode %T in CBOR", value)
+	}
+	return nil
+}
+
+func writeCBORHead(buf *bytes.Buffer, major byte, n uint64) {
+	major <<= 5
+	switch {
+	case n < 24:
+		buf.WriteByte(major | byte(n))
+	case n <= math.MaxUint8:
+		buf.WriteByte(major | 24)
+		buf.WriteByte(byte(n))
+	case n <= math.MaxUint16:
+		buf.WriteByte(major | 25)
+		binary.Write(buf, binary.BigEndian, uint16(n))
+	case n <= math.MaxUint32:
+		buf.WriteByte(major | 26)
+		binary.Write(buf, binary.BigEndian, uint32(n))
+	default:
+		buf.WriteByte(major | 27)
+		binary.Write(buf, binary.BigEndian, n)
+	}
+}
+
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
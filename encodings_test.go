@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestEncodeMessagePack(t *testing.T) {
+	value := map[string]interface{}{
+		"a": []interface{}{json.Number("1"), json.Number("-300"), json.Number("1.5"), true, nil},
+		"b": "x",
+	}
+	encoded, err := EncodeMessagePack(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{
+		0x82,
+		0xa1, 'a', 0x95, 0x01, 0xd1, 0xfe, 0xd4, 0xcb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0, 0xc3, 0xc0,
+		0xa1, 'b', 0xa1, 'x',
+	}
+	if !bytes.Equal(encoded, expected) {
+		t.Fatalf("unexpected encoding % x", encoded)
+	}
+}
+
+func TestEncodeCBOR(t *testing.T) {
+	value := map[string]interface{}{
+		"a": []interface{}{json.Number("1"), json.Number("-300"), json.Number("1.5"), true, nil},
+		"b": "x",
+	}
+	encoded, err := EncodeCBOR(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []byte{
+		0xa2,
+		0x61, 'a', 0x85, 0x01, 0x39, 0x01, 0x2b, 0xfb, 0x3f, 0xf8, 0, 0, 0, 0, 0, 0, 0xf5, 0xf6,
+		0x61, 'b', 0x61, 'x',
+	}
+	if !bytes.Equal(encoded, expected) {
+		t.Fatalf("unexpected encoding % x", encoded)
+	}
+}
+
+func TestHandler_ResponseEncoders(t *testing.T) {
+	h := New(&Config{
+		Schema:           &testutil.StarWarsSchema,
+		ResponseEncoders: map[string]ResponseEncoder{ContentTypeCBOR: EncodeCBOR},
+	})
+
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+	req.Header.Set("Accept", "application/json;q=0.5, application/cbor")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Header().Get("Content-Type") != ContentTypeCBOR || !bytes.Contains(resp.Body.Bytes(), []byte("R2-D2")) {
+		t.Fatalf("expected a CBOR response, got %v", resp.Header())
+	}
+	if resp.Header().Get("Vary") != "Accept" {
+		t.Fatalf("expected the response to vary on Accept, got %v", resp.Header())
+	}
+
+	req.Header.Set("Accept", "application/json, application/cbor")
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Header().Get("Content-Type") != "application/json; charset=utf-8" || resp.Header().Get("Vary") != "Accept" {
+		t.Fatalf("expected a JSON response varying on Accept, got %v", resp.Header())
+	}
+}
@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// ErrorCode identifies the errors the handler rejects requests with before
+// executing them, in the "code" extension of the error.
+type ErrorCode string
+
+const (
+	CodeMethodNotAllowed            ErrorCode = "METHOD_NOT_ALLOWED"
+	CodeTooManyConcurrentRequests   ErrorCode = "TOO_MANY_CONCURRENT_REQUESTS"
+	CodeTooManyConcurrentMutations  ErrorCode = "TOO_MANY_CONCURRENT_MUTATIONS"
+	CodeTooManyConcurrentOperations ErrorCode = "TOO_MANY_CONCURRENT_OPERATIONS"
+	CodeCircuitOpen                 ErrorCode = "CIRCUIT_OPEN"
+	CodeRateLimited                 ErrorCode = "RATE_LIMITED"
+	CodeUnauthenticated             ErrorCode = "UNAUTHENTICATED"
+	CodeForbidden                   ErrorCode = "FORBIDDEN"
+	CodeClientBlocked               ErrorCode = "CLIENT_BLOCKED"
+	CodeIPForbidden                 ErrorCode = "IP_FORBIDDEN"
+	CodeCSRFPrevented               ErrorCode = "CSRF_PREVENTED"
+	CodeIdempotencyKeyReused        ErrorCode = "IDEMPOTENCY_KEY_REUSED"
+	CodeUnsupportedMediaType        ErrorCode = "UNSUPPORTED_MEDIA_TYPE"
+	CodeUnsupportedContentEncoding  ErrorCode = "UNSUPPORTED_CONTENT_ENCODING"
+	CodeInvalidRequest              ErrorCode = "INVALID_REQUEST"
+	CodeRequestTimeout              ErrorCode = "REQUEST_TIMEOUT"
+	CodeRequestTooLarge             ErrorCode = "REQUEST_TOO_LARGE"
+	CodePersistedQueryNotFound      ErrorCode = "PERSISTED_QUERY_NOT_FOUND"
+	CodePersistedQueryInvalid       ErrorCode = "PERSISTED_QUERY_INVALID"
+	CodeUnknownExtension            ErrorCode = "UNKNOWN_EXTENSION"
+	CodeOperationNotAllowed         ErrorCode = "OPERATION_NOT_ALLOWED"
+	CodeReadOnly                    ErrorCode = "READ_ONLY"
+	CodeVariablesTooLarge           ErrorCode = "VARIABLES_TOO_LARGE"
+	CodeResponseTooLarge            ErrorCode = "RESPONSE_TOO_LARGE"
+	CodeQueryTooLarge               ErrorCode = "QUERY_TOO_LARGE"
+	CodeQueryTooComplex             ErrorCode = "QUERY_TOO_COMPLEX"
+	CodeIntrospectionDisabled       ErrorCode = "INTROSPECTION_DISABLED"
+	CodeOperationNotSupported       ErrorCode = "OPERATION_NOT_SUPPORTED"
+	CodeInternalServerError         ErrorCode = "INTERNAL_SERVER_ERROR"
+)
+
+// ErrorCodeInfo documents an ErrorCode.
+type ErrorCodeInfo struct {
+	Code ErrorCode `json:"code"`
+	// Status is the HTTP status code of the responses, those of the errors
+	// rejecting operations like validation errors being 200 unless strict
+	// status codes apply.
+	Status      int    `json:"status"`
+	Description string `json:"description"`
+}
+
+var errorCodes = []ErrorCodeInfo{
+	{CodeMethodNotAllowed, http.StatusMethodNotAllowed, "The HTTP method is not allowed, or the operation type cannot be sent with it."},
+	{CodeTooManyConcurrentRequests, http.StatusServiceUnavailable, "Too many requests are being served or having their body read, retry later."},
+	{CodeTooManyConcurrentMutations, http.StatusTooManyRequests, "Too many mutations of the same subject are being executed."},
+	{CodeTooManyConcurrentOperations, http.StatusTooManyRequests, "Too many operations are being executed, retry after the Retry-After delay."},
+	{CodeCircuitOpen, http.StatusServiceUnavailable, "The operation, or the server, fails too often and is rejected until it recovers, retry after the Retry-After delay."},
+	{CodeRateLimited, http.StatusTooManyRequests, "Too many requests were sent, retry after the Retry-After delay."},
+	{CodeUnauthenticated, http.StatusUnauthorized, "The bearer token, API key or signature of the request is missing or invalid."},
+	{CodeForbidden, http.StatusForbidden, "The operation is not authorized for the request."},
+	{CodeClientBlocked, http.StatusForbidden, "The client application or its version is not allowed, upgrade it."},
+	{CodeIPForbidden, http.StatusForbidden, "The IP address of the request is not allowed."},
+	{CodeCSRFPrevented, http.StatusBadRequest, "The request could have been sent cross-site by a browser, send a JSON Content-Type or a preflight header."},
+	{CodeIdempotencyKeyReused, http.StatusUnprocessableEntity, "The Idempotency-Key was already sent with another operation or variables."},
+	{CodeUnsupportedMediaType, http.StatusUnsupportedMediaType, "The Content-Type of the request body is missing or not supported."},
+	{CodeUnsupportedContentEncoding, http.StatusUnsupportedMediaType, "The Content-Encoding of the request body is not supported."},
+	{CodeInvalidRequest, http.StatusBadRequest, "The request body or parameters cannot be decoded."},
+	{CodeRequestTimeout, http.StatusRequestTimeout, "The request body was not received in time."},
+	{CodeRequestTooLarge, http.StatusRequestEntityTooLarge, "The request body is too large, as sent or once decompressed."},
+	{CodePersistedQueryNotFound, http.StatusOK, "The persisted query is unknown, send it along with its hash."},
+	{CodePersistedQueryInvalid, http.StatusBadRequest, "The persistedQuery extension is malformed or its hash does not match the query."},
+	{CodeUnknownExtension, http.StatusBadRequest, "The request has an extension unknown to the server."},
+	{CodeOperationNotAllowed, http.StatusForbidden, "The operation is anonymous or not in the allowlist."},
+	{CodeReadOnly, http.StatusServiceUnavailable, "The API is in read-only mode, which rejects mutations and possibly subscriptions."},
+	{CodeVariablesTooLarge, http.StatusBadRequest, "The variables exceed the maximum size, key count or nesting depth."},
+	{CodeResponseTooLarge, http.StatusBadRequest, "The response exceeds the maximum size, select less data."},
+	{CodeQueryTooLarge, http.StatusBadRequest, "The query exceeds the maximum size or token count."},
+	{CodeQueryTooComplex, http.StatusBadRequest, "The operation exceeds the maximum cost, aliases, root fields or selections."},
+	{CodeIntrospectionDisabled, http.StatusBadRequest, "The query selects introspection fields, which are not allowed."},
+	{CodeOperationNotSupported, http.StatusOK, "The operation type is not supported over WebSocket."},
+	{CodeInternalServerError, http.StatusInternalServerError, "The operation failed unexpectedly."},
+}
+
+// errorCode returns the "code" extension of an error, set as a string or an
+// ErrorCode, empty when missing.
+func errorCode(err gqlerrors.FormattedError) string {
+	switch code := err.Extensions["code"].(type) {
+	case string:
+		return code
+	case ErrorCode:
+		return string(code)
+	}
+	return ""
+}
+
+// ErrorCodes lists the codes of the errors the handler rejects requests
+// with.
+func ErrorCodes() []ErrorCodeInfo {
+	return append([]ErrorCodeInfo(nil), errorCodes...)
+}
+
+// ErrorCodesHandler serves the ErrorCodes in JSON, e.g. for client SDK
+// generators.
+func ErrorCodesHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string][]ErrorCodeInfo{"codes": ErrorCodes()})
+	})
+}
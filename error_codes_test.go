@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestErrorCodesHandler(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/graphql/error-codes", nil)
+	resp := httptest.NewRecorder()
+	ErrorCodesHandler().ServeHTTP(resp, req)
+
+	var catalog struct {
+		Codes []ErrorCodeInfo `json:"codes"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &catalog); err != nil {
+		t.Fatal(err)
+	}
+	seen := make(map[ErrorCode]bool)
+	for _, info := range catalog.Codes {
+		if seen[info.Code] || info.Status == 0 || info.Description == "" {
+			t.Errorf("unexpected catalog entry %+v", info)
+		}
+		seen[info.Code] = true
+	}
+	for _, code := range []ErrorCode{CodeMethodNotAllowed, CodeInvalidRequest, CodePersistedQueryNotFound, CodeQueryTooComplex} {
+		if !seen[code] {
+			t.Errorf("expected %s in the catalog", code)
+		}
+	}
+}
+
+func TestHandler_RequestErrorCodes(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema})
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}&variables=oops", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	var result struct {
+		Errors []struct {
+			Extensions map[string]string `json:"extensions"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	if resp.Code != http.StatusBadRequest || len(result.Errors) != 1 || result.Errors[0].Extensions["code"] != string(CodeInvalidRequest) {
+		t.Errorf("unexpected response %d %s", resp.Code, resp.Body.String())
+	}
+}
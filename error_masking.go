@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// InternalErrorFn receives the original of every error masked by
+// Config.MaskInternalErrors, with the correlation ID sent to the client.
+type InternalErrorFn func(ctx context.Context, err error, correlationID string)
+
+const internalErrorMessage = "Internal server error"
+
+// isInternalError reports whether the error was returned, or panicked, by a
+// resolver without being meant for clients, which would have extensions.
+func isInternalError(err gqlerrors.FormattedError) bool {
+	if len(err.Path) == 0 {
+		return false
+	}
+	located, ok := err.OriginalError().(*gqlerrors.Error)
+	if !ok || located.OriginalError == nil {
+		return false
+	}
+	_, extended := located.OriginalError.(gqlerrors.ExtendedError)
+	return !extended
+}
+
+// internalErrors returns the indexes of the internal errors, when masked.
+func (h *Handler) internalErrors(errs []gqlerrors.FormattedError) []int {
+	if !h.maskInternalErrors {
+		return nil
+	}
+	var internal []int
+	for i, err := range errs {
+		if isInternalError(err) {
+			internal = append(internal, i)
+		}
+	}
+	return internal
+}
+
+// maskErrors replaces the message of the internal errors by a generic one
+// with a correlation ID, passing the original errors to the InternalErrorFn.
+func (h *Handler) maskErrors(ctx context.Context, original, errs []gqlerrors.FormattedError, internal []int) {
+	for _, i := range internal {
+		correlationID := newCorrelationID()
+		if h.internalErrorFn != nil {
+			err := error(original[i])
+			if located, ok := original[i].OriginalError().(*gqlerrors.Error); ok {
+				err = located.OriginalError
+			}
+			h.internalErrorFn(ctx, err, correlationID)
+		}
+		errs[i].Message = internalErrorMessage
+		errs[i].Extensions = map[string]interface{}{
+			"code":          CodeInternalServerError,
+			"correlationId": correlationID,
+		}
+	}
+}
+
+func newCorrelationID() string {
+	id := make([]byte, 8)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
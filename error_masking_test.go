@@ -0,0 +1,86 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_MaskInternalErrors(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"failing": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return nil, errors.New("pq: connection refused")
+					},
+				},
+				"panicking": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						panic("nil map")
+					},
+				},
+				"forbidden": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return nil, &fieldAuthError{coordinate: "Query.forbidden"}
+					},
+				},
+			},
+		}),
+	})
+	logged := make(map[string]string)
+	h := New(&Config{
+		Schema:             &schema,
+		MaskInternalErrors: true,
+		InternalErrorFn: func(ctx context.Context, err error, correlationID string) {
+			logged[correlationID] = err.Error()
+		},
+	})
+
+	req, _ := http.NewRequest("GET", "/graphql?query={failing+panicking+forbidden+unknown}", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	req, _ = http.NewRequest("GET", "/graphql?query={failing+panicking+forbidden}", nil)
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	var result struct {
+		Errors []struct {
+			Message    string            `json:"message"`
+			Path       []string          `json:"path"`
+			Extensions map[string]string `json:"extensions"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	originals := map[string]string{}
+	for _, err := range result.Errors {
+		if err.Path[0] == "forbidden" {
+			if err.Message != "Not authorized to access Query.forbidden" {
+				t.Errorf("expected errors with extensions not to be masked, got %q", err.Message)
+			}
+			continue
+		}
+		id := err.Extensions["correlationId"]
+		if err.Message != internalErrorMessage || err.Extensions["code"] != string(CodeInternalServerError) || id == "" {
+			t.Errorf("unexpected error %+v", err)
+		}
+		originals[err.Path[0]] = logged[id]
+	}
+	if originals["failing"] != "pq: connection refused" || originals["panicking"] != "nil map" {
+		t.Errorf("unexpected logged errors %v", originals)
+	}
+	if len(logged) != 2 {
+		t.Errorf("expected validation errors not to be masked, logged %v", logged)
+	}
+}
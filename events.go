@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// EventSeverity is the severity of an Event, numbered like the severities
+// of OpenTelemetry log records.
+type EventSeverity int
+
+const (
+	SeverityInfo  EventSeverity = 9
+	SeverityWarn  EventSeverity = 13
+	SeverityError EventSeverity = 17
+)
+
+// Event is a structured record of something the handler did, shaped like an
+// OpenTelemetry log record so that an EventEmitter can forward it to a
+// LoggerProvider.
+type Event struct {
+	Timestamp  time.Time
+	Name       string
+	Severity   EventSeverity
+	Body       string
+	Attributes map[string]interface{}
+}
+
+const (
+	EventRequestRejected  = "graphql.request.rejected"
+	EventPanic            = "graphql.panic"
+	EventCacheHit         = "graphql.cache.hit"
+	EventCacheServeStale  = "graphql.cache.serve_stale"
+	EventCachePurge       = "graphql.cache.purge"
+	EventSchemaChange     = "graphql.schema.change"
+	EventResponseTooLarge = "graphql.response.too_large"
+)
+
+// EventEmitter receives the events of the handler: requests rejected before
+// execution, panics, response cache hits and purges, schema swaps and
+// responses replaced for being too large.
+type EventEmitter interface {
+	Emit(ctx context.Context, event Event)
+}
+
+// EventEmitterFunc adapts a function to an EventEmitter.
+type EventEmitterFunc func(ctx context.Context, event Event)
+
+// Emit calls f(ctx, event).
+func (f EventEmitterFunc) Emit(ctx context.Context, event Event) {
+	f(ctx, event)
+}
+
+func (h *Handler) emit(ctx context.Context, name string, severity EventSeverity, body string, attributes map[string]interface{}) {
+	if h.eventEmitter == nil {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	h.eventEmitter.Emit(ctx, Event{
+		Timestamp:  time.Now(),
+		Name:       name,
+		Severity:   severity,
+		Body:       body,
+		Attributes: attributes,
+	})
+}
+
+func (h *Handler) emitRejection(ctx context.Context, err *requestError) {
+	h.recordError(ctx, string(err.code))
+	h.emit(ctx, EventRequestRejected, SeverityWarn, err.message, map[string]interface{}{
+		"graphql.error.code":   string(err.code),
+		"http.response.status": err.status,
+	})
+}
+
+func (h *Handler) emitPanic(ctx context.Context, recovered interface{}) {
+	h.log(ctx, LevelError, "panic serving a request", "panic", fmt.Sprint(recovered))
+	h.emit(ctx, EventPanic, SeverityError, fmt.Sprint(recovered), nil)
+}
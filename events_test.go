@@ -0,0 +1,77 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+type eventRecorder struct {
+	mu     sync.Mutex
+	events []Event
+}
+
+func (e *eventRecorder) Emit(ctx context.Context, event Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.events = append(e.events, event)
+}
+
+func (e *eventRecorder) last() Event {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if len(e.events) == 0 {
+		return Event{}
+	}
+	return e.events[len(e.events)-1]
+}
+
+func TestHandler_EventEmitter(t *testing.T) {
+	events := &eventRecorder{}
+	h := New(&Config{
+		Schema:       &testutil.StarWarsSchema,
+		EventEmitter: events,
+		RootObjectFn: func(ctx context.Context, r *http.Request) map[string]interface{} {
+			if r.URL.Query().Get("panic") != "" {
+				panic("boom")
+			}
+			return nil
+		},
+	})
+
+	req, _ := http.NewRequest("GET", "/graphql?query=mutation{foo}", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	event := events.last()
+	if event.Name != EventRequestRejected || event.Severity != SeverityWarn || event.Attributes["graphql.error.code"] != string(CodeMethodNotAllowed) || event.Attributes["http.response.status"] != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("expected the panic to be propagated")
+			}
+		}()
+		req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}&panic=1", nil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	if event := events.last(); event.Name != EventPanic || event.Severity != SeverityError || event.Body != "boom" {
+		t.Errorf("unexpected event %+v", event)
+	}
+
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   "Query",
+			Fields: graphql.Fields{"name": &graphql.Field{Type: graphql.String}},
+		}),
+	})
+	h.SetSchema(&schema)
+	if event := events.last(); event.Name != EventSchemaChange || event.Attributes["graphql.schema.added"] != 1 {
+		t.Errorf("unexpected event %+v", event)
+	}
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"context"
+	"hash/fnv"
+	"net/http"
+)
+
+// Experiment splits clients between weighted buckets. A client is always
+// assigned the same bucket of an experiment.
+type Experiment struct {
+	Name    string
+	Buckets []ExperimentBucket
+}
+
+// ExperimentBucket is a variant of an Experiment, receiving a share of the
+// clients proportional to its weight.
+type ExperimentBucket struct {
+	Name   string
+	Weight int
+}
+
+// ExperimentClientIDFn identifies the client of a request for bucketing,
+// returning an empty string leaves the request out of the experiments.
+type ExperimentClientIDFn func(ctx context.Context, r *http.Request) string
+
+type experimentsKey struct{}
+
+// ExperimentBucketFromContext returns the bucket of the named experiment
+// assigned to the request, empty when it is not part of the experiment.
+func ExperimentBucketFromContext(ctx context.Context, experiment string) string {
+	return ExperimentBucketsFromContext(ctx)[experiment]
+}
+
+// ExperimentBucketsFromContext returns the buckets assigned to the request
+// by experiment name.
+func ExperimentBucketsFromContext(ctx context.Context) map[string]string {
+	buckets, _ := ctx.Value(experimentsKey{}).(map[string]string)
+	return buckets
+}
+
+// bucket deterministically assigns a bucket to the client.
+func (e Experiment) bucket(clientID string) string {
+	total := 0
+	for _, b := range e.Buckets {
+		if b.Weight > 0 {
+			total += b.Weight
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(e.Name))
+	hash.Write([]byte{0})
+	hash.Write([]byte(clientID))
+	n := int(hash.Sum32() % uint32(total))
+	for _, b := range e.Buckets {
+		if b.Weight <= 0 {
+			continue
+		}
+		if n < b.Weight {
+			return b.Name
+		}
+		n -= b.Weight
+	}
+	return ""
+}
+
+// withExperiments assigns the request client to the experiment buckets.
+func (h *Handler) withExperiments(ctx context.Context, r *http.Request) context.Context {
+	if len(h.experiments) == 0 || h.experimentClientIDFn == nil {
+		return ctx
+	}
+	clientID := h.experimentClientIDFn(ctx, r)
+	if clientID == "" {
+		return ctx
+	}
+
+	buckets := make(map[string]string, len(h.experiments))
+	for _, experiment := range h.experiments {
+		if bucket := experiment.bucket(clientID); bucket != "" {
+			buckets[experiment.Name] = bucket
+		}
+	}
+	return context.WithValue(ctx, experimentsKey{}, buckets)
+}
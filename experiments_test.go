@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestExperiment_BucketIsDeterministic(t *testing.T) {
+	experiment := Experiment{
+		Name: "ttl",
+		Buckets: []ExperimentBucket{
+			{Name: "control", Weight: 1},
+			{Name: "long", Weight: 1},
+		},
+	}
+
+	counts := map[string]int{}
+	for i := 0; i < 1000; i++ {
+		clientID := fmt.Sprintf("client-%d", i)
+		bucket := experiment.bucket(clientID)
+		if bucket != experiment.bucket(clientID) {
+			t.Fatalf("bucket assignment is not stable for %v", clientID)
+		}
+		counts[bucket]++
+	}
+	if counts["control"] < 400 || counts["long"] < 400 {
+		t.Fatalf("unbalanced buckets: %v", counts)
+	}
+}
+
+func TestHandler_Experiments(t *testing.T) {
+	experiment := Experiment{
+		Name:    "ttl",
+		Buckets: []ExperimentBucket{{Name: "control", Weight: 1}, {Name: "long", Weight: 1}},
+	}
+	h := New(&Config{
+		Schema:      &testutil.StarWarsSchema,
+		Experiments: []Experiment{experiment},
+		ExperimentClientIDFn: func(ctx context.Context, r *http.Request) string {
+			return r.Header.Get("X-Client-ID")
+		},
+		ExperimentsExtension: true,
+	})
+
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+	req.Header.Set("X-Client-ID", "client-1")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	var body struct {
+		Extensions struct {
+			Experiments map[string]string `json:"experiments"`
+		} `json:"extensions"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if expected := experiment.bucket("client-1"); body.Extensions.Experiments["ttl"] != expected {
+		t.Fatalf("expected bucket %q, got %v", expected, body.Extensions.Experiments)
+	}
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sort"
+)
+
+// handlerExtensions are the request extensions the handler reads.
+var handlerExtensions = map[string]bool{
+	"persistedQuery": true,
+	"clientLibrary":  true,
+	"resultPatch":    true,
+}
+
+// UnknownExtensionsPolicy decides what happens to requests with extensions
+// neither read by the handler nor listed in Config.KnownExtensions.
+type UnknownExtensionsPolicy int
+
+const (
+	// IgnoreUnknownExtensions executes the requests as usual.
+	IgnoreUnknownExtensions UnknownExtensionsPolicy = iota
+	// WarnUnknownExtensions calls Config.UnknownExtensionsFn, then executes
+	// the requests.
+	WarnUnknownExtensions
+	// RejectUnknownExtensions answers 400 to the requests.
+	RejectUnknownExtensions
+)
+
+// UnknownExtensionsFn is called with the unknown extension keys of a request,
+// sorted.
+type UnknownExtensionsFn func(ctx context.Context, r *http.Request, keys []string)
+
+type extensionsKey struct{}
+
+// ExtensionsFromContext returns the extensions of the request, e.g. for
+// resolvers to read application-defined ones.
+func ExtensionsFromContext(ctx context.Context) map[string]interface{} {
+	extensions, _ := ctx.Value(extensionsKey{}).(map[string]interface{})
+	return extensions
+}
+
+// checkExtensions applies the UnknownExtensionsPolicy to the request, and
+// returns a context holding its extensions.
+func (h *Handler) checkExtensions(ctx context.Context, r *http.Request, opts *RequestOptions) (context.Context, *requestError) {
+	if len(opts.Extensions) == 0 {
+		return ctx, nil
+	}
+	ctx = context.WithValue(ctx, extensionsKey{}, opts.Extensions)
+	if h.unknownExtensions == IgnoreUnknownExtensions {
+		return ctx, nil
+	}
+
+	var unknown []string
+	for key := range opts.Extensions {
+		if !handlerExtensions[key] && !h.knownExtensions[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) == 0 {
+		return ctx, nil
+	}
+	sort.Strings(unknown)
+	if h.unknownExtensions == RejectUnknownExtensions {
+		return ctx, newRequestError(http.StatusBadRequest, CodeUnknownExtension, "Unknown extension \""+unknown[0]+"\"")
+	}
+	if h.unknownExtensionsFn != nil {
+		h.unknownExtensionsFn(ctx, r, unknown)
+	}
+	return ctx, nil
+}
+
+// PersistedQuery is the persistedQuery extension of a request.
+type PersistedQuery struct {
+	Version    float64
+	SHA256Hash string
+}
+
+// ClientAwareness identifies the client application of a request, as sent
+// by Apollo clients in the clientLibrary extension.
+type ClientAwareness struct {
+	Name    string
+	Version string
+}
+
+// GetPersistedQuery returns the persistedQuery extension of the request, nil
+// when absent, and an error when it is malformed.
+func (o *RequestOptions) GetPersistedQuery() (*PersistedQuery, error) {
+	extension, ok := o.Extensions["persistedQuery"]
+	if !ok || extension == nil {
+		return nil, nil
+	}
+	values, ok := extension.(map[string]interface{})
+	if !ok {
+		return nil, errors.New("Invalid persistedQuery extension")
+	}
+
+	var pq PersistedQuery
+	if pq.SHA256Hash, ok = values["sha256Hash"].(string); !ok && values["sha256Hash"] != nil {
+		return nil, errors.New("Invalid persistedQuery sha256Hash")
+	}
+	if pq.Version, ok = values["version"].(float64); !ok && values["version"] != nil {
+		return nil, errors.New("Invalid persistedQuery version")
+	}
+	return &pq, nil
+}
+
+// GetClientAwareness returns the clientLibrary extension of the request,
+// false when absent or malformed.
+func (o *RequestOptions) GetClientAwareness() (ClientAwareness, bool) {
+	values, ok := o.Extensions["clientLibrary"].(map[string]interface{})
+	if !ok {
+		return ClientAwareness{}, false
+	}
+	name, _ := values["name"].(string)
+	version, _ := values["version"].(string)
+	if name == "" {
+		return ClientAwareness{}, false
+	}
+	return ClientAwareness{Name: name, Version: version}, true
+}
+
+// GetStringExtension returns the extension of the request with the key,
+// false when absent or not a string.
+func (o *RequestOptions) GetStringExtension(key string) (string, bool) {
+	value, ok := o.Extensions[key].(string)
+	return value, ok
+}
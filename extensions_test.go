@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_UnknownExtensions(t *testing.T) {
+	var seen map[string]interface{}
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"locale": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						seen = ExtensionsFromContext(p.Context)
+						return seen["locale"], nil
+					},
+				},
+			},
+		}),
+	})
+	request := func(h *Handler) *httptest.ResponseRecorder {
+		extensions := `{"locale":"fr","tracing":true,"persistedQuery":null}`
+		req, _ := http.NewRequest("GET", "/graphql?query={locale}&extensions="+url.QueryEscape(extensions), nil)
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := request(New(&Config{Schema: &schema}))
+	if expected := `{"data":{"locale":"fr"}}`; resp.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, resp.Body.String())
+	}
+	if seen["tracing"] != true {
+		t.Errorf("expected the extensions in the context, got %v", seen)
+	}
+
+	var warned []string
+	request(New(&Config{
+		Schema:            &schema,
+		KnownExtensions:   []string{"locale"},
+		UnknownExtensions: WarnUnknownExtensions,
+		UnknownExtensionsFn: func(ctx context.Context, r *http.Request, keys []string) {
+			warned = keys
+		},
+	}))
+	if !reflect.DeepEqual(warned, []string{"tracing"}) {
+		t.Errorf("expected tracing to be warned about, got %v", warned)
+	}
+
+	resp = request(New(&Config{Schema: &schema, UnknownExtensions: RejectUnknownExtensions}))
+	if resp.Code != http.StatusBadRequest || !strings.Contains(resp.Body.String(), `Unknown extension \"locale\"`) {
+		t.Errorf("expected the request to be rejected, got %d %s", resp.Code, resp.Body.String())
+	}
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+)
+
+// FeatureFlags holds the feature flags evaluated for a request.
+type FeatureFlags map[string]bool
+
+// Enabled reports whether the named flag is on.
+func (f FeatureFlags) Enabled(name string) bool {
+	return f[name]
+}
+
+// FeatureFlagsFn evaluates the feature flags of a request, typically by
+// asking a flag service about the calling client.
+type FeatureFlagsFn func(ctx context.Context, r *http.Request) FeatureFlags
+
+// HandlerFeature names a handler feature that can be gated behind a flag with
+// Config.FeatureGates. Gating the limits rolls them out to the requests
+// having the flag on, the others not being rejected by them.
+type HandlerFeature string
+
+const (
+	FeatureSubscriptions       HandlerFeature = "subscriptions"
+	FeatureIncrementalDelivery HandlerFeature = "incrementalDelivery"
+	// FeatureOperationLimits gates MaxAliases, MaxRootFields and
+	// MaxSelections, and FeatureCostLimit gates MaxComplexity.
+	FeatureOperationLimits HandlerFeature = "operationLimits"
+	FeatureCostLimit       HandlerFeature = "costLimit"
+)
+
+type featureFlagsKey struct{}
+
+// FeatureFlagsFromContext returns the flags evaluated for the request, nil
+// when no FeatureFlagsFn is configured.
+func FeatureFlagsFromContext(ctx context.Context) FeatureFlags {
+	flags, _ := ctx.Value(featureFlagsKey{}).(FeatureFlags)
+	return flags
+}
+
+// withFeatureFlags evaluates the request flags into the context.
+func (h *Handler) withFeatureFlags(ctx context.Context, r *http.Request) context.Context {
+	if h.featureFlagsFn == nil {
+		return ctx
+	}
+	flags := h.featureFlagsFn(ctx, r)
+	if flags == nil {
+		flags = FeatureFlags{}
+	}
+	return context.WithValue(ctx, featureFlagsKey{}, flags)
+}
+
+// featureEnabled reports whether a handler feature is enabled for the
+// request; features without a gate are always enabled.
+func (h *Handler) featureEnabled(ctx context.Context, feature HandlerFeature) bool {
+	flag, gated := h.featureGates[feature]
+	if !gated {
+		return true
+	}
+	return FeatureFlagsFromContext(ctx).Enabled(flag)
+}
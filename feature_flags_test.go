@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_FeatureFlags(t *testing.T) {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"beta": &graphql.Field{
+					Type: graphql.Boolean,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return FeatureFlagsFromContext(p.Context).Enabled("beta"), nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	h := New(&Config{
+		Schema: &schema,
+		FeatureFlagsFn: func(ctx context.Context, r *http.Request) FeatureFlags {
+			return FeatureFlags{"beta": r.Header.Get("X-Client") == "internal"}
+		},
+		FeatureFlagsExtension: true,
+	})
+
+	req, _ := http.NewRequest("GET", "/graphql?query={beta}", nil)
+	req.Header.Set("X-Client", "internal")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	var body struct {
+		Data       map[string]interface{} `json:"data"`
+		Extensions map[string]interface{} `json:"extensions"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	if body.Data["beta"] != true {
+		t.Fatalf("expected beta to be enabled in context, got %v", body.Data)
+	}
+	flags, _ := body.Extensions["featureFlags"].(map[string]interface{})
+	if flags["beta"] != true {
+		t.Fatalf("expected featureFlags extension, got %v", body.Extensions)
+	}
+}
+
+func TestHandler_FeatureGates(t *testing.T) {
+	h := New(&Config{
+		Schema: newSubscriptionSchema(t),
+		FeatureFlagsFn: func(ctx context.Context, r *http.Request) FeatureFlags {
+			return FeatureFlags{"ws": r.Header.Get("X-Client") == "internal"}
+		},
+		FeatureGates: map[HandlerFeature]string{FeatureSubscriptions: "ws"},
+	})
+
+	ctx := h.withFeatureFlags(context.Background(), httptest.NewRequest("GET", "/graphql", nil))
+	if h.featureEnabled(ctx, FeatureSubscriptions) {
+		t.Fatalf("expected subscriptions to be gated off")
+	}
+	req := httptest.NewRequest("GET", "/graphql", nil)
+	req.Header.Set("X-Client", "internal")
+	if !h.featureEnabled(h.withFeatureFlags(context.Background(), req), FeatureSubscriptions) {
+		t.Fatalf("expected subscriptions to be enabled by the flag")
+	}
+}
+
+func TestHandler_FeatureGatesLimits(t *testing.T) {
+	h := New(&Config{
+		Schema:        &testutil.StarWarsSchema,
+		MaxAliases:    1,
+		MaxComplexity: 1,
+		FeatureFlagsFn: func(ctx context.Context, r *http.Request) FeatureFlags {
+			return FeatureFlags{"limits": r.Header.Get("X-Client") == "internal"}
+		},
+		FeatureGates: map[HandlerFeature]string{FeatureOperationLimits: "limits", FeatureCostLimit: "limits"},
+	})
+	query := func(client, query string) string {
+		req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape(query), nil)
+		req.Header.Set("X-Client", client)
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp.Body.String()
+	}
+
+	for _, q := range []string{`{ a: hero { name } b: hero { name } }`, `{ hero { name friends { name } } }`} {
+		if body := query("", q); strings.Contains(body, "errors") {
+			t.Errorf("expected the limits to be gated off, got %s", body)
+		}
+		if body := query("internal", q); !strings.Contains(body, string(CodeQueryTooComplex)) {
+			t.Errorf("expected the limits to apply with the flag, got %s", body)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+)
+
+// AuthDirective declares the @auth(requires: [String!]!) directive, to be
+// added to the schema Directives to document the Config.FieldAuth
+// requirements: graphql-go field definitions cannot carry directives, so the
+// requirements are declared by field coordinate.
+var AuthDirective = graphql.NewDirective(graphql.DirectiveConfig{
+	Name:        "auth",
+	Description: "Restricts the field to the principals holding the required roles.",
+	Locations:   []string{graphql.DirectiveLocationFieldDefinition},
+	Args: graphql.FieldConfigArgument{
+		"requires": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(graphql.String)))},
+	},
+})
+
+// FieldAuthFn reports whether the request holds all the required roles of a
+// field.
+type FieldAuthFn func(ctx context.Context, requires []string) bool
+
+// RoleHolder is implemented by the principals holding roles.
+type RoleHolder interface {
+	HasRole(role string) bool
+}
+
+type fieldAuthError struct {
+	coordinate string
+}
+
+func (e *fieldAuthError) Error() string {
+	return "Not authorized to access " + e.coordinate
+}
+
+func (e *fieldAuthError) Extensions() map[string]interface{} {
+	return map[string]interface{}{"code": CodeForbidden}
+}
+
+type fieldAuth struct {
+	requires map[string][]string
+	fn       FieldAuthFn
+	mu       sync.Mutex
+	wrapped  map[*graphql.FieldDefinition]bool
+}
+
+func newFieldAuth(requires map[string][]string, fn FieldAuthFn) *fieldAuth {
+	if len(requires) == 0 {
+		return nil
+	}
+	if fn == nil {
+		fn = principalHasRoles
+	}
+	return &fieldAuth{requires: requires, fn: fn, wrapped: make(map[*graphql.FieldDefinition]bool)}
+}
+
+// apply wraps the resolvers of the fields with requirements, those declared
+// on an interface applying to its implementations, so that they resolve to
+// null with an error for the requests without the required roles.
+func (a *fieldAuth) apply(schema *graphql.Schema) {
+	if schema == nil {
+		return
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for coordinate, requires := range a.requires {
+		i := strings.Index(coordinate, ".")
+		if i < 0 {
+			continue
+		}
+		typeName, fieldName := coordinate[:i], coordinate[i+1:]
+		var objects []*graphql.Object
+		switch t := schema.Type(typeName).(type) {
+		case *graphql.Object:
+			objects = append(objects, t)
+		case *graphql.Interface:
+			objects = append(objects, schema.PossibleTypes(t)...)
+		}
+		for _, object := range objects {
+			if def, ok := object.Fields()[fieldName]; ok {
+				a.wrap(def, object.Name()+"."+fieldName, requires)
+			}
+		}
+	}
+}
+
+func (a *fieldAuth) wrap(def *graphql.FieldDefinition, coordinate string, requires []string) {
+	if a.wrapped[def] {
+		return
+	}
+	a.wrapped[def] = true
+	resolve := def.Resolve
+	if resolve == nil {
+		resolve = graphql.DefaultResolveFn
+	}
+	def.Resolve = func(p graphql.ResolveParams) (interface{}, error) {
+		if !a.fn(p.Context, requires) {
+			return nil, &fieldAuthError{coordinate: coordinate}
+		}
+		return resolve(p)
+	}
+}
+
+// principalHasRoles is the default FieldAuthFn, reading the roles of the
+// Config.APIKeyFn principal, a RoleHolder or a []string, or else those of the
+// "roles" or "scope" claim of the JWT.
+func principalHasRoles(ctx context.Context, requires []string) bool {
+	has := func(string) bool { return false }
+	if principal, ok := PrincipalFromContext(ctx); ok {
+		switch principal := principal.(type) {
+		case RoleHolder:
+			has = principal.HasRole
+		case []string:
+			has = func(role string) bool { return containsString(principal, role) }
+		}
+	} else if claims, ok := JWTClaimsFromContext(ctx); ok {
+		var roles []string
+		if list, ok := claims["roles"].([]interface{}); ok {
+			for _, role := range list {
+				if role, ok := role.(string); ok {
+					roles = append(roles, role)
+				}
+			}
+		} else if scope, ok := claims["scope"].(string); ok {
+			roles = strings.Fields(scope)
+		}
+		has = func(role string) bool { return containsString(roles, role) }
+	}
+	for _, role := range requires {
+		if !has(role) {
+			return false
+		}
+	}
+	return true
+}
+
+func containsString(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,76 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_FieldAuth(t *testing.T) {
+	user := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"name":  &graphql.Field{Type: graphql.String},
+			"email": &graphql.Field{Type: graphql.String},
+		},
+	})
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"me": &graphql.Field{
+					Type: user,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return map[string]interface{}{"name": "Alice", "email": "alice@example.com"}, nil
+					},
+				},
+			},
+		}),
+		Directives: append(graphql.SpecifiedDirectives, AuthDirective),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := New(&Config{
+		Schema:    &schema,
+		FieldAuth: map[string][]string{"User.email": {"admin"}},
+		APIKeyFn: StaticAPIKeys{
+			"admin": []string{"admin"},
+			"user":  []string{"user"},
+		}.Authenticate,
+	})
+	// swapping the schema does not wrap the resolvers twice
+	h.SetSchema(&schema)
+
+	query := func(key string) string {
+		req, _ := http.NewRequest("GET", "/graphql?query={me{name+email}}", nil)
+		req.Header.Set(APIKeyHeader, key)
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp.Body.String()
+	}
+	if body := query("admin"); body != `{"data":{"me":{"email":"alice@example.com","name":"Alice"}}}` {
+		t.Errorf("unexpected response %s", body)
+	}
+	expected := `{"data":{"me":{"email":null,"name":"Alice"}},"errors":[{"message":"Not authorized to access User.email","locations":[{"line":1,"column":10}],"path":["me","email"],"extensions":{"code":"FORBIDDEN"}}]}`
+	if body := query("user"); body != expected {
+		t.Errorf("unexpected response %s", body)
+	}
+}
+
+func TestPrincipalHasRoles_JWTClaims(t *testing.T) {
+	ctx := context.WithValue(context.Background(), jwtClaimsKey{}, JWTClaims{"scope": "read write"})
+	if !principalHasRoles(ctx, []string{"read", "write"}) || principalHasRoles(ctx, []string{"admin"}) {
+		t.Errorf("unexpected roles from the scope claim")
+	}
+	ctx = context.WithValue(context.Background(), jwtClaimsKey{}, JWTClaims{"roles": []interface{}{"admin"}})
+	if !principalHasRoles(ctx, []string{"admin"}) {
+		t.Errorf("unexpected roles from the roles claim")
+	}
+	if principalHasRoles(context.Background(), []string{"admin"}) || !principalHasRoles(context.Background(), nil) {
+		t.Errorf("unexpected roles without principal")
+	}
+}
@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// setGetHint sets the Content-Location of the responses to queries sent with
+// POST to the equivalent GET URL, referencing the query by its hash when
+// persisted, when Config.GetHint is set.
+func (h *Handler) setGetHint(w http.ResponseWriter, r *http.Request, op *operation, opts *RequestOptions) {
+	if !h.getHint || r.Method != http.MethodPost || op == nil || op.Type() != ast.OperationTypeQuery {
+		return
+	}
+	if location, ok := h.getURL(r, opts); ok {
+		w.Header().Set("Content-Location", location)
+	}
+}
+
+// getURL returns the GET URL executing the same operation as the request.
+func (h *Handler) getURL(r *http.Request, opts *RequestOptions) (string, bool) {
+	values := url.Values{}
+	sum := sha256.Sum256([]byte(opts.Query))
+	hash := hex.EncodeToString(sum[:])
+	if entry, ok := h.persistedQueries.get(hash); ok && entry.query == opts.Query {
+		extensions, err := json.Marshal(map[string]interface{}{
+			"persistedQuery": map[string]interface{}{"version": 1, "sha256Hash": hash},
+		})
+		if err != nil {
+			return "", false
+		}
+		values.Set("extensions", string(extensions))
+	} else {
+		values.Set("query", opts.Query)
+	}
+	if opts.OperationName != "" {
+		values.Set("operationName", opts.OperationName)
+	}
+	if len(opts.Variables) > 0 {
+		// maps are marshaled with sorted keys
+		variables, err := json.Marshal(opts.Variables)
+		if err != nil {
+			return "", false
+		}
+		values.Set("variables", string(variables))
+	}
+	return r.URL.Path + "?" + values.Encode(), true
+}
@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_GetHint(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema, GetHint: true})
+	post := func(body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+
+	query := "query Hero($episode: Episode) { hero(episode: $episode) { name } }"
+	resp := post(`{"query":"` + query + `","operationName":"Hero","variables":{"episode":"JEDI"}}`)
+	location := resp.Header().Get("Content-Location")
+	parsed, err := url.Parse(location)
+	if err != nil || parsed.Path != "/graphql" || parsed.Query().Get("query") != query || parsed.Query().Get("variables") != `{"episode":"JEDI"}` {
+		t.Fatalf("unexpected Content-Location %q", location)
+	}
+
+	req, _ := http.NewRequest("GET", location, nil)
+	getResp := httptest.NewRecorder()
+	h.ServeHTTP(getResp, req)
+	if getResp.Body.String() != resp.Body.String() {
+		t.Errorf("expected the GET URL to return %s, got %s", resp.Body.String(), getResp.Body.String())
+	}
+
+	sum := sha256.Sum256([]byte(query))
+	hash := hex.EncodeToString(sum[:])
+	resp = post(`{"query":"` + query + `","extensions":{"persistedQuery":{"version":1,"sha256Hash":"` + hash + `"}}}`)
+	parsed, _ = url.Parse(resp.Header().Get("Content-Location"))
+	if parsed.Query().Get("query") != "" || !strings.Contains(parsed.Query().Get("extensions"), hash) {
+		t.Errorf("expected the persisted query hash, got %q", resp.Header().Get("Content-Location"))
+	}
+
+	if resp := post(`{"query":"mutation { hero { name } }"}`); resp.Header().Get("Content-Location") != "" {
+		t.Errorf("expected no hint for mutations")
+	}
+}
@@ -3,6 +3,7 @@ module github.com/alanleite/go-graphql-handler
 go 1.13
 
 require (
-	github.com/graphql-go/graphql v0.7.8
+	github.com/gorilla/websocket v1.5.3
+	github.com/graphql-go/graphql v0.8.1
 	github.com/graphql-go/handler v0.2.3
 )
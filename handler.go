@@ -2,16 +2,21 @@ package handler
 
 import (
 	"encoding/json"
+	"html/template"
 	"io/ioutil"
 	"net/http"
 	"net/url"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/gorilla/websocket"
 	"github.com/graphql-go/graphql"
 
 	"context"
 
 	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
 )
 
 const (
@@ -23,13 +28,143 @@ const (
 type ResultCallbackFn func(ctx context.Context, params *graphql.Params, result *graphql.Result, responseBody []byte)
 
 type Handler struct {
-	Schema           *graphql.Schema
-	pretty           bool
-	graphiql         bool
-	playground       bool
-	rootObjectFn     RootObjectFn
-	resultCallbackFn ResultCallbackFn
-	formatErrorFn    func(err error) gqlerrors.FormattedError
+	Schema                       *graphql.Schema
+	pretty                       bool
+	graphiql                     bool
+	playground                   bool
+	rootObjectFn                 RootObjectFn
+	resultCallbackFn             ResultCallbackFn
+	formatErrorFn                func(err error) gqlerrors.FormattedError
+	personalDataFields           map[string]bool
+	dataAccessSink               DataAccessSink
+	dataAccessActorFn            DataAccessActorFn
+	dataAccessSubjectVariables   []string
+	subscriptions                bool
+	legacySubscriptions          bool
+	featureFlagsFn               FeatureFlagsFn
+	featureFlagsExtension        bool
+	featureGates                 map[HandlerFeature]string
+	shadowConfig                 *ShadowConfig
+	shadowSlots                  chan struct{}
+	onWebSocketInit              OnWebSocketInitFn
+	experiments                  []Experiment
+	experimentClientIDFn         ExperimentClientIDFn
+	experimentsExtension         bool
+	wsPingInterval               time.Duration
+	wsPongTimeout                time.Duration
+	wsConnections                *connectionLimiter
+	incrementalDelivery          bool
+	persistedQueries             *persistedQueryCache
+	cacheBroadcaster             CacheBroadcaster
+	cacheSubscription            context.CancelFunc
+	instanceID                   string
+	responseCache                *responseCache
+	responseCacheKeyFn           ResponseCacheKeyFn
+	cachePurgeAuthFn             CachePurgeAuthFn
+	statusCodes                  bool
+	allowAnyMethod               bool
+	strictContentType            bool
+	resultPatches                *resultStore
+	getMutationsGraphQLError     bool
+	maxDecompressedBodySize      int64
+	maxBodyBytes                 int64
+	responseCharset              string
+	transcodeFn                  TranscodeFn
+	compression                  *CompressionConfig
+	responseEncoders             map[string]ResponseEncoder
+	passThroughHeaders           []string
+	corsConfig                   *CORSConfig
+	statusPage                   bool
+	statusPageTemplate           *template.Template
+	optionsHeaders               http.Header
+	crawlerBlocking              bool
+	crawlerUserAgents            []string
+	subjectIDFn                  SubjectIDFn
+	subjectMutations             *subjectLimiter
+	requests                     *fairLimiter
+	clientIDFn                   ClientIDFn
+	slos                         *sloTracker
+	sloBurnFn                    SLOBurnFn
+	serverTiming                 bool
+	inFlight                     *inFlightRequests
+	inFlightAuthFn               InFlightAuthFn
+	responseSerializer           ResponseSerializer
+	baggageKeys                  map[string]bool
+	requestErrorsWithoutData     bool
+	webSocketOperations          bool
+	costAnalysis                 *costAnalysis
+	schemaMu                     sync.RWMutex
+	schemaWebhookURLs            []string
+	schemaWebhookClient          *http.Client
+	maxAliases                   int
+	janitor                      *janitor
+	maxRootFields                int
+	maxSelections                int
+	bodyReadTimeout              time.Duration
+	bodyReads                    chan struct{}
+	maxQueryBytes                int
+	maxQueryTokens               int
+	disableIntrospection         bool
+	introspectionAllowedFn       IntrospectionAllowedFn
+	hideSuggestions              bool
+	knownExtensions              map[string]bool
+	unknownExtensions            UnknownExtensionsPolicy
+	unknownExtensionsFn          UnknownExtensionsFn
+	allowedOperations            map[string]bool
+	allowedOperationFn           AllowedOperationFn
+	estimateAuthFn               EstimateAuthFn
+	readOnly                     int32
+	readOnlyMessage              string
+	readOnlyRejectsSubscriptions bool
+	getHint                      bool
+	rateLimiter                  RateLimiter
+	rateLimitKeyFn               RateLimitKeyFn
+	operationSlots               *operationSlots
+	latencies                    *latencyHistory
+	circuitBreaker               *circuitBreaker
+	eventEmitter                 EventEmitter
+	globalOperationTimeout       time.Duration
+	operationTimeouts            map[string]time.Duration
+	operationTimeoutFn           OperationTimeoutFn
+	csrfPrevention               bool
+	csrfRequiredHeaders          []string
+	typeUsage                    *typeUsageStats
+	jwt                          *jwtVerifier
+	lenientParsing               bool
+	legacyNormalizationFn        LegacyNormalizationFn
+	apiKeyFn                     APIKeyFn
+	authorizeFn                  AuthorizeFn
+	fieldAuth                    *fieldAuth
+	maskInternalErrors           bool
+	internalErrorFn              InternalErrorFn
+	debugErrors                  *debugErrors
+	maxVariablesBytes            int
+	maxVariablesKeys             int
+	maxVariablesDepth            int
+	maxResponseBytes             int
+	globalCircuit                *circuitBreaker
+	idempotencyStore             IdempotencyStore
+	idempotencyWindow            time.Duration
+	clientPolicy                 *ClientPolicy
+	ipPolicyFn                   IPPolicyFn
+	requestSignature             *RequestSignature
+	auditLog                     *auditLog
+	metrics                      *Metrics
+	tracer                       Tracer
+	meter                        *meterInstruments
+	traceResolvers               bool
+	resolverTimingFn             ResolverTimingFn
+	apolloTracing                bool
+	apolloTracingHeader          string
+	usageReporter                *usageReporter
+	metricsEmitter               MetricsEmitter
+	logger                       Logger
+	requestID                    bool
+	requestIDHeader              string
+	requestIDFn                  func() string
+	clientNameHeader             string
+	clientVersionHeader          string
+	clientLabels                 *clientLabels
 }
 
 type RequestOptions struct {
@@ -43,116 +178,279 @@ type RequestOptions struct {
 
 // a workaround for getting`variables` as a JSON string
 type requestOptionsCompatibility struct {
-	Query         string                 `json:"query" url:"query" schema:"query"`
-	Variables     string                 `json:"variables" url:"variables" schema:"variables"`
-	OperationName string                 `json:"operationName" url:"operationName" schema:"operationName"`
-	Extensions    map[string]interface{} `json:"extensions" url:"extensions" schema:"extensions"`
+	RequestOptions
+	Variables json.RawMessage `json:"variables"`
 }
 
-func getFromForm(values url.Values) *RequestOptions {
+func getFromForm(values url.Values) (*RequestOptions, *requestError) {
 	query := values.Get("query")
-	variablesStr := values.Get("variables")
 	extensionsStr := values.Get("extensions")
 
-	variables := make(map[string]interface{}, len(values))
-	json.Unmarshal([]byte(variablesStr), &variables)
-
-	extensions := make(map[string]interface{}, len(values))
-	json.Unmarshal([]byte(extensionsStr), &extensions)
+	// persisted queries may be sent with the extensions only
+	if query == "" && extensionsStr == "" {
+		return nil, nil
+	}
 
-	return &RequestOptions{
+	opts := &RequestOptions{
 		Query:         query,
-		Variables:     variables,
+		Variables:     make(map[string]interface{}, len(values)),
 		OperationName: values.Get("operationName"),
-		Extensions:    extensions,
 	}
+	err := formJSON(values, "variables", &opts.Variables)
+	if extensionsErr := formJSON(values, "extensions", &opts.Extensions); err == nil {
+		err = extensionsErr
+	}
+	return opts, err
+}
+
+// formJSON decodes the JSON parameter of a form, when present, returning a
+// request error when it is not valid.
+func formJSON(values url.Values, name string, v interface{}) *requestError {
+	value := values.Get(name)
+	if value == "" {
+		return nil
+	}
+	if err := json.Unmarshal([]byte(value), v); err != nil {
+		return newRequestError(http.StatusBadRequest, CodeInvalidRequest, "Invalid "+name+" parameter: "+err.Error())
+	}
+	return nil
 }
 
 // RequestOptions Parses a http.Request into GraphQL request options struct
 func NewRequestOptions(r *http.Request) *RequestOptions {
+	opts, _ := parseRequestOptions(r)
+	return opts
+}
 
-	reqOpt := getFromForm(r.URL.Query())
+// parseRequestOptions parses the request like NewRequestOptions, also
+// reporting the query string and form parameters which are not valid JSON.
+func parseRequestOptions(r *http.Request) (*RequestOptions, *requestError) {
+	contentType := requestContentType(r)
 
-	if r.Method != "POST" && reqOpt != nil {
-		return reqOpt
+	if reqOpt, reqErr := getFromForm(r.URL.Query()); reqOpt != nil {
+		// a GraphQL body holds the query the other parameters go with
+		if reqOpt.Query != "" || r.Method != http.MethodPost || r.Body == nil || contentType != ContentTypeGraphQL {
+			return reqOpt, reqErr
+		}
 	}
 
 	if r.Method != http.MethodPost {
-		return &RequestOptions{}
+		return &RequestOptions{}, nil
 	}
 
 	if r.Body == nil {
-		return &RequestOptions{}
+		return &RequestOptions{}, nil
 	}
 
-	// TODO: improve Content-Type handling
-	contentTypeStr := r.Header.Get("Content-Type")
-	contentTypeTokens := strings.Split(contentTypeStr, ";")
-	contentType := contentTypeTokens[0]
-
 	switch contentType {
 	case ContentTypeGraphQL:
 		body, err := ioutil.ReadAll(r.Body)
 		if err != nil {
-			return &RequestOptions{}
+			return &RequestOptions{}, nil
 		}
-		return &RequestOptions{
-			Query: string(body),
+		// the other parameters may be sent in the query string
+		values := r.URL.Query()
+		opts := &RequestOptions{
+			Query:         string(body),
+			OperationName: values.Get("operationName"),
 		}
+		reqErr := formJSON(values, "variables", &opts.Variables)
+		if extensionsErr := formJSON(values, "extensions", &opts.Extensions); reqErr == nil {
+			reqErr = extensionsErr
+		}
+		return opts, reqErr
 	case ContentTypeFormURLEncoded:
 		if err := r.ParseForm(); err != nil {
-			return &RequestOptions{}
+			return &RequestOptions{}, nil
 		}
 
-		if reqOpt := getFromForm(r.PostForm); reqOpt != nil {
-			return reqOpt
+		if reqOpt, reqErr := getFromForm(r.PostForm); reqOpt != nil {
+			return reqOpt, reqErr
 		}
 
-		return &RequestOptions{}
+		return &RequestOptions{}, nil
 
 	case ContentTypeJSON:
 		fallthrough
 	default:
-		var opts RequestOptions
-		body, err := ioutil.ReadAll(r.Body)
-		if err != nil {
-			return &opts
-		}
-		err = json.Unmarshal(body, &opts)
-		if err != nil {
+		// decoded as a stream from the body, which Config.MaxBodyBytes
+		// limits, the handler answering 413 when the decoder hits the limit
+		var optsCompatible requestOptionsCompatibility
+		json.NewDecoder(r.Body).Decode(&optsCompatible)
+		opts := optsCompatible.RequestOptions
+		variables := []byte(optsCompatible.Variables)
+		if len(variables) > 0 && variables[0] == '"' {
 			// Probably `variables` was sent as a string instead of an object.
 			// So, we try to be polite and try to parse that as a JSON string
-			var optsCompatible requestOptionsCompatibility
-			json.Unmarshal(body, &optsCompatible)
-			json.Unmarshal([]byte(optsCompatible.Variables), &opts.Variables)
+			var variablesStr string
+			json.Unmarshal(variables, &variablesStr)
+			variables = []byte(variablesStr)
 		}
-		return &opts
+		json.Unmarshal(variables, &opts.Variables)
+		return &opts, nil
 	}
 }
 
 // ContextHandler provides an entrypoint into executing graphQL queries with a
 // user-provided context.
 func (h *Handler) ContextHandler(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	if h.eventEmitter != nil || h.logger != nil {
+		defer func() {
+			if r := recover(); r != nil {
+				h.emitPanic(ctx, r)
+				panic(r)
+			}
+		}()
+	}
+
+	ctx, r = h.withRequestID(ctx, w, r)
+	ctx = h.withClientInfo(ctx, r)
+	ctx, w, observed, finish := h.observe(ctx, w, r)
+	defer finish()
+
+	if reqErr := h.checkIPPolicy(r); reqErr != nil {
+		h.writeRequestError(w, r, reqErr)
+		return
+	}
+
+	if h.blockCrawlers(w, r) {
+		return
+	}
+
+	if h.cors(w, r) {
+		return
+	}
+
+	if r.Method == http.MethodOptions {
+		h.options(w)
+		return
+	}
+
+	ctx = h.withFeatureFlags(ctx, r)
+	ctx = h.withExperiments(ctx, r)
+	ctx = h.withPassThroughHeaders(ctx, w, r)
+	ctx = h.withBaggage(ctx, r)
+	ctx = withMediaType(ctx, r)
+
+	if h.subscriptions && h.featureEnabled(ctx, FeatureSubscriptions) && websocket.IsWebSocketUpgrade(r) {
+		h.serveWebSocket(ctx, w, r)
+		return
+	}
+
+	if !h.allowAnyMethod && !allowedMethod(r.Method) {
+		w.Header().Set("Allow", allowedMethods)
+		h.writeRequestError(w, r, newRequestError(http.StatusMethodNotAllowed, CodeMethodNotAllowed, "Method "+r.Method+" is not allowed"))
+		return
+	}
+
+	if reqErr := h.checkCSRF(r); reqErr != nil {
+		h.writeRequestError(w, r, reqErr)
+		return
+	}
+
+	ctx, reqErr := h.authenticateJWT(ctx, w, r)
+	if reqErr != nil {
+		h.writeRequestError(w, r, reqErr)
+		return
+	}
+
+	ctx, reqErr = h.authenticateAPIKey(ctx, r)
+	if reqErr != nil {
+		h.writeRequestError(w, r, reqErr)
+		return
+	}
+
+	if reqErr := h.checkClientPolicy(ctx, w, r); reqErr != nil {
+		h.writeRequestError(w, r, reqErr)
+		return
+	}
+
+	if h.requests != nil {
+		if !h.requests.acquire(ctx, h.clientID(ctx, r)) {
+			w.Header().Set("Retry-After", "1")
+			h.writeRequestError(w, r, newRequestError(http.StatusServiceUnavailable, CodeTooManyConcurrentRequests, "Too many concurrent requests"))
+			return
+		}
+		defer h.requests.release()
+	}
+
+	timing := h.newServerTiming(w)
+	parseStart := time.Now()
+	r, body, reqErr := h.limitBody(w, r)
+	if reqErr != nil {
+		h.writeRequestError(w, r, reqErr)
+		return
+	}
+	r, reqErr = h.bufferBody(ctx, r)
+	reqErr = body.check(reqErr)
+	if reqErr != nil {
+		if reqErr.status == http.StatusRequestTimeout {
+			w.Header().Set("Connection", "close")
+		}
+		h.writeRequestError(w, r, reqErr)
+		return
+	}
+
+	r, reqErr = h.verifySignature(r, time.Now())
+	reqErr = body.check(reqErr)
+	if reqErr != nil {
+		h.writeRequestError(w, r, reqErr)
+		return
+	}
+	r, reqErr = h.decompressBody(r)
+	reqErr = body.check(reqErr)
+	if reqErr != nil {
+		h.writeRequestError(w, r, reqErr)
+		return
+	}
+	r = h.normalizeLegacyRequest(ctx, r)
+
+	strict := h.strictStatusCodes(r)
+	if (strict || h.strictContentType) && !supportedContentType(r, h.strictContentType) {
+		h.writeRequestError(w, r, newRequestError(http.StatusUnsupportedMediaType, CodeUnsupportedMediaType, unsupportedContentTypeMessage(r)))
+		return
+	}
+
 	// get query
-	opts := NewRequestOptions(r)
+	opts, reqErr := parseRequestOptions(r)
+	reqErr = body.check(reqErr)
+	if reqErr != nil {
+		h.writeRequestError(w, r, reqErr)
+		return
+	}
+	if reqErr := h.checkVariables(opts.Variables); reqErr != nil {
+		h.writeRequestError(w, r, reqErr)
+		return
+	}
+	timing.add("parse", parseStart)
 
 	// persisted query implementation
-	opts, err := persistedQueryCheck(opts)
+	persistedStart := time.Now()
+	opts, err := persistedQueryCheck(h.persistedQueries, opts)
+	timing.add("persisted", persistedStart)
+	h.metrics.persistedQuery(opts, err)
 
+	if reqErr, ok := err.(*requestError); ok {
+		h.writeRequestError(w, r, reqErr)
+		return
+	}
 	if err != nil {
-		w.Header().Set("Content-Type", "application/json")
-		w.Write([]byte(err.Error()))
+		h.writeRequestError(w, r, newRequestError(http.StatusOK, CodePersistedQueryNotFound, "PersistedQueryNotFound"))
 		return
 	}
 
-	if r.Method == "OPTIONS" {
-		w.WriteHeader(204)
+	ctx, reqErr = h.checkExtensions(ctx, r, opts)
+	if reqErr != nil {
+		h.writeRequestError(w, r, reqErr)
 		return
 	}
 
+	ctx = h.withApolloTracing(ctx, r)
+	ctx, response := withResponse(ctx)
+
 	// execute graphql query
 	params := graphql.Params{
-		Schema:         *h.Schema,
+		Schema:         *h.schema(),
 		RequestString:  opts.Query,
 		VariableValues: opts.Variables,
 		OperationName:  opts.OperationName,
@@ -161,16 +459,147 @@ func (h *Handler) ContextHandler(ctx context.Context, w http.ResponseWriter, r *
 	if h.rootObjectFn != nil {
 		params.RootObject = h.rootObjectFn(ctx, r)
 	}
-	result := graphql.Do(params)
+	params.RootObject = withJWTClaims(ctx, params.RootObject)
+
+	if reqErr := h.checkDocument(opts.Query, strict); reqErr != nil {
+		h.writeRequestError(w, r, reqErr)
+		return
+	}
+
+	// parse ahead of execution to inspect the operation, errors are
+	// reported by graphql.Do
+	op, err := parseOperation(opts.Query, opts.OperationName)
+	if err != nil && opts.Persisted {
+		h.log(ctx, LevelWarn, "persisted query does not parse", "operation_name", opts.OperationName, "error", err.Error())
+	}
+	observed.setOperation(op)
+	observed.persisted = opts.Persisted
+
+	if status := requestStatus(r, op); status != 0 {
+		if h.getMutationsGraphQLError && !strict {
+			status = http.StatusOK
+		} else {
+			w.Header().Set("Allow", http.MethodPost)
+		}
+		h.writeRequestError(w, r, newRequestError(status, CodeMethodNotAllowed, op.Type()+" operations can only be sent with POST"))
+		return
+	}
+
+	if reqErr := h.checkRateLimit(ctx, w, r, op); reqErr != nil {
+		h.writeRequestError(w, r, reqErr)
+		return
+	}
+
+	if reqErr := h.checkReadOnly(op); reqErr != nil {
+		w.Header().Set("Retry-After", "60")
+		h.writeRequestError(w, r, reqErr)
+		return
+	}
+
+	if reqErr := h.checkAllowedOperation(ctx, r, op); reqErr != nil {
+		h.writeRequestError(w, r, reqErr)
+		return
+	}
+
+	if reqErr := h.checkAuthorization(ctx, r, opts); reqErr != nil {
+		h.writeRequestError(w, r, reqErr)
+		return
+	}
+
+	if reqErr := h.checkIntrospection(ctx, r, op, strict); reqErr != nil {
+		h.writeRequestError(w, r, reqErr)
+		return
+	}
+
+	if reqErr := h.checkLimits(op, strict); reqErr != nil && h.featureEnabled(ctx, FeatureOperationLimits) {
+		h.writeRequestError(w, r, reqErr)
+		return
+	}
 
-	if formatErrorFn := h.formatErrorFn; formatErrorFn != nil && len(result.Errors) > 0 {
-		formatted := make([]gqlerrors.FormattedError, len(result.Errors))
-		for i, formattedError := range result.Errors {
-			formatted[i] = formatErrorFn(formattedError.OriginalError())
+	cost, reqErr := h.checkCost(op, opts.Variables, strict)
+	if reqErr != nil && h.featureEnabled(ctx, FeatureCostLimit) {
+		h.writeRequestError(w, r, reqErr)
+		return
+	}
+
+	idempotency := h.idempotency(ctx, r, op, opts)
+	if idempotency != nil {
+		replayed, reqErr := idempotency.replay(ctx, h, w, r)
+		if reqErr != nil {
+			h.writeRequestError(w, r, reqErr)
+			return
+		}
+		if replayed {
+			return
+		}
+	}
+
+	if h.subjectMutations != nil && op != nil && op.Type() == ast.OperationTypeMutation && h.subjectIDFn != nil {
+		if subject := h.subjectIDFn(ctx); subject != "" {
+			if !h.subjectMutations.acquire(ctx, subject) {
+				h.writeRequestError(w, r, newRequestError(http.StatusTooManyRequests, CodeTooManyConcurrentMutations, "Too many concurrent mutations"))
+				return
+			}
+			defer h.subjectMutations.release(subject)
+		}
+	}
+
+	recordCircuit, reqErr := h.checkCircuit(w, op)
+	if reqErr != nil {
+		h.writeRequestError(w, r, reqErr)
+		return
+	}
+
+	release, reqErr := h.acquireOperationSlot(ctx, w)
+	if reqErr != nil {
+		h.writeRequestError(w, r, reqErr)
+		return
+	}
+	defer release()
+
+	ctx, done := h.trackInFlight(ctx, r, op)
+	defer done()
+	params.Context = ctx
+
+	if h.incrementalDelivery && op != nil && op.usesIncrementalDelivery() {
+		if op.Type() == ast.OperationTypeQuery && acceptsIncrementalDelivery(r) && h.featureEnabled(ctx, FeatureIncrementalDelivery) {
+			start := time.Now()
+			result, buff := h.executeIncremental(r, w, op, params, planIncremental(op, opts.Variables, true), strict)
+			observed.errors = len(result.Errors)
+			h.recordDataAccess(ctx, r, op, opts)
+			h.recordTypeUsage(op)
+			h.recordSLO(ctx, op, time.Since(start), result.HasErrors())
+			recordCircuit(circuitFailed(result))
+			if h.resultCallbackFn != nil {
+				h.resultCallbackFn(ctx, &params, result, buff)
+			}
+			return
 		}
-		result.Errors = formatted
+		// deliver the whole response at once
+		plan := planIncremental(op, opts.Variables, false)
+		params.RequestString = plan.query(plan.full)
 	}
 
+	start := time.Now()
+	result := h.executeQuery(r, op, params)
+	timing.add("execute", start)
+	recordCircuit(circuitFailed(result))
+	h.patchResult(op, opts, result)
+	if h.costAnalysis != nil {
+		setExtension(result, "cost", map[string]int{"requested": cost, "maximum": h.costAnalysis.max})
+	}
+	if tracing := apolloTracingFromContext(ctx); tracing != nil {
+		setExtension(result, "tracing", tracing.result())
+	}
+
+	h.recordDataAccess(ctx, r, op, opts)
+	h.recordTypeUsage(op)
+
+	h.finishResult(ctx, result)
+	h.countErrors(ctx, result)
+	observed.errors = len(result.Errors)
+	h.recordSLO(ctx, op, time.Since(start), result.HasErrors())
+
 	if h.graphiql {
 		acceptHeader := r.Header.Get("Accept")
 		_, raw := r.URL.Query()["raw"]
@@ -189,27 +618,104 @@ func (h *Handler) ContextHandler(ctx context.Context, w http.ResponseWriter, r *
 		}
 	}
 
-	// use proper JSON Header
-	w.Header().Add("Content-Type", "application/json; charset=utf-8")
+	if h.statusPage && prefersHTML(r) {
+		h.renderStatusPage(w, r, params, result)
+		return
+	}
+
+	h.setGetHint(w, r, op, opts)
+
+	status := http.StatusOK
+	if strict {
+		status = resultStatus(result)
+	}
+	status = response.writeHeader(w, status)
 
 	var buff []byte
-	if h.pretty {
-		w.WriteHeader(http.StatusOK)
-		buff, _ = json.MarshalIndent(result, "", "\t")
+	if len(h.responseEncoders) > 0 {
+		w.Header().Add("Vary", "Accept")
+	}
+	if mediaType, encoder := h.responseEncoder(r); encoder != nil {
+		if encoded, err := encodeResponse(encoder, result); err == nil {
+			w.Header().Add("Content-Type", mediaType)
+			buff = encoded
+		}
+	}
+	if buff == nil {
+		var contentType string
+		buff, contentType = h.serialize(r, result, JSONSerializer{
+			Pretty:       h.prettyResponse(r),
+			OmitNullData: isRequestError(result) && h.omitRequestErrorData(r),
+		})
+		w.Header().Add("Content-Type", contentType)
+	}
 
-		w.Write(buff)
-	} else {
-		w.WriteHeader(http.StatusOK)
-		buff, _ = json.Marshal(result)
+	if tooLarge, replaced, tooLargeStatus, contentType := h.guardResponseSize(ctx, r, strict, len(buff)); tooLarge != nil {
+		result, buff, status = tooLarge, replaced, tooLargeStatus
+		w.Header().Set("Content-Type", contentType)
+	}
 
-		w.Write(buff)
+	h.recordAudit(ctx, r, op, opts.Variables, status, result, time.Since(start))
+	if idempotency != nil {
+		idempotency.store(ctx, h, status, w.Header().Get("Content-Type"), buff)
 	}
 
+	h.writeBody(w, r, status, buff)
+
 	if h.resultCallbackFn != nil {
 		h.resultCallbackFn(ctx, &params, result, buff)
 	}
 }
 
+// finishResult adds the handler extensions to the result and formats its
+// errors.
+func (h *Handler) finishResult(ctx context.Context, result *graphql.Result) {
+	if flags := FeatureFlagsFromContext(ctx); h.featureFlagsExtension && flags != nil {
+		setExtension(result, "featureFlags", flags)
+	}
+	if buckets := ExperimentBucketsFromContext(ctx); h.experimentsExtension && len(buckets) > 0 {
+		setExtension(result, "experiments", buckets)
+	}
+
+	result.Errors = h.formatErrors(ctx, result.Errors)
+}
+
+// setExtension adds an entry to the extensions of the response.
+func setExtension(result *graphql.Result, key string, value interface{}) {
+	if result.Extensions == nil {
+		result.Extensions = make(map[string]interface{})
+	}
+	result.Extensions[key] = value
+}
+
+// formatErrors applies the FormatErrorFn, if any, to the result errors,
+// masks the internal ones and adds the debug extension when configured,
+// strips their suggestions when hidden and adds the request ID.
+func (h *Handler) formatErrors(ctx context.Context, errs []gqlerrors.FormattedError) []gqlerrors.FormattedError {
+	original := errs
+	internal := h.internalErrors(errs)
+	if (h.formatErrorFn != nil || len(internal) > 0 || h.debugErrors != nil) && len(errs) > 0 {
+		formatted := make([]gqlerrors.FormattedError, len(errs))
+		for i, formattedError := range errs {
+			if h.formatErrorFn != nil {
+				formatted[i] = h.formatErrorFn(formattedError.OriginalError())
+			} else {
+				formatted[i] = formattedError
+			}
+		}
+		errs = formatted
+	}
+	h.maskErrors(ctx, original, errs, internal)
+	if h.debugErrors != nil {
+		addDebugExtensions(original, errs)
+	}
+	if h.hideSuggestions {
+		errs = stripSuggestions(errs)
+	}
+	addRequestID(ctx, errs)
+	return errs
+}
+
 // ServeHTTP provides an entrypoint into executing graphQL queries.
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	h.ContextHandler(r.Context(), w, r)
@@ -226,6 +732,524 @@ type Config struct {
 	RootObjectFn     RootObjectFn
 	ResultCallbackFn ResultCallbackFn
 	FormatErrorFn    func(err error) gqlerrors.FormattedError
+
+	// StatusCodes answers request errors with the status codes of the
+	// GraphQL over HTTP specification: 400 for documents failing to parse or
+	// validate, 405 for mutations sent with GET, 415 for unsupported request
+	// bodies, 200 for executed operations. Clients accepting the
+	// application/graphql-response+json media type always get them.
+	StatusCodes bool
+
+	// AllowAnyMethod restores the legacy behavior of reading requests of any
+	// method, instead of answering 405 to methods other than GET, HEAD, POST
+	// and OPTIONS. HEAD requests are answered like GET ones, without body.
+	AllowAnyMethod bool
+
+	// StrictContentType answers 415 to POST requests with a missing or
+	// unrecognized Content-Type, instead of reading their body as JSON.
+	StrictContentType bool
+
+	// Mutations and subscriptions sent with GET are answered with 405.
+	// GetMutationsGraphQLError answers them with a GraphQL error and 200
+	// instead, unless StatusCodes apply.
+	GetMutationsGraphQLError bool
+
+	// MaxBodyBytes limits the size of the request bodies as sent, answering
+	// 413 to larger ones. MaxDecompressedBodySize limits the size of the
+	// gzip or deflate compressed request bodies once decompressed, 10 MB by
+	// default.
+	MaxBodyBytes            int64
+	MaxDecompressedBodySize int64
+
+	// ResultPatches experimentally reports the hash of query results in the
+	// "resultHash" extension, and remembers this many recent results. Polling
+	// clients sending the hash of their last result in the "resultPatch"
+	// request extension, as {"baseHash": hash}, get a JSON Patch against it
+	// in the "resultPatch" response extension instead of the data, when
+	// smaller.
+	ResultPatches int
+
+	// ResponseCharset is declared in the Content-Type of the JSON responses,
+	// utf-8 by default. ResponseTranscodeFn converts the responses to it,
+	// e.g. TranscodeLatin1 for iso-8859-1.
+	ResponseCharset     string
+	ResponseTranscodeFn TranscodeFn
+
+	// StatusPage answers the browsers, when no IDE is served, with a page
+	// describing the endpoint, rendered from StatusPageTemplate with a
+	// StatusPageData when set.
+	StatusPage         bool
+	StatusPageTemplate *template.Template
+
+	// CORS answers the requests of browsers from other origins, including
+	// the preflight ones.
+	CORS *CORSConfig
+
+	// MaxConcurrentRequests bounds the requests executed concurrently. Up to
+	// MaxQueuedRequests requests over the bound wait for their turn, without
+	// limit when zero, queued per client identified by ClientIDFn so that
+	// each client is served in turn. The other ones are answered with 503.
+	MaxConcurrentRequests int
+	MaxQueuedRequests     int
+	ClientIDFn            ClientIDFn
+
+	// MaxConcurrentMutationsPerSubject bounds the mutations executed
+	// concurrently on behalf of the subject identified by SubjectIDFn, 1
+	// serializing them. Mutations over the bound are answered with 429, or
+	// wait for their turn when QueueSubjectMutations is set.
+	SubjectIDFn                      SubjectIDFn
+	MaxConcurrentMutationsPerSubject int
+	QueueSubjectMutations            bool
+
+	// BlockCrawlers adds an "X-Robots-Tag: noindex, nofollow" header to the
+	// responses, including the IDE pages, and answers 403 to the user agents
+	// containing one of CrawlerUserAgents, which defaults to common crawlers.
+	BlockCrawlers     bool
+	CrawlerUserAgents []string
+
+	// OptionsHeaders are added to the 204 responses of the OPTIONS requests,
+	// besides the Allow header.
+	OptionsHeaders http.Header
+
+	// PassThroughHeaders lists request headers, e.g. correlation IDs, echoed
+	// in the response and available with PassThroughHeadersFromContext.
+	PassThroughHeaders []string
+
+	// Compression compresses the responses, see CompressionConfig.
+	Compression *CompressionConfig
+
+	// ResponseEncoders encodes the responses of the clients preferring one of
+	// the media types over JSON in their Accept header, e.g.
+	// ContentTypeMessagePack with EncodeMessagePack or ContentTypeCBOR with
+	// EncodeCBOR.
+	ResponseEncoders map[string]ResponseEncoder
+
+	// PersonalDataFields lists the "Type.field" coordinates holding personal
+	// data. Executed operations and subscriptions selecting any of them, also
+	// through an interface or union, are reported to DataAccessSink, with the
+	// actor from DataAccessActorFn and the values of the
+	// DataAccessSubjectVariables as subject IDs.
+	PersonalDataFields         []string
+	DataAccessSink             DataAccessSink
+	DataAccessActorFn          DataAccessActorFn
+	DataAccessSubjectVariables []string
+
+	// Subscriptions serves subscription operations to WebSocket upgrade
+	// requests using the graphql-transport-ws subprotocol, and the legacy
+	// graphql-ws subprotocol too when LegacySubscriptionsProtocol is set.
+	Subscriptions               bool
+	LegacySubscriptionsProtocol bool
+
+	// OnWebSocketInit validates the connection_init payload of WebSocket
+	// connections, e.g. an auth token, see OnWebSocketInitFn.
+	OnWebSocketInit OnWebSocketInitFn
+
+	// WebSocketPingInterval sends ping frames to WebSocket clients, closing
+	// the connections that don't answer within WebSocketPongTimeout.
+	// WebSocketMaxConnections and WebSocketMaxConnectionsPerIP bound the
+	// number of concurrent connections, zero meaning no limit.
+	WebSocketPingInterval        time.Duration
+	WebSocketPongTimeout         time.Duration
+	WebSocketMaxConnections      int
+	WebSocketMaxConnectionsPerIP int
+
+	// IncrementalDelivery answers queries using @defer or @stream with
+	// multipart/mixed responses when the client accepts them with
+	// deferSpec=20220824. Other clients receive the whole response at once.
+	// This is a buffered emulation: the whole operation executes before the
+	// first part is written, then its result is split into parts. The
+	// directives shape the response but do not make the initial part
+	// faster, and all errors are reported with it.
+	IncrementalDelivery bool
+
+	// FeatureFlagsFn evaluates per request flags, available to resolvers with
+	// FeatureFlagsFromContext and reported in the "featureFlags" response
+	// extension when FeatureFlagsExtension is set. FeatureGates only enables
+	// the listed handler features for requests having the mapped flag on.
+	FeatureFlagsFn        FeatureFlagsFn
+	FeatureFlagsExtension bool
+	FeatureGates          map[HandlerFeature]string
+
+	// Shadow re-executes a sample of queries against a second schema and
+	// reports the differences, see ShadowConfig.
+	Shadow *ShadowConfig
+
+	// Experiments assigns the clients identified by ExperimentClientIDFn to
+	// experiment buckets, available with ExperimentBucketFromContext and
+	// reported in the "experiments" response extension when
+	// ExperimentsExtension is set.
+	Experiments          []Experiment
+	ExperimentClientIDFn ExperimentClientIDFn
+	ExperimentsExtension bool
+
+	// ResponseCacheTTL enables caching the responses of the queries executed
+	// without errors for this duration, within the scope ResponseCacheKeyFn
+	// returns; nothing is cached without it. At most ResponseCacheMaxEntries
+	// responses are kept, 10000 by default, evicting the least recently used.
+	// For ResponseCacheStaleIfError after expiring, they are served in place
+	// of responses with errors, marked with the "servedStale" extension.
+	// Responses hit ResponseCacheRefreshHits times are refreshed in the
+	// background ResponseCacheRefreshAhead before expiring, with the context
+	// values of the request triggering the refresh.
+	// CachePurgeAuthFn authorizes the requests to PurgeHandler.
+	ResponseCacheTTL          time.Duration
+	ResponseCacheKeyFn        ResponseCacheKeyFn
+	ResponseCacheMaxEntries   int
+	ResponseCacheStaleIfError time.Duration
+	ResponseCacheRefreshAhead time.Duration
+	ResponseCacheRefreshHits  int
+	CachePurgeAuthFn          CachePurgeAuthFn
+
+	// PersistedOperations are executable by their ID as persisted queries,
+	// with their default variables, see ReadPersistedOperationsManifest.
+	PersistedOperations []PersistedOperation
+
+	// CacheBroadcaster propagates persisted query registrations and cache
+	// purges to the other instances of the service. The handler subscribes
+	// to their changes until Handler.Close.
+	CacheBroadcaster CacheBroadcaster
+
+	// SLOs declares the service level objectives of operations by name.
+	// SLOBurnFn is called when one of them burns its error budget at its
+	// BurnRateThreshold or faster.
+	SLOs      map[string]SLO
+	SLOBurnFn SLOBurnFn
+
+	// ServerTiming reports the durations of parsing the request, looking up
+	// persisted queries and executing the operation in the Server-Timing
+	// response header, as the "parse", "persisted" and "execute" metrics.
+	ServerTiming bool
+
+	// TrackInFlightRequests lists the operations being executed in
+	// InFlightRequests, and lets CancelInFlightRequest cancel them.
+	// InFlightAuthFn authorizes the requests to InFlightHandler.
+	TrackInFlightRequests bool
+	InFlightAuthFn        InFlightAuthFn
+
+	// ResponseSerializer writes the bodies of the responses in place of the
+	// default JSONSerializer, except those of the ResponseEncoders.
+	ResponseSerializer ResponseSerializer
+
+	// BaggageKeys lists the keys of the W3C Baggage request header entries
+	// available with BaggageFromContext and BaggageValue.
+	BaggageKeys []string
+
+	// RequestErrorsWithoutData leaves the data entry out of the responses to
+	// the requests failing before execution, as the spec requires. It is
+	// always the case with strict status codes.
+	RequestErrorsWithoutData bool
+
+	// WebSocketOperations executes queries and mutations sent over the
+	// WebSocket transport too, besides subscriptions, so that clients can
+	// multiplex all their operations over a single connection.
+	WebSocketOperations bool
+
+	// MaxComplexity rejects the operations costing more, before executing
+	// them, and adds their cost to the response extensions. Every field
+	// costs 1 unless listed in FieldCosts, keyed by "Type.field", and the
+	// cost of the selections of a field is multiplied by the value of its
+	// first CostMultiplierArguments argument, "first", "last" or "limit"
+	// by default.
+	MaxComplexity           int
+	FieldCosts              map[string]int
+	CostMultiplierArguments []string
+
+	// SchemaWebhookURLs are posted the SchemaChange in JSON when the schema
+	// is swapped with Handler.SetSchema, using SchemaWebhookClient or a
+	// client with a 10 seconds timeout.
+	SchemaWebhookURLs   []string
+	SchemaWebhookClient *http.Client
+
+	// MaxAliases rejects the operations selecting more aliased fields,
+	// counting the fields of fragments every time they are spread.
+	MaxAliases int
+
+	// JanitorTasks are run periodically along with the handler maintenance
+	// tasks, e.g. sweeping the expired responses of the response cache,
+	// between Handler.StartJanitor and Handler.StopJanitor. JanitorRunFn is
+	// called after every execution of a task.
+	JanitorTasks []JanitorTask
+	JanitorRunFn JanitorRunFn
+
+	// MaxRootFields and MaxSelections reject the operations selecting more
+	// root fields, or more fields in total, counting the fields of
+	// fragments every time they are spread.
+	MaxRootFields int
+	MaxSelections int
+
+	// BodyReadTimeout answers 408 to the POST requests whose body is not
+	// received in time, and MaxConcurrentBodyReads bounds the bodies being
+	// received at once, answering 503 to the requests waiting longer than
+	// BodyReadTimeout for their turn, or at once without it.
+	BodyReadTimeout        time.Duration
+	MaxConcurrentBodyReads int
+
+	// MaxQueryBytes and MaxQueryTokens reject the longer queries before
+	// parsing them.
+	MaxQueryBytes  int
+	MaxQueryTokens int
+
+	// DisableIntrospection rejects the documents selecting the __schema or
+	// __type introspection fields, see NoIntrospectionRule. With an
+	// IntrospectionAllowedFn, they are rejected unless it allows the request
+	// whether DisableIntrospection is set or not.
+	DisableIntrospection   bool
+	IntrospectionAllowedFn IntrospectionAllowedFn
+
+	// HideSuggestions strips the "Did you mean" suggestions of validation
+	// errors, which reveal the schema even with introspection disabled.
+	HideSuggestions bool
+
+	// UnknownExtensions decides what happens to the requests with
+	// extensions neither read by the handler nor listed in KnownExtensions.
+	// The extensions of the requests are available to resolvers with
+	// ExtensionsFromContext either way.
+	KnownExtensions     []string
+	UnknownExtensions   UnknownExtensionsPolicy
+	UnknownExtensionsFn UnknownExtensionsFn
+
+	// AllowedOperations and AllowedOperationFn restrict the operations
+	// executed to the named ones they allow, answering 403 to the others
+	// and to anonymous operations, when either is set.
+	AllowedOperations  []string
+	AllowedOperationFn AllowedOperationFn
+
+	// EstimateAuthFn authorizes the requests to the EstimateHandler, which
+	// is disabled without it.
+	EstimateAuthFn EstimateAuthFn
+
+	// ReadOnly starts the handler in read-only mode, toggled at runtime
+	// with Handler.SetReadOnly, answering 503 with the ReadOnlyMessage to
+	// mutations, and to subscriptions with ReadOnlyRejectsSubscriptions.
+	ReadOnly                     bool
+	ReadOnlyMessage              string
+	ReadOnlyRejectsSubscriptions bool
+
+	// GetHint sets the Content-Location of the responses to queries sent
+	// with POST to the equivalent GET URL, which CDNs can cache, using the
+	// persisted query hash instead of the query when it is known.
+	GetHint bool
+
+	// RateLimiter answers 429 to the operations it does not allow, keyed by
+	// the RateLimitKeyFn or the client ID, e.g. a TokenBucketLimiter.
+	RateLimiter    RateLimiter
+	RateLimitKeyFn RateLimitKeyFn
+
+	// MaxConcurrentOperations bounds the operations executed concurrently,
+	// over HTTP and WebSocket. Operations over the bound wait for a slot up
+	// to MaxOperationWait, a second by default, then get a 429.
+	MaxConcurrentOperations int
+	MaxOperationWait        time.Duration
+
+	// LatencyHistory keeps rolling latency percentiles per operation,
+	// reported by OperationLatencies, optionally deriving adaptive timeouts
+	// from them so that a regressing operation times out quickly.
+	LatencyHistory *LatencyHistory
+
+	// CircuitBreaker opens a circuit per operation failing too often,
+	// answering 503 with a retryable error while it is open, so that a broken
+	// dependency of one operation does not tie up the whole handler.
+	CircuitBreaker *CircuitBreaker
+	CircuitStateFn CircuitStateFn
+
+	// EventEmitter receives structured events of the handler, e.g. to be
+	// forwarded to an OpenTelemetry LoggerProvider.
+	EventEmitter EventEmitter
+
+	// OperationTimeout bounds the execution of operations. The
+	// OperationTimeoutFn and then the OperationTimeouts, keyed by operation
+	// name, override it and the adaptive timeouts of the LatencyHistory,
+	// e.g. to give known heavy reports a longer deadline.
+	OperationTimeout   time.Duration
+	OperationTimeouts  map[string]time.Duration
+	OperationTimeoutFn OperationTimeoutFn
+
+	// CSRFPrevention rejects the requests a browser could send cross-site
+	// without a CORS preflight, i.e. without a Content-Type or with the one
+	// of a form, unless they have one of the CSRFRequiredHeaders,
+	// DefaultCSRFRequiredHeaders by default.
+	CSRFPrevention      bool
+	CSRFRequiredHeaders []string
+
+	// TypeUsageStats counts the concrete types operations request behind
+	// interfaces and unions, reported by TypeUsage.
+	TypeUsageStats bool
+
+	// JWT answers 401 to the HTTP requests without a valid bearer token,
+	// the claims of the token being returned by JWTClaimsFromContext and
+	// set as the "jwtClaims" entry of the RootObject. WebSocket connections
+	// are authenticated by OnWebSocketInit instead.
+	JWT *JWTConfig
+
+	// LenientParsing tolerates the quirks of legacy clients: trailing commas
+	// in JSON, operationName sent as the "null" string and duplicate
+	// parameters. LegacyNormalizationFn reports each quirk normalized, e.g.
+	// to track the client versions still relying on it.
+	LenientParsing        bool
+	LegacyNormalizationFn LegacyNormalizationFn
+
+	// APIKeyFn authenticates the HTTP requests before their body is read,
+	// answering 401 to those it rejects, e.g. StaticAPIKeys.Authenticate.
+	// The WebSocket connections it rejects are authenticated once more with
+	// the string entries of their connection_init payload as headers,
+	// closing them with 4401 Unauthorized when rejected again. The principal
+	// is returned by PrincipalFromContext.
+	APIKeyFn APIKeyFn
+
+	// AuthorizeFn authorizes the operations before their execution, e.g. to
+	// enforce per-operation permissions, see AuthorizeFn.
+	AuthorizeFn AuthorizeFn
+
+	// FieldAuth maps "Type.field" coordinates to the roles required to
+	// resolve them, the fields resolving to null with an error otherwise,
+	// see AuthDirective. FieldAuthFn checks the roles of the request, those
+	// of the principal or of the JWT claims by default.
+	FieldAuth   map[string][]string
+	FieldAuthFn FieldAuthFn
+
+	// MaskInternalErrors replaces the message of the errors returned or
+	// panicked by resolvers, unless they have extensions, by "Internal
+	// server error" with a correlation ID in the extensions. The original
+	// errors are passed to the InternalErrorFn along with the ID.
+	MaskInternalErrors bool
+	InternalErrorFn    InternalErrorFn
+
+	// Debug adds the resolver path, the original error and the stack of the
+	// resolver panics to the "debug" extension of the execution errors, even
+	// masked ones. It is meant for development only.
+	Debug bool
+
+	// MaxVariablesBytes, MaxVariablesKeys and MaxVariablesDepth bound the
+	// decoded variables of requests: their JSON size, their number of
+	// object keys at any depth and their nesting of objects and lists.
+	MaxVariablesBytes int
+	MaxVariablesKeys  int
+	MaxVariablesDepth int
+
+	// MaxResponseBytes replaces the responses serialized over this size by
+	// a RESPONSE_TOO_LARGE error, passed to the ResultCallbackFn in place of
+	// the result.
+	MaxResponseBytes int
+
+	// GlobalCircuitBreaker opens a single circuit when the executions of all
+	// operations fail too often, answering 503 with a Retry-After for its
+	// OpenDuration to protect the dependencies of the resolvers.
+	GlobalCircuitBreaker *CircuitBreaker
+
+	// IdempotencyStore stores the responses of the mutations sent with an
+	// Idempotency-Key header, returned as is to the retries with the same
+	// key from the same client, with an Idempotent-Replayed header. A key
+	// reused for another operation or variables is rejected with a 422.
+	// Responses with a 5xx status are not stored.
+	IdempotencyStore IdempotencyStore
+	// IdempotencyWindow is how long the responses are stored, 24 hours by
+	// default.
+	IdempotencyWindow time.Duration
+
+	// ClientPolicy blocks or throttles client applications by the name and
+	// version headers of their requests, over HTTP and WebSocket.
+	ClientPolicy *ClientPolicy
+
+	// IPPolicyFn allows or rejects the requests by the IP address of their
+	// connection, before any other processing, e.g. AllowCIDRs for an
+	// internal-only endpoint. It applies to the WebSocket upgrades too.
+	IPPolicyFn IPPolicyFn
+
+	// RequestSignature rejects the HTTP requests without a valid HMAC
+	// signature of their raw body, made with a shared secret.
+	RequestSignature *RequestSignature
+
+	// AuditSink receives a hash-chained AuditRecord for every executed
+	// mutation, over HTTP and WebSocket, with the principal from
+	// AuditPrincipalFn, by default the "sub" claim of the JWT or the API key
+	// principal. The values of the AuditRedactVariables, matched by key at
+	// any depth of the variables and regardless of case, are redacted.
+	AuditSink            AuditSink
+	AuditPrincipalFn     AuditPrincipalFn
+	AuditRedactVariables []string
+
+	// Production applies safe defaults in one switch: introspection,
+	// GraphiQL, Playground and Debug off, MaskInternalErrors,
+	// HideSuggestions and StrictContentType on, and limits on the request
+	// bodies, queries and variables, unless set: 1 MB bodies, as sent and
+	// decompressed, read within 10s, 64 KB and 10000 tokens queries, 512 KB
+	// variables nested 16 levels deep. IntrospectionAllowedFn can still
+	// allow introspection to some requests.
+	Production bool
+
+	// Metrics counts the requests, their duration and their errors, see
+	// NewMetrics.
+	Metrics *Metrics
+
+	// TracerProvider traces the requests in a server span named and
+	// attributed after their operation like the OpenTelemetry GraphQL
+	// semantic conventions, with child spans for the parsing, validation
+	// and execution of the operations.
+	TracerProvider TracerProvider
+
+	// MeterProvider records the duration of the requests, their errors by
+	// code and the requests being served, in the
+	// graphql.server.request.duration, graphql.server.errors and
+	// graphql.server.active_requests instruments.
+	MeterProvider MeterProvider
+
+	// TraceResolvers times the field resolvers, until they return their
+	// value: in "graphql.resolve" spans with the TracerProvider, by
+	// "Type.field" with the Metrics, the MeterProvider and the
+	// MetricsEmitter, and passed to the ResolverTimingFn. It adds a
+	// graphql-go extension to the schema.
+	TraceResolvers   bool
+	ResolverTimingFn ResolverTimingFn
+
+	// ApolloTracing reports the timings of the parsing, validation and
+	// resolvers of the operations sent over HTTP in the Apollo Tracing
+	// format, in the "tracing" extension of the responses. With an
+	// ApolloTracingHeader, e.g. "X-Apollo-Tracing", the requests with the
+	// header set, to a value other than "0" or "false", get it too.
+	ApolloTracing       bool
+	ApolloTracingHeader string
+
+	// ApolloUsageReporting reports the usage of the operations sent over
+	// HTTP to Apollo Studio, by signature and by the client named in the
+	// apollographql-client-name and apollographql-client-version headers.
+	// The reports are uploaded by the janitor, see StartJanitor.
+	ApolloUsageReporting *ApolloUsageReporting
+
+	// MetricsEmitter receives counters and timings of the requests tagged
+	// with their operation, e.g. a StatsD from NewStatsD for StatsD or
+	// DogStatsD servers.
+	MetricsEmitter MetricsEmitter
+
+	// Logger receives an access-log record per request served over HTTP,
+	// with its method, operation name, type and document hash, status,
+	// duration, response size, errors count and client, and warnings about
+	// the failures otherwise recovered from silently: persisted operations
+	// not parsing, serializers failing, callbacks panicking. SlogLogger
+	// adapts a *slog.Logger and LogrusLogger a logrus one.
+	Logger Logger
+
+	// RequestID adopts the ID of the requests from their RequestIDHeader,
+	// X-Request-ID by default, or generates one with the RequestIDFn, random
+	// by default. The ID is in the context, see RequestIDFromContext, in the
+	// header of the response, in the "requestId" extension of the errors and
+	// in the access-log records.
+	RequestID       bool
+	RequestIDHeader string
+	RequestIDFn     func() string
+
+	// ClientNameHeader and ClientVersionHeader identify the client
+	// application of the requests, defaulting to the Apollo client headers.
+	// The ClientInfo is in the context, see ClientInfoFromContext, in the
+	// access-log records, the usage reports and the metrics: the
+	// client_requests_total Metrics counter, the MeterProvider attributes
+	// and the MetricsEmitter tags.
+	ClientNameHeader    string
+	ClientVersionHeader string
+
+	// MaxClientLabels bounds the distinct clients in the usage reports and
+	// the metrics, 100 by default: the requests of the clients seen after
+	// are reported with the OtherClients name and version.
+	MaxClientLabels int
 }
 
 func NewConfig() *Config {
@@ -242,17 +1266,291 @@ func New(p *Config) *Handler {
 		p = NewConfig()
 	}
 
+	if p.Production {
+		p = productionConfig(p)
+	}
+
 	if p.Schema == nil {
 		panic("undefined GraphQL schema")
 	}
+	if p.CORS != nil && p.CORS.AllowCredentials && p.CORS.allowedOrigin("*") {
+		panic("CORS credentials cannot be allowed for any origin")
+	}
 
-	return &Handler{
-		Schema:           p.Schema,
-		pretty:           p.Pretty,
-		graphiql:         p.GraphiQL,
-		playground:       p.Playground,
-		rootObjectFn:     p.RootObjectFn,
-		resultCallbackFn: p.ResultCallbackFn,
-		formatErrorFn:    p.FormatErrorFn,
+	personalDataFields := make(map[string]bool, len(p.PersonalDataFields))
+	for _, coordinate := range p.PersonalDataFields {
+		personalDataFields[coordinate] = true
 	}
+
+	baggageKeys := make(map[string]bool, len(p.BaggageKeys))
+	for _, key := range p.BaggageKeys {
+		baggageKeys[key] = true
+	}
+
+	knownExtensions := make(map[string]bool, len(p.KnownExtensions))
+	for _, key := range p.KnownExtensions {
+		knownExtensions[key] = true
+	}
+
+	var allowedOperations map[string]bool
+	if p.AllowedOperations != nil {
+		allowedOperations = make(map[string]bool, len(p.AllowedOperations))
+		for _, name := range p.AllowedOperations {
+			allowedOperations[name] = true
+		}
+	}
+
+	h := &Handler{
+		Schema:                       p.Schema,
+		pretty:                       p.Pretty,
+		graphiql:                     p.GraphiQL,
+		playground:                   p.Playground,
+		rootObjectFn:                 p.RootObjectFn,
+		resultCallbackFn:             p.ResultCallbackFn,
+		formatErrorFn:                p.FormatErrorFn,
+		personalDataFields:           personalDataFields,
+		dataAccessSink:               p.DataAccessSink,
+		dataAccessActorFn:            p.DataAccessActorFn,
+		dataAccessSubjectVariables:   p.DataAccessSubjectVariables,
+		subscriptions:                p.Subscriptions,
+		legacySubscriptions:          p.LegacySubscriptionsProtocol,
+		featureFlagsFn:               p.FeatureFlagsFn,
+		featureFlagsExtension:        p.FeatureFlagsExtension,
+		featureGates:                 p.FeatureGates,
+		shadowConfig:                 p.Shadow,
+		shadowSlots:                  newShadowSlots(p.Shadow),
+		onWebSocketInit:              p.OnWebSocketInit,
+		experiments:                  p.Experiments,
+		experimentClientIDFn:         p.ExperimentClientIDFn,
+		experimentsExtension:         p.ExperimentsExtension,
+		wsPingInterval:               p.WebSocketPingInterval,
+		wsPongTimeout:                p.WebSocketPongTimeout,
+		wsConnections:                newConnectionLimiter(p.WebSocketMaxConnections, p.WebSocketMaxConnectionsPerIP),
+		incrementalDelivery:          p.IncrementalDelivery,
+		persistedQueries:             newPersistedQueryCache(),
+		cacheBroadcaster:             p.CacheBroadcaster,
+		instanceID:                   newInstanceID(),
+		responseCache:                newResponseCache(p),
+		responseCacheKeyFn:           p.ResponseCacheKeyFn,
+		cachePurgeAuthFn:             p.CachePurgeAuthFn,
+		statusCodes:                  p.StatusCodes,
+		allowAnyMethod:               p.AllowAnyMethod,
+		strictContentType:            p.StrictContentType,
+		resultPatches:                newResultStore(p.ResultPatches),
+		getMutationsGraphQLError:     p.GetMutationsGraphQLError,
+		maxDecompressedBodySize:      p.MaxDecompressedBodySize,
+		maxBodyBytes:                 p.MaxBodyBytes,
+		responseCharset:              p.ResponseCharset,
+		transcodeFn:                  p.ResponseTranscodeFn,
+		compression:                  p.Compression,
+		responseEncoders:             p.ResponseEncoders,
+		passThroughHeaders:           p.PassThroughHeaders,
+		corsConfig:                   p.CORS,
+		statusPage:                   p.StatusPage,
+		statusPageTemplate:           p.StatusPageTemplate,
+		optionsHeaders:               p.OptionsHeaders,
+		crawlerBlocking:              p.BlockCrawlers,
+		crawlerUserAgents:            p.CrawlerUserAgents,
+		subjectIDFn:                  p.SubjectIDFn,
+		subjectMutations:             newSubjectLimiter(p.MaxConcurrentMutationsPerSubject, p.QueueSubjectMutations),
+		requests:                     newFairLimiter(p.MaxConcurrentRequests, p.MaxQueuedRequests),
+		clientIDFn:                   p.ClientIDFn,
+		slos:                         newSLOTracker(p.SLOs),
+		sloBurnFn:                    p.SLOBurnFn,
+		serverTiming:                 p.ServerTiming,
+		inFlight:                     newInFlightRequests(p.TrackInFlightRequests),
+		inFlightAuthFn:               p.InFlightAuthFn,
+		responseSerializer:           p.ResponseSerializer,
+		baggageKeys:                  baggageKeys,
+		requestErrorsWithoutData:     p.RequestErrorsWithoutData,
+		webSocketOperations:          p.WebSocketOperations,
+		costAnalysis:                 newCostAnalysis(p),
+		schemaWebhookURLs:            p.SchemaWebhookURLs,
+		schemaWebhookClient:          p.SchemaWebhookClient,
+		maxAliases:                   p.MaxAliases,
+		janitor:                      newJanitor(p),
+		maxRootFields:                p.MaxRootFields,
+		maxSelections:                p.MaxSelections,
+		bodyReadTimeout:              p.BodyReadTimeout,
+		bodyReads:                    newBodyReads(p.MaxConcurrentBodyReads),
+		maxQueryBytes:                p.MaxQueryBytes,
+		maxQueryTokens:               p.MaxQueryTokens,
+		disableIntrospection:         p.DisableIntrospection,
+		introspectionAllowedFn:       p.IntrospectionAllowedFn,
+		hideSuggestions:              p.HideSuggestions,
+		knownExtensions:              knownExtensions,
+		unknownExtensions:            p.UnknownExtensions,
+		unknownExtensionsFn:          p.UnknownExtensionsFn,
+		allowedOperations:            allowedOperations,
+		allowedOperationFn:           p.AllowedOperationFn,
+		estimateAuthFn:               p.EstimateAuthFn,
+		readOnlyMessage:              p.ReadOnlyMessage,
+		readOnlyRejectsSubscriptions: p.ReadOnlyRejectsSubscriptions,
+		getHint:                      p.GetHint,
+		rateLimiter:                  p.RateLimiter,
+		rateLimitKeyFn:               p.RateLimitKeyFn,
+		operationSlots:               newOperationSlots(p.MaxConcurrentOperations, p.MaxOperationWait),
+		latencies:                    newLatencyHistory(p.LatencyHistory),
+		circuitBreaker:               newCircuitBreaker(p.CircuitBreaker, p.CircuitStateFn),
+		eventEmitter:                 p.EventEmitter,
+		globalOperationTimeout:       p.OperationTimeout,
+		operationTimeouts:            p.OperationTimeouts,
+		operationTimeoutFn:           p.OperationTimeoutFn,
+		csrfPrevention:               p.CSRFPrevention,
+		csrfRequiredHeaders:          p.CSRFRequiredHeaders,
+		typeUsage:                    newTypeUsageStats(p.TypeUsageStats),
+		jwt:                          newJWTVerifier(p.JWT),
+		lenientParsing:               p.LenientParsing,
+		legacyNormalizationFn:        p.LegacyNormalizationFn,
+		apiKeyFn:                     p.APIKeyFn,
+		authorizeFn:                  p.AuthorizeFn,
+		fieldAuth:                    newFieldAuth(p.FieldAuth, p.FieldAuthFn),
+		maskInternalErrors:           p.MaskInternalErrors,
+		internalErrorFn:              p.InternalErrorFn,
+		debugErrors:                  newDebugErrors(p.Debug),
+		maxVariablesBytes:            p.MaxVariablesBytes,
+		maxVariablesKeys:             p.MaxVariablesKeys,
+		maxVariablesDepth:            p.MaxVariablesDepth,
+		maxResponseBytes:             p.MaxResponseBytes,
+		globalCircuit:                newCircuitBreaker(p.GlobalCircuitBreaker, p.CircuitStateFn),
+		idempotencyStore:             p.IdempotencyStore,
+		idempotencyWindow:            idempotencyWindowOrDefault(p.IdempotencyWindow),
+		clientPolicy:                 p.ClientPolicy,
+		ipPolicyFn:                   p.IPPolicyFn,
+		requestSignature:             p.RequestSignature,
+		auditLog:                     newAuditLog(p),
+		metrics:                      p.Metrics,
+		tracer:                       newTracer(p.TracerProvider),
+		meter:                        newMeterInstruments(p.MeterProvider),
+		traceResolvers:               p.TraceResolvers,
+		resolverTimingFn:             p.ResolverTimingFn,
+		apolloTracing:                p.ApolloTracing,
+		apolloTracingHeader:          p.ApolloTracingHeader,
+		usageReporter:                newUsageReporter(p.ApolloUsageReporting),
+		metricsEmitter:               p.MetricsEmitter,
+		logger:                       p.Logger,
+		requestID:                    p.RequestID,
+		requestIDHeader:              p.RequestIDHeader,
+		requestIDFn:                  p.RequestIDFn,
+		clientNameHeader:             p.ClientNameHeader,
+		clientVersionHeader:          p.ClientVersionHeader,
+		clientLabels:                 newClientLabels(p.MaxClientLabels),
+	}
+
+	if h.fieldAuth != nil {
+		h.fieldAuth.apply(h.Schema)
+	}
+	if h.debugErrors != nil {
+		h.debugErrors.apply(h.Schema)
+	}
+	if h.tracer != nil || h.traceResolvers || h.apolloTracingEnabled() {
+		instrumentSchema(h.Schema)
+	}
+	if len(h.csrfRequiredHeaders) == 0 {
+		h.csrfRequiredHeaders = DefaultCSRFRequiredHeaders
+	}
+	if h.maxDecompressedBodySize <= 0 {
+		h.maxDecompressedBodySize = defaultMaxDecompressedBodySize
+	}
+	if h.responseCharset == "" {
+		h.responseCharset = "utf-8"
+	}
+	if h.clientNameHeader == "" {
+		h.clientNameHeader = ClientNameHeader
+	}
+	if h.clientVersionHeader == "" {
+		h.clientVersionHeader = ClientVersionHeader
+	}
+	if h.requestIDHeader == "" {
+		h.requestIDHeader = RequestIDHeader
+	}
+
+	h.SetReadOnly(p.ReadOnly)
+	h.persistedQueries.loadManifest(p.PersistedOperations)
+	for _, op := range p.PersistedOperations {
+		if !matchesHash(op.Body, op.ID) {
+			h.log(nil, LevelWarn, "persisted operation does not match its ID", "id", op.ID, "operation_name", op.Name)
+		} else if _, err := parseOperation(op.Body, op.Name); err != nil {
+			h.log(nil, LevelWarn, "persisted operation does not parse", "id", op.ID, "operation_name", op.Name, "error", err.Error())
+		}
+	}
+
+	if h.cacheBroadcaster != nil {
+		h.persistedQueries.onRegister = func(entry CacheEntry) {
+			h.publishCacheEvent(CacheEvent{
+				Cache:         CachePersistedQueries,
+				Type:          CacheEventRegister,
+				Key:           entry.sha256Hash,
+				OperationName: entry.operationName,
+				Query:         entry.query,
+				Version:       entry.version,
+			})
+		}
+		h.subscribeCacheEvents()
+	}
+
+	if limiter, ok := h.rateLimiter.(*TokenBucketLimiter); ok {
+		h.janitor.add(JanitorTask{
+			Name:     "rate-limiter-sweep",
+			Interval: time.Minute,
+			Jitter:   0.1,
+			Run: func(ctx context.Context) error {
+				limiter.sweep(time.Now())
+				return nil
+			},
+		})
+	}
+	if h.latencies != nil {
+		h.janitor.add(JanitorTask{
+			Name:     "latency-history-sweep",
+			Interval: 10 * time.Minute,
+			Jitter:   0.1,
+			Run: func(ctx context.Context) error {
+				h.latencies.sweep(time.Now())
+				return nil
+			},
+		})
+	}
+	if h.circuitBreaker != nil {
+		h.janitor.add(JanitorTask{
+			Name:     "circuit-breaker-sweep",
+			Interval: h.circuitBreaker.Window,
+			Jitter:   0.1,
+			Run: func(ctx context.Context) error {
+				h.circuitBreaker.sweep(time.Now())
+				return nil
+			},
+		})
+	}
+	if store, ok := h.idempotencyStore.(*MemoryIdempotencyStore); ok {
+		h.janitor.add(JanitorTask{
+			Name:     "idempotency-store-sweep",
+			Interval: time.Minute,
+			Jitter:   0.1,
+			Run: func(ctx context.Context) error {
+				store.sweep(time.Now())
+				return nil
+			},
+		})
+	}
+	if h.usageReporter != nil {
+		h.janitor.add(JanitorTask{
+			Name:     "usage-reporting",
+			Interval: h.usageReporter.config.Interval,
+			Run:      h.FlushUsageReport,
+		})
+	}
+	if h.responseCache != nil {
+		h.janitor.add(JanitorTask{
+			Name:     "response-cache-sweep",
+			Interval: h.responseCache.ttl,
+			Jitter:   0.1,
+			Run: func(ctx context.Context) error {
+				h.responseCache.sweep(time.Now())
+				return nil
+			},
+		})
+	}
+
+	return h
 }
@@ -0,0 +1,134 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// IdempotencyKeyHeader is the header of the mutations whose response is
+// replayed to the retries holding the same key.
+const IdempotencyKeyHeader = "Idempotency-Key"
+
+// IdempotentResponse is a response stored for an idempotency key, along with
+// the hash of the request it answered.
+type IdempotentResponse struct {
+	RequestHash string
+	Status      int
+	ContentType string
+	Body        []byte
+}
+
+// IdempotencyStore stores the responses of the mutations by idempotency key.
+type IdempotencyStore interface {
+	Get(ctx context.Context, key string) (IdempotentResponse, bool)
+	Set(ctx context.Context, key string, response IdempotentResponse, ttl time.Duration)
+}
+
+const defaultIdempotencyWindow = 24 * time.Hour
+
+// MemoryIdempotencyStore is an in-memory IdempotencyStore, the handler
+// sweeping its expired responses when it is the Config.IdempotencyStore.
+type MemoryIdempotencyStore struct {
+	mu        sync.Mutex
+	responses map[string]memoryIdempotentResponse
+}
+
+type memoryIdempotentResponse struct {
+	IdempotentResponse
+	expires time.Time
+}
+
+// NewMemoryIdempotencyStore returns an empty MemoryIdempotencyStore.
+func NewMemoryIdempotencyStore() *MemoryIdempotencyStore {
+	return &MemoryIdempotencyStore{responses: make(map[string]memoryIdempotentResponse)}
+}
+
+// Get returns the response stored for the key, unless expired.
+func (s *MemoryIdempotencyStore) Get(ctx context.Context, key string) (IdempotentResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	response, ok := s.responses[key]
+	if !ok || time.Now().After(response.expires) {
+		return IdempotentResponse{}, false
+	}
+	return response.IdempotentResponse, true
+}
+
+// Set stores the response of the key for the ttl.
+func (s *MemoryIdempotencyStore) Set(ctx context.Context, key string, response IdempotentResponse, ttl time.Duration) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.responses[key] = memoryIdempotentResponse{IdempotentResponse: response, expires: time.Now().Add(ttl)}
+}
+
+func (s *MemoryIdempotencyStore) sweep(now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key, response := range s.responses {
+		if now.After(response.expires) {
+			delete(s.responses, key)
+		}
+	}
+}
+
+// idempotency identifies a mutation sent with an idempotency key, scoped to
+// its client.
+type idempotency struct {
+	key         string
+	requestHash string
+}
+
+func (h *Handler) idempotency(ctx context.Context, r *http.Request, op *operation, opts *RequestOptions) *idempotency {
+	if h.idempotencyStore == nil || op == nil || op.Type() != ast.OperationTypeMutation {
+		return nil
+	}
+	key := r.Header.Get(IdempotencyKeyHeader)
+	if key == "" {
+		return nil
+	}
+	return &idempotency{
+		key:         h.clientID(ctx, r) + "\x00" + key,
+		requestHash: responseCacheKey("", op, opts.Variables),
+	}
+}
+
+// replay writes the response stored for the idempotency key, returning false
+// when none was. A key reused for another request is rejected.
+func (i *idempotency) replay(ctx context.Context, h *Handler, w http.ResponseWriter, r *http.Request) (bool, *requestError) {
+	stored, ok := h.idempotencyStore.Get(ctx, i.key)
+	if !ok {
+		return false, nil
+	}
+	if stored.RequestHash != i.requestHash {
+		return false, newRequestError(http.StatusUnprocessableEntity, CodeIdempotencyKeyReused, "The Idempotency-Key was used for another request")
+	}
+	w.Header().Set("Content-Type", stored.ContentType)
+	w.Header().Set("Idempotent-Replayed", "true")
+	h.writeBody(w, r, stored.Status, stored.Body)
+	return true, nil
+}
+
+// store stores the response of the mutation, unless it failed on the server
+// side and may be retried.
+func (i *idempotency) store(ctx context.Context, h *Handler, status int, contentType string, body []byte) {
+	if status >= http.StatusInternalServerError {
+		return
+	}
+	h.idempotencyStore.Set(ctx, i.key, IdempotentResponse{
+		RequestHash: i.requestHash,
+		Status:      status,
+		ContentType: contentType,
+		Body:        body,
+	}, h.idempotencyWindow)
+}
+
+func idempotencyWindowOrDefault(window time.Duration) time.Duration {
+	if window <= 0 {
+		return defaultIdempotencyWindow
+	}
+	return window
+}
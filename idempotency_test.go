@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_IdempotencyKey(t *testing.T) {
+	var executions int
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   "Query",
+			Fields: graphql.Fields{"name": &graphql.Field{Type: graphql.String}},
+		}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Mutation",
+			Fields: graphql.Fields{
+				"increment": &graphql.Field{
+					Type: graphql.Int,
+					Args: graphql.FieldConfigArgument{"by": &graphql.ArgumentConfig{Type: graphql.Int}},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						executions++
+						return executions, nil
+					},
+				},
+			},
+		}),
+	})
+	h := New(&Config{Schema: &schema, IdempotencyStore: NewMemoryIdempotencyStore()})
+	send := func(key, query string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"`+query+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		if key != "" {
+			req.Header.Set(IdempotencyKeyHeader, key)
+		}
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+
+	first := send("a", "mutation { increment(by: 1) }")
+	if first.Code != http.StatusOK || first.Body.String() != `{"data":{"increment":1}}` {
+		t.Fatalf("unexpected response %d %s", first.Code, first.Body.String())
+	}
+	retry := send("a", "mutation { increment(by: 1) }")
+	if retry.Body.String() != first.Body.String() || retry.Header().Get("Idempotent-Replayed") != "true" || executions != 1 {
+		t.Errorf("expected the response to be replayed, got %s after %d executions", retry.Body.String(), executions)
+	}
+	if retry.Header().Get("Content-Type") != first.Header().Get("Content-Type") {
+		t.Errorf("unexpected Content-Type %q", retry.Header().Get("Content-Type"))
+	}
+
+	if resp := send("a", "mutation { increment(by: 2) }"); resp.Code != http.StatusUnprocessableEntity || !strings.Contains(resp.Body.String(), string(CodeIdempotencyKeyReused)) {
+		t.Errorf("expected the reused key to be rejected, got %d %s", resp.Code, resp.Body.String())
+	}
+	if resp := send("b", "mutation { increment(by: 1) }"); resp.Body.String() != `{"data":{"increment":2}}` {
+		t.Errorf("expected another key to execute the mutation, got %s", resp.Body.String())
+	}
+	if resp := send("", "mutation { increment(by: 1) }"); resp.Body.String() != `{"data":{"increment":3}}` {
+		t.Errorf("expected the mutation without key to be executed, got %s", resp.Body.String())
+	}
+	if resp := send("a", "{ name }"); resp.Code != http.StatusOK || resp.Header().Get("Idempotent-Replayed") != "" {
+		t.Errorf("expected queries to ignore the key, got %d %s", resp.Code, resp.Body.String())
+	}
+}
@@ -0,0 +1,139 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// InFlightAuthFn authorizes a request to the in-flight requests endpoint.
+type InFlightAuthFn func(r *http.Request) bool
+
+// InFlightRequest describes an operation being executed.
+type InFlightRequest struct {
+	ID            string    `json:"id"`
+	OperationName string    `json:"operationName,omitempty"`
+	Client        string    `json:"client,omitempty"`
+	Started       time.Time `json:"started"`
+	// Elapsed is in nanoseconds in JSON.
+	Elapsed  time.Duration `json:"elapsed"`
+	Deadline time.Time     `json:"deadline,omitempty"`
+}
+
+type inFlightRequests struct {
+	mu       sync.Mutex
+	seq      uint64
+	requests map[string]*inFlightEntry
+}
+
+type inFlightEntry struct {
+	request InFlightRequest
+	cancel  context.CancelFunc
+}
+
+func newInFlightRequests(track bool) *inFlightRequests {
+	if !track {
+		return nil
+	}
+	return &inFlightRequests{requests: make(map[string]*inFlightEntry)}
+}
+
+// trackInFlight registers the operation until done is called, returning a
+// context canceled by CancelInFlightRequest.
+func (h *Handler) trackInFlight(ctx context.Context, r *http.Request, op *operation) (context.Context, func()) {
+	if h.inFlight == nil {
+		return ctx, func() {}
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	entry := &inFlightEntry{
+		request: InFlightRequest{Client: h.clientID(ctx, r), Started: time.Now()},
+		cancel:  cancel,
+	}
+	if op != nil {
+		entry.request.OperationName = op.Name()
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		entry.request.Deadline = deadline
+	}
+
+	f := h.inFlight
+	f.mu.Lock()
+	f.seq++
+	entry.request.ID = strconv.FormatUint(f.seq, 10)
+	f.requests[entry.request.ID] = entry
+	f.mu.Unlock()
+
+	return ctx, func() {
+		f.mu.Lock()
+		delete(f.requests, entry.request.ID)
+		f.mu.Unlock()
+		cancel()
+	}
+}
+
+// InFlightRequests lists the operations being executed, oldest first, when
+// Config.TrackInFlightRequests is set.
+func (h *Handler) InFlightRequests() []InFlightRequest {
+	if h.inFlight == nil {
+		return nil
+	}
+	now := time.Now()
+	h.inFlight.mu.Lock()
+	requests := make([]InFlightRequest, 0, len(h.inFlight.requests))
+	for _, entry := range h.inFlight.requests {
+		request := entry.request
+		request.Elapsed = now.Sub(request.Started)
+		requests = append(requests, request)
+	}
+	h.inFlight.mu.Unlock()
+
+	sort.Slice(requests, func(i, j int) bool {
+		return requests[i].Started.Before(requests[j].Started)
+	})
+	return requests
+}
+
+// CancelInFlightRequest cancels the context of an operation being executed,
+// and reports whether it was found.
+func (h *Handler) CancelInFlightRequest(id string) bool {
+	if h.inFlight == nil {
+		return false
+	}
+	h.inFlight.mu.Lock()
+	entry, ok := h.inFlight.requests[id]
+	h.inFlight.mu.Unlock()
+	if ok {
+		entry.cancel()
+	}
+	return ok
+}
+
+// InFlightHandler serves the in-flight requests debug endpoint, authorized by
+// Config.InFlightAuthFn: GET requests list the operations being executed,
+// DELETE requests cancel the one given by the "id" query parameter.
+func (h *Handler) InFlightHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.inFlightAuthFn == nil || !h.inFlightAuthFn(r) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			json.NewEncoder(w).Encode(map[string][]InFlightRequest{"requests": h.InFlightRequests()})
+		case http.MethodDelete:
+			if !h.CancelInFlightRequest(r.URL.Query().Get("id")) {
+				http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.Header().Set("Allow", "GET, DELETE")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		}
+	})
+}
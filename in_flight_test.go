@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_InFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	canceled := make(chan struct{})
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"stuck": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						close(started)
+						<-p.Context.Done()
+						close(canceled)
+						return nil, p.Context.Err()
+					},
+				},
+			},
+		}),
+	})
+	h := New(&Config{
+		Schema:                &schema,
+		TrackInFlightRequests: true,
+		InFlightAuthFn: func(r *http.Request) bool {
+			return r.Header.Get("Authorization") == "Bearer admin"
+		},
+	})
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		req, _ := http.NewRequest("GET", "/graphql?query=query+Stuck{stuck}", nil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	<-started
+
+	req, _ := http.NewRequest("GET", "/debug/requests", nil)
+	resp := httptest.NewRecorder()
+	h.InFlightHandler().ServeHTTP(resp, req)
+	if resp.Code != http.StatusUnauthorized {
+		t.Fatalf("expected 401, got %d", resp.Code)
+	}
+
+	req.Header.Set("Authorization", "Bearer admin")
+	resp = httptest.NewRecorder()
+	h.InFlightHandler().ServeHTTP(resp, req)
+	var list struct {
+		Requests []InFlightRequest `json:"requests"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &list)
+	if len(list.Requests) != 1 || list.Requests[0].OperationName != "Stuck" || list.Requests[0].Elapsed <= 0 {
+		t.Fatalf("expected the stuck request, got %s", resp.Body.String())
+	}
+
+	req, _ = http.NewRequest("DELETE", "/debug/requests?id="+list.Requests[0].ID, nil)
+	req.Header.Set("Authorization", "Bearer admin")
+	resp = httptest.NewRecorder()
+	h.InFlightHandler().ServeHTTP(resp, req)
+	if resp.Code != http.StatusNoContent {
+		t.Fatalf("expected 204, got %d", resp.Code)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the request to be canceled")
+	}
+	<-done
+	if requests := h.InFlightRequests(); len(requests) != 0 {
+		t.Fatalf("expected no request in flight, got %v", requests)
+	}
+}
@@ -0,0 +1,553 @@
+package handler
+
+import (
+	"encoding/json"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/kinds"
+	"github.com/graphql-go/graphql/language/printer"
+)
+
+const (
+	ContentTypeMultipartMixed = "multipart/mixed"
+
+	incrementalBoundary = "graphql"
+	incrementalSpec     = "20220824"
+)
+
+// deferredFragment is an inline fragment marked with @defer, with the
+// selections leading to it from the operation root.
+type deferredFragment struct {
+	label    string
+	path     []ast.Selection
+	fragment *ast.InlineFragment
+}
+
+// streamedField is a list field marked with @stream, the last selection of
+// its path.
+type streamedField struct {
+	label        string
+	path         []ast.Selection
+	initialCount int
+}
+
+// incrementalPlan splits an operation using @defer and @stream into the
+// initial operation and the parts delivered afterwards.
+type incrementalPlan struct {
+	op        *operation
+	variables map[string]interface{}
+	full      *ast.SelectionSet
+	initial   *ast.SelectionSet
+	deferred  []deferredFragment
+	streamed  []streamedField
+}
+
+// usesIncrementalDelivery reports whether the operation has @defer or
+// @stream directives.
+func (o *operation) usesIncrementalDelivery() bool {
+	found := false
+	var visit func(set *ast.SelectionSet)
+	visit = func(set *ast.SelectionSet) {
+		if set == nil || found {
+			return
+		}
+		for _, selection := range set.Selections {
+			switch selection := selection.(type) {
+			case *ast.Field:
+				found = found || hasDirective(selection.Directives, "stream")
+				visit(selection.SelectionSet)
+			case *ast.InlineFragment:
+				found = found || hasDirective(selection.Directives, "defer")
+				visit(selection.SelectionSet)
+			case *ast.FragmentSpread:
+				found = found || hasDirective(selection.Directives, "defer")
+			}
+		}
+	}
+	visit(o.definition.SelectionSet)
+	for _, fragment := range o.fragments {
+		visit(fragment.SelectionSet)
+	}
+	return found
+}
+
+// planIncremental splits the operation. When incremental is false the
+// directives are only removed, for delivering everything at once.
+func planIncremental(op *operation, variables map[string]interface{}, incremental bool) *incrementalPlan {
+	p := &incrementalPlan{
+		op:        op,
+		variables: variables,
+	}
+	inlined := op.inlineSelectionSet(op.definition.SelectionSet, map[string]bool{})
+	p.full = p.split(inlined, nil, true)
+	p.initial = p.split(inlined, nil, !incremental)
+	return p
+}
+
+// inlineSelectionSet copies the selection set, replacing fragment spreads by
+// the equivalent inline fragments.
+func (o *operation) inlineSelectionSet(set *ast.SelectionSet, visiting map[string]bool) *ast.SelectionSet {
+	if set == nil {
+		return nil
+	}
+	selections := make([]ast.Selection, 0, len(set.Selections))
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			field := *selection
+			field.SelectionSet = o.inlineSelectionSet(selection.SelectionSet, visiting)
+			selections = append(selections, &field)
+		case *ast.InlineFragment:
+			fragment := *selection
+			fragment.SelectionSet = o.inlineSelectionSet(selection.SelectionSet, visiting)
+			selections = append(selections, &fragment)
+		case *ast.FragmentSpread:
+			if selection.Name == nil || visiting[selection.Name.Value] {
+				continue
+			}
+			def, ok := o.fragments[selection.Name.Value]
+			if !ok {
+				continue
+			}
+			visiting[selection.Name.Value] = true
+			selections = append(selections, &ast.InlineFragment{
+				Kind:          kinds.InlineFragment,
+				TypeCondition: def.TypeCondition,
+				Directives:    selection.Directives,
+				SelectionSet:  o.inlineSelectionSet(def.SelectionSet, visiting),
+			})
+			delete(visiting, selection.Name.Value)
+		}
+	}
+	return &ast.SelectionSet{Kind: kinds.SelectionSet, Selections: selections}
+}
+
+// split removes the deferred fragments and @stream directives from the
+// inlined selection set, recording them in the plan unless stripOnly is set.
+func (p *incrementalPlan) split(set *ast.SelectionSet, path []ast.Selection, stripOnly bool) *ast.SelectionSet {
+	if set == nil {
+		return nil
+	}
+	selections := make([]ast.Selection, 0, len(set.Selections))
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			field := *selection
+			directives, stream := takeDirective(field.Directives, "stream")
+			field.Directives = directives
+			fieldPath := appendSelection(path, &field)
+			if stream != nil && !stripOnly && p.directiveEnabled(stream) {
+				initialCount, _ := p.intArgument(stream, "initialCount")
+				p.streamed = append(p.streamed, streamedField{
+					label:        p.stringArgument(stream, "label"),
+					path:         fieldPath,
+					initialCount: initialCount,
+				})
+			}
+			field.SelectionSet = p.split(field.SelectionSet, fieldPath, stripOnly)
+			selections = append(selections, &field)
+		case *ast.InlineFragment:
+			fragment := *selection
+			directives, deferDirective := takeDirective(fragment.Directives, "defer")
+			fragment.Directives = directives
+			if deferDirective != nil && !stripOnly && p.directiveEnabled(deferDirective) {
+				// nested incremental directives are delivered with the fragment
+				fragment.SelectionSet = p.split(fragment.SelectionSet, path, true)
+				p.deferred = append(p.deferred, deferredFragment{
+					label:    p.stringArgument(deferDirective, "label"),
+					path:     path,
+					fragment: &fragment,
+				})
+				continue
+			}
+			fragment.SelectionSet = p.split(fragment.SelectionSet, appendSelection(path, &fragment), stripOnly)
+			selections = append(selections, &fragment)
+		}
+	}
+	if len(selections) == 0 {
+		// keep the selection set valid when everything was deferred
+		selections = append(selections, &ast.Field{
+			Kind: kinds.Field,
+			Name: &ast.Name{Kind: kinds.Name, Value: "__typename"},
+		})
+	}
+	return &ast.SelectionSet{Kind: kinds.SelectionSet, Selections: selections}
+}
+
+// query prints the operation with the given selection set as a document,
+// keeping the variable definitions it uses.
+func (p *incrementalPlan) query(set *ast.SelectionSet) string {
+	used := make(map[string]bool)
+	collectVariables(set, used)
+
+	definition := *p.op.definition
+	definition.SelectionSet = set
+	definition.VariableDefinitions = nil
+	for _, def := range p.op.definition.VariableDefinitions {
+		if def.Variable != nil && def.Variable.Name != nil && used[def.Variable.Name.Value] {
+			definition.VariableDefinitions = append(definition.VariableDefinitions, def)
+		}
+	}
+	doc := &ast.Document{Kind: kinds.Document, Definitions: []ast.Node{&definition}}
+	return printer.Print(doc).(string)
+}
+
+// deferredQuery selects the deferred fragment through its path.
+func (p *incrementalPlan) deferredQuery(d deferredFragment) string {
+	set := &ast.SelectionSet{Kind: kinds.SelectionSet, Selections: []ast.Selection{d.fragment}}
+	for i := len(d.path) - 1; i >= 0; i-- {
+		switch selection := d.path[i].(type) {
+		case *ast.Field:
+			field := *selection
+			field.SelectionSet = set
+			set = &ast.SelectionSet{Kind: kinds.SelectionSet, Selections: []ast.Selection{&field}}
+		case *ast.InlineFragment:
+			fragment := *selection
+			fragment.SelectionSet = set
+			set = &ast.SelectionSet{Kind: kinds.SelectionSet, Selections: []ast.Selection{&fragment}}
+		}
+	}
+	return p.query(set)
+}
+
+func (p *incrementalPlan) directiveEnabled(directive *ast.Directive) bool {
+	for _, arg := range directive.Arguments {
+		if arg.Name != nil && arg.Name.Value == "if" {
+			value, _ := p.value(arg.Value).(bool)
+			return value
+		}
+	}
+	return true
+}
+
+func (p *incrementalPlan) intArgument(directive *ast.Directive, name string) (int, bool) {
+	for _, arg := range directive.Arguments {
+		if arg.Name == nil || arg.Name.Value != name {
+			continue
+		}
+		switch value := p.value(arg.Value).(type) {
+		case string:
+			n, err := strconv.Atoi(value)
+			return n, err == nil
+		case float64:
+			return int(value), true
+		case int:
+			return value, true
+		}
+	}
+	return 0, false
+}
+
+func (p *incrementalPlan) stringArgument(directive *ast.Directive, name string) string {
+	for _, arg := range directive.Arguments {
+		if arg.Name != nil && arg.Name.Value == name {
+			value, _ := p.value(arg.Value).(string)
+			return value
+		}
+	}
+	return ""
+}
+
+// value resolves a directive argument literal or variable.
+func (p *incrementalPlan) value(value ast.Value) interface{} {
+	switch value := value.(type) {
+	case *ast.Variable:
+		if value.Name == nil {
+			return nil
+		}
+		return p.variables[value.Name.Value]
+	case *ast.BooleanValue:
+		return value.Value
+	case *ast.IntValue:
+		return value.Value
+	case *ast.StringValue:
+		return value.Value
+	}
+	return nil
+}
+
+func hasDirective(directives []*ast.Directive, name string) bool {
+	for _, directive := range directives {
+		if directive.Name != nil && directive.Name.Value == name {
+			return true
+		}
+	}
+	return false
+}
+
+// takeDirective returns the directives without the named one, and the
+// removed directive.
+func takeDirective(directives []*ast.Directive, name string) ([]*ast.Directive, *ast.Directive) {
+	var taken *ast.Directive
+	kept := make([]*ast.Directive, 0, len(directives))
+	for _, directive := range directives {
+		if taken == nil && directive.Name != nil && directive.Name.Value == name {
+			taken = directive
+			continue
+		}
+		kept = append(kept, directive)
+	}
+	return kept, taken
+}
+
+func appendSelection(path []ast.Selection, selection ast.Selection) []ast.Selection {
+	return append(path[:len(path):len(path)], selection)
+}
+
+// collectVariables records the names of the variables used in the set.
+func collectVariables(set *ast.SelectionSet, used map[string]bool) {
+	if set == nil {
+		return
+	}
+	collectDirectives := func(directives []*ast.Directive) {
+		for _, directive := range directives {
+			for _, arg := range directive.Arguments {
+				collectValueVariables(arg.Value, used)
+			}
+		}
+	}
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			for _, arg := range selection.Arguments {
+				collectValueVariables(arg.Value, used)
+			}
+			collectDirectives(selection.Directives)
+			collectVariables(selection.SelectionSet, used)
+		case *ast.InlineFragment:
+			collectDirectives(selection.Directives)
+			collectVariables(selection.SelectionSet, used)
+		}
+	}
+}
+
+func collectValueVariables(value ast.Value, used map[string]bool) {
+	switch value := value.(type) {
+	case *ast.Variable:
+		if value.Name != nil {
+			used[value.Name.Value] = true
+		}
+	case *ast.ListValue:
+		for _, item := range value.Values {
+			collectValueVariables(item, used)
+		}
+	case *ast.ObjectValue:
+		for _, field := range value.Fields {
+			collectValueVariables(field.Value, used)
+		}
+	}
+}
+
+// responsePath returns the response keys of the fields of a path.
+func responsePath(path []ast.Selection) []string {
+	keys := []string{}
+	for _, selection := range path {
+		if field, ok := selection.(*ast.Field); ok {
+			keys = append(keys, fieldResponseKey(field))
+		}
+	}
+	return keys
+}
+
+func fieldResponseKey(field *ast.Field) string {
+	if field.Alias != nil {
+		return field.Alias.Value
+	}
+	return field.Name.Value
+}
+
+// visitObjects calls fn for every object found at the response keys, lists
+// along the way being expanded.
+func visitObjects(data interface{}, keys []string, path []interface{}, fn func(object map[string]interface{}, path []interface{})) {
+	switch value := data.(type) {
+	case []interface{}:
+		for i, item := range value {
+			visitObjects(item, keys, append(path[:len(path):len(path)], i), fn)
+		}
+	case map[string]interface{}:
+		if len(keys) == 0 {
+			fn(value, path)
+			return
+		}
+		visitObjects(value[keys[0]], keys[1:], append(path[:len(path):len(path)], keys[0]), fn)
+	}
+}
+
+// acceptsIncrementalDelivery reports whether the client accepts multipart
+// responses following the supported incremental delivery spec.
+func acceptsIncrementalDelivery(r *http.Request) bool {
+	for _, part := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(part)
+		if err == nil && mediaType == ContentTypeMultipartMixed && params["deferspec"] == incrementalSpec && params["q"] != "0" {
+			return true
+		}
+	}
+	return false
+}
+
+// incrementalPayload is a part of a multipart response.
+type incrementalPayload struct {
+	Data        interface{}                `json:"data,omitempty"`
+	Errors      []gqlerrors.FormattedError `json:"errors,omitempty"`
+	Extensions  map[string]interface{}     `json:"extensions,omitempty"`
+	Incremental []incrementalResult        `json:"incremental,omitempty"`
+	HasNext     bool                       `json:"hasNext"`
+}
+
+type incrementalResult struct {
+	Data   interface{}                `json:"data,omitempty"`
+	Items  []interface{}              `json:"items,omitempty"`
+	Path   []interface{}              `json:"path"`
+	Label  string                     `json:"label,omitempty"`
+	Errors []gqlerrors.FormattedError `json:"errors,omitempty"`
+}
+
+// multipartWriter writes the parts of an incremental response.
+type multipartWriter struct {
+	w http.ResponseWriter
+}
+
+func newMultipartWriter(w http.ResponseWriter) *multipartWriter {
+	w.Header().Set("Content-Type", ContentTypeMultipartMixed+`; boundary="`+incrementalBoundary+`"; deferSpec=`+incrementalSpec)
+	w.WriteHeader(http.StatusOK)
+	return &multipartWriter{w: w}
+}
+
+func (m *multipartWriter) write(body []byte, hasNext bool) {
+	m.w.Write([]byte("\r\n--" + incrementalBoundary + "\r\nContent-Type: application/json; charset=utf-8\r\n\r\n"))
+	m.w.Write(body)
+	if !hasNext {
+		m.w.Write([]byte("\r\n--" + incrementalBoundary + "--\r\n"))
+	}
+	if flusher, ok := m.w.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// executeIncremental executes the operation once and splits its result
+// into the initial part, the deferred fragments and the streamed items. It
+// buffers the whole result before writing the first part, as graphql-go
+// cannot report fields as they resolve. Parts over Config.MaxResponseBytes
+// in total are replaced by a single error response. It returns the result
+// and the initial payload.
+func (h *Handler) executeIncremental(r *http.Request, w http.ResponseWriter, op *operation, params graphql.Params, plan *incrementalPlan, strict bool) (*graphql.Result, []byte) {
+	ctx := params.Context
+	params.RequestString = plan.query(plan.full)
+	result := h.executeQuery(r, op, params)
+	h.finishResult(ctx, result)
+	h.countErrors(ctx, result)
+
+	var incremental [][]incrementalResult
+	for _, d := range plan.deferred {
+		var parts []incrementalResult
+		visitObjects(result.Data, responsePath(d.path), nil, func(object map[string]interface{}, path []interface{}) {
+			data := project(nil, object, d.fragment.SelectionSet)
+			if data, ok := data.(map[string]interface{}); !ok || len(data) == 0 {
+				return
+			}
+			parts = append(parts, incrementalResult{Data: data, Path: path, Label: d.label})
+		})
+		if len(parts) > 0 {
+			incremental = append(incremental, parts)
+		}
+	}
+	initial := project(nil, result.Data, plan.initial)
+
+	// cut the streamed lists to their initial count
+	for _, stream := range plan.streamed {
+		keys := responsePath(stream.path)
+		visitObjects(initial, keys[:len(keys)-1], nil, func(object map[string]interface{}, path []interface{}) {
+			key := keys[len(keys)-1]
+			list, ok := object[key].([]interface{})
+			if !ok || len(list) <= stream.initialCount {
+				return
+			}
+			for i := stream.initialCount; i < len(list); i++ {
+				incremental = append(incremental, []incrementalResult{{
+					Items: []interface{}{list[i]},
+					Path:  append(append(path[:len(path):len(path)], key), i),
+					Label: stream.label,
+				}})
+			}
+			object[key] = list[:stream.initialCount]
+		})
+	}
+
+	payloads := []incrementalPayload{{
+		Data:       initial,
+		Errors:     result.Errors,
+		Extensions: result.Extensions,
+		HasNext:    len(incremental) > 0,
+	}}
+	for i, parts := range incremental {
+		payloads = append(payloads, incrementalPayload{Incremental: parts, HasNext: i < len(incremental)-1})
+	}
+	bodies := make([][]byte, len(payloads))
+	size := 0
+	for i, payload := range payloads {
+		bodies[i], _ = json.Marshal(payload)
+		size += len(bodies[i])
+	}
+
+	response := ResponseFromContext(ctx)
+	if tooLarge, buff, status, contentType := h.guardResponseSize(ctx, r, strict, size); tooLarge != nil {
+		if response != nil {
+			status = response.writeHeader(w, status)
+		}
+		w.Header().Set("Content-Type", contentType)
+		h.writeBody(w, r, status, buff)
+		return tooLarge, buff
+	}
+
+	if response != nil {
+		response.writeHeader(w, http.StatusOK)
+	}
+	out := newMultipartWriter(w)
+	for i, body := range bodies {
+		out.write(body, payloads[i].HasNext)
+	}
+	return result, bodies[0]
+}
+
+// project copies the parts of the value selected by the set into target,
+// merging them with the parts already copied.
+func project(target, value interface{}, set *ast.SelectionSet) interface{} {
+	if set == nil {
+		return value
+	}
+	switch value := value.(type) {
+	case []interface{}:
+		list, ok := target.([]interface{})
+		if !ok || len(list) != len(value) {
+			list = make([]interface{}, len(value))
+		}
+		for i, item := range value {
+			list[i] = project(list[i], item, set)
+		}
+		return list
+	case map[string]interface{}:
+		object, ok := target.(map[string]interface{})
+		if !ok {
+			object = make(map[string]interface{})
+		}
+		for _, selection := range set.Selections {
+			switch selection := selection.(type) {
+			case *ast.Field:
+				key := fieldResponseKey(selection)
+				if fieldValue, ok := value[key]; ok {
+					object[key] = project(object[key], fieldValue, selection.SelectionSet)
+				}
+			case *ast.InlineFragment:
+				project(object, value, selection.SelectionSet)
+			}
+		}
+		return object
+	}
+	return value
+}
@@ -0,0 +1,216 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func executeIncrementalTest(t *testing.T, h *Handler, query string) []map[string]interface{} {
+	req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape(query), nil)
+	req.Header.Set("Accept", "multipart/mixed; deferSpec=20220824, application/json")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	mediaType, params, err := mime.ParseMediaType(resp.Header().Get("Content-Type"))
+	if err != nil || mediaType != ContentTypeMultipartMixed {
+		t.Fatalf("expected a multipart response, got %q", resp.Header().Get("Content-Type"))
+	}
+	var payloads []map[string]interface{}
+	reader := multipart.NewReader(resp.Body, params["boundary"])
+	for {
+		part, err := reader.NextPart()
+		if err != nil {
+			break
+		}
+		body, _ := ioutil.ReadAll(part)
+		var payload map[string]interface{}
+		if err := json.Unmarshal(body, &payload); err != nil {
+			t.Fatalf("invalid part %s: %v", body, err)
+		}
+		payloads = append(payloads, payload)
+	}
+	return payloads
+}
+
+func TestHandler_IncrementalDelivery_Defer(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema, IncrementalDelivery: true})
+	payloads := executeIncrementalTest(t, h, `{ hero { id ...HeroName @defer(label: "name", if: true) } } fragment HeroName on Character { name }`)
+	if len(payloads) != 2 {
+		t.Fatalf("expected 2 parts, got %v", payloads)
+	}
+
+	expectedInitial := map[string]interface{}{
+		"data":    map[string]interface{}{"hero": map[string]interface{}{"id": "2001"}},
+		"hasNext": true,
+	}
+	if !reflect.DeepEqual(payloads[0], expectedInitial) {
+		t.Fatalf("unexpected initial payload %v", payloads[0])
+	}
+	expectedDeferred := map[string]interface{}{
+		"incremental": []interface{}{map[string]interface{}{
+			"data":  map[string]interface{}{"name": "R2-D2"},
+			"path":  []interface{}{"hero"},
+			"label": "name",
+		}},
+		"hasNext": false,
+	}
+	if !reflect.DeepEqual(payloads[1], expectedDeferred) {
+		t.Fatalf("unexpected deferred payload %v", payloads[1])
+	}
+}
+
+func TestHandler_IncrementalDelivery_Stream(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema, IncrementalDelivery: true})
+	payloads := executeIncrementalTest(t, h, `{ hero { friends @stream(initialCount: 1) { name } } }`)
+	if len(payloads) != 3 {
+		t.Fatalf("expected 3 parts, got %v", payloads)
+	}
+
+	expectedInitial := map[string]interface{}{
+		"data": map[string]interface{}{"hero": map[string]interface{}{
+			"friends": []interface{}{map[string]interface{}{"name": "Luke Skywalker"}},
+		}},
+		"hasNext": true,
+	}
+	if !reflect.DeepEqual(payloads[0], expectedInitial) {
+		t.Fatalf("unexpected initial payload %v", payloads[0])
+	}
+	expectedLast := map[string]interface{}{
+		"incremental": []interface{}{map[string]interface{}{
+			"items": []interface{}{map[string]interface{}{"name": "Leia Organa"}},
+			"path":  []interface{}{"hero", "friends", float64(2)},
+		}},
+		"hasNext": false,
+	}
+	if !reflect.DeepEqual(payloads[2], expectedLast) {
+		t.Fatalf("unexpected last payload %v", payloads[2])
+	}
+}
+
+func TestHandler_IncrementalDelivery_WithoutMultipartAccept(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema, IncrementalDelivery: true})
+	query := url.QueryEscape(`{ hero { id ... @defer { name } } }`)
+	req, _ := http.NewRequest("GET", "/graphql?query="+query, nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	var result map[string]interface{}
+	json.Unmarshal(resp.Body.Bytes(), &result)
+	expected := map[string]interface{}{
+		"data": map[string]interface{}{"hero": map[string]interface{}{"id": "2001", "name": "R2-D2"}},
+	}
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("expected the whole response at once, got %s", resp.Body.String())
+	}
+}
+
+func TestHandler_IncrementalDelivery_ExecutesOnce(t *testing.T) {
+	var executions int32
+	viewer := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Viewer",
+		Fields: graphql.Fields{
+			"name":  &graphql.Field{Type: graphql.String},
+			"email": &graphql.Field{Type: graphql.String},
+		},
+	})
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"viewer": &graphql.Field{
+					Type: viewer,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						atomic.AddInt32(&executions, 1)
+						return map[string]interface{}{"name": "Luke", "email": "luke@example.com"}, nil
+					},
+				},
+			},
+		}),
+	})
+	h := New(&Config{Schema: &schema, IncrementalDelivery: true})
+
+	payloads := executeIncrementalTest(t, h, `{ viewer { name ... @defer { email } } }`)
+	if len(payloads) != 2 {
+		t.Fatalf("expected 2 parts, got %v", payloads)
+	}
+	if executions != 1 {
+		t.Fatalf("expected the operation to execute once, got %d executions", executions)
+	}
+	expected := map[string]interface{}{"email": "luke@example.com"}
+	if data := payloads[1]["incremental"].([]interface{})[0].(map[string]interface{})["data"]; !reflect.DeepEqual(data, expected) {
+		t.Fatalf("unexpected deferred data %v", data)
+	}
+}
+
+func TestHandler_IncrementalDelivery_RequiresDeferSpec(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema, IncrementalDelivery: true})
+	query := url.QueryEscape(`{ hero { id ... @defer { name } } }`)
+	req, _ := http.NewRequest("GET", "/graphql?query="+query, nil)
+	req.Header.Set("Accept", "multipart/mixed, application/json")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	if contentType := resp.Header().Get("Content-Type"); contentType != "application/json; charset=utf-8" {
+		t.Fatalf("expected the whole response at once, got %q", contentType)
+	}
+}
+
+func TestHandler_IncrementalDelivery_MaxResponseBytes(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema, IncrementalDelivery: true, MaxResponseBytes: 64})
+	query := url.QueryEscape(`{ hero { friends @stream(initialCount: 0) { name } } }`)
+	req, _ := http.NewRequest("GET", "/graphql?query="+query, nil)
+	req.Header.Set("Accept", "multipart/mixed; deferSpec=20220824")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	if !strings.HasPrefix(resp.Header().Get("Content-Type"), "application/json") || !strings.Contains(resp.Body.String(), string(CodeResponseTooLarge)) {
+		t.Fatalf("expected the parts to be replaced by an error, got %q %s", resp.Header().Get("Content-Type"), resp.Body.String())
+	}
+}
+
+func TestHandler_IncrementalDelivery_ResponseCache(t *testing.T) {
+	var executions int32
+	h := New(&Config{
+		Schema:              newCountingSchema(t, &executions),
+		IncrementalDelivery: true,
+		ResponseCacheTTL:    time.Minute,
+		ResponseCacheKeyFn: func(ctx context.Context, r *http.Request) (string, bool) {
+			return "public", true
+		},
+	})
+	for i := 0; i < 2; i++ {
+		payloads := executeIncrementalTest(t, h, `{ ... @defer { product } }`)
+		if len(payloads) != 2 {
+			t.Fatalf("expected 2 parts, got %v", payloads)
+		}
+	}
+	if executions := atomic.LoadInt32(&executions); executions != 1 {
+		t.Fatalf("expected the cached response to be split, got %d executions", executions)
+	}
+}
+
+func TestHandler_IncrementalDelivery_Metrics(t *testing.T) {
+	metrics := NewMetrics("", 1)
+	h := New(&Config{Schema: &testutil.StarWarsSchema, IncrementalDelivery: true, Metrics: metrics})
+	executeIncrementalTest(t, h, `{ hero { id ... @defer { secretBackstory } } }`)
+
+	resp := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(resp, httptest.NewRequest("GET", "/metrics", nil))
+	if line := `graphql_errors_total{code="EXECUTION_ERROR"} 1`; !strings.Contains(resp.Body.String(), line) {
+		t.Errorf("expected %s in\n%s", line, resp.Body.String())
+	}
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"regexp"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/kinds"
+	"github.com/graphql-go/graphql/language/visitor"
+)
+
+// NoIntrospectionRule is a validation rule rejecting the __schema and __type
+// introspection fields, __typename remaining allowed.
+func NoIntrospectionRule(context *graphql.ValidationContext) *graphql.ValidationRuleInstance {
+	return &graphql.ValidationRuleInstance{
+		VisitorOpts: &visitor.VisitorOptions{
+			KindFuncMap: map[string]visitor.NamedVisitFuncs{
+				kinds.Field: {
+					Kind: func(p visitor.VisitFuncParams) (string, interface{}) {
+						if field, ok := p.Node.(*ast.Field); ok && field.Name != nil {
+							if name := field.Name.Value; name == "__schema" || name == "__type" {
+								context.ReportError(gqlerrors.NewError(
+									"GraphQL introspection is not allowed, but the query contained "+name,
+									[]ast.Node{field}, "", nil, []int{}, nil,
+								))
+							}
+						}
+						return visitor.ActionNoChange, nil
+					},
+				},
+			},
+		},
+	}
+}
+
+// IntrospectionAllowedFn reports whether a request may select introspection
+// fields, e.g. from internal tooling.
+type IntrospectionAllowedFn func(ctx context.Context, r *http.Request) bool
+
+// checkIntrospection rejects the documents selecting introspection fields
+// when Config.DisableIntrospection is set or the IntrospectionAllowedFn
+// disallows the request.
+func (h *Handler) checkIntrospection(ctx context.Context, r *http.Request, op *operation, strict bool) *requestError {
+	if op == nil || (!h.disableIntrospection && h.introspectionAllowedFn == nil) {
+		return nil
+	}
+	if h.introspectionAllowedFn != nil && h.introspectionAllowedFn(ctx, r) {
+		return nil
+	}
+	result := graphql.ValidateDocument(h.schema(), op.document, []graphql.ValidationRuleFn{NoIntrospectionRule})
+	if len(result.Errors) > 0 {
+		return validationError(strict, CodeIntrospectionDisabled, result.Errors[0].Message)
+	}
+	return nil
+}
+
+var suggestionPattern = regexp.MustCompile(` Did you mean .*\?$`)
+
+// stripSuggestions returns the errors without the "Did you mean" suggestions
+// of validation errors, which reveal the schema.
+func stripSuggestions(errs []gqlerrors.FormattedError) []gqlerrors.FormattedError {
+	stripped := make([]gqlerrors.FormattedError, len(errs))
+	for i, err := range errs {
+		err.Message = suggestionPattern.ReplaceAllString(err.Message, "")
+		stripped[i] = err
+	}
+	return stripped
+}
@@ -0,0 +1,79 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestHandler_DisableIntrospection(t *testing.T) {
+	schema := limitsSchema(t, new(int))
+	h := New(&Config{Schema: &schema, DisableIntrospection: true})
+
+	tests := []struct {
+		query    string
+		rejected string
+	}{
+		{query: "{ name __typename }"},
+		{query: "{ __schema { queryType { name } } }", rejected: "__schema"},
+		{query: `{ ...f } fragment f on Query { __type(name: "Query") { name } }`, rejected: "__type"},
+	}
+	for _, test := range tests {
+		_, errs := limitsRequest(h, test.query)
+		if test.rejected == "" {
+			if len(errs) != 0 {
+				t.Errorf("%s: unexpected errors %v", test.query, errs)
+			}
+			continue
+		}
+		if len(errs) != 1 || !strings.Contains(errs[0], "introspection is not allowed, but the query contained "+test.rejected) {
+			t.Errorf("%s: unexpected errors %v", test.query, errs)
+		}
+	}
+
+	h = New(&Config{Schema: &schema, DisableIntrospection: true, StatusCodes: true})
+	if resp, _ := limitsRequest(h, "{ __schema { queryType { name } } }"); resp.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", resp.Code)
+	}
+}
+
+func TestHandler_IntrospectionAllowedFn(t *testing.T) {
+	schema := limitsSchema(t, new(int))
+	h := New(&Config{
+		Schema: &schema,
+		IntrospectionAllowedFn: func(ctx context.Context, r *http.Request) bool {
+			return r.Header.Get("X-Internal-Token") == "secret"
+		},
+	})
+
+	query := "{ __schema { queryType { name } } }"
+	if _, errs := limitsRequest(h, query); len(errs) != 1 || !strings.Contains(errs[0], "introspection is not allowed") {
+		t.Errorf("expected public requests to be rejected, got %v", errs)
+	}
+
+	req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape(query), nil)
+	req.Header.Set("X-Internal-Token", "secret")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if expected := `{"data":{"__schema":{"queryType":{"name":"Query"}}}}`; resp.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, resp.Body.String())
+	}
+}
+
+func TestHandler_HideSuggestions(t *testing.T) {
+	schema := limitsSchema(t, new(int))
+	query := "{ nam }"
+
+	h := New(&Config{Schema: &schema})
+	if _, errs := limitsRequest(h, query); len(errs) != 1 || !strings.Contains(errs[0], `Did you mean "name"?`) {
+		t.Fatalf("expected a suggestion, got %v", errs)
+	}
+
+	h = New(&Config{Schema: &schema, HideSuggestions: true})
+	if _, errs := limitsRequest(h, query); len(errs) != 1 || errs[0] != `Cannot query field "nam" on type "Query".` {
+		t.Errorf("expected the suggestion to be stripped, got %v", errs)
+	}
+}
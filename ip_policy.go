@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"errors"
+	"net"
+	"net/http"
+)
+
+// IPPolicyFn decides whether the requests from an IP address are served,
+// before anything else is done with them. The errors it returns reject the
+// requests, with their status when they have a StatusCode method like
+// StatusError, 403 otherwise. The IP is that of the connection, nil when it
+// cannot be parsed.
+type IPPolicyFn func(ip net.IP) error
+
+// ErrIPForbidden is the error of the IPPolicyFn returned by AllowCIDRs and
+// DenyCIDRs.
+var ErrIPForbidden = errors.New("IP address not allowed")
+
+// AllowCIDRs returns an IPPolicyFn allowing only the IP addresses in the
+// CIDR ranges, e.g. "10.0.0.0/8".
+func AllowCIDRs(cidrs ...string) (IPPolicyFn, error) {
+	networks, err := parseCIDRs(cidrs)
+	if err != nil {
+		return nil, err
+	}
+	return func(ip net.IP) error {
+		if !containsIP(networks, ip) {
+			return ErrIPForbidden
+		}
+		return nil
+	}, nil
+}
+
+// DenyCIDRs returns an IPPolicyFn rejecting the IP addresses in the CIDR
+// ranges.
+func DenyCIDRs(cidrs ...string) (IPPolicyFn, error) {
+	networks, err := parseCIDRs(cidrs)
+	if err != nil {
+		return nil, err
+	}
+	return func(ip net.IP) error {
+		if ip == nil || containsIP(networks, ip) {
+			return ErrIPForbidden
+		}
+		return nil
+	}, nil
+}
+
+func parseCIDRs(cidrs []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(cidrs))
+	for _, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return nil, err
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func containsIP(networks []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkIPPolicy rejects the requests from the IP addresses the
+// Config.IPPolicyFn does not allow.
+func (h *Handler) checkIPPolicy(r *http.Request) *requestError {
+	if h.ipPolicyFn == nil {
+		return nil
+	}
+	if err := h.ipPolicyFn(net.ParseIP(clientIP(r))); err != nil {
+		return statusRequestError(err, http.StatusForbidden, CodeIPForbidden)
+	}
+	return nil
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestAllowCIDRs(t *testing.T) {
+	if _, err := AllowCIDRs("10.0.0.0/33"); err == nil {
+		t.Errorf("expected the malformed CIDR to be rejected")
+	}
+	allow, _ := AllowCIDRs("10.0.0.0/8", "fd00::/8")
+	deny, _ := DenyCIDRs("10.1.0.0/16")
+	for _, tc := range []struct {
+		ip            string
+		allow, denied bool
+	}{
+		{"10.1.2.3", true, true},
+		{"10.2.0.1", true, false},
+		{"192.168.0.1", false, false},
+		{"fd00::1", true, false},
+		{"", false, true},
+	} {
+		ip := net.ParseIP(tc.ip)
+		if got := allow(ip) == nil; got != tc.allow {
+			t.Errorf("AllowCIDRs(%q) = %v", tc.ip, got)
+		}
+		if got := deny(ip) != nil; got != tc.denied {
+			t.Errorf("DenyCIDRs(%q) = %v", tc.ip, got)
+		}
+	}
+}
+
+func TestHandler_IPPolicyFn(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   "Query",
+			Fields: graphql.Fields{"name": &graphql.Field{Type: graphql.String}},
+		}),
+	})
+	allow, _ := AllowCIDRs("10.0.0.0/8")
+	h := New(&Config{Schema: &schema, IPPolicyFn: allow})
+	query := func(remoteAddr string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ name }"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.RemoteAddr = remoteAddr
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := query("10.0.0.1:1234"); resp.Code != http.StatusOK {
+		t.Errorf("expected the internal request to be executed, got %d %s", resp.Code, resp.Body.String())
+	}
+	if resp := query("203.0.113.1:1234"); resp.Code != http.StatusForbidden || !strings.Contains(resp.Body.String(), string(CodeIPForbidden)) {
+		t.Errorf("expected the external request to be rejected, got %d %s", resp.Code, resp.Body.String())
+	}
+}
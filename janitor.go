@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+// JanitorTask is a background maintenance task run periodically between
+// Handler.StartJanitor and Handler.StopJanitor.
+type JanitorTask struct {
+	Name     string
+	Interval time.Duration
+	// Jitter randomizes every interval by up to this fraction of it, e.g.
+	// 0.1 for ±10%, so tasks of many instances don't run in lockstep.
+	Jitter float64
+	Run    func(ctx context.Context) error
+}
+
+// JanitorTaskStatus reports the executions of a janitor task.
+type JanitorTaskStatus struct {
+	Name         string        `json:"name"`
+	Runs         int           `json:"runs"`
+	Failures     int           `json:"failures"`
+	LastRun      time.Time     `json:"lastRun,omitempty"`
+	LastDuration time.Duration `json:"lastDuration"`
+	LastError    string        `json:"lastError,omitempty"`
+	NextRun      time.Time     `json:"nextRun,omitempty"`
+}
+
+// JanitorRunFn is called after every execution of a janitor task.
+type JanitorRunFn func(name string, duration time.Duration, err error)
+
+// janitor schedules the maintenance tasks of the handler, in place of
+// features spawning their own goroutines.
+type janitor struct {
+	runFn  JanitorRunFn
+	mu     sync.Mutex
+	tasks  []*janitorTask
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type janitorTask struct {
+	JanitorTask
+	status JanitorTaskStatus
+}
+
+func newJanitor(p *Config) *janitor {
+	j := &janitor{runFn: p.JanitorRunFn}
+	for _, task := range p.JanitorTasks {
+		j.add(task)
+	}
+	return j
+}
+
+// add registers a task, to be called before the janitor starts.
+func (j *janitor) add(task JanitorTask) {
+	if task.Interval <= 0 || task.Run == nil {
+		return
+	}
+	j.tasks = append(j.tasks, &janitorTask{JanitorTask: task, status: JanitorTaskStatus{Name: task.Name}})
+}
+
+// StartJanitor starts running the janitor tasks: the handler maintenance
+// and Config.JanitorTasks. It does nothing when they are already running.
+func (h *Handler) StartJanitor() {
+	j := h.janitor
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	if j.cancel != nil {
+		return
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	j.cancel = cancel
+	for _, task := range j.tasks {
+		j.wg.Add(1)
+		go j.loop(ctx, task)
+	}
+}
+
+// running reports whether the janitor tasks are running.
+func (j *janitor) running() bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return j.cancel != nil
+}
+
+// StopJanitor stops running the janitor tasks, waiting for the running ones
+// to return.
+func (h *Handler) StopJanitor() {
+	j := h.janitor
+	j.mu.Lock()
+	cancel := j.cancel
+	j.cancel = nil
+	j.mu.Unlock()
+	if cancel == nil {
+		return
+	}
+	cancel()
+	j.wg.Wait()
+}
+
+// Close stops the janitor and the CacheBroadcaster subscription, after
+// which the handler no longer applies the cache changes of the other
+// instances.
+func (h *Handler) Close() {
+	h.StopJanitor()
+	if h.cacheSubscription != nil {
+		h.cacheSubscription()
+	}
+}
+
+// JanitorStatus reports the executions of the janitor tasks.
+func (h *Handler) JanitorStatus() []JanitorTaskStatus {
+	j := h.janitor
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	statuses := make([]JanitorTaskStatus, 0, len(j.tasks))
+	for _, task := range j.tasks {
+		statuses = append(statuses, task.status)
+	}
+	return statuses
+}
+
+func (j *janitor) loop(ctx context.Context, task *janitorTask) {
+	defer j.wg.Done()
+	for {
+		wait := task.interval()
+		j.mu.Lock()
+		task.status.NextRun = time.Now().Add(wait)
+		j.mu.Unlock()
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		j.run(ctx, task)
+	}
+}
+
+func (j *janitor) run(ctx context.Context, task *janitorTask) {
+	start := time.Now()
+	err := runJanitorTask(ctx, task.Run)
+	duration := time.Since(start)
+
+	j.mu.Lock()
+	task.status.Runs++
+	task.status.LastRun = start
+	task.status.LastDuration = duration
+	task.status.LastError = ""
+	if err != nil {
+		task.status.Failures++
+		task.status.LastError = err.Error()
+	}
+	j.mu.Unlock()
+
+	if j.runFn != nil {
+		j.runFn(task.Name, duration, err)
+	}
+}
+
+// runJanitorTask runs a task, reporting its panics as errors.
+func runJanitorTask(ctx context.Context, run func(ctx context.Context) error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("panic: %v", r)
+		}
+	}()
+	return run(ctx)
+}
+
+func (t *janitorTask) interval() time.Duration {
+	if t.Jitter <= 0 {
+		return t.Interval
+	}
+	return t.Interval + time.Duration(float64(t.Interval)*t.Jitter*(2*rand.Float64()-1))
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestHandler_Janitor(t *testing.T) {
+	schema := limitsSchema(t, new(int))
+	var mu sync.Mutex
+	runs := map[string]int{}
+	h := New(&Config{
+		Schema: &schema,
+		JanitorTasks: []JanitorTask{
+			{
+				Name:     "ok",
+				Interval: 5 * time.Millisecond,
+				Jitter:   0.5,
+				Run:      func(ctx context.Context) error { return nil },
+			},
+			{
+				Name:     "failing",
+				Interval: 5 * time.Millisecond,
+				Run:      func(ctx context.Context) error { panic(errors.New("boom")) },
+			},
+		},
+		JanitorRunFn: func(name string, duration time.Duration, err error) {
+			mu.Lock()
+			runs[name]++
+			mu.Unlock()
+		},
+	})
+
+	h.StartJanitor()
+	h.StartJanitor()
+	time.Sleep(50 * time.Millisecond)
+	h.StopJanitor()
+	h.StopJanitor()
+
+	mu.Lock()
+	okRuns, failingRuns := runs["ok"], runs["failing"]
+	mu.Unlock()
+	if okRuns == 0 || failingRuns == 0 {
+		t.Fatalf("expected both tasks to run, got %v", runs)
+	}
+
+	statuses := h.JanitorStatus()
+	if len(statuses) != 2 {
+		t.Fatalf("expected 2 tasks, got %+v", statuses)
+	}
+	if statuses[0].Name != "ok" || statuses[0].Runs != okRuns || statuses[0].Failures != 0 {
+		t.Errorf("unexpected status %+v", statuses[0])
+	}
+	if statuses[1].Failures != failingRuns || statuses[1].LastError != "panic: boom" {
+		t.Errorf("unexpected status %+v", statuses[1])
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	mu.Lock()
+	defer mu.Unlock()
+	if runs["ok"] != okRuns {
+		t.Errorf("expected the tasks to stop running")
+	}
+}
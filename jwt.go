@@ -0,0 +1,328 @@
+package handler
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// JWTConfig configures the verification of the JSON Web Tokens of the
+// Authorization header. HMAC, RSA PKCS #1 v1.5 and ECDSA signatures are
+// supported.
+type JWTConfig struct {
+	// Keys verify the tokens by key ID, the "" key verifying the tokens
+	// without one: []byte HMAC secrets, *rsa.PublicKey or *ecdsa.PublicKey.
+	Keys map[string]interface{}
+	// JWKSURL serves a JSON Web Key Set verifying the tokens whose key ID is
+	// not in Keys, fetched with the JWKSClient and refreshed every
+	// JWKSRefresh, an hour by default, or on unknown key IDs.
+	JWKSURL     string
+	JWKSClient  *http.Client
+	JWKSRefresh time.Duration
+	// Issuer and Audience, when set, must match the iss and aud claims.
+	Issuer   string
+	Audience string
+	// Leeway tolerates clock skew in the exp and nbf claims.
+	Leeway time.Duration
+	// Optional lets the requests without an Authorization header through,
+	// without claims.
+	Optional bool
+}
+
+// JWTClaims are the claims of a verified token.
+type JWTClaims map[string]interface{}
+
+type jwtClaimsKey struct{}
+
+// JWTClaimsFromContext returns the claims of the token of the request,
+// false when it had none.
+func JWTClaimsFromContext(ctx context.Context) (JWTClaims, bool) {
+	claims, ok := ctx.Value(jwtClaimsKey{}).(JWTClaims)
+	return claims, ok
+}
+
+// jwtRootObjectKey is the RootObject entry holding the token claims.
+const jwtRootObjectKey = "jwtClaims"
+
+const (
+	defaultJWKSRefresh = time.Hour
+	defaultJWKSTimeout = 10 * time.Second
+	// jwksMinRefetch bounds the fetches of the key set on unknown key IDs.
+	jwksMinRefetch = time.Minute
+)
+
+var jwtAlgorithms = map[string]crypto.Hash{
+	"HS256": crypto.SHA256, "HS384": crypto.SHA384, "HS512": crypto.SHA512,
+	"RS256": crypto.SHA256, "RS384": crypto.SHA384, "RS512": crypto.SHA512,
+	"ES256": crypto.SHA256, "ES384": crypto.SHA384, "ES512": crypto.SHA512,
+}
+
+type jwtVerifier struct {
+	JWTConfig
+
+	mu       sync.Mutex
+	jwks     map[string]interface{}
+	fetched  time.Time
+	attempts time.Time
+}
+
+func newJWTVerifier(p *JWTConfig) *jwtVerifier {
+	if p == nil {
+		return nil
+	}
+	v := &jwtVerifier{JWTConfig: *p}
+	if v.JWKSRefresh <= 0 {
+		v.JWKSRefresh = defaultJWKSRefresh
+	}
+	if v.JWKSClient == nil {
+		v.JWKSClient = &http.Client{Timeout: defaultJWKSTimeout}
+	}
+	return v
+}
+
+// verify checks the signature and the claims of the token.
+func (v *jwtVerifier) verify(token string, now time.Time) (JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, errors.New("malformed token header")
+	}
+	hash, ok := jwtAlgorithms[header.Alg]
+	if !ok {
+		return nil, errors.New("unsupported algorithm " + header.Alg)
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, errors.New("malformed token signature")
+	}
+	key, ok := v.key(header.Kid, now)
+	if !ok {
+		return nil, errors.New("unknown key")
+	}
+	if !verifyJWTSignature(header.Alg, hash, key, parts[0]+"."+parts[1], signature) {
+		return nil, errors.New("invalid signature")
+	}
+
+	var claims JWTClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil {
+		return nil, errors.New("malformed token claims")
+	}
+	if exp, ok := claims["exp"].(float64); ok && now.After(time.Unix(int64(exp), 0).Add(v.Leeway)) {
+		return nil, errors.New("token expired")
+	}
+	if nbf, ok := claims["nbf"].(float64); ok && now.Add(v.Leeway).Before(time.Unix(int64(nbf), 0)) {
+		return nil, errors.New("token not valid yet")
+	}
+	if v.Issuer != "" && claims["iss"] != v.Issuer {
+		return nil, errors.New("invalid issuer")
+	}
+	if v.Audience != "" && !jwtAudienceMatches(claims["aud"], v.Audience) {
+		return nil, errors.New("invalid audience")
+	}
+	return claims, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	decoded, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(decoded, v)
+}
+
+func jwtAudienceMatches(aud interface{}, audience string) bool {
+	switch aud := aud.(type) {
+	case string:
+		return aud == audience
+	case []interface{}:
+		for _, a := range aud {
+			if a == audience {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func verifyJWTSignature(alg string, hash crypto.Hash, key interface{}, input string, signature []byte) bool {
+	switch alg[:2] {
+	case "HS":
+		secret, ok := key.([]byte)
+		if !ok {
+			return false
+		}
+		mac := hmac.New(hash.New, secret)
+		mac.Write([]byte(input))
+		return hmac.Equal(mac.Sum(nil), signature)
+	case "RS":
+		public, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return false
+		}
+		hasher := hash.New()
+		hasher.Write([]byte(input))
+		return rsa.VerifyPKCS1v15(public, hash, hasher.Sum(nil), signature) == nil
+	case "ES":
+		public, ok := key.(*ecdsa.PublicKey)
+		if !ok {
+			return false
+		}
+		size := (public.Curve.Params().BitSize + 7) / 8
+		if len(signature) != 2*size {
+			return false
+		}
+		hasher := hash.New()
+		hasher.Write([]byte(input))
+		r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+		return ecdsa.Verify(public, hasher.Sum(nil), r, s)
+	}
+	return false
+}
+
+// key returns the key of the key ID, from the Keys or the key set.
+func (v *jwtVerifier) key(kid string, now time.Time) (interface{}, bool) {
+	if key, ok := v.Keys[kid]; ok {
+		return key, true
+	}
+	if v.JWKSURL == "" {
+		return nil, false
+	}
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	key, ok := v.jwks[kid]
+	if (!ok || now.Sub(v.fetched) > v.JWKSRefresh) && now.Sub(v.attempts) > jwksMinRefetch {
+		v.attempts = now
+		if keys, err := v.fetchJWKS(); err == nil {
+			v.jwks, v.fetched = keys, now
+			key, ok = v.jwks[kid]
+		}
+	}
+	return key, ok
+}
+
+func (v *jwtVerifier) fetchJWKS() (map[string]interface{}, error) {
+	resp, err := v.JWKSClient.Get(v.JWKSURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("unexpected JWKS response status " + resp.Status)
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&set); err != nil {
+		return nil, err
+	}
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if key, ok := jwk.publicKey(); ok {
+			keys[jwk.Kid] = key
+		}
+	}
+	return keys, nil
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	K   string `json:"k"`
+}
+
+func (k jsonWebKey) publicKey() (interface{}, bool) {
+	if k.Use != "" && k.Use != "sig" {
+		return nil, false
+	}
+	decode := func(s string) *big.Int {
+		b, err := base64.RawURLEncoding.DecodeString(s)
+		if err != nil || len(b) == 0 {
+			return nil
+		}
+		return new(big.Int).SetBytes(b)
+	}
+	switch k.Kty {
+	case "RSA":
+		n, e := decode(k.N), decode(k.E)
+		if n == nil || e == nil || !e.IsInt64() {
+			return nil, false
+		}
+		return &rsa.PublicKey{N: n, E: int(e.Int64())}, true
+	case "EC":
+		curves := map[string]elliptic.Curve{"P-256": elliptic.P256(), "P-384": elliptic.P384(), "P-521": elliptic.P521()}
+		curve, ok := curves[k.Crv]
+		x, y := decode(k.X), decode(k.Y)
+		if !ok || x == nil || y == nil {
+			return nil, false
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: x, Y: y}, true
+	case "oct":
+		secret, err := base64.RawURLEncoding.DecodeString(k.K)
+		if err != nil {
+			return nil, false
+		}
+		return secret, true
+	}
+	return nil, false
+}
+
+// authenticateJWT verifies the bearer token of the request when Config.JWT is
+// set, returning the context holding its claims.
+func (h *Handler) authenticateJWT(ctx context.Context, w http.ResponseWriter, r *http.Request) (context.Context, *requestError) {
+	if h.jwt == nil {
+		return ctx, nil
+	}
+	authorization := r.Header.Get("Authorization")
+	if authorization == "" && h.jwt.Optional {
+		return ctx, nil
+	}
+	const prefix = "bearer "
+	if len(authorization) <= len(prefix) || !strings.EqualFold(authorization[:len(prefix)], prefix) {
+		w.Header().Set("WWW-Authenticate", `Bearer`)
+		return ctx, newRequestError(http.StatusUnauthorized, CodeUnauthenticated, "Missing bearer token")
+	}
+	claims, err := h.jwt.verify(strings.TrimSpace(authorization[len(prefix):]), time.Now())
+	if err != nil {
+		w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+		return ctx, newRequestError(http.StatusUnauthorized, CodeUnauthenticated, "Invalid token: "+err.Error())
+	}
+	return context.WithValue(ctx, jwtClaimsKey{}, claims), nil
+}
+
+// withJWTClaims adds the token claims of the request to a copy of the
+// RootObject, which the RootObjectFn may share between requests.
+func withJWTClaims(ctx context.Context, root map[string]interface{}) map[string]interface{} {
+	claims, ok := JWTClaimsFromContext(ctx)
+	if !ok {
+		return root
+	}
+	copied := make(map[string]interface{}, len(root)+1)
+	for key, value := range root {
+		copied[key] = value
+	}
+	copied[jwtRootObjectKey] = map[string]interface{}(claims)
+	return copied
+}
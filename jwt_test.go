@@ -0,0 +1,144 @@
+package handler
+
+import (
+	"context"
+	"crypto"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+func signJWT(t *testing.T, header, claims map[string]interface{}, sign func(input []byte) []byte) string {
+	encode := func(v interface{}) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.RawURLEncoding.EncodeToString(b)
+	}
+	input := encode(header) + "." + encode(claims)
+	return input + "." + base64.RawURLEncoding.EncodeToString(sign([]byte(input)))
+}
+
+func TestJWTVerifier(t *testing.T) {
+	secret := []byte("secret")
+	hs256 := func(input []byte) []byte {
+		mac := hmac.New(sha256.New, secret)
+		mac.Write(input)
+		return mac.Sum(nil)
+	}
+	v := newJWTVerifier(&JWTConfig{Keys: map[string]interface{}{"": secret}, Issuer: "issuer", Audience: "api"})
+	now := time.Now()
+	header := map[string]interface{}{"alg": "HS256"}
+
+	tests := []struct {
+		name   string
+		token  string
+		errMsg string
+	}{
+		{"valid", signJWT(t, header, map[string]interface{}{"sub": "1", "iss": "issuer", "aud": []string{"api"}, "exp": now.Add(time.Hour).Unix()}, hs256), ""},
+		{"expired", signJWT(t, header, map[string]interface{}{"iss": "issuer", "aud": "api", "exp": now.Add(-time.Hour).Unix()}, hs256), "token expired"},
+		{"wrong issuer", signJWT(t, header, map[string]interface{}{"iss": "other", "aud": "api"}, hs256), "invalid issuer"},
+		{"wrong audience", signJWT(t, header, map[string]interface{}{"iss": "issuer", "aud": "other"}, hs256), "invalid audience"},
+		{"forged", signJWT(t, header, map[string]interface{}{"iss": "issuer", "aud": "api"}, func([]byte) []byte { return []byte("forged") }), "invalid signature"},
+		{"none", signJWT(t, map[string]interface{}{"alg": "none"}, map[string]interface{}{}, func([]byte) []byte { return nil }), "unsupported algorithm none"},
+		{"unknown key", signJWT(t, map[string]interface{}{"alg": "HS256", "kid": "other"}, map[string]interface{}{}, hs256), "unknown key"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims, err := v.verify(test.token, now)
+			if test.errMsg == "" {
+				if err != nil || claims["sub"] != "1" {
+					t.Errorf("unexpected result %v %v", claims, err)
+				}
+			} else if err == nil || err.Error() != test.errMsg {
+				t.Errorf("expected %q, got %v", test.errMsg, err)
+			}
+		})
+	}
+}
+
+func TestHandler_JWT(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	jwks := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{{
+			"kty": "RSA",
+			"kid": "k1",
+			"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+			"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+		}}})
+	}))
+	defer jwks.Close()
+
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"subject": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						root, _ := p.Info.RootValue.(map[string]interface{})
+						claims, _ := root["jwtClaims"].(map[string]interface{})
+						return claims["sub"], nil
+					},
+				},
+			},
+		}),
+	})
+	h := New(&Config{Schema: &schema, JWT: &JWTConfig{JWKSURL: jwks.URL}})
+	query := func(authorization string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/graphql?query={subject}", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+
+	token := signJWT(t, map[string]interface{}{"alg": "RS256", "kid": "k1"}, map[string]interface{}{"sub": "alice"}, func(input []byte) []byte {
+		hashed := sha256.Sum256(input)
+		signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, hashed[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return signature
+	})
+	if resp := query("Bearer " + token); resp.Code != http.StatusOK || resp.Body.String() != `{"data":{"subject":"alice"}}` {
+		t.Errorf("unexpected response %d %s", resp.Code, resp.Body.String())
+	}
+
+	resp := query("")
+	if resp.Code != http.StatusUnauthorized || !strings.Contains(resp.Body.String(), string(CodeUnauthenticated)) || resp.Header().Get("WWW-Authenticate") != "Bearer" {
+		t.Errorf("unexpected response %d %s", resp.Code, resp.Body.String())
+	}
+	if resp := query("Bearer " + token[:len(token)-4] + "AAAA"); resp.Code != http.StatusUnauthorized {
+		t.Errorf("expected the forged token to be rejected, got %d", resp.Code)
+	}
+}
+
+func TestWithJWTClaims_CopiesRootObject(t *testing.T) {
+	shared := map[string]interface{}{"tenant": "acme"}
+	ctx := context.WithValue(context.Background(), jwtClaimsKey{}, JWTClaims{"sub": "alice"})
+	root := withJWTClaims(ctx, shared)
+	if _, leaked := shared[jwtRootObjectKey]; leaked || len(shared) != 1 {
+		t.Errorf("expected the shared root object to be left untouched, got %v", shared)
+	}
+	if root["tenant"] != "acme" || root[jwtRootObjectKey].(map[string]interface{})["sub"] != "alice" {
+		t.Errorf("unexpected root object %v", root)
+	}
+}
@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LatencyHistory configures the rolling latency percentiles kept per
+// operation, and the adaptive timeouts derived from them.
+type LatencyHistory struct {
+	// Samples is the number of latest execution durations kept per
+	// operation, 1000 by default.
+	Samples int
+	// TimeoutFactor derives a timeout per operation: its executions time out
+	// after the TimeoutPercentile of its durations, the 99th by default,
+	// times the factor. No timeouts are derived when zero.
+	TimeoutFactor     float64
+	TimeoutPercentile float64
+	// MinTimeout and MaxTimeout bound the derived timeouts, MaxTimeout being
+	// also the timeout of the operations with too few durations when set.
+	MinTimeout time.Duration
+	MaxTimeout time.Duration
+	// MinSamples is the number of durations an operation needs before its
+	// timeout is derived, 100 by default.
+	MinSamples int
+}
+
+// OperationLatency reports the latency percentiles of an operation.
+type OperationLatency struct {
+	Fingerprint   string        `json:"fingerprint"`
+	OperationName string        `json:"operationName,omitempty"`
+	Samples       int           `json:"samples"`
+	P50           time.Duration `json:"p50"`
+	P90           time.Duration `json:"p90"`
+	P99           time.Duration `json:"p99"`
+	// Timeout is the adaptive timeout of the operation, zero when none.
+	Timeout time.Duration `json:"timeout"`
+}
+
+const (
+	defaultLatencySamples    = 1000
+	defaultLatencyMinSamples = 100
+	// latencyTimeoutRefresh is the number of executions after which the
+	// timeout of an operation is derived again.
+	latencyTimeoutRefresh = 16
+	// latencyHistoryTTL is how long the history of an operation not executed
+	// anymore is kept.
+	latencyHistoryTTL = time.Hour
+)
+
+type latencyHistory struct {
+	LatencyHistory
+	mu         sync.Mutex
+	operations map[string]*operationLatencies
+}
+
+// operationLatencies holds the latest durations of an operation in a ring.
+type operationLatencies struct {
+	name      string
+	durations []time.Duration
+	next      int
+	recorded  int
+	timeout   time.Duration
+	lastSeen  time.Time
+}
+
+func newLatencyHistory(p *LatencyHistory) *latencyHistory {
+	if p == nil {
+		return nil
+	}
+	l := &latencyHistory{LatencyHistory: *p, operations: make(map[string]*operationLatencies)}
+	if l.Samples <= 0 {
+		l.Samples = defaultLatencySamples
+	}
+	if l.MinSamples <= 0 {
+		l.MinSamples = defaultLatencyMinSamples
+	}
+	if l.MinSamples > l.Samples {
+		l.MinSamples = l.Samples
+	}
+	if l.TimeoutPercentile <= 0 || l.TimeoutPercentile > 1 {
+		l.TimeoutPercentile = 0.99
+	}
+	return l
+}
+
+// timeout returns the adaptive timeout of the operation, zero when none.
+func (l *latencyHistory) timeout(fingerprint string) time.Duration {
+	if l.TimeoutFactor <= 0 {
+		return 0
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if o, ok := l.operations[fingerprint]; ok && o.timeout > 0 {
+		return o.timeout
+	}
+	return l.MaxTimeout
+}
+
+func (l *latencyHistory) record(fingerprint, name string, duration time.Duration, now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	o, ok := l.operations[fingerprint]
+	if !ok {
+		o = &operationLatencies{name: name, durations: make([]time.Duration, 0, l.Samples)}
+		l.operations[fingerprint] = o
+	}
+	if len(o.durations) < l.Samples {
+		o.durations = append(o.durations, duration)
+	} else {
+		o.durations[o.next] = duration
+	}
+	o.next = (o.next + 1) % l.Samples
+	o.recorded++
+	o.lastSeen = now
+
+	if l.TimeoutFactor > 0 && len(o.durations) >= l.MinSamples && (o.timeout == 0 || o.recorded%latencyTimeoutRefresh == 0) {
+		o.timeout = l.deriveTimeout(percentiles(o.durations, l.TimeoutPercentile)[0])
+	}
+}
+
+func (l *latencyHistory) deriveTimeout(percentile time.Duration) time.Duration {
+	timeout := time.Duration(float64(percentile) * l.TimeoutFactor)
+	if timeout < l.MinTimeout {
+		timeout = l.MinTimeout
+	}
+	if l.MaxTimeout > 0 && timeout > l.MaxTimeout {
+		timeout = l.MaxTimeout
+	}
+	if timeout <= 0 {
+		timeout = time.Millisecond
+	}
+	return timeout
+}
+
+// sweep forgets the operations not executed for latencyHistoryTTL.
+func (l *latencyHistory) sweep(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for fingerprint, o := range l.operations {
+		if now.Sub(o.lastSeen) > latencyHistoryTTL {
+			delete(l.operations, fingerprint)
+		}
+	}
+}
+
+// percentiles returns the percentiles, between 0 and 1, of the durations.
+func percentiles(durations []time.Duration, ps ...float64) []time.Duration {
+	sorted := append([]time.Duration(nil), durations...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	values := make([]time.Duration, len(ps))
+	if len(sorted) == 0 {
+		return values
+	}
+	for i, p := range ps {
+		rank := int(math.Ceil(p*float64(len(sorted)))) - 1
+		if rank < 0 {
+			rank = 0
+		}
+		values[i] = sorted[rank]
+	}
+	return values
+}
+
+// OperationLatencies reports the latency percentiles of the operations
+// executed lately, when Config.LatencyHistory is set.
+func (h *Handler) OperationLatencies() []OperationLatency {
+	if h.latencies == nil {
+		return nil
+	}
+	l := h.latencies
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	latencies := make([]OperationLatency, 0, len(l.operations))
+	for fingerprint, o := range l.operations {
+		p := percentiles(o.durations, 0.5, 0.9, 0.99)
+		latency := OperationLatency{
+			Fingerprint:   fingerprint,
+			OperationName: o.name,
+			Samples:       len(o.durations),
+			P50:           p[0],
+			P90:           p[1],
+			P99:           p[2],
+		}
+		if l.TimeoutFactor > 0 {
+			latency.Timeout = o.timeout
+			if latency.Timeout == 0 {
+				latency.Timeout = l.MaxTimeout
+			}
+		}
+		latencies = append(latencies, latency)
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i].Fingerprint < latencies[j].Fingerprint })
+	return latencies
+}
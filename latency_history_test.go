@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestLatencyHistory_Timeout(t *testing.T) {
+	l := newLatencyHistory(&LatencyHistory{Samples: 10, MinSamples: 5, TimeoutFactor: 2, MinTimeout: 5 * time.Millisecond, MaxTimeout: time.Second})
+	now := time.Now()
+	if timeout := l.timeout("a"); timeout != time.Second {
+		t.Errorf("expected the max timeout without history, got %v", timeout)
+	}
+	for i := 1; i <= 5; i++ {
+		l.record("a", "A", time.Duration(i)*10*time.Millisecond, now)
+	}
+	if timeout := l.timeout("a"); timeout != 100*time.Millisecond {
+		t.Errorf("expected twice the 99th percentile, got %v", timeout)
+	}
+	for i := 0; i < 20; i++ {
+		l.record("b", "B", time.Microsecond, now)
+	}
+	if timeout := l.timeout("b"); timeout != 5*time.Millisecond {
+		t.Errorf("expected the min timeout, got %v", timeout)
+	}
+	if len(l.operations["b"].durations) != 10 {
+		t.Errorf("expected the latest 10 durations to be kept")
+	}
+
+	l.sweep(now.Add(2 * latencyHistoryTTL))
+	if len(l.operations) != 0 {
+		t.Errorf("expected the stale operations to be swept")
+	}
+}
+
+func TestHandler_LatencyHistory(t *testing.T) {
+	delay := time.Duration(0)
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"name": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						time.Sleep(delay)
+						return "name", nil
+					},
+				},
+			},
+		}),
+	})
+	h := New(&Config{Schema: &schema, LatencyHistory: &LatencyHistory{MinSamples: 3, TimeoutFactor: 2, MinTimeout: 20 * time.Millisecond}})
+	query := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"query Name { name }"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+
+	for i := 0; i < 3; i++ {
+		if resp := query(); strings.Contains(resp.Body.String(), "errors") {
+			t.Fatalf("unexpected response %s", resp.Body.String())
+		}
+	}
+	latencies := h.OperationLatencies()
+	if len(latencies) != 1 || latencies[0].OperationName != "Name" || latencies[0].Samples != 3 || latencies[0].Timeout < 20*time.Millisecond || latencies[0].Timeout > 100*time.Millisecond {
+		t.Fatalf("unexpected latencies %+v", latencies)
+	}
+
+	delay = 200 * time.Millisecond
+	if resp := query(); !strings.Contains(resp.Body.String(), "deadline exceeded") {
+		t.Errorf("expected the regressing operation to time out, got %s", resp.Body.String())
+	}
+}
+
+func TestHandler_LatencyHistoryIncrementalDelivery(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema, IncrementalDelivery: true, LatencyHistory: &LatencyHistory{}})
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"query Hero { hero { id ... @defer { name } } }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "multipart/mixed; deferSpec=20220824")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if !strings.Contains(resp.Body.String(), "hasNext") {
+		t.Fatalf("expected a multipart response, got %s", resp.Body.String())
+	}
+	latencies := h.OperationLatencies()
+	if len(latencies) != 1 || latencies[0].OperationName != "Hero" || latencies[0].Samples != 1 {
+		t.Errorf("expected incremental requests to feed the latency history, got %+v", latencies)
+	}
+}
@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// LegacyNormalization is a quirk of legacy clients the lenient parsing
+// tolerates.
+type LegacyNormalization string
+
+const (
+	// NormalizationTrailingComma removes the trailing commas of JSON objects
+	// and arrays, in the body or the variables and extensions parameters.
+	NormalizationTrailingComma LegacyNormalization = "trailing_comma"
+	// NormalizationNullOperationName drops an operationName sent as the
+	// "null" or "undefined" string.
+	NormalizationNullOperationName LegacyNormalization = "null_operation_name"
+	// NormalizationDuplicateParameter keeps the first value of a query
+	// string or form parameter sent several times.
+	NormalizationDuplicateParameter LegacyNormalization = "duplicate_parameter"
+)
+
+// LegacyNormalizationFn is called for each normalization the lenient parsing
+// applies to a request, with the name of the parameter normalized, empty for
+// the whole body.
+type LegacyNormalizationFn func(ctx context.Context, r *http.Request, normalization LegacyNormalization, parameter string)
+
+var legacyParameters = []string{"query", "operationName", "variables", "extensions"}
+
+// normalizeLegacyRequest rewrites the quirks of legacy clients in the
+// parameters and the body of the request when Config.LenientParsing is set.
+func (h *Handler) normalizeLegacyRequest(ctx context.Context, r *http.Request) *http.Request {
+	if !h.lenientParsing {
+		return r
+	}
+	report := func(normalization LegacyNormalization, parameter string) {
+		if h.legacyNormalizationFn != nil {
+			h.legacyNormalizationFn(ctx, r, normalization, parameter)
+		}
+	}
+
+	normalized := r
+	if values, ok := normalizeLegacyValues(r.URL.Query(), report); ok {
+		normalized = r.Clone(r.Context())
+		normalized.URL.RawQuery = values.Encode()
+	}
+
+	if r.Method != http.MethodPost || r.Body == nil || r.Body == http.NoBody {
+		return normalized
+	}
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return normalized
+	}
+	switch requestContentType(r) {
+	case ContentTypeGraphQL:
+	case ContentTypeFormURLEncoded:
+		if values, err := url.ParseQuery(string(body)); err == nil {
+			if values, ok := normalizeLegacyValues(values, report); ok {
+				body = []byte(values.Encode())
+			}
+		}
+	default:
+		body = normalizeLegacyJSON(body, report)
+	}
+	if normalized == r {
+		normalized = r.Clone(r.Context())
+	}
+	normalized.Body = ioutil.NopCloser(bytes.NewReader(body))
+	normalized.ContentLength = int64(len(body))
+	return normalized
+}
+
+func normalizeLegacyValues(values url.Values, report func(LegacyNormalization, string)) (url.Values, bool) {
+	changed := false
+	for _, name := range legacyParameters {
+		if len(values[name]) > 1 {
+			values[name] = values[name][:1]
+			report(NormalizationDuplicateParameter, name)
+			changed = true
+		}
+	}
+	if isNullString(values.Get("operationName")) {
+		values.Del("operationName")
+		report(NormalizationNullOperationName, "operationName")
+		changed = true
+	}
+	for _, name := range []string{"variables", "extensions"} {
+		value := []byte(values.Get(name))
+		if len(value) == 0 || json.Valid(value) {
+			continue
+		}
+		if fixed := stripTrailingCommas(value); json.Valid(fixed) {
+			values.Set(name, string(fixed))
+			report(NormalizationTrailingComma, name)
+			changed = true
+		}
+	}
+	return values, changed
+}
+
+func normalizeLegacyJSON(body []byte, report func(LegacyNormalization, string)) []byte {
+	if !json.Valid(body) {
+		fixed := stripTrailingCommas(body)
+		if !json.Valid(fixed) {
+			return body
+		}
+		body = fixed
+		report(NormalizationTrailingComma, "")
+	}
+
+	var params map[string]json.RawMessage
+	if err := json.Unmarshal(body, &params); err != nil {
+		return body
+	}
+	changed := false
+	var operationName string
+	if json.Unmarshal(params["operationName"], &operationName) == nil && isNullString(operationName) {
+		delete(params, "operationName")
+		report(NormalizationNullOperationName, "operationName")
+		changed = true
+	}
+	for _, name := range []string{"variables", "extensions"} {
+		// variables sent as a string are decoded later
+		var value string
+		if json.Unmarshal(params[name], &value) != nil || json.Valid([]byte(value)) {
+			continue
+		}
+		if fixed := stripTrailingCommas([]byte(value)); json.Valid(fixed) {
+			params[name] = fixed
+			report(NormalizationTrailingComma, name)
+			changed = true
+		}
+	}
+	if !changed {
+		return body
+	}
+	if normalized, err := json.Marshal(params); err == nil {
+		return normalized
+	}
+	return body
+}
+
+func isNullString(s string) bool {
+	return s == "null" || s == "undefined"
+}
+
+// stripTrailingCommas removes the commas followed by the end of a JSON object
+// or array, outside of strings.
+func stripTrailingCommas(data []byte) []byte {
+	stripped := make([]byte, 0, len(data))
+	inString, escaped := false, false
+	for i := 0; i < len(data); i++ {
+		c := data[i]
+		if inString {
+			switch {
+			case escaped:
+				escaped = false
+			case c == '\\':
+				escaped = true
+			case c == '"':
+				inString = false
+			}
+		} else if c == '"' {
+			inString = true
+		} else if c == ',' {
+			j := i + 1
+			for j < len(data) && strings.IndexByte(" \t\r\n", data[j]) >= 0 {
+				j++
+			}
+			if j < len(data) && (data[j] == '}' || data[j] == ']') {
+				continue
+			}
+		}
+		stripped = append(stripped, c)
+	}
+	return stripped
+}
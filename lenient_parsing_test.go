@@ -0,0 +1,62 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestStripTrailingCommas(t *testing.T) {
+	got := string(stripTrailingCommas([]byte(`{"a": [1, 2, ], "b": "x, }", "c": {"d": 1,
+}, }`)))
+	expected := `{"a": [1, 2 ], "b": "x, }", "c": {"d": 1
+} }`
+	if got != expected {
+		t.Errorf("unexpected %s", got)
+	}
+}
+
+func TestHandler_LenientParsing(t *testing.T) {
+	var normalizations []string
+	h := New(&Config{
+		Schema:         &testutil.StarWarsSchema,
+		LenientParsing: true,
+		LegacyNormalizationFn: func(ctx context.Context, r *http.Request, normalization LegacyNormalization, parameter string) {
+			normalizations = append(normalizations, string(normalization)+":"+parameter)
+		},
+	})
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		contentType    string
+		body           string
+		normalizations string
+	}{
+		{"JSON trailing commas", "POST", "/graphql", "application/json", `{"query":"query ($id: String!) { human(id: $id) { name } }","variables":{"id":"1000",},}`, "trailing_comma:"},
+		{"null operation name", "POST", "/graphql", "application/json", `{"query":"query ($id: String!) { human(id: $id) { name } }","variables":"{\"id\":\"1000\",}","operationName":"null"}`, "null_operation_name:operationName,trailing_comma:variables"},
+		{"duplicate parameters", "GET", `/graphql?query=query+($id:+String!)+{+human(id:+$id)+{+name+}+}&variables={"id":"1000",}&operationName=undefined&query=other`, "", "", "duplicate_parameter:query,null_operation_name:operationName,trailing_comma:variables"},
+		{"form", "POST", "/graphql", "application/x-www-form-urlencoded", `query=query+($id:+String!)+{+human(id:+$id)+{+name+}+}&variables={"id":"1000",}`, "trailing_comma:variables"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			normalizations = nil
+			req, _ := http.NewRequest(test.method, test.url, strings.NewReader(test.body))
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+			if resp.Body.String() != `{"data":{"human":{"name":"Luke Skywalker"}}}` {
+				t.Errorf("unexpected response %s", resp.Body.String())
+			}
+			if got := strings.Join(normalizations, ","); got != test.normalizations {
+				t.Errorf("unexpected normalizations %s", got)
+			}
+		})
+	}
+}
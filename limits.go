@@ -0,0 +1,94 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/lexer"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// checkDocument rejects the queries longer than Config.MaxQueryBytes or
+// holding more than Config.MaxQueryTokens tokens, before parsing them.
+func (h *Handler) checkDocument(query string, strict bool) *requestError {
+	if h.maxQueryBytes > 0 && len(query) > h.maxQueryBytes {
+		return validationError(strict, CodeQueryTooLarge, fmt.Sprintf("Query of %d bytes exceeds the maximum of %d", len(query), h.maxQueryBytes))
+	}
+	if h.maxQueryTokens > 0 {
+		next := lexer.Lex(source.NewSource(&source.Source{Body: []byte(query)}))
+		for tokens := 0; ; tokens++ {
+			token, err := next(0)
+			// syntax errors are reported by the parser
+			if err != nil || token.Kind == lexer.EOF {
+				break
+			}
+			if tokens == h.maxQueryTokens {
+				return validationError(strict, CodeQueryTooLarge, fmt.Sprintf("Query exceeds the maximum of %d tokens", h.maxQueryTokens))
+			}
+		}
+	}
+	return nil
+}
+
+// operationSize measures the selections of an operation, counting the fields
+// of fragments every time they are spread.
+type operationSize struct {
+	aliases    int
+	rootFields int
+	selections int
+	depth      int
+}
+
+func measureOperation(schema *graphql.Schema, op *operation) operationSize {
+	var size operationSize
+	op.walkFields(schema, func(parent graphql.Type, field *ast.Field, def *graphql.FieldDefinition, depth int) {
+		if field.Alias != nil {
+			size.aliases++
+		}
+		if depth == 1 {
+			size.rootFields++
+		}
+		if depth > size.depth {
+			size.depth = depth
+		}
+		size.selections++
+	})
+	return size
+}
+
+// checkLimits rejects the operations exceeding the configured limits, before
+// executing them.
+func (h *Handler) checkLimits(op *operation, strict bool) *requestError {
+	if op == nil {
+		return nil
+	}
+	if h.maxAliases <= 0 && h.maxRootFields <= 0 && h.maxSelections <= 0 {
+		return nil
+	}
+	return h.checkSize(measureOperation(h.schema(), op), strict)
+}
+
+func (h *Handler) checkSize(size operationSize, strict bool) *requestError {
+	if h.maxAliases > 0 && size.aliases > h.maxAliases {
+		return validationError(strict, CodeQueryTooComplex, fmt.Sprintf("Operation has %d aliases, exceeding the maximum of %d", size.aliases, h.maxAliases))
+	}
+	if h.maxRootFields > 0 && size.rootFields > h.maxRootFields {
+		return validationError(strict, CodeQueryTooComplex, fmt.Sprintf("Operation has %d root fields, exceeding the maximum of %d", size.rootFields, h.maxRootFields))
+	}
+	if h.maxSelections > 0 && size.selections > h.maxSelections {
+		return validationError(strict, CodeQueryTooComplex, fmt.Sprintf("Operation has %d selections, exceeding the maximum of %d", size.selections, h.maxSelections))
+	}
+	return nil
+}
+
+// validationError reports an operation rejected before execution, with a 400
+// status code only when strict status codes apply, like validation errors.
+func validationError(strict bool, code ErrorCode, message string) *requestError {
+	status := http.StatusOK
+	if strict {
+		status = http.StatusBadRequest
+	}
+	return newRequestError(status, code, message)
+}
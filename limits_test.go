@@ -0,0 +1,119 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func limitsSchema(t *testing.T, resolved *int) graphql.Schema {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"name": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						*resolved++
+						return "name", nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return schema
+}
+
+func limitsRequest(h *Handler, query string) (*httptest.ResponseRecorder, []string) {
+	req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape(query), nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	var result struct {
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &result)
+	var messages []string
+	for _, err := range result.Errors {
+		messages = append(messages, err.Message)
+	}
+	return resp, messages
+}
+
+func TestHandler_MaxAliases(t *testing.T) {
+	resolved := 0
+	schema := limitsSchema(t, &resolved)
+	h := New(&Config{Schema: &schema, MaxAliases: 2})
+
+	if _, errs := limitsRequest(h, "{ a: name b: name name }"); len(errs) != 0 {
+		t.Errorf("unexpected errors %v", errs)
+	}
+
+	resolved = 0
+	resp, errs := limitsRequest(h, "{ a: name ...f } fragment f on Query { b: name c: name }")
+	if resp.Code != http.StatusOK || len(errs) != 1 || !strings.Contains(errs[0], "3 aliases, exceeding the maximum of 2") {
+		t.Errorf("unexpected response %d %v", resp.Code, errs)
+	}
+	if resolved != 0 {
+		t.Errorf("expected resolvers not to run")
+	}
+
+	h = New(&Config{Schema: &schema, MaxAliases: 2, StatusCodes: true})
+	if resp, _ := limitsRequest(h, "{ a: name b: name c: name }"); resp.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", resp.Code)
+	}
+}
+
+func TestHandler_MaxRootFieldsAndSelections(t *testing.T) {
+	resolved := 0
+	schema := limitsSchema(t, &resolved)
+	h := New(&Config{Schema: &schema, MaxRootFields: 2, MaxSelections: 3})
+
+	if _, errs := limitsRequest(h, "{ a: name b: name }"); len(errs) != 0 {
+		t.Errorf("unexpected errors %v", errs)
+	}
+	if _, errs := limitsRequest(h, "{ a: name b: name c: name }"); len(errs) != 1 || !strings.Contains(errs[0], "3 root fields, exceeding the maximum of 2") {
+		t.Errorf("unexpected errors %v", errs)
+	}
+
+	h = New(&Config{Schema: &schema, MaxSelections: 3})
+	resolved = 0
+	_, errs := limitsRequest(h, "{ ...f ...g } fragment f on Query { a: name b: name } fragment g on Query { ...f }")
+	if len(errs) != 1 || !strings.Contains(errs[0], "4 selections, exceeding the maximum of 3") {
+		t.Errorf("unexpected errors %v", errs)
+	}
+	if resolved != 0 {
+		t.Errorf("expected resolvers not to run")
+	}
+}
+
+func TestHandler_MaxQueryBytesAndTokens(t *testing.T) {
+	resolved := 0
+	schema := limitsSchema(t, &resolved)
+	h := New(&Config{Schema: &schema, MaxQueryBytes: 20, MaxQueryTokens: 5})
+
+	if _, errs := limitsRequest(h, "{ a: name }"); len(errs) != 0 {
+		t.Errorf("unexpected errors %v", errs)
+	}
+	if _, errs := limitsRequest(h, "{ name }               "); len(errs) != 1 || !strings.Contains(errs[0], "Query of 23 bytes exceeds the maximum of 20") {
+		t.Errorf("unexpected errors %v", errs)
+	}
+	if _, errs := limitsRequest(h, "{ a: name name }"); len(errs) != 1 || !strings.Contains(errs[0], "maximum of 5 tokens") {
+		t.Errorf("unexpected errors %v", errs)
+	}
+	if _, errs := limitsRequest(h, "{ name "); len(errs) != 1 || !strings.Contains(errs[0], "Syntax Error") {
+		t.Errorf("expected a syntax error, got %v", errs)
+	}
+	if resolved != 1 {
+		t.Errorf("expected resolvers to run once, got %d", resolved)
+	}
+}
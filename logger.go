@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// LogLevel is the level of a log record, numbered like the slog levels.
+type LogLevel int
+
+const (
+	LevelDebug LogLevel = -4
+	LevelInfo  LogLevel = 0
+	LevelWarn  LogLevel = 4
+	LevelError LogLevel = 8
+)
+
+// Logger receives the structured log records of the handler, their
+// attributes given as alternating keys and values like with slog: the
+// access-log records, and the failures the handler otherwise recovers from
+// silently. SlogLogger adapts a *slog.Logger and LogrusLogger a logrus one.
+type Logger interface {
+	Log(ctx context.Context, level LogLevel, msg string, args ...interface{})
+}
+
+// LoggerFunc adapts a function to a Logger.
+type LoggerFunc func(ctx context.Context, level LogLevel, msg string, args ...interface{})
+
+// Log calls f(ctx, level, msg, args...).
+func (f LoggerFunc) Log(ctx context.Context, level LogLevel, msg string, args ...interface{}) {
+	f(ctx, level, msg, args...)
+}
+
+// LeveledLogger is the part of the leveled loggers like those of logrus,
+// *logrus.Logger and *logrus.Entry, used by LogrusLogger.
+type LeveledLogger interface {
+	Debugf(format string, args ...interface{})
+	Infof(format string, args ...interface{})
+	Warnf(format string, args ...interface{})
+	Errorf(format string, args ...interface{})
+}
+
+// LogrusLogger adapts a logrus logger, or any LeveledLogger, to a Logger,
+// appending the attributes to the message as key=value pairs sorted by key.
+func LogrusLogger(logger LeveledLogger) Logger {
+	return LoggerFunc(func(ctx context.Context, level LogLevel, msg string, args ...interface{}) {
+		logf := logger.Infof
+		switch {
+		case level >= LevelError:
+			logf = logger.Errorf
+		case level >= LevelWarn:
+			logf = logger.Warnf
+		case level < LevelInfo:
+			logf = logger.Debugf
+		}
+		logf("%s", msg+formatLogAttributes(args))
+	})
+}
+
+// formatLogAttributes formats alternating keys and values as " key=value"
+// pairs sorted by key, quoting the values holding spaces.
+func formatLogAttributes(args []interface{}) string {
+	pairs := make([]string, 0, len(args)/2)
+	for i := 0; i+1 < len(args); i += 2 {
+		value := fmt.Sprint(args[i+1])
+		if strings.ContainsAny(value, " \t\n\"=") {
+			value = fmt.Sprintf("%q", value)
+		}
+		pairs = append(pairs, fmt.Sprint(args[i])+"="+value)
+	}
+	sort.Strings(pairs)
+	var b strings.Builder
+	for _, pair := range pairs {
+		b.WriteByte(' ')
+		b.WriteString(pair)
+	}
+	return b.String()
+}
+
+func (h *Handler) log(ctx context.Context, level LogLevel, msg string, args ...interface{}) {
+	if h.logger == nil {
+		return
+	}
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	h.logger.Log(ctx, level, msg, args...)
+}
+
+// recoverCallback logs the panic of a callback run in its own goroutine,
+// where it would crash the process, to be deferred.
+func (h *Handler) recoverCallback(ctx context.Context, callback string) {
+	if r := recover(); r != nil {
+		h.log(ctx, LevelError, "callback panicked", "callback", callback, "panic", fmt.Sprint(r))
+	}
+}
+
+// logAccess logs the access-log record of a request once served, at the
+// error level for the server errors.
+func (h *Handler) logAccess(ctx context.Context, r *http.Request, observed *observedRequest, status, size int, duration time.Duration) {
+	if h.logger == nil {
+		return
+	}
+	var name, operationType, hash string
+	if observed.op != nil {
+		name = observed.op.Name()
+		operationType = observed.op.Type()
+		hash = observed.op.fingerprint()
+	}
+	client, _ := ClientInfoFromContext(ctx)
+	level := LevelInfo
+	if status >= http.StatusInternalServerError {
+		level = LevelError
+	}
+	h.logger.Log(ctx, level, "graphql request",
+		"method", r.Method,
+		"operation_name", name,
+		"operation_type", operationType,
+		"document_hash", hash,
+		"status", status,
+		"duration", duration,
+		"response_size", size,
+		"errors", observed.errors,
+		"client", h.clientID(ctx, r),
+		"client_name", client.Name,
+		"client_version", client.Version,
+		"request_id", RequestIDFromContext(ctx),
+	)
+}
@@ -0,0 +1,22 @@
+//go:build go1.21
+// +build go1.21
+
+package handler
+
+import (
+	"context"
+	"log/slog"
+)
+
+// SlogLogger adapts a *slog.Logger to a Logger.
+func SlogLogger(logger *slog.Logger) Logger {
+	return slogLogger{logger}
+}
+
+type slogLogger struct {
+	logger *slog.Logger
+}
+
+func (l slogLogger) Log(ctx context.Context, level LogLevel, msg string, args ...interface{}) {
+	l.logger.Log(ctx, slog.Level(level), msg, args...)
+}
@@ -0,0 +1,21 @@
+//go:build go1.21
+// +build go1.21
+
+package handler
+
+import (
+	"bytes"
+	"context"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestSlogLogger(t *testing.T) {
+	var buf bytes.Buffer
+	logger := SlogLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	logger.Log(context.Background(), LevelWarn, "graphql request", "status", 200)
+	if !strings.Contains(buf.String(), `level=WARN msg="graphql request" status=200`) {
+		t.Errorf("unexpected record %q", buf.String())
+	}
+}
@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+type logRecord struct {
+	level LogLevel
+	msg   string
+	attrs map[string]interface{}
+}
+
+func TestHandler_Logger(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   "Query",
+			Fields: graphql.Fields{"name": &graphql.Field{Type: graphql.String}},
+		}),
+	})
+	var records []logRecord
+	h := New(&Config{Schema: &schema, Logger: LoggerFunc(func(ctx context.Context, level LogLevel, msg string, args ...interface{}) {
+		attrs := make(map[string]interface{})
+		for i := 0; i+1 < len(args); i += 2 {
+			attrs[args[i].(string)] = args[i+1]
+		}
+		records = append(records, logRecord{level, msg, attrs})
+	})})
+
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"query Names { name }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.RemoteAddr = "192.0.2.1:1234"
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	req, _ = http.NewRequest("PUT", "/graphql", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(records) != 2 {
+		t.Fatalf("expected a record per request, got %+v", records)
+	}
+	served := records[0]
+	if served.level != LevelInfo || served.msg != "graphql request" || served.attrs["method"] != "POST" ||
+		served.attrs["operation_name"] != "Names" || served.attrs["operation_type"] != "query" || served.attrs["document_hash"] == "" ||
+		served.attrs["status"] != http.StatusOK || served.attrs["response_size"] != resp.Body.Len() || served.attrs["errors"] != 0 ||
+		served.attrs["client"] != "192.0.2.1" {
+		t.Errorf("unexpected record %+v", served)
+	}
+	rejected := records[1]
+	if rejected.attrs["method"] != "PUT" || rejected.attrs["status"] != http.StatusMethodNotAllowed || rejected.attrs["errors"] != 1 {
+		t.Errorf("unexpected record %+v", rejected)
+	}
+}
+
+type leveledRecorder struct {
+	lines []string
+}
+
+func (l *leveledRecorder) Debugf(format string, args ...interface{}) {
+	l.lines = append(l.lines, "debug "+fmt.Sprintf(format, args...))
+}
+
+func (l *leveledRecorder) Infof(format string, args ...interface{}) {
+	l.lines = append(l.lines, "info "+fmt.Sprintf(format, args...))
+}
+
+func (l *leveledRecorder) Warnf(format string, args ...interface{}) {
+	l.lines = append(l.lines, "warn "+fmt.Sprintf(format, args...))
+}
+
+func (l *leveledRecorder) Errorf(format string, args ...interface{}) {
+	l.lines = append(l.lines, "error "+fmt.Sprintf(format, args...))
+}
+
+func TestLogrusLogger(t *testing.T) {
+	recorder := &leveledRecorder{}
+	logger := LogrusLogger(recorder)
+	logger.Log(context.Background(), LevelWarn, "schema webhook failed", "url", "http://example.com", "error", "connection refused")
+	logger.Log(context.Background(), LevelDebug, "debug")
+	expected := []string{`warn schema webhook failed error="connection refused" url=http://example.com`, "debug debug"}
+	if !reflect.DeepEqual(recorder.lines, expected) {
+		t.Errorf("unexpected lines %q", recorder.lines)
+	}
+}
+
+type failingSerializer struct{}
+
+func (failingSerializer) Serialize(w io.Writer, result *graphql.Result) error {
+	return errors.New("unsupported")
+}
+
+func (failingSerializer) ContentType() string {
+	return "application/x-failing"
+}
+
+func TestHandler_LoggerWarnings(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   "Query",
+			Fields: graphql.Fields{"name": &graphql.Field{Type: graphql.String}},
+		}),
+	})
+	var warnings []string
+	h := New(&Config{
+		Schema:             &schema,
+		ResponseSerializer: failingSerializer{},
+		PersistedOperations: []PersistedOperation{
+			{ID: "abc", Name: "Mismatch", Body: "{ name }"},
+		},
+		Logger: LoggerFunc(func(ctx context.Context, level LogLevel, msg string, args ...interface{}) {
+			if level == LevelWarn {
+				warnings = append(warnings, msg)
+			}
+		}),
+	})
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ name }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	expected := []string{"persisted operation does not match its ID", "response serialization failed, falling back to JSON"}
+	if !reflect.DeepEqual(warnings, expected) {
+		t.Errorf("unexpected warnings %q", warnings)
+	}
+}
@@ -0,0 +1,107 @@
+package handler
+
+import (
+	"context"
+	"time"
+)
+
+// MeterProvider provides the Meter of the handler, shaped like the
+// OpenTelemetry one so that an adapter of a metric.MeterProvider is a few
+// lines long.
+type MeterProvider interface {
+	Meter(name string) Meter
+}
+
+// Meter creates the instruments of the handler.
+type Meter interface {
+	Float64Histogram(name, unit, description string) Float64Histogram
+	Int64Counter(name, unit, description string) Int64Counter
+	Int64UpDownCounter(name, unit, description string) Int64UpDownCounter
+}
+
+// Float64Histogram records measurements, e.g. durations.
+type Float64Histogram interface {
+	Record(ctx context.Context, value float64, attributes map[string]interface{})
+}
+
+// Int64Counter counts monotonically.
+type Int64Counter interface {
+	Add(ctx context.Context, incr int64, attributes map[string]interface{})
+}
+
+// Int64UpDownCounter counts up and down, e.g. the requests being served.
+type Int64UpDownCounter interface {
+	Add(ctx context.Context, incr int64, attributes map[string]interface{})
+}
+
+// meterInstruments are the instruments of the handler, named like the
+// OpenTelemetry semantic conventions.
+type meterInstruments struct {
+	duration Float64Histogram
+	errors   Int64Counter
+	active   Int64UpDownCounter
+	resolver Float64Histogram
+}
+
+func newMeterInstruments(provider MeterProvider) *meterInstruments {
+	if provider == nil {
+		return nil
+	}
+	meter := provider.Meter(TracerName)
+	return &meterInstruments{
+		duration: meter.Float64Histogram("graphql.server.request.duration", "s", "Duration of the GraphQL requests."),
+		errors:   meter.Int64Counter("graphql.server.errors", "{error}", "Errors returned by the GraphQL requests, by code."),
+		active:   meter.Int64UpDownCounter("graphql.server.active_requests", "{request}", "GraphQL requests being served."),
+		resolver: meter.Float64Histogram("graphql.server.resolver.duration", "s", "Duration of the field resolvers, with Config.TraceResolvers."),
+	}
+}
+
+func (m *meterInstruments) requestStarted(ctx context.Context) {
+	if m == nil {
+		return
+	}
+	m.active.Add(ctx, 1, nil)
+}
+
+func (m *meterInstruments) requestFinished(ctx context.Context, op *operation, client ClientInfo, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.active.Add(ctx, -1, nil)
+	attributes := map[string]interface{}{"http.response.status_code": status}
+	if op != nil {
+		attributes["graphql.operation.type"] = op.Type()
+		if name := op.Name(); name != "" {
+			attributes["graphql.operation.name"] = name
+		}
+	}
+	if client.Name != "" {
+		attributes["graphql.client.name"] = client.Name
+		attributes["graphql.client.version"] = client.Version
+	}
+	m.duration.Record(ctx, duration.Seconds(), attributes)
+}
+
+func (m *meterInstruments) resolverFinished(ctx context.Context, coordinate string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.resolver.Record(ctx, duration.Seconds(), map[string]interface{}{"graphql.field.coordinate": coordinate})
+}
+
+func (m *meterInstruments) error(ctx context.Context, code string) {
+	if m == nil {
+		return
+	}
+	m.errors.Add(ctx, 1, map[string]interface{}{"error.type": code})
+}
+
+// recordError counts an error by its code, in the Metrics, with the
+// MeterProvider and the MetricsEmitter.
+func (h *Handler) recordError(ctx context.Context, code string) {
+	h.metrics.error(code)
+	h.meter.error(ctx, code)
+	if h.metricsEmitter != nil {
+		h.metricsEmitter.Count("graphql.errors", 1, map[string]string{"code": code})
+	}
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+type measurement struct {
+	instrument string
+	value      float64
+	attributes map[string]interface{}
+}
+
+type meterRecorder struct {
+	mu           sync.Mutex
+	measurements []measurement
+}
+
+type recordedInstrument struct {
+	name string
+	r    *meterRecorder
+}
+
+func (r *meterRecorder) Meter(name string) Meter {
+	return r
+}
+
+func (r *meterRecorder) Float64Histogram(name, unit, description string) Float64Histogram {
+	return &recordedInstrument{name, r}
+}
+
+func (r *meterRecorder) Int64Counter(name, unit, description string) Int64Counter {
+	return &recordedInstrument{name, r}
+}
+
+func (r *meterRecorder) Int64UpDownCounter(name, unit, description string) Int64UpDownCounter {
+	return &recordedInstrument{name, r}
+}
+
+func (i *recordedInstrument) Record(ctx context.Context, value float64, attributes map[string]interface{}) {
+	i.r.mu.Lock()
+	defer i.r.mu.Unlock()
+	i.r.measurements = append(i.r.measurements, measurement{i.name, value, attributes})
+}
+
+func (i *recordedInstrument) Add(ctx context.Context, incr int64, attributes map[string]interface{}) {
+	i.Record(ctx, float64(incr), attributes)
+}
+
+func TestHandler_MeterProvider(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   "Query",
+			Fields: graphql.Fields{"name": &graphql.Field{Type: graphql.String}},
+		}),
+	})
+	recorder := &meterRecorder{}
+	h := New(&Config{Schema: &schema, MeterProvider: recorder})
+
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"query Names { name }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	req, _ = http.NewRequest("PUT", "/graphql", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	m := recorder.measurements
+	if len(m) != 7 {
+		t.Fatalf("unexpected measurements %+v", m)
+	}
+	if m[0].instrument != "graphql.server.active_requests" || m[0].value != 1 || m[1].value != -1 {
+		t.Errorf("unexpected active requests %+v %+v", m[0], m[1])
+	}
+	duration := m[2]
+	if duration.instrument != "graphql.server.request.duration" || duration.attributes["graphql.operation.name"] != "Names" ||
+		duration.attributes["graphql.operation.type"] != "query" || duration.attributes["http.response.status_code"] != http.StatusOK {
+		t.Errorf("unexpected duration %+v", duration)
+	}
+	if m[4].instrument != "graphql.server.errors" || m[4].attributes["error.type"] != string(CodeMethodNotAllowed) {
+		t.Errorf("unexpected error %+v", m[4])
+	}
+	if m[6].attributes["http.response.status_code"] != http.StatusMethodNotAllowed {
+		t.Errorf("unexpected duration %+v", m[6])
+	}
+}
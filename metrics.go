@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+// DefaultLatencyBuckets are the upper bounds, in seconds, of the buckets of
+// the request duration histogram, those of the Prometheus client.
+var DefaultLatencyBuckets = []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10}
+
+// Metrics instruments the handler, in the Prometheus text exposition format
+// without depending on the Prometheus client:
+//
+//	<namespace>_requests_total{operation_type, status}
+//	<namespace>_requests_in_flight
+//	<namespace>_request_duration_seconds{operation_type}
+//	<namespace>_errors_total{code}
+//	<namespace>_persisted_queries_total{result="hit|miss|register"}
+//	<namespace>_client_requests_total{client_name, client_version}, see Config.MaxClientLabels
+//	<namespace>_resolver_duration_seconds{field}, with Config.TraceResolvers
+//
+// Serve them with Handler, or append them to an existing exposition with
+// WritePrometheus. The errors are counted by the code of the request errors,
+// and of the execution errors with a code extension, EXECUTION_ERROR
+// otherwise.
+//
+// Metrics is not a prometheus.Collector: registering with a registry would
+// make the Prometheus client a dependency of every user of the handler. To
+// serve them with the metrics of a registry, write both expositions from the
+// same handler.
+type Metrics struct {
+	namespace string
+	buckets   []float64
+
+	mu               sync.Mutex
+	inFlight         int64
+	requests         map[string]uint64
+	durations        map[string]*histogram
+	resolvers        map[string]*histogram
+	errors           map[string]uint64
+	persistedQueries map[string]uint64
+	clients          map[string]uint64
+}
+
+type histogram struct {
+	counts []uint64
+	sum    float64
+	count  uint64
+}
+
+// NewMetrics returns Metrics named with the namespace, "graphql" when empty,
+// with the DefaultLatencyBuckets when no bucket is given.
+func NewMetrics(namespace string, buckets ...float64) *Metrics {
+	if namespace == "" {
+		namespace = "graphql"
+	}
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64(nil), buckets...)
+	sort.Float64s(buckets)
+	return &Metrics{
+		namespace:        namespace,
+		buckets:          buckets,
+		requests:         make(map[string]uint64),
+		durations:        make(map[string]*histogram),
+		resolvers:        make(map[string]*histogram),
+		errors:           make(map[string]uint64),
+		persistedQueries: make(map[string]uint64),
+		clients:          make(map[string]uint64),
+	}
+}
+
+func (m *Metrics) requestStarted() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.inFlight++
+	m.mu.Unlock()
+}
+
+func (m *Metrics) requestFinished(operationType string, client ClientInfo, status int, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.inFlight--
+	m.requests[labels("operation_type", operationType, "status", strconv.Itoa(status))]++
+	m.observe(m.durations, labels("operation_type", operationType), duration)
+	if client.Name != "" {
+		m.clients[labels("client_name", client.Name, "client_version", client.Version)]++
+	}
+}
+
+// resolverFinished records the duration of a resolver of the "Type.field"
+// coordinate.
+func (m *Metrics) resolverFinished(coordinate string, duration time.Duration) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.observe(m.resolvers, labels("field", coordinate), duration)
+}
+
+func (m *Metrics) observe(histograms map[string]*histogram, key string, duration time.Duration) {
+	h, ok := histograms[key]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(m.buckets))}
+		histograms[key] = h
+	}
+	seconds := duration.Seconds()
+	for i, bound := range m.buckets {
+		if seconds <= bound {
+			h.counts[i]++
+		}
+	}
+	h.sum += seconds
+	h.count++
+}
+
+func (m *Metrics) error(code string) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.errors[labels("code", code)]++
+	m.mu.Unlock()
+}
+
+// persistedQuery counts the outcome of the persisted query check of a
+// request.
+func (m *Metrics) persistedQuery(opts *RequestOptions, err error) {
+	if m == nil {
+		return
+	}
+	var result string
+	switch {
+	case err == errPersistedQueryNotFound:
+		result = "miss"
+	case err != nil || opts == nil || !opts.HasPersistedParams:
+		return
+	case opts.Persisted:
+		result = "hit"
+	default:
+		result = "register"
+	}
+	m.mu.Lock()
+	m.persistedQueries[labels("result", result)]++
+	m.mu.Unlock()
+}
+
+var labelValueEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// labels formats label pairs in the exposition format.
+func labels(pairs ...string) string {
+	var b strings.Builder
+	for i := 0; i < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(labelValueEscaper.Replace(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+// WritePrometheus writes the metrics in the Prometheus text exposition
+// format.
+func (m *Metrics) WritePrometheus(w io.Writer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	b := bufio.NewWriter(w)
+	writeCounters(b, m.namespace+"_requests_total", "Requests served, by operation type and HTTP status.", m.requests)
+	fmt.Fprintf(b, "# HELP %s_requests_in_flight Requests being served.\n# TYPE %[1]s_requests_in_flight gauge\n%[1]s_requests_in_flight %d\n", m.namespace, m.inFlight)
+	m.writeHistograms(b, m.namespace+"_request_duration_seconds", "Duration of the requests, by operation type.", m.durations)
+	writeCounters(b, m.namespace+"_errors_total", "Errors returned, by code.", m.errors)
+	writeCounters(b, m.namespace+"_persisted_queries_total", "Automatic persisted query lookups and registrations.", m.persistedQueries)
+	if len(m.resolvers) > 0 {
+		m.writeHistograms(b, m.namespace+"_resolver_duration_seconds", "Duration of the field resolvers, by field.", m.resolvers)
+	}
+	if len(m.clients) > 0 {
+		writeCounters(b, m.namespace+"_client_requests_total", "Requests served, by client name and version.", m.clients)
+	}
+	return b.Flush()
+}
+
+func writeCounters(b *bufio.Writer, name, help string, counters map[string]uint64) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %[1]s counter\n", name, help)
+	keys := make([]string, 0, len(counters))
+	for key := range counters {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		fmt.Fprintf(b, "%s{%s} %d\n", name, key, counters[key])
+	}
+}
+
+func (m *Metrics) writeHistograms(b *bufio.Writer, name, help string, histograms map[string]*histogram) {
+	fmt.Fprintf(b, "# HELP %s %s\n# TYPE %[1]s histogram\n", name, help)
+	keys := make([]string, 0, len(histograms))
+	for key := range histograms {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		h := histograms[key]
+		for i, bound := range m.buckets {
+			fmt.Fprintf(b, "%s_bucket{%s,le=\"%s\"} %d\n", name, key, strconv.FormatFloat(bound, 'g', -1, 64), h.counts[i])
+		}
+		fmt.Fprintf(b, "%s_bucket{%s,le=\"+Inf\"} %d\n", name, key, h.count)
+		fmt.Fprintf(b, "%s_sum{%s} %s\n", name, key, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(b, "%s_count{%s} %d\n", name, key, h.count)
+	}
+}
+
+// Handler serves the metrics in the Prometheus text exposition format, to
+// be mounted e.g. on /metrics.
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		m.WritePrometheus(w)
+	})
+}
+
+// countErrors counts the errors of an executed operation by their code.
+func (h *Handler) countErrors(ctx context.Context, result *graphql.Result) {
+	if h.metrics == nil && h.meter == nil && h.metricsEmitter == nil {
+		return
+	}
+	for _, err := range result.Errors {
+		code := errorCode(err)
+		if code == "" {
+			code = "EXECUTION_ERROR"
+		}
+		h.recordError(ctx, code)
+	}
+}
@@ -0,0 +1,105 @@
+package handler
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_Metrics(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"name": &graphql.Field{Type: graphql.String},
+				"fail": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return nil, errors.New("failed")
+					},
+				},
+			},
+		}),
+	})
+	metrics := NewMetrics("", 0.5, 1)
+	h := New(&Config{Schema: &schema, Metrics: metrics})
+	send := func(method, body string) {
+		req, _ := http.NewRequest(method, "/graphql", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	send("POST", `{"query":"{ name }"}`)
+	send("POST", `{"query":"{ fail }"}`)
+	send("PUT", `{"query":"{ name }"}`)
+	send("POST", `{"extensions":{"persistedQuery":{"version":1,"sha256Hash":"ecf4edb46db40b5132295c0291d62fb65d6759a9eedfa4d5d612dd5ec54a6b38"}}}`)
+	send("POST", `{"query":"{__typename}","extensions":{"persistedQuery":{"version":1,"sha256Hash":"ecf4edb46db40b5132295c0291d62fb65d6759a9eedfa4d5d612dd5ec54a6b38"}}}`)
+	send("POST", `{"extensions":{"persistedQuery":{"version":1,"sha256Hash":"ecf4edb46db40b5132295c0291d62fb65d6759a9eedfa4d5d612dd5ec54a6b38"}}}`)
+
+	resp := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(resp, httptest.NewRequest("GET", "/metrics", nil))
+	body := resp.Body.String()
+	for _, line := range []string{
+		`graphql_requests_total{operation_type="query",status="200"} 4`,
+		`graphql_requests_total{operation_type="",status="405"} 1`,
+		`graphql_requests_in_flight 0`,
+		`graphql_request_duration_seconds_bucket{operation_type="query",le="0.5"} 4`,
+		`graphql_request_duration_seconds_count{operation_type="query"} 4`,
+		`graphql_errors_total{code="EXECUTION_ERROR"} 1`,
+		`graphql_errors_total{code="METHOD_NOT_ALLOWED"} 1`,
+		`graphql_persisted_queries_total{result="hit"} 1`,
+		`graphql_persisted_queries_total{result="miss"} 1`,
+		`graphql_persisted_queries_total{result="register"} 1`,
+	} {
+		if !strings.Contains(body, line+"\n") {
+			t.Errorf("expected %s in\n%s", line, body)
+		}
+	}
+
+	var buf bytes.Buffer
+	metrics.WritePrometheus(&buf)
+	if !strings.HasPrefix(buf.String(), "# HELP graphql_requests_total") {
+		t.Errorf("unexpected exposition %s", buf.String())
+	}
+}
+
+func TestHandler_MetricsErrorCodes(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"failing": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return nil, errors.New("pq: connection refused")
+					},
+				},
+				"forbidden": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return nil, &fieldAuthError{coordinate: "Query.forbidden"}
+					},
+				},
+			},
+		}),
+	})
+	metrics := NewMetrics("", 1)
+	h := New(&Config{Schema: &schema, Metrics: metrics, MaskInternalErrors: true})
+	req, _ := http.NewRequest("GET", "/graphql?query={failing+forbidden}", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	resp := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(resp, httptest.NewRequest("GET", "/metrics", nil))
+	for _, line := range []string{
+		`graphql_errors_total{code="INTERNAL_SERVER_ERROR"} 1`,
+		`graphql_errors_total{code="FORBIDDEN"} 1`,
+	} {
+		if !strings.Contains(resp.Body.String(), line+"\n") {
+			t.Errorf("expected %s in\n%s", line, resp.Body.String())
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package handler
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// observedRequest is what the instrumentation of the handler reports about
+// a request once served.
+type observedRequest struct {
+	start time.Time
+	op    *operation
+	span  Span
+	// errors counts the errors of the result, and persisted reports whether
+	// the operation was a persisted query.
+	errors    int
+	persisted bool
+}
+
+// setOperation records the operation of the request once parsed.
+func (o *observedRequest) setOperation(op *operation) {
+	o.op = op
+	if o.span == nil || op == nil {
+		return
+	}
+	o.span.SetName(spanName(op))
+	o.span.SetAttributes(operationAttributes(op))
+}
+
+// observedWriter records the status and size of a response, and whether
+// the request was rejected, forwarding the optional interfaces the handler
+// relies on.
+type observedWriter struct {
+	http.ResponseWriter
+	status   int
+	bytes    int
+	rejected bool
+}
+
+func (w *observedWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *observedWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.bytes += n
+	return n, err
+}
+
+func (w *observedWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (w *observedWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("the response writer does not support hijacking")
+	}
+	w.status = http.StatusSwitchingProtocols
+	return hijacker.Hijack()
+}
+
+// observe starts observing a request when the handler is instrumented,
+// returning its context, the writer recording its response and the function
+// reporting it once served.
+func (h *Handler) observe(ctx context.Context, w http.ResponseWriter, r *http.Request) (context.Context, http.ResponseWriter, *observedRequest, func()) {
+	if h.metrics == nil && h.tracer == nil && h.meter == nil && !h.traceResolvers && !h.apolloTracingEnabled() && h.usageReporter == nil && h.metricsEmitter == nil && h.logger == nil {
+		return ctx, w, &observedRequest{}, func() {}
+	}
+	ctx = context.WithValue(ctx, instrumentedKey{}, h)
+	observed := &observedRequest{start: time.Now()}
+	ow := &observedWriter{ResponseWriter: w}
+	h.metrics.requestStarted()
+	h.meter.requestStarted(ctx)
+	if h.tracer != nil {
+		ctx, observed.span = h.startRequestSpan(ctx, r)
+	}
+	return ctx, ow, observed, func() {
+		operationType := ""
+		if observed.op != nil {
+			operationType = observed.op.Type()
+		}
+		status := ow.status
+		if status == 0 {
+			status = http.StatusOK
+		}
+		if ow.rejected {
+			observed.errors = 1
+		}
+		duration := time.Since(observed.start)
+		client, _ := ClientInfoFromContext(ctx)
+		client = h.clientLabels.label(client)
+		h.metrics.requestFinished(operationType, client, status, duration)
+		h.meter.requestFinished(ctx, observed.op, client, status, duration)
+		h.emitRequestMetrics(observed.op, client, status, duration)
+		h.recordUsage(ctx, observed.op, client, duration, observed.errors, observed.persisted)
+		h.logAccess(ctx, r, observed, status, ow.bytes, duration)
+		if observed.span != nil {
+			endRequestSpan(observed.span, status)
+		}
+	}
+}
@@ -0,0 +1,152 @@
+package handler
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/printer"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// operation is the parsed document of a request, resolved to the operation
+// that is going to be executed, so the handler can inspect it before running.
+type operation struct {
+	document   *ast.Document
+	definition *ast.OperationDefinition
+	fragments  map[string]*ast.FragmentDefinition
+}
+
+var errOperationNotFound = errors.New("operation not found in document")
+
+// parseOperation parses the query and selects the operation named by
+// operationName, or the only operation of the document when it is empty.
+func parseOperation(query string, operationName string) (*operation, error) {
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{
+			Body: []byte(query),
+			Name: "GraphQL request",
+		}),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	op := &operation{
+		document:  doc,
+		fragments: make(map[string]*ast.FragmentDefinition),
+	}
+	count := 0
+	for _, def := range doc.Definitions {
+		switch def := def.(type) {
+		case *ast.OperationDefinition:
+			count++
+			if operationName == "" || (def.Name != nil && def.Name.Value == operationName) {
+				op.definition = def
+			}
+		case *ast.FragmentDefinition:
+			if def.Name != nil {
+				op.fragments[def.Name.Value] = def
+			}
+		}
+	}
+	if op.definition == nil || (operationName == "" && count > 1) {
+		return nil, errOperationNotFound
+	}
+	return op, nil
+}
+
+// Type returns the operation type: query, mutation or subscription.
+func (o *operation) Type() string {
+	if o.definition.Operation == "" {
+		return ast.OperationTypeQuery
+	}
+	return o.definition.Operation
+}
+
+// Name returns the operation name, empty for anonymous operations.
+func (o *operation) Name() string {
+	if o.definition.Name == nil {
+		return ""
+	}
+	return o.definition.Name.Value
+}
+
+// fingerprint identifies the operation regardless of the formatting of its
+// document.
+func (o *operation) fingerprint() string {
+	printed, _ := printer.Print(o.document).(string)
+	hash := sha256.Sum256([]byte(printed + "\x00" + o.Name()))
+	return hex.EncodeToString(hash[:])
+}
+
+// rootType returns the schema type the operation selects from.
+func (o *operation) rootType(schema *graphql.Schema) *graphql.Object {
+	switch o.Type() {
+	case ast.OperationTypeMutation:
+		return schema.MutationType()
+	case ast.OperationTypeSubscription:
+		return schema.SubscriptionType()
+	default:
+		return schema.QueryType()
+	}
+}
+
+// fieldVisitFn is called for every field selected by an operation, with the
+// type the field is selected on, its definition (nil when unknown to the
+// schema) and its nesting depth starting at 1 for root fields.
+type fieldVisitFn func(parent graphql.Type, field *ast.Field, def *graphql.FieldDefinition, depth int)
+
+// walkFields visits every field of the operation, expanding fragments.
+func (o *operation) walkFields(schema *graphql.Schema, fn fieldVisitFn) {
+	root := o.rootType(schema)
+	if root == nil {
+		return
+	}
+	o.walkSelectionSet(schema, root, o.definition.SelectionSet, 1, map[string]bool{}, fn)
+}
+
+func (o *operation) walkSelectionSet(schema *graphql.Schema, parent graphql.Type, set *ast.SelectionSet, depth int, spreads map[string]bool, fn fieldVisitFn) {
+	if set == nil {
+		return
+	}
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			def := graphql.DefaultTypeInfoFieldDef(schema, parent, selection)
+			fn(parent, selection, def, depth)
+			if def == nil || selection.SelectionSet == nil {
+				continue
+			}
+			if named, ok := graphql.GetNamed(def.Type).(graphql.Type); ok {
+				o.walkSelectionSet(schema, named, selection.SelectionSet, depth+1, spreads, fn)
+			}
+		case *ast.InlineFragment:
+			o.walkSelectionSet(schema, o.conditionType(schema, parent, selection.TypeCondition), selection.SelectionSet, depth, spreads, fn)
+		case *ast.FragmentSpread:
+			if selection.Name == nil || spreads[selection.Name.Value] {
+				continue
+			}
+			fragment, ok := o.fragments[selection.Name.Value]
+			if !ok {
+				continue
+			}
+			spreads[selection.Name.Value] = true
+			o.walkSelectionSet(schema, o.conditionType(schema, parent, fragment.TypeCondition), fragment.SelectionSet, depth, spreads, fn)
+			delete(spreads, selection.Name.Value)
+		}
+	}
+}
+
+func (o *operation) conditionType(schema *graphql.Schema, parent graphql.Type, condition *ast.Named) graphql.Type {
+	if condition == nil || condition.Name == nil {
+		return parent
+	}
+	if t := schema.Type(condition.Name.Value); t != nil {
+		return t
+	}
+	return parent
+}
@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+)
+
+// AllowedOperationFn reports whether the operation with the name may be
+// executed for the request.
+type AllowedOperationFn func(ctx context.Context, r *http.Request, name string) bool
+
+// checkAllowedOperation rejects the anonymous operations and those neither
+// listed in Config.AllowedOperations nor allowed by the AllowedOperationFn,
+// when either is configured.
+func (h *Handler) checkAllowedOperation(ctx context.Context, r *http.Request, op *operation) *requestError {
+	if op == nil || (h.allowedOperations == nil && h.allowedOperationFn == nil) {
+		return nil
+	}
+	name := op.Name()
+	if name == "" {
+		return newRequestError(http.StatusForbidden, CodeOperationNotAllowed, "Anonymous operations are not allowed")
+	}
+	if h.allowedOperations[name] || (h.allowedOperationFn != nil && h.allowedOperationFn(ctx, r, name)) {
+		return nil
+	}
+	return newRequestError(http.StatusForbidden, CodeOperationNotAllowed, "Operation \""+name+"\" is not allowed")
+}
@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestHandler_AllowedOperations(t *testing.T) {
+	resolved := 0
+	schema := limitsSchema(t, &resolved)
+	h := New(&Config{
+		Schema:            &schema,
+		AllowedOperations: []string{"Name"},
+		AllowedOperationFn: func(ctx context.Context, r *http.Request, name string) bool {
+			return strings.HasPrefix(name, "Internal")
+		},
+	})
+
+	tests := []struct {
+		query    string
+		rejected string
+	}{
+		{query: "query Name { name }"},
+		{query: "query InternalName { name }"},
+		{query: "{ name }", rejected: "Anonymous operations are not allowed"},
+		{query: "query Other { name }", rejected: `Operation "Other" is not allowed`},
+	}
+	for _, test := range tests {
+		resolved = 0
+		resp, errs := limitsRequest(h, test.query)
+		if test.rejected == "" {
+			if len(errs) != 0 || resolved != 1 {
+				t.Errorf("%s: unexpected errors %v", test.query, errs)
+			}
+			continue
+		}
+		if resp.Code != http.StatusForbidden || len(errs) != 1 || errs[0] != test.rejected || resolved != 0 {
+			t.Errorf("%s: unexpected response %d %v", test.query, resp.Code, errs)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+package handler
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/printer"
+)
+
+// CatalogOperation documents a persisted operation of the manifest.
+type CatalogOperation struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Type string `json:"type"`
+	// Description is taken from the comments preceding the operation.
+	Description string            `json:"description,omitempty"`
+	Variables   []CatalogVariable `json:"variables"`
+	// Example holds variables the operation can be executed with, the
+	// default ones or placeholders.
+	Example map[string]interface{} `json:"example"`
+	Body    string                 `json:"body"`
+}
+
+// CatalogVariable documents a variable of a persisted operation.
+type CatalogVariable struct {
+	Name         string `json:"name"`
+	Type         string `json:"type"`
+	Required     bool   `json:"required"`
+	DefaultValue string `json:"defaultValue,omitempty"`
+}
+
+// OperationCatalog documents the operations of Config.PersistedOperations,
+// sorted by name. Operations failing to parse are skipped.
+func (h *Handler) OperationCatalog() []CatalogOperation {
+	h.persistedQueries.mu.RLock()
+	var entries []CacheEntry
+	for _, entry := range h.persistedQueries.entries {
+		if entry.manifest {
+			entries = append(entries, entry)
+		}
+	}
+	h.persistedQueries.mu.RUnlock()
+
+	catalog := make([]CatalogOperation, 0, len(entries))
+	for _, entry := range entries {
+		op, err := parseOperation(entry.query, entry.operationName)
+		if err != nil {
+			continue
+		}
+		catalog = append(catalog, catalogOperation(entry, op))
+	}
+	sort.Slice(catalog, func(i, j int) bool {
+		if catalog[i].Name != catalog[j].Name {
+			return catalog[i].Name < catalog[j].Name
+		}
+		return catalog[i].ID < catalog[j].ID
+	})
+	return catalog
+}
+
+func catalogOperation(entry CacheEntry, op *operation) CatalogOperation {
+	c := CatalogOperation{
+		ID:          entry.sha256Hash,
+		Name:        entry.operationName,
+		Type:        op.Type(),
+		Description: leadingComments(entry.query, op.definition),
+		Variables:   []CatalogVariable{},
+		Example:     make(map[string]interface{}),
+		Body:        entry.query,
+	}
+	if c.Name == "" {
+		c.Name = op.Name()
+	}
+	for _, def := range op.definition.VariableDefinitions {
+		if def.Variable == nil || def.Variable.Name == nil {
+			continue
+		}
+		name := def.Variable.Name.Value
+		_, required := def.Type.(*ast.NonNull)
+		variable := CatalogVariable{
+			Name:     name,
+			Type:     printer.Print(def.Type).(string),
+			Required: required && def.DefaultValue == nil,
+		}
+		if def.DefaultValue != nil {
+			variable.DefaultValue = printer.Print(def.DefaultValue).(string)
+		}
+		c.Variables = append(c.Variables, variable)
+
+		if value, ok := entry.defaultVariables[name]; ok {
+			c.Example[name] = value
+		} else if def.DefaultValue == nil {
+			c.Example[name] = placeholder(def.Type)
+		}
+	}
+	return c
+}
+
+// leadingComments returns the comments on the lines right before the
+// operation definition.
+func leadingComments(body string, def *ast.OperationDefinition) string {
+	if def.Loc == nil || def.Loc.Start > len(body) {
+		return ""
+	}
+	lines := strings.Split(body[:def.Loc.Start], "\n")
+	var comments []string
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+		if line == "" && i == len(lines)-1 {
+			continue
+		}
+		if !strings.HasPrefix(line, "#") {
+			break
+		}
+		comments = append([]string{strings.TrimSpace(strings.TrimPrefix(line, "#"))}, comments...)
+	}
+	return strings.Join(comments, "\n")
+}
+
+// placeholder returns an example value of the type.
+func placeholder(t ast.Type) interface{} {
+	switch t := t.(type) {
+	case *ast.NonNull:
+		return placeholder(t.Type)
+	case *ast.List:
+		return []interface{}{}
+	case *ast.Named:
+		switch t.Name.Value {
+		case "Int", "Float":
+			return 0
+		case "Boolean":
+			return false
+		case "String", "ID":
+			return ""
+		}
+	}
+	return nil
+}
+
+var operationCatalogTemplate = template.Must(template.New("OperationCatalog").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>GraphQL operations</title>
+  <style>
+    body { font-family: sans-serif; max-width: 50em; margin: 4em auto; color: #333; }
+    pre { background: #eee; padding: 0.5em; overflow: auto; }
+  </style>
+</head>
+<body>
+  <h1>GraphQL operations</h1>
+  {{range .}}
+  <h2 id="{{.ID}}">{{.Name}} <small>{{.Type}}</small></h2>
+  {{if .Description}}<p>{{.Description}}</p>{{end}}
+  <p>ID: <code>{{.ID}}</code></p>
+  {{if .Variables}}<ul>{{range .Variables}}<li><code>${{.Name}}: {{.Type}}{{if .DefaultValue}} = {{.DefaultValue}}{{end}}</code></li>{{end}}</ul>{{end}}
+  <pre>{{.Body}}</pre>
+  {{else}}
+  <p>No persisted operations.</p>
+  {{end}}
+</body>
+</html>
+`))
+
+// OperationCatalogHandler serves the OperationCatalog as an HTML page to
+// browsers and as JSON otherwise, for an internal route.
+func (h *Handler) OperationCatalogHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		catalog := h.OperationCatalog()
+		if prefersHTML(r) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			operationCatalogTemplate.Execute(w, catalog)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string][]CatalogOperation{"operations": catalog})
+	})
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_OperationCatalog(t *testing.T) {
+	h := New(&Config{
+		Schema: &testutil.StarWarsSchema,
+		PersistedOperations: []PersistedOperation{
+			{
+				ID:   "human",
+				Name: "Human",
+				Body: "# Fetches a human.\n# By ID.\nquery Human($id: String!, $episode: Episode = JEDI) { human(id: $id) { name } }",
+			},
+			{
+				ID:               "hero",
+				Name:             "Hero",
+				Body:             "query Hero($episode: Episode) { hero(episode: $episode) { name } }",
+				DefaultVariables: map[string]interface{}{"episode": "EMPIRE"},
+			},
+		},
+	})
+
+	expected := []CatalogOperation{
+		{
+			ID:        "hero",
+			Name:      "Hero",
+			Type:      "query",
+			Variables: []CatalogVariable{{Name: "episode", Type: "Episode"}},
+			Example:   map[string]interface{}{"episode": "EMPIRE"},
+			Body:      "query Hero($episode: Episode) { hero(episode: $episode) { name } }",
+		},
+		{
+			ID:          "human",
+			Name:        "Human",
+			Type:        "query",
+			Description: "Fetches a human.\nBy ID.",
+			Variables: []CatalogVariable{
+				{Name: "id", Type: "String!", Required: true},
+				{Name: "episode", Type: "Episode", DefaultValue: "JEDI"},
+			},
+			Example: map[string]interface{}{"id": ""},
+			Body:    "# Fetches a human.\n# By ID.\nquery Human($id: String!, $episode: Episode = JEDI) { human(id: $id) { name } }",
+		},
+	}
+	if catalog := h.OperationCatalog(); !reflect.DeepEqual(catalog, expected) {
+		t.Fatalf("unexpected catalog %+v", catalog)
+	}
+
+	req, _ := http.NewRequest("GET", "/operations", nil)
+	resp := httptest.NewRecorder()
+	h.OperationCatalogHandler().ServeHTTP(resp, req)
+	var body struct {
+		Operations []CatalogOperation `json:"operations"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &body); err != nil || len(body.Operations) != 2 {
+		t.Fatalf("unexpected JSON catalog %s", resp.Body.String())
+	}
+
+	req.Header.Set("Accept", "text/html")
+	resp = httptest.NewRecorder()
+	h.OperationCatalogHandler().ServeHTTP(resp, req)
+	if !strings.Contains(resp.Body.String(), "Fetches a human.") {
+		t.Fatalf("unexpected HTML catalog %s", resp.Body.String())
+	}
+}
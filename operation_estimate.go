@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// EstimateAuthFn authorizes a request to the operation estimate endpoint.
+type EstimateAuthFn func(r *http.Request) bool
+
+// OperationEstimate describes how the handler would treat an operation,
+// without executing it.
+type OperationEstimate struct {
+	OperationName string `json:"operationName,omitempty"`
+	Type          string `json:"type,omitempty"`
+	Depth         int    `json:"depth"`
+	// Cost is computed like for Config.MaxComplexity, whether it is set or
+	// not.
+	Cost       int `json:"cost"`
+	Aliases    int `json:"aliases"`
+	RootFields int `json:"rootFields"`
+	Selections int `json:"selections"`
+	// Cacheable reports whether the operation is a query, which can be sent
+	// with GET and cached, by the response cache when configured.
+	Cacheable bool `json:"cacheable"`
+	// Errors lists the errors the operation would be rejected with: those
+	// of the handler limits and policies, and the validation errors.
+	Errors []gqlerrors.FormattedError `json:"errors"`
+}
+
+// EstimateOperation describes how the handler would treat the operation.
+// The policies depending on the request, like the IntrospectionAllowedFn,
+// are applied to the given one.
+func (h *Handler) EstimateOperation(ctx context.Context, r *http.Request, opts *RequestOptions) OperationEstimate {
+	estimate := OperationEstimate{Errors: []gqlerrors.FormattedError{}}
+	reject := func(err *requestError) {
+		if err != nil {
+			estimate.Errors = append(estimate.Errors, err.formatted())
+		}
+	}
+
+	query := opts.Query
+	if query == "" {
+		if persistedQuery, _ := opts.GetPersistedQuery(); persistedQuery != nil {
+			entry, _ := h.persistedQueries.get(persistedQuery.SHA256Hash)
+			query = entry.query
+		}
+	}
+	if query == "" {
+		reject(newRequestError(http.StatusBadRequest, CodeInvalidRequest, "Missing query"))
+		return estimate
+	}
+	if err := h.checkDocument(query, true); err != nil {
+		reject(err)
+		return estimate
+	}
+	op, err := parseOperation(query, opts.OperationName)
+	if err != nil {
+		estimate.Errors = append(estimate.Errors, gqlerrors.FormatError(err))
+		return estimate
+	}
+
+	schema := h.schema()
+	size := measureOperation(schema, op)
+	estimate.OperationName = op.Name()
+	estimate.Type = op.Type()
+	estimate.Depth = size.depth
+	estimate.Cost = h.estimateCost(op, opts.Variables)
+	estimate.Aliases = size.aliases
+	estimate.RootFields = size.rootFields
+	estimate.Selections = size.selections
+	estimate.Cacheable = op.Type() == ast.OperationTypeQuery
+
+	reject(h.checkAllowedOperation(ctx, r, op))
+	reject(h.checkIntrospection(ctx, r, op, true))
+	reject(h.checkSize(size, true))
+	_, costErr := h.checkCost(op, opts.Variables, true)
+	reject(costErr)
+	estimate.Errors = append(estimate.Errors, graphql.ValidateDocument(schema, op.document, nil).Errors...)
+	return estimate
+}
+
+// EstimateHandler serves the operation estimate endpoint, authorized by
+// Config.EstimateAuthFn: requests holding an operation like those to the
+// handler get back its OperationEstimate in JSON.
+func (h *Handler) EstimateHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if h.estimateAuthFn == nil || !h.estimateAuthFn(r) {
+			http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+			return
+		}
+		if r.Method != http.MethodGet && r.Method != http.MethodPost {
+			w.Header().Set("Allow", "GET, POST")
+			http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+			return
+		}
+
+		opts, reqErr := parseRequestOptions(r)
+		if reqErr != nil {
+			http.Error(w, reqErr.message, reqErr.status)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(h.EstimateOperation(r.Context(), r, opts))
+	})
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestHandler_EstimateHandler(t *testing.T) {
+	resolved := 0
+	schema := complexitySchema(t, &resolved)
+	h := New(&Config{
+		Schema:         &schema,
+		MaxComplexity:  50,
+		MaxAliases:     1,
+		EstimateAuthFn: func(r *http.Request) bool { return r.Header.Get("Authorization") == "Bearer admin" },
+	})
+
+	estimate := func(body string) (*httptest.ResponseRecorder, OperationEstimate) {
+		req, _ := http.NewRequest("POST", "/graphql/estimate", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer admin")
+		resp := httptest.NewRecorder()
+		h.EstimateHandler().ServeHTTP(resp, req)
+		var estimate OperationEstimate
+		json.Unmarshal(resp.Body.Bytes(), &estimate)
+		return resp, estimate
+	}
+
+	_, e := estimate(`{"query":"query Items($n: Int) { items(first: $n) { id price } }","variables":{"n":10}}`)
+	if e.OperationName != "Items" || e.Type != "query" || !e.Cacheable || e.Depth != 2 || e.Cost != 21 || e.Selections != 3 || len(e.Errors) != 0 {
+		t.Errorf("unexpected estimate %+v", e)
+	}
+
+	_, e = estimate(`{"query":"{ a: items(first: 30) { b: id price } nope }"}`)
+	var messages []string
+	for _, err := range e.Errors {
+		messages = append(messages, err.Message)
+	}
+	expected := []string{
+		"Operation has 2 aliases, exceeding the maximum of 1",
+		"Operation cost 62 exceeds the maximum cost 50",
+		`Cannot query field "nope" on type "Query".`,
+	}
+	if strings.Join(messages, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("unexpected errors %v", messages)
+	}
+	if resolved != 0 {
+		t.Errorf("expected the operations not to be executed")
+	}
+
+	req, _ := http.NewRequest("POST", "/graphql/estimate", strings.NewReader(`{"query":"{ items { id } }"}`))
+	resp := httptest.NewRecorder()
+	h.EstimateHandler().ServeHTTP(resp, req)
+	if resp.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", resp.Code)
+	}
+}
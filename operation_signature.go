@@ -0,0 +1,220 @@
+package handler
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// signature returns the usage reporting signature of the operation, like
+// the default one of Apollo: its document restricted to the operation and
+// the fragments it spreads, with the literals hidden, the aliases removed,
+// the selections, arguments and fragments sorted, and the whitespace
+// reduced.
+func (o *operation) signature() string {
+	s := &signatureWriter{fragments: o.fragments, used: make(map[string]bool)}
+	s.collectFragments(o.definition.SelectionSet)
+	names := make([]string, 0, len(s.used))
+	for name := range s.used {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fragment := o.fragments[name]
+		s.token("fragment")
+		s.token(name)
+		s.token("on")
+		s.token(fragment.TypeCondition.Name.Value)
+		s.directives(fragment.Directives)
+		s.selectionSet(fragment.SelectionSet)
+	}
+
+	def := o.definition
+	s.token(o.Type())
+	if def.Name != nil {
+		s.token(def.Name.Value)
+	}
+	if len(def.VariableDefinitions) > 0 {
+		variables := append([]*ast.VariableDefinition(nil), def.VariableDefinitions...)
+		sort.SliceStable(variables, func(i, j int) bool {
+			return variables[i].Variable.Name.Value < variables[j].Variable.Name.Value
+		})
+		s.token("(")
+		for i, variable := range variables {
+			if i > 0 {
+				s.token(",")
+			}
+			s.token("$" + variable.Variable.Name.Value)
+			s.token(":")
+			s.typeRef(variable.Type)
+			if variable.DefaultValue != nil {
+				s.token("=")
+				s.value(variable.DefaultValue)
+			}
+		}
+		s.token(")")
+	}
+	s.directives(def.Directives)
+	s.selectionSet(def.SelectionSet)
+	return s.b.String()
+}
+
+type signatureWriter struct {
+	b         strings.Builder
+	fragments map[string]*ast.FragmentDefinition
+	used      map[string]bool
+}
+
+func (s *signatureWriter) collectFragments(set *ast.SelectionSet) {
+	if set == nil {
+		return
+	}
+	for _, selection := range set.Selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			s.collectFragments(selection.SelectionSet)
+		case *ast.InlineFragment:
+			s.collectFragments(selection.SelectionSet)
+		case *ast.FragmentSpread:
+			name := selection.Name.Value
+			if fragment, ok := s.fragments[name]; ok && !s.used[name] {
+				s.used[name] = true
+				s.collectFragments(fragment.SelectionSet)
+			}
+		}
+	}
+}
+
+func isSignatureWordByte(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
+
+// token writes a token, separated by a space from the previous one only
+// when both are words.
+func (s *signatureWriter) token(token string) {
+	if n := s.b.Len(); n > 0 && isSignatureWordByte(s.b.String()[n-1]) && isSignatureWordByte(token[0]) {
+		s.b.WriteByte(' ')
+	}
+	s.b.WriteString(token)
+}
+
+func (s *signatureWriter) selectionSet(set *ast.SelectionSet) {
+	if set == nil || len(set.Selections) == 0 {
+		return
+	}
+	selections := append([]ast.Selection(nil), set.Selections...)
+	sort.SliceStable(selections, func(i, j int) bool {
+		ki, ni := selectionSortKey(selections[i])
+		kj, nj := selectionSortKey(selections[j])
+		if ki != kj {
+			return ki < kj
+		}
+		return ni < nj
+	})
+	s.token("{")
+	for _, selection := range selections {
+		switch selection := selection.(type) {
+		case *ast.Field:
+			s.token(selection.Name.Value)
+			s.arguments(selection.Arguments)
+			s.directives(selection.Directives)
+			s.selectionSet(selection.SelectionSet)
+		case *ast.FragmentSpread:
+			s.token("...")
+			s.token(selection.Name.Value)
+			s.directives(selection.Directives)
+		case *ast.InlineFragment:
+			s.token("...")
+			if selection.TypeCondition != nil {
+				s.token("on")
+				s.token(selection.TypeCondition.Name.Value)
+			}
+			s.directives(selection.Directives)
+			s.selectionSet(selection.SelectionSet)
+		}
+	}
+	s.token("}")
+}
+
+func selectionSortKey(selection ast.Selection) (int, string) {
+	switch selection := selection.(type) {
+	case *ast.Field:
+		return 0, selection.Name.Value
+	case *ast.FragmentSpread:
+		return 1, selection.Name.Value
+	}
+	return 2, ""
+}
+
+func (s *signatureWriter) arguments(args []*ast.Argument) {
+	if len(args) == 0 {
+		return
+	}
+	args = append([]*ast.Argument(nil), args...)
+	sort.SliceStable(args, func(i, j int) bool {
+		return args[i].Name.Value < args[j].Name.Value
+	})
+	s.token("(")
+	for i, arg := range args {
+		if i > 0 {
+			s.token(",")
+		}
+		s.token(arg.Name.Value)
+		s.token(":")
+		s.value(arg.Value)
+	}
+	s.token(")")
+}
+
+func (s *signatureWriter) directives(directives []*ast.Directive) {
+	directives = append([]*ast.Directive(nil), directives...)
+	sort.SliceStable(directives, func(i, j int) bool {
+		return directives[i].Name.Value < directives[j].Name.Value
+	})
+	for _, directive := range directives {
+		s.token("@" + directive.Name.Value)
+		s.arguments(directive.Arguments)
+	}
+}
+
+// value writes a value with its literals hidden, except the booleans and
+// enums.
+func (s *signatureWriter) value(value ast.Value) {
+	switch value := value.(type) {
+	case *ast.Variable:
+		s.token("$" + value.Name.Value)
+	case *ast.IntValue, *ast.FloatValue:
+		s.token("0")
+	case *ast.StringValue:
+		s.token(`""`)
+	case *ast.BooleanValue:
+		if value.Value {
+			s.token("true")
+		} else {
+			s.token("false")
+		}
+	case *ast.EnumValue:
+		s.token(value.Value)
+	case *ast.ListValue:
+		s.token("[]")
+	case *ast.ObjectValue:
+		s.token("{}")
+	default:
+		s.token("null")
+	}
+}
+
+func (s *signatureWriter) typeRef(t ast.Type) {
+	switch t := t.(type) {
+	case *ast.Named:
+		s.token(t.Name.Value)
+	case *ast.List:
+		s.token("[")
+		s.typeRef(t.Type)
+		s.token("]")
+	case *ast.NonNull:
+		s.typeRef(t.Type)
+		s.token("!")
+	}
+}
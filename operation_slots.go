@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const defaultOperationWait = time.Second
+
+// operationSlots bounds the operations executed concurrently, the operations
+// over the bound waiting a bounded time for a slot.
+type operationSlots struct {
+	slots chan struct{}
+	wait  time.Duration
+}
+
+func newOperationSlots(max int, wait time.Duration) *operationSlots {
+	if max <= 0 {
+		return nil
+	}
+	if wait <= 0 {
+		wait = defaultOperationWait
+	}
+	return &operationSlots{slots: make(chan struct{}, max), wait: wait}
+}
+
+// acquire reserves an execution slot, returning false when none was freed in
+// time or the context is done.
+func (s *operationSlots) acquire(ctx context.Context) bool {
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+	}
+	timer := time.NewTimer(s.wait)
+	defer timer.Stop()
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return false
+}
+
+func (s *operationSlots) release() {
+	<-s.slots
+}
+
+// acquireOperationSlot reserves an execution slot for the operation when
+// Config.MaxConcurrentOperations is set, returning the function releasing it.
+// The Retry-After header of the response is set when given and saturated.
+func (h *Handler) acquireOperationSlot(ctx context.Context, w http.ResponseWriter) (func(), *requestError) {
+	if h.operationSlots == nil {
+		return func() {}, nil
+	}
+	if !h.operationSlots.acquire(ctx) {
+		if w != nil {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(h.operationSlots.wait.Seconds()))))
+		}
+		return nil, newRequestError(http.StatusTooManyRequests, CodeTooManyConcurrentOperations, "Too many concurrent operations")
+	}
+	return h.operationSlots.release, nil
+}
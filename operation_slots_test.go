@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_MaxConcurrentOperations(t *testing.T) {
+	entered, unblock := make(chan struct{}), make(chan struct{})
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"slow": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						entered <- struct{}{}
+						<-unblock
+						return "done", nil
+					},
+				},
+				"fast": &graphql.Field{Type: graphql.String},
+			},
+		}),
+	})
+	h := New(&Config{Schema: &schema, MaxConcurrentOperations: 1, MaxOperationWait: 10 * time.Millisecond})
+	query := func(q string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"`+q+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- query("{ slow }") }()
+	<-entered
+
+	resp := query("{ fast }")
+	if resp.Code != http.StatusTooManyRequests || !strings.Contains(resp.Body.String(), string(CodeTooManyConcurrentOperations)) {
+		t.Errorf("unexpected response %d %s", resp.Code, resp.Body.String())
+	}
+	if resp.Header().Get("Retry-After") != "1" {
+		t.Errorf("unexpected Retry-After %q", resp.Header().Get("Retry-After"))
+	}
+
+	close(unblock)
+	if resp := <-done; resp.Code != http.StatusOK {
+		t.Errorf("expected the slow operation to be executed, got %d", resp.Code)
+	}
+	if resp := query("{ fast }"); resp.Code != http.StatusOK {
+		t.Errorf("expected the slot to be released, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"context"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+// OperationTimeoutFn returns the timeout of an operation, falling back to the
+// Config.OperationTimeouts and the other timeouts when zero.
+type OperationTimeoutFn func(ctx context.Context, operationName string) time.Duration
+
+// operationTimeout returns the timeout of the operation: its override, or its
+// adaptive timeout, or the global one. It is zero when the operation has
+// none.
+func (h *Handler) operationTimeout(ctx context.Context, op *operation, fingerprint string) time.Duration {
+	if h.operationTimeoutFn != nil {
+		if timeout := h.operationTimeoutFn(ctx, op.Name()); timeout > 0 {
+			return timeout
+		}
+	}
+	if timeout, ok := h.operationTimeouts[op.Name()]; ok && op.Name() != "" {
+		return timeout
+	}
+	if h.latencies != nil {
+		if timeout := h.latencies.timeout(fingerprint); timeout > 0 {
+			return timeout
+		}
+	}
+	return h.globalOperationTimeout
+}
+
+// do executes the operation within its timeout, recording its duration in the
+// latency history.
+func (h *Handler) do(op *operation, params graphql.Params) *graphql.Result {
+	if op == nil {
+		return graphql.Do(params)
+	}
+	var fingerprint string
+	if h.latencies != nil {
+		fingerprint = op.fingerprint()
+	}
+	ctx := params.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if timeout := h.operationTimeout(ctx, op, fingerprint); timeout > 0 {
+		var cancel context.CancelFunc
+		params.Context, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	start := time.Now()
+	result := graphql.Do(params)
+	if h.latencies != nil {
+		h.latencies.record(fingerprint, op.Name(), time.Since(start), time.Now())
+	}
+	return result
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_OperationTimeouts(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"slow": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						time.Sleep(50 * time.Millisecond)
+						return "done", nil
+					},
+				},
+			},
+		}),
+	})
+	h := New(&Config{
+		Schema:            &schema,
+		OperationTimeout:  10 * time.Millisecond,
+		OperationTimeouts: map[string]time.Duration{"Report": time.Second},
+		OperationTimeoutFn: func(ctx context.Context, operationName string) time.Duration {
+			if operationName == "Export" {
+				return time.Second
+			}
+			return 0
+		},
+	})
+	query := func(q string) string {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"`+q+`"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp.Body.String()
+	}
+
+	if body := query("query Interactive { slow }"); !strings.Contains(body, "deadline exceeded") {
+		t.Errorf("expected the global timeout, got %s", body)
+	}
+	if body := query("query Report { slow }"); body != `{"data":{"slow":"done"}}` {
+		t.Errorf("expected the timeout override, got %s", body)
+	}
+	if body := query("query Export { slow }"); body != `{"data":{"slow":"done"}}` {
+		t.Errorf("expected the timeout of the callback, got %s", body)
+	}
+}
+
+func TestHandler_OperationTimeoutsIncrementalDelivery(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"fast": &graphql.Field{Type: graphql.String},
+				"slow": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						time.Sleep(50 * time.Millisecond)
+						return "done", nil
+					},
+				},
+			},
+		}),
+	})
+	h := New(&Config{Schema: &schema, IncrementalDelivery: true, OperationTimeout: 10 * time.Millisecond})
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"query Deferred { fast ... @defer { slow } }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "multipart/mixed; deferSpec=20220824")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if !strings.Contains(resp.Body.String(), "deadline exceeded") {
+		t.Errorf("expected the timeout to apply to incremental delivery, got %s", resp.Body.String())
+	}
+}
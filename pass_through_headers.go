@@ -0,0 +1,34 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+)
+
+type passThroughHeadersKey struct{}
+
+// PassThroughHeadersFromContext returns the request headers listed in
+// Config.PassThroughHeaders, nil when none is configured.
+func PassThroughHeadersFromContext(ctx context.Context) http.Header {
+	headers, _ := ctx.Value(passThroughHeadersKey{}).(http.Header)
+	return headers
+}
+
+// withPassThroughHeaders echoes the pass-through headers of the request in
+// the response and keeps them in the context.
+func (h *Handler) withPassThroughHeaders(ctx context.Context, w http.ResponseWriter, r *http.Request) context.Context {
+	if len(h.passThroughHeaders) == 0 {
+		return ctx
+	}
+	headers := make(http.Header, len(h.passThroughHeaders))
+	for _, name := range h.passThroughHeaders {
+		name = http.CanonicalHeaderKey(name)
+		values := r.Header[name]
+		if len(values) == 0 {
+			continue
+		}
+		headers[name] = append([]string(nil), values...)
+		w.Header()[name] = append([]string(nil), values...)
+	}
+	return context.WithValue(ctx, passThroughHeadersKey{}, headers)
+}
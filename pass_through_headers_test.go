@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_PassThroughHeaders(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"tenant": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return PassThroughHeadersFromContext(p.Context).Get("X-Tenant-ID"), nil
+					},
+				},
+			},
+		}),
+	})
+	h := New(&Config{Schema: &schema, PassThroughHeaders: []string{"x-correlation-id", "X-Tenant-ID"}})
+
+	req, _ := http.NewRequest("GET", "/graphql?query={tenant}", nil)
+	req.Header.Set("X-Correlation-ID", "abc")
+	req.Header.Set("X-Tenant-ID", "acme")
+	req.Header.Set("Authorization", "secret")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	if resp.Header().Get("X-Correlation-ID") != "abc" || resp.Header().Get("X-Tenant-ID") != "acme" {
+		t.Fatalf("expected the headers echoed, got %v", resp.Header())
+	}
+	if resp.Header().Get("Authorization") != "" {
+		t.Fatal("expected only the listed headers echoed")
+	}
+	if expected := `{"data":{"tenant":"acme"}}`; resp.Body.String() != expected {
+		t.Fatalf("expected %s, got %s", expected, resp.Body.String())
+	}
+}
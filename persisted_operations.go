@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// PersistedOperation is an operation of a persisted operations manifest,
+// executable by clients sending its ID as the sha256Hash of the
+// persistedQuery extension, without the query.
+type PersistedOperation struct {
+	// ID is the SHA-256 hash of Body.
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	Body string `json:"body"`
+	// DefaultVariables are applied when the client omits them.
+	DefaultVariables map[string]interface{} `json:"defaultVariables,omitempty"`
+}
+
+// PersistedOperationsManifest lists the operations persisted at build time,
+// in the JSON format of Apollo persisted query manifests.
+type PersistedOperationsManifest struct {
+	Format     string               `json:"format"`
+	Version    int                  `json:"version"`
+	Operations []PersistedOperation `json:"operations"`
+}
+
+// ReadPersistedOperationsManifest decodes a JSON manifest.
+func ReadPersistedOperationsManifest(r io.Reader) (*PersistedOperationsManifest, error) {
+	var manifest PersistedOperationsManifest
+	if err := json.NewDecoder(r).Decode(&manifest); err != nil {
+		return nil, err
+	}
+	return &manifest, nil
+}
+
+// loadManifest registers the manifest operations.
+func (c *persistedQueryCache) loadManifest(operations []PersistedOperation) {
+	for _, op := range operations {
+		c.set(CacheEntry{
+			operationName:    op.Name,
+			query:            op.Body,
+			sha256Hash:       op.ID,
+			version:          1,
+			defaultVariables: op.DefaultVariables,
+			manifest:         true,
+		})
+	}
+}
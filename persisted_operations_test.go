@@ -0,0 +1,42 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_PersistedOperationDefaultVariables(t *testing.T) {
+	manifest, err := ReadPersistedOperationsManifest(strings.NewReader(`{
+		"format": "apollo-persisted-query-manifest",
+		"version": 1,
+		"operations": [{
+			"id": "human",
+			"name": "Human",
+			"body": "query Human($id: String!) { human(id: $id) { name } }",
+			"defaultVariables": {"id": "1000"}
+		}]
+	}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := New(&Config{Schema: &testutil.StarWarsSchema, PersistedOperations: manifest.Operations})
+
+	extensions := url.QueryEscape(`{"persistedQuery":{"version":1,"sha256Hash":"human"}}`)
+	tests := map[string]string{
+		"": "Luke Skywalker",
+		"&variables=" + url.QueryEscape(`{"id":"1002"}`): "Han Solo",
+	}
+	for variables, expected := range tests {
+		req, _ := http.NewRequest("GET", "/graphql?extensions="+extensions+variables, nil)
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		if !strings.Contains(resp.Body.String(), expected) {
+			t.Fatalf("expected %s, got %s", expected, resp.Body.String())
+		}
+	}
+}
@@ -1,56 +1,119 @@
 package handler
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"net/http"
+	"strings"
+	"sync"
 )
 
 type CacheEntry struct {
-	operationName string
-	query         string
-	sha256Hash    string
-	version       float64
+	operationName    string
+	query            string
+	sha256Hash       string
+	version          float64
+	defaultVariables map[string]interface{}
+	// manifest entries are never replaced by client registrations
+	manifest bool
 }
 
-var cache = make(map[string]CacheEntry)
+// persistedQueryCache holds the automatic persisted queries registered by
+// clients.
+type persistedQueryCache struct {
+	mu      sync.RWMutex
+	entries map[string]CacheEntry
 
-func persistedQueryCheck(opts *RequestOptions) (*RequestOptions, error) {
-	if opts.Extensions == nil {
-		return opts, nil
-	}
+	// onRegister is called when a client registers a new query
+	onRegister func(entry CacheEntry)
+}
+
+func newPersistedQueryCache() *persistedQueryCache {
+	return &persistedQueryCache{entries: make(map[string]CacheEntry)}
+}
 
-	persistedQuery := opts.Extensions["persistedQuery"]
+func (c *persistedQueryCache) get(sha string) (CacheEntry, bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	entry, ok := c.entries[sha]
+	return entry, ok
+}
 
-	if persistedQuery == nil {
-		return opts, nil
+// set stores the entry, returning false when one was already known for its
+// hash, which is never replaced.
+func (c *persistedQueryCache) set(entry CacheEntry) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries[entry.sha256Hash]; ok {
+		return false
 	}
+	c.entries[entry.sha256Hash] = entry
+	return true
+}
 
-	values := persistedQuery.(map[string]interface{})
+func (c *persistedQueryCache) delete(sha string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.entries[sha]
+	delete(c.entries, sha)
+	return ok
+}
 
-	sha := values["sha256Hash"].(string)
+// errPersistedQueryNotFound reports a hash missing from the cache, which the
+// handler answers with a PERSISTED_QUERY_NOT_FOUND request error.
+var errPersistedQueryNotFound = errors.New("PersistedQueryNotFound")
 
-	if sha == "" {
+func persistedQueryCheck(cache *persistedQueryCache, opts *RequestOptions) (*RequestOptions, error) {
+	persistedQuery, err := opts.GetPersistedQuery()
+	if err != nil {
+		return nil, newRequestError(http.StatusBadRequest, CodePersistedQueryInvalid, err.Error())
+	}
+	if persistedQuery == nil || persistedQuery.SHA256Hash == "" {
 		return opts, nil
 	}
+	sha, version := persistedQuery.SHA256Hash, persistedQuery.Version
 
 	opts.HasPersistedParams = true
 
 	if opts.Query == "" {
-		cachedValue := cache[sha]
+		cachedValue, _ := cache.get(sha)
 		if cachedValue.query == "" {
-			return nil, errors.New("{\"errors\":[{\"message\":\"PersistedQueryNotFound\",\"extensions\":{\"code\":\"PERSISTED_QUERY_NOT_FOUND\"}}]}")
+			return nil, errPersistedQueryNotFound
 		}
 		opts.OperationName = cachedValue.operationName
 		opts.Query = cachedValue.query
 		opts.Persisted = true
+		for name, value := range cachedValue.defaultVariables {
+			if _, ok := opts.Variables[name]; ok {
+				continue
+			}
+			if opts.Variables == nil {
+				opts.Variables = make(map[string]interface{}, len(cachedValue.defaultVariables))
+			}
+			opts.Variables[name] = value
+		}
 		return opts, nil
 	} else if opts.Query != "" {
-		cache[sha] = CacheEntry{
+		if !matchesHash(opts.Query, sha) {
+			return nil, newRequestError(http.StatusBadRequest, CodePersistedQueryInvalid, "provided sha does not match query")
+		}
+		entry := CacheEntry{
 			operationName: opts.OperationName,
 			query:         opts.Query,
 			sha256Hash:    sha,
-			version:       values["version"].(float64),
+			version:       version,
+		}
+		if cache.set(entry) && cache.onRegister != nil {
+			cache.onRegister(entry)
 		}
 	}
 
 	return opts, nil
 }
+
+// matchesHash reports whether sha is the hex SHA-256 hash of the query.
+func matchesHash(query, sha string) bool {
+	sum := sha256.Sum256([]byte(query))
+	return strings.EqualFold(hex.EncodeToString(sum[:]), sha)
+}
@@ -0,0 +1,50 @@
+package handler
+
+import "time"
+
+// The limits of the Production preset, when not set.
+const (
+	productionMaxBodyBytes            = 1 << 20
+	productionMaxDecompressedBodySize = 1 << 20
+	productionBodyReadTimeout         = 10 * time.Second
+	productionMaxQueryBytes           = 64 << 10
+	productionMaxQueryTokens          = 10000
+	productionMaxVariablesBytes       = 512 << 10
+	productionMaxVariablesDepth       = 16
+)
+
+// productionConfig returns a copy of the config with the Production preset
+// applied: the switches exposing the schema or internals are forced, the
+// limits only set when they are not.
+func productionConfig(p *Config) *Config {
+	c := *p
+	c.DisableIntrospection = true
+	c.GraphiQL = false
+	c.Playground = false
+	c.Debug = false
+	c.MaskInternalErrors = true
+	c.HideSuggestions = true
+	c.StrictContentType = true
+	if c.MaxBodyBytes <= 0 {
+		c.MaxBodyBytes = productionMaxBodyBytes
+	}
+	if c.MaxDecompressedBodySize <= 0 {
+		c.MaxDecompressedBodySize = productionMaxDecompressedBodySize
+	}
+	if c.BodyReadTimeout <= 0 {
+		c.BodyReadTimeout = productionBodyReadTimeout
+	}
+	if c.MaxQueryBytes <= 0 {
+		c.MaxQueryBytes = productionMaxQueryBytes
+	}
+	if c.MaxQueryTokens <= 0 {
+		c.MaxQueryTokens = productionMaxQueryTokens
+	}
+	if c.MaxVariablesBytes <= 0 {
+		c.MaxVariablesBytes = productionMaxVariablesBytes
+	}
+	if c.MaxVariablesDepth <= 0 {
+		c.MaxVariablesDepth = productionMaxVariablesDepth
+	}
+	return &c
+}
@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_Production(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"name": &graphql.Field{Type: graphql.String},
+				"fail": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return nil, errors.New("database password leaked")
+					},
+				},
+			},
+		}),
+	})
+	config := &Config{Schema: &schema, GraphiQL: true, MaxQueryBytes: 1000, Production: true}
+	h := New(config)
+	if !config.GraphiQL || config.MaxVariablesDepth != 0 {
+		t.Errorf("expected the config not to be modified")
+	}
+	if h.graphiql || h.maxQueryBytes != 1000 || h.maxVariablesDepth != productionMaxVariablesDepth || h.maxBodyBytes != productionMaxBodyBytes {
+		t.Errorf("unexpected settings %v %d %d", h.graphiql, h.maxQueryBytes, h.maxVariablesDepth)
+	}
+
+	query := func(contentType, body string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+	if resp := query("application/json", `{"query":"{ __schema { queryType { name } } }"}`); !strings.Contains(resp.Body.String(), string(CodeIntrospectionDisabled)) {
+		t.Errorf("expected introspection to be disabled, got %s", resp.Body.String())
+	}
+	if resp := query("application/json", `{"query":"{ nam }"}`); strings.Contains(resp.Body.String(), "Did you mean") {
+		t.Errorf("expected suggestions to be hidden, got %s", resp.Body.String())
+	}
+	if resp := query("application/json", `{"query":"{ fail }"}`); strings.Contains(resp.Body.String(), "password") {
+		t.Errorf("expected internal errors to be masked, got %s", resp.Body.String())
+	}
+	if resp := query("", `{"query":"{ name }"}`); resp.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("expected content types to be strict, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// RateLimiter decides whether the requests identified by a key may be
+// executed, returning how long to wait before retrying when they may not.
+type RateLimiter interface {
+	Allow(ctx context.Context, key string) (bool, time.Duration)
+}
+
+// RateLimitKeyFn identifies what a request is rate limited by, e.g. its API
+// key or its operation name.
+type RateLimitKeyFn func(ctx context.Context, r *http.Request, operationName string) string
+
+// TokenBucketLimiter is a RateLimiter allowing a sustained rate of requests
+// per key, with bursts up to a number of requests.
+type TokenBucketLimiter struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	buckets map[string]*tokenBucket
+}
+
+type tokenBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// NewTokenBucketLimiter returns a TokenBucketLimiter allowing rate requests
+// per second per key, with bursts up to burst requests. The buckets refilled
+// are only forgotten by the janitor of the handler having the limiter as its
+// Config.RateLimiter, see Handler.StartJanitor: used otherwise, the limiter
+// keeps a bucket for every key it has seen.
+func NewTokenBucketLimiter(rate float64, burst int) *TokenBucketLimiter {
+	return &TokenBucketLimiter{rate: rate, burst: float64(burst), buckets: make(map[string]*tokenBucket)}
+}
+
+// Allow takes a token from the bucket of the key.
+func (l *TokenBucketLimiter) Allow(ctx context.Context, key string) (bool, time.Duration) {
+	return l.allow(key, time.Now())
+}
+
+func (l *TokenBucketLimiter) allow(key string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: l.burst, updated: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(l.burst, bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate)
+	bucket.updated = now
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, 0
+	}
+	if l.rate <= 0 {
+		return false, time.Minute
+	}
+	return false, time.Duration((1 - bucket.tokens) / l.rate * float64(time.Second))
+}
+
+// sweep forgets the buckets refilled by now, which are as good as new.
+func (l *TokenBucketLimiter) sweep(now time.Time) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for key, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*l.rate >= l.burst {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// checkRateLimit rejects the requests over the Config.RateLimiter limit,
+// setting the Retry-After header of the response when given.
+func (h *Handler) checkRateLimit(ctx context.Context, w http.ResponseWriter, r *http.Request, op *operation) *requestError {
+	if h.rateLimiter == nil {
+		return nil
+	}
+	var name string
+	if op != nil {
+		name = op.Name()
+	}
+	var key string
+	if h.rateLimitKeyFn != nil {
+		key = h.rateLimitKeyFn(ctx, r, name)
+	} else {
+		key = h.clientID(ctx, r)
+	}
+	allowed, retryAfter := h.rateLimiter.Allow(ctx, key)
+	if allowed {
+		return nil
+	}
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	if w != nil {
+		w.Header().Set("Retry-After", strconv.Itoa(seconds))
+	}
+	return newRequestError(http.StatusTooManyRequests, CodeRateLimited, "Rate limit exceeded, retry in "+strconv.Itoa(seconds)+"s")
+}
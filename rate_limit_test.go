@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestTokenBucketLimiter(t *testing.T) {
+	l := NewTokenBucketLimiter(1, 2)
+	now := time.Now()
+	for i := 0; i < 2; i++ {
+		if ok, _ := l.allow("a", now); !ok {
+			t.Fatalf("expected request %d to be allowed", i)
+		}
+	}
+	if ok, retryAfter := l.allow("a", now); ok || retryAfter != time.Second {
+		t.Errorf("expected the burst to be exhausted, got %v %v", ok, retryAfter)
+	}
+	if ok, _ := l.allow("b", now); !ok {
+		t.Errorf("expected other keys to have their own bucket")
+	}
+	if ok, _ := l.allow("a", now.Add(time.Second)); !ok {
+		t.Errorf("expected the bucket to be refilled")
+	}
+
+	l.sweep(now.Add(time.Second))
+	if _, ok := l.buckets["a"]; !ok {
+		t.Errorf("expected the empty bucket to be kept")
+	}
+	if _, ok := l.buckets["b"]; ok {
+		t.Errorf("expected the refilled bucket to be swept")
+	}
+}
+
+func TestHandler_RateLimiter(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   "Query",
+			Fields: graphql.Fields{"name": &graphql.Field{Type: graphql.String}},
+		}),
+	})
+	h := New(&Config{
+		Schema:      &schema,
+		RateLimiter: NewTokenBucketLimiter(0.001, 1),
+		RateLimitKeyFn: func(ctx context.Context, r *http.Request, operationName string) string {
+			return operationName
+		},
+	})
+	query := func(name string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"query `+name+` { name }"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := query("A"); resp.Code != http.StatusOK {
+		t.Fatalf("expected the first request to be executed, got %d", resp.Code)
+	}
+	resp := query("A")
+	var result struct {
+		Errors []struct {
+			Extensions map[string]string `json:"extensions"`
+		} `json:"errors"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &result)
+	if resp.Code != http.StatusTooManyRequests || len(result.Errors) != 1 || result.Errors[0].Extensions["code"] != string(CodeRateLimited) {
+		t.Errorf("unexpected response %d %s", resp.Code, resp.Body.String())
+	}
+	if resp.Header().Get("Retry-After") != "1000" {
+		t.Errorf("unexpected Retry-After %q", resp.Header().Get("Retry-After"))
+	}
+	if resp := query("B"); resp.Code != http.StatusOK {
+		t.Errorf("expected other operations to be executed, got %d", resp.Code)
+	}
+}
@@ -0,0 +1,46 @@
+package handler
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+const defaultReadOnlyMessage = "The API is in read-only mode, try again later"
+
+// SetReadOnly toggles the read-only mode, in which mutations are rejected,
+// and subscriptions too when Config.ReadOnlyRejectsSubscriptions is set.
+func (h *Handler) SetReadOnly(readOnly bool) {
+	var value int32
+	if readOnly {
+		value = 1
+	}
+	atomic.StoreInt32(&h.readOnly, value)
+}
+
+// ReadOnly reports whether the read-only mode is on.
+func (h *Handler) ReadOnly() bool {
+	return atomic.LoadInt32(&h.readOnly) == 1
+}
+
+// checkReadOnly rejects the operations not allowed in read-only mode.
+func (h *Handler) checkReadOnly(op *operation) *requestError {
+	if op == nil || !h.ReadOnly() {
+		return nil
+	}
+	switch op.Type() {
+	case ast.OperationTypeMutation:
+	case ast.OperationTypeSubscription:
+		if !h.readOnlyRejectsSubscriptions {
+			return nil
+		}
+	default:
+		return nil
+	}
+	message := h.readOnlyMessage
+	if message == "" {
+		message = defaultReadOnlyMessage
+	}
+	return newRequestError(http.StatusServiceUnavailable, CodeReadOnly, message)
+}
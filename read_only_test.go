@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_ReadOnly(t *testing.T) {
+	mutated := 0
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   "Query",
+			Fields: graphql.Fields{"name": &graphql.Field{Type: graphql.String}},
+		}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Mutation",
+			Fields: graphql.Fields{
+				"rename": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						mutated++
+						return "renamed", nil
+					},
+				},
+			},
+		}),
+	})
+	h := New(&Config{Schema: &schema, ReadOnly: true, ReadOnlyMessage: "Down for maintenance"})
+	mutate := func() *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"mutation { rename }"}`))
+		req.Header.Set("Content-Type", "application/json")
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+
+	resp := mutate()
+	var result struct {
+		Errors []struct {
+			Message    string            `json:"message"`
+			Extensions map[string]string `json:"extensions"`
+		} `json:"errors"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &result)
+	if resp.Code != http.StatusServiceUnavailable || len(result.Errors) != 1 || result.Errors[0].Message != "Down for maintenance" || result.Errors[0].Extensions["code"] != string(CodeReadOnly) {
+		t.Errorf("unexpected response %d %s", resp.Code, resp.Body.String())
+	}
+	if mutated != 0 {
+		t.Errorf("expected the mutation not to be executed")
+	}
+
+	req, _ := http.NewRequest("GET", "/graphql?query={name}", nil)
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Errorf("expected queries to be executed, got %d", resp.Code)
+	}
+
+	h.SetReadOnly(false)
+	if resp := mutate(); resp.Code != http.StatusOK || mutated != 1 {
+		t.Errorf("expected the mutation to be executed, got %d %s", resp.Code, resp.Body.String())
+	}
+}
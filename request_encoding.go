@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// defaultMaxDecompressedBodySize limits the compressed request bodies when
+// Config.MaxDecompressedBodySize is not set.
+const defaultMaxDecompressedBodySize = 10 << 20
+
+// decompressBody returns the request with its body decompressed according to
+// its Content-Encoding.
+func (h *Handler) decompressBody(r *http.Request) (*http.Request, *requestError) {
+	encoding := strings.ToLower(strings.TrimSpace(r.Header.Get("Content-Encoding")))
+	if encoding == "" || encoding == "identity" || r.Body == nil {
+		return r, nil
+	}
+
+	var reader io.ReadCloser
+	var err error
+	switch encoding {
+	case "gzip", "x-gzip":
+		reader, err = gzip.NewReader(r.Body)
+	case "deflate":
+		reader, err = zlib.NewReader(r.Body)
+	default:
+		return r, newRequestError(http.StatusUnsupportedMediaType, CodeUnsupportedContentEncoding, "Unsupported Content-Encoding \""+encoding+"\"")
+	}
+	if err != nil {
+		return r, newRequestError(http.StatusBadRequest, CodeInvalidRequest, "Invalid "+encoding+" request body: "+err.Error())
+	}
+	defer reader.Close()
+
+	limit := h.maxDecompressedBodySize
+	body, err := ioutil.ReadAll(io.LimitReader(reader, limit+1))
+	if err != nil {
+		return r, newRequestError(http.StatusBadRequest, CodeInvalidRequest, "Invalid "+encoding+" request body: "+err.Error())
+	}
+	if int64(len(body)) > limit {
+		return r, newRequestError(http.StatusRequestEntityTooLarge, CodeRequestTooLarge, "Request body exceeds "+strconv.FormatInt(limit, 10)+" bytes once decompressed")
+	}
+
+	decompressed := r.Clone(r.Context())
+	decompressed.Body = ioutil.NopCloser(bytes.NewReader(body))
+	decompressed.ContentLength = int64(len(body))
+	decompressed.Header.Del("Content-Encoding")
+	decompressed.Header.Del("Content-Length")
+	return decompressed, nil
+}
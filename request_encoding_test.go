@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"compress/zlib"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func compressedRequest(t *testing.T, encoding, body string) *http.Request {
+	var buf bytes.Buffer
+	var writer io.WriteCloser
+	switch encoding {
+	case "gzip":
+		writer = gzip.NewWriter(&buf)
+	case "deflate":
+		writer = zlib.NewWriter(&buf)
+	}
+	writer.Write([]byte(body))
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", "/graphql", &buf)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Content-Encoding", encoding)
+	return req
+}
+
+func TestHandler_CompressedRequestBody(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema})
+	for _, encoding := range []string{"gzip", "deflate"} {
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, compressedRequest(t, encoding, `{"query":"{hero{name}}"}`))
+		if resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), "R2-D2") {
+			t.Fatalf("unexpected %s response %d %s", encoding, resp.Code, resp.Body.String())
+		}
+	}
+}
+
+func TestHandler_CompressedRequestBody_Limit(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema, MaxDecompressedBodySize: 64})
+	body := `{"query":"{hero{name}}","variables":{"padding":"` + strings.Repeat("a", 100) + `"}}`
+
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, compressedRequest(t, "gzip", body))
+	if resp.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected 413, got %d %s", resp.Code, resp.Body.String())
+	}
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// RequestIDHeader is the default header the request IDs are adopted from
+// and echoed in.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength bounds the adopted request IDs, which end up in logs.
+const maxRequestIDLength = 128
+
+type requestIDKey struct{}
+
+// RequestIDFromContext returns the ID of the request, empty unless
+// Config.RequestID is set.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// withRequestID adopts the ID of the request from its header or generates
+// one, echoing it in the header of the response.
+func (h *Handler) withRequestID(ctx context.Context, w http.ResponseWriter, r *http.Request) (context.Context, *http.Request) {
+	if !h.requestID {
+		return ctx, r
+	}
+	id := r.Header.Get(h.requestIDHeader)
+	if !validRequestID(id) {
+		if h.requestIDFn != nil {
+			id = h.requestIDFn()
+		} else {
+			id = newRequestID()
+		}
+	}
+	w.Header().Set(h.requestIDHeader, id)
+	// the rejections only get the request
+	r = r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id))
+	return context.WithValue(ctx, requestIDKey{}, id), r
+}
+
+// validRequestID reports whether an ID sent by the client can be adopted:
+// not empty, not too long and made of visible ASCII characters only.
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+// addRequestID adds the ID of the request to the "requestId" extension of
+// the errors, copying their extensions which may be shared.
+func addRequestID(ctx context.Context, errs []gqlerrors.FormattedError) {
+	id := RequestIDFromContext(ctx)
+	if id == "" {
+		return
+	}
+	for i := range errs {
+		extensions := make(map[string]interface{}, len(errs[i].Extensions)+1)
+		for key, value := range errs[i].Extensions {
+			extensions[key] = value
+		}
+		extensions["requestId"] = id
+		errs[i].Extensions = extensions
+	}
+}
@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_RequestID(t *testing.T) {
+	var contextID string
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{"name": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					contextID = RequestIDFromContext(p.Context)
+					return nil, errors.New("failed")
+				},
+			}},
+		}),
+	})
+	h := New(&Config{Schema: &schema, RequestID: true, RequestIDFn: func() string { return "generated" }})
+
+	for _, test := range []struct {
+		header, expected string
+	}{
+		{"abc-123", "abc-123"},
+		{"", "generated"},
+		{"with space", "generated"},
+		{strings.Repeat("a", maxRequestIDLength+1), "generated"},
+	} {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ name }"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set(RequestIDHeader, test.header)
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+
+		if id := resp.Header().Get(RequestIDHeader); id != test.expected || contextID != test.expected {
+			t.Errorf("%q: unexpected request ID %q in the response, %q in the context", test.header, id, contextID)
+		}
+		var result struct {
+			Errors []struct {
+				Extensions map[string]interface{}
+			}
+		}
+		json.Unmarshal(resp.Body.Bytes(), &result)
+		if len(result.Errors) != 1 || result.Errors[0].Extensions["requestId"] != test.expected {
+			t.Errorf("%q: expected the request ID in the error extensions of %s", test.header, resp.Body.String())
+		}
+	}
+
+	req, _ := http.NewRequest("PUT", "/graphql", nil)
+	req.Header.Set(RequestIDHeader, "rejected")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if !strings.Contains(resp.Body.String(), `"requestId":"rejected"`) {
+		t.Errorf("expected the request ID in the rejection %s", resp.Body.String())
+	}
+}
+
+func TestHandler_RequestIDDisabled(t *testing.T) {
+	h := New(&Config{Schema: &graphql.Schema{}})
+	resp := httptest.NewRecorder()
+	h.ContextHandler(context.Background(), resp, httptest.NewRequest("PUT", "/graphql", nil))
+	if resp.Header().Get(RequestIDHeader) != "" || strings.Contains(resp.Body.String(), "requestId") {
+		t.Errorf("unexpected request ID in %v %s", resp.Header(), resp.Body.String())
+	}
+}
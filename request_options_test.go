@@ -6,6 +6,7 @@ import (
 	"net/http"
 	"net/url"
 	"reflect"
+	"strings"
 	"testing"
 
 	"github.com/graphql-go/graphql/testutil"
@@ -95,6 +96,24 @@ func TestRequestOptions_POST_ContentTypeApplicationGraphQL(t *testing.T) {
 		t.Fatalf("wrong result, graphql result diff: %v", testutil.Diff(expected, result))
 	}
 }
+func TestRequestOptions_POST_ContentTypeApplicationGraphQL_WithQueryStringParams(t *testing.T) {
+	body := []byte(`query RebelsShipsQuery($id: ID) { rebels { name } }`)
+	expected := &RequestOptions{
+		Query:         "query RebelsShipsQuery($id: ID) { rebels { name } }",
+		Variables:     map[string]interface{}{"id": "1"},
+		OperationName: "RebelsShipsQuery",
+		Extensions:    map[string]interface{}{"tracing": true},
+	}
+
+	target := "/graphql?operationName=RebelsShipsQuery&variables=" + url.QueryEscape(`{"id":"1"}`) + "&extensions=" + url.QueryEscape(`{"tracing":true}`)
+	req, _ := http.NewRequest("POST", target, bytes.NewBuffer(body))
+	req.Header.Add("Content-Type", "application/graphql")
+	result := NewRequestOptions(req)
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("wrong result, graphql result diff: %v", testutil.Diff(expected, result))
+	}
+}
 func TestRequestOptions_POST_ContentTypeApplicationGraphQL_WithNonGraphQLQueryContent(t *testing.T) {
 	body := []byte(`not a graphql query`)
 	expected := &RequestOptions{
@@ -408,3 +427,82 @@ func TestRequestOptions_POST_UnsupportedContentType(t *testing.T) {
 		t.Fatalf("wrong result, graphql result diff: %v", testutil.Diff(expected, result))
 	}
 }
+
+func TestRequestOptions_GET_OperationNameAndExtensionsOnly(t *testing.T) {
+	extensions := `{"persistedQuery":{"version":1,"sha256Hash":"abc"}}`
+	expected := &RequestOptions{
+		Variables:     map[string]interface{}{},
+		OperationName: "HeroQuery",
+		Extensions: map[string]interface{}{
+			"persistedQuery": map[string]interface{}{"version": float64(1), "sha256Hash": "abc"},
+		},
+	}
+
+	req, _ := http.NewRequest("GET", "/graphql?operationName=HeroQuery&extensions="+url.QueryEscape(extensions), nil)
+	result := NewRequestOptions(req)
+
+	if !reflect.DeepEqual(result, expected) {
+		t.Fatalf("wrong result, graphql result diff: %v", testutil.Diff(expected, result))
+	}
+}
+
+func TestRequestOptions_GET_InvalidParameters(t *testing.T) {
+	tests := []struct {
+		target  string
+		message string
+	}{
+		{"/graphql?query={hero{name}}", ""},
+		{"/graphql?query={hero{name}}&variables={", "Invalid variables parameter"},
+		{"/graphql?query={hero{name}}&extensions=[1]", "Invalid extensions parameter"},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", test.target, nil)
+		opts, err := parseRequestOptions(req)
+		if opts.Query != "{hero{name}}" {
+			t.Fatalf("expected the query to be parsed for %s, got %+v", test.target, opts)
+		}
+		if test.message == "" && err != nil {
+			t.Fatalf("unexpected error for %s: %v", test.target, err)
+		}
+		if test.message != "" && (err == nil || err.status != http.StatusBadRequest || !strings.HasPrefix(err.message, test.message)) {
+			t.Fatalf("expected %q for %s, got %v", test.message, test.target, err)
+		}
+	}
+}
+
+func TestRequestOptions_ExtensionAccessors(t *testing.T) {
+	opts := &RequestOptions{Extensions: map[string]interface{}{
+		"persistedQuery": map[string]interface{}{"version": float64(1), "sha256Hash": "abc"},
+		"clientLibrary":  map[string]interface{}{"name": "web", "version": "1.2.0"},
+		"traceId":        "t1",
+		"count":          float64(2),
+	}}
+	if pq, err := opts.GetPersistedQuery(); err != nil || *pq != (PersistedQuery{Version: 1, SHA256Hash: "abc"}) {
+		t.Errorf("unexpected persisted query %+v %v", pq, err)
+	}
+	if client, ok := opts.GetClientAwareness(); !ok || client != (ClientAwareness{Name: "web", Version: "1.2.0"}) {
+		t.Errorf("unexpected client %+v", client)
+	}
+	if value, ok := opts.GetStringExtension("traceId"); !ok || value != "t1" {
+		t.Errorf("unexpected extension %q", value)
+	}
+	if _, ok := opts.GetStringExtension("count"); ok {
+		t.Errorf("expected a number not to be returned as a string")
+	}
+
+	malformed := &RequestOptions{Extensions: map[string]interface{}{
+		"persistedQuery": map[string]interface{}{"sha256Hash": 1},
+		"clientLibrary":  "web",
+	}}
+	if _, err := malformed.GetPersistedQuery(); err == nil {
+		t.Errorf("expected an invalid persisted query to be reported")
+	}
+	if _, ok := malformed.GetClientAwareness(); ok {
+		t.Errorf("expected an invalid client library to be ignored")
+	}
+
+	var empty RequestOptions
+	if pq, err := empty.GetPersistedQuery(); pq != nil || err != nil {
+		t.Errorf("expected no persisted query, got %+v %v", pq, err)
+	}
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_RequestSignature(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   "Query",
+			Fields: graphql.Fields{"name": &graphql.Field{Type: graphql.String}},
+		}),
+	})
+	signature := &RequestSignature{Secrets: [][]byte{[]byte("new"), []byte("old")}}
+	h := New(&Config{Schema: &schema, RequestSignature: signature})
+	body := `{"query":"{ name }"}`
+	query := func(body, sig string, timestamp time.Time) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if sig != "" {
+			req.Header.Set(SignatureHeader, sig)
+			req.Header.Set(SignatureTimestampHeader, strconv.FormatInt(timestamp.Unix(), 10))
+		}
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+
+	now := time.Now()
+	if resp := query(body, "sha256="+signature.Sign(now, []byte(body)), now); resp.Code != http.StatusOK || resp.Body.String() != `{"data":{"name":null}}` {
+		t.Errorf("expected the signed request to be executed, got %d %s", resp.Code, resp.Body.String())
+	}
+	old := &RequestSignature{Secrets: [][]byte{[]byte("old")}}
+	if resp := query(body, old.Sign(now, []byte(body)), now); resp.Code != http.StatusOK {
+		t.Errorf("expected the rotated secret to be accepted, got %d %s", resp.Code, resp.Body.String())
+	}
+
+	for name, resp := range map[string]*httptest.ResponseRecorder{
+		"unsigned": query(body, "", now),
+		"tampered": query(`{"query":"{ __typename }"}`, signature.Sign(now, []byte(body)), now),
+		"expired":  query(body, signature.Sign(now.Add(-time.Hour), []byte(body)), now.Add(-time.Hour)),
+		"other":    query(body, (&RequestSignature{Secrets: [][]byte{[]byte("other")}}).Sign(now, []byte(body)), now),
+	} {
+		if resp.Code != http.StatusUnauthorized || !strings.Contains(resp.Body.String(), string(CodeUnauthenticated)) {
+			t.Errorf("expected the %s request to be rejected, got %d %s", name, resp.Code, resp.Body.String())
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+// ResolverTiming is the timing of a field resolver, until it returned its
+// value.
+type ResolverTiming struct {
+	Path       []interface{}
+	ParentType string
+	FieldName  string
+	ReturnType string
+	Start      time.Time
+	Duration   time.Duration
+	Err        error
+}
+
+// ResolverTimingFn receives the timing of every field resolver of the
+// requests.
+type ResolverTimingFn func(ctx context.Context, timing ResolverTiming)
+
+// timeResolver starts timing a resolver, returning the function reporting
+// the timing once it returned: to the Apollo Tracing extension of the
+// request, and with Config.TraceResolvers in a span with the TracerProvider,
+// with the Metrics, the MeterProvider and the MetricsEmitter by
+// "Type.field", and to the ResolverTimingFn.
+func (h *Handler) timeResolver(ctx context.Context, info *graphql.ResolveInfo) graphql.ResolveFieldFinishFunc {
+	timing := ResolverTiming{
+		FieldName: info.FieldName,
+		Start:     time.Now(),
+	}
+	if info.Path != nil {
+		timing.Path = info.Path.AsArray()
+	}
+	if info.ParentType != nil {
+		timing.ParentType = info.ParentType.Name()
+	}
+	if info.ReturnType != nil {
+		timing.ReturnType = info.ReturnType.String()
+	}
+	tracing := apolloTracingFromContext(ctx)
+	if !h.traceResolvers {
+		return func(value interface{}, err error) {
+			timing.Duration = time.Since(timing.Start)
+			tracing.addResolver(timing)
+		}
+	}
+	coordinate := timing.ParentType + "." + timing.FieldName
+	span := startSpan(ctx, "graphql.resolve", map[string]interface{}{
+		"graphql.field.name":        timing.FieldName,
+		"graphql.field.parent_type": timing.ParentType,
+		"graphql.field.path":        pathString(timing.Path),
+	})
+	return func(value interface{}, err error) {
+		timing.Duration = time.Since(timing.Start)
+		timing.Err = err
+		tracing.addResolver(timing)
+		if span != nil {
+			if err != nil {
+				span.RecordError(err)
+			}
+			span.End()
+		}
+		h.metrics.resolverFinished(coordinate, timing.Duration)
+		h.meter.resolverFinished(ctx, coordinate, timing.Duration)
+		if h.metricsEmitter != nil {
+			h.metricsEmitter.Timing("graphql.resolver.duration", timing.Duration, map[string]string{"field": coordinate})
+		}
+		if h.resolverTimingFn != nil {
+			h.resolverTimingFn(ctx, timing)
+		}
+	}
+}
+
+// pathString joins the keys of a response path with dots, e.g.
+// "hero.friends.0.name".
+func pathString(path []interface{}) string {
+	keys := make([]string, len(path))
+	for i, key := range path {
+		keys[i] = fmt.Sprint(key)
+	}
+	return strings.Join(keys, ".")
+}
@@ -0,0 +1,96 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_TraceResolvers(t *testing.T) {
+	user := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"name": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					time.Sleep(10 * time.Millisecond)
+					return "Alice", nil
+				},
+			},
+			"email": &graphql.Field{
+				Type: graphql.String,
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return nil, errors.New("hidden")
+				},
+			},
+		},
+	})
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"users": &graphql.Field{
+					Type: graphql.NewList(user),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return []interface{}{1}, nil
+					},
+				},
+			},
+		}),
+	})
+	var timings []ResolverTiming
+	spans := &spanRecorder{}
+	metrics := NewMetrics("")
+	h := New(&Config{
+		Schema:         &schema,
+		TraceResolvers: true,
+		TracerProvider: spans,
+		Metrics:        metrics,
+		ResolverTimingFn: func(ctx context.Context, timing ResolverTiming) {
+			timings = append(timings, timing)
+		},
+	})
+
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ users { name email } }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(timings) != 3 {
+		t.Fatalf("expected a timing per resolver, got %+v", timings)
+	}
+	// graphql-go resolves the fields of an object in no particular order
+	byField := make(map[string]ResolverTiming)
+	for _, timing := range timings {
+		byField[timing.FieldName] = timing
+	}
+	name := byField["name"]
+	if name.ParentType != "User" || name.FieldName != "name" || name.ReturnType != "String" || !reflect.DeepEqual(name.Path, []interface{}{"users", 0, "name"}) || name.Duration < 10*time.Millisecond {
+		t.Errorf("unexpected timing %+v", name)
+	}
+	if byField["email"].Err == nil {
+		t.Errorf("expected the error of the resolver")
+	}
+
+	resolves := make(map[interface{}]*recordedSpan)
+	for _, span := range spans.spans {
+		if span.name == "graphql.resolve" {
+			resolves[span.attributes["graphql.field.path"]] = span
+		}
+	}
+	if len(resolves) != 3 || resolves["users.0.name"] == nil || resolves["users.0.email"] == nil || resolves["users.0.email"].err == nil || resolves["users.0.name"].parent != spans.spans[0] {
+		t.Errorf("unexpected resolver spans %+v", resolves)
+	}
+
+	resp := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(resp, httptest.NewRequest("GET", "/metrics", nil))
+	if !strings.Contains(resp.Body.String(), `graphql_resolver_duration_seconds_count{field="User.name"} 1`) {
+		t.Errorf("expected the resolver durations in\n%s", resp.Body.String())
+	}
+}
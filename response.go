@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Response lets resolvers set headers, cookies and the status of the HTTP
+// response of their request, before it is written.
+type Response struct {
+	mu     sync.Mutex
+	header http.Header
+	status int
+}
+
+// headers the handler writes itself
+var protectedHeaders = map[string]bool{
+	"Content-Type":      true,
+	"Content-Length":    true,
+	"Content-Encoding":  true,
+	"Transfer-Encoding": true,
+}
+
+type responseKey struct{}
+
+// ResponseFromContext returns the response of the request, nil when the
+// operation is not answered with a single HTTP response, e.g. over a
+// WebSocket.
+func ResponseFromContext(ctx context.Context) *Response {
+	response, _ := ctx.Value(responseKey{}).(*Response)
+	return response
+}
+
+func withResponse(ctx context.Context) (context.Context, *Response) {
+	response := &Response{header: make(http.Header)}
+	return context.WithValue(ctx, responseKey{}, response), response
+}
+
+// SetHeader sets a response header, except the ones describing the body.
+func (r *Response) SetHeader(name, value string) error {
+	name = http.CanonicalHeaderKey(name)
+	if protectedHeaders[name] {
+		return fmt.Errorf("the %s header cannot be set", name)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.header.Set(name, value)
+	return nil
+}
+
+// SetCookie adds a Set-Cookie header.
+func (r *Response) SetCookie(cookie *http.Cookie) {
+	if v := cookie.String(); v != "" {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+		r.header.Add("Set-Cookie", v)
+	}
+}
+
+// SetStatus sets the status code, which must be 2xx to 5xx and allow a body.
+func (r *Response) SetStatus(code int) error {
+	if code < 200 || code > 599 || code == http.StatusNoContent || code == http.StatusNotModified {
+		return fmt.Errorf("invalid response status %d", code)
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.status = code
+	return nil
+}
+
+// writeHeader copies the headers set by the resolvers to w, returning the
+// status they set or the given one.
+func (r *Response) writeHeader(w http.ResponseWriter, status int) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for name, values := range r.header {
+		w.Header()[name] = append(w.Header()[name], values...)
+	}
+	if r.status != 0 {
+		return r.status
+	}
+	return status
+}
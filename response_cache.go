@@ -0,0 +1,311 @@
+package handler
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sync"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// responseCacheEntry is the data of an executed query, kept with what
+// purges select on.
+type responseCacheEntry struct {
+	key           string
+	operationName string
+	variables     map[string]interface{}
+	tags          map[string]bool
+	data          []byte
+	expires       time.Time
+	hits          int
+	refreshing    bool
+}
+
+// ResponseCacheKeyFn scopes the cached responses of a request to what they
+// depend on besides the operation and its variables, e.g. the user ID or
+// "public" for data shared by everyone. It returns false when the response
+// must not be cached.
+type ResponseCacheKeyFn func(ctx context.Context, r *http.Request) (string, bool)
+
+// defaultResponseCacheMaxEntries bounds the response cache when
+// Config.ResponseCacheMaxEntries is not set.
+const defaultResponseCacheMaxEntries = 10000
+
+// responseCache holds the responses of the queries executed without errors,
+// evicting the least recently used ones beyond its size.
+type responseCache struct {
+	mu           sync.Mutex
+	ttl          time.Duration
+	stale        time.Duration
+	refreshAhead time.Duration
+	refreshHits  int
+	size         int
+	order        *list.List
+	entries      map[string]*list.Element
+}
+
+func newResponseCache(p *Config) *responseCache {
+	if p.ResponseCacheTTL <= 0 || p.ResponseCacheKeyFn == nil {
+		return nil
+	}
+	size := p.ResponseCacheMaxEntries
+	if size <= 0 {
+		size = defaultResponseCacheMaxEntries
+	}
+	return &responseCache{
+		ttl:          p.ResponseCacheTTL,
+		stale:        p.ResponseCacheStaleIfError,
+		refreshAhead: p.ResponseCacheRefreshAhead,
+		refreshHits:  p.ResponseCacheRefreshHits,
+		size:         size,
+		order:        list.New(),
+		entries:      make(map[string]*list.Element),
+	}
+}
+
+// CacheKey returns a key identifying the response of the request regardless
+// of the formatting of its query and the order of its variables, e.g. for a
+// CDN to cache the GET queries under.
+func CacheKey(opts *RequestOptions) (string, error) {
+	op, err := parseOperation(opts.Query, opts.OperationName)
+	if err != nil {
+		return "", err
+	}
+	return responseCacheKey("", op, opts.Variables), nil
+}
+
+// responseCacheKey identifies a query execution within a scope.
+func responseCacheKey(scope string, op *operation, variables map[string]interface{}) string {
+	// maps are marshaled with sorted keys
+	encoded, _ := json.Marshal(variables)
+	hash := sha256.New()
+	hash.Write([]byte(scope))
+	hash.Write([]byte{0})
+	hash.Write([]byte(op.fingerprint()))
+	hash.Write([]byte{0})
+	hash.Write(encoded)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// get returns the entry cached for the key, and whether it expired and may
+// only be served stale.
+func (c *responseCache) get(key string) (entry *responseCacheEntry, stale bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	element, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry = element.Value.(*responseCacheEntry)
+	now := time.Now()
+	if now.After(entry.expires.Add(c.stale)) {
+		c.remove(element)
+		return nil, false
+	}
+	c.order.MoveToFront(element)
+	return entry, now.After(entry.expires)
+}
+
+// hit counts an access to a fresh entry, and reports whether the entry is
+// hot and close enough to expiring to be refreshed.
+func (c *responseCache) hit(entry *responseCacheEntry) bool {
+	if c.refreshAhead <= 0 {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.hits++
+	if entry.refreshing || entry.hits < c.refreshHits || time.Until(entry.expires) > c.refreshAhead {
+		return false
+	}
+	entry.refreshing = true
+	return true
+}
+
+// refreshFailed lets the next hits refresh the entry again.
+func (c *responseCache) refreshFailed(entry *responseCacheEntry) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	entry.refreshing = false
+}
+
+func (c *responseCache) set(entry *responseCacheEntry) {
+	entry.expires = time.Now().Add(c.ttl)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if element, ok := c.entries[entry.key]; ok {
+		c.remove(element)
+	}
+	c.entries[entry.key] = c.order.PushFront(entry)
+	for c.order.Len() > c.size {
+		c.remove(c.order.Back())
+	}
+}
+
+func (c *responseCache) remove(element *list.Element) {
+	c.order.Remove(element)
+	delete(c.entries, element.Value.(*responseCacheEntry).key)
+}
+
+// purge removes the entries selected by the filter and returns their count.
+func (c *responseCache) purge(filter ResponseCachePurge) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	purged := 0
+	for _, element := range c.entries {
+		if filter.matches(element.Value.(*responseCacheEntry)) {
+			c.remove(element)
+			purged++
+		}
+	}
+	return purged
+}
+
+// executeQuery executes the operation, answering queries from the response
+// cache when configured.
+func (h *Handler) executeQuery(r *http.Request, op *operation, params graphql.Params) *graphql.Result {
+	if h.responseCache == nil || op == nil || op.Type() != ast.OperationTypeQuery {
+		return h.execute(op, params)
+	}
+	scope, ok := h.responseCacheKeyFn(params.Context, r)
+	if !ok {
+		return h.execute(op, params)
+	}
+
+	key := responseCacheKey(scope, op, params.VariableValues)
+	entry, stale := h.responseCache.get(key)
+	if entry != nil && !stale {
+		if result := entry.result(); result != nil {
+			if h.responseCache.hit(entry) {
+				go h.refresh(key, entry, op, params)
+			}
+			h.emit(params.Context, EventCacheHit, SeverityInfo, "", map[string]interface{}{"graphql.operation.name": op.Name()})
+			return result
+		}
+	}
+
+	result := h.executeCached(key, op, params)
+	if len(result.Errors) > 0 && entry != nil {
+		// serve the stale response rather than the errors
+		if stale := entry.result(); stale != nil {
+			setExtension(stale, "servedStale", true)
+			h.emit(params.Context, EventCacheServeStale, SeverityWarn, "", map[string]interface{}{"graphql.operation.name": op.Name()})
+			return stale
+		}
+	}
+	return result
+}
+
+// executeCached executes a query and caches its response when free of
+// errors.
+func (h *Handler) executeCached(key string, op *operation, params graphql.Params) *graphql.Result {
+	var tags *cacheTags
+	params.Context, tags = withCacheTags(params.Context)
+	result := h.execute(op, params)
+	if len(result.Errors) == 0 {
+		if data, err := json.Marshal(result.Data); err == nil {
+			h.responseCache.set(&responseCacheEntry{
+				key:           key,
+				operationName: op.Name(),
+				variables:     params.VariableValues,
+				tags:          tags.list(),
+				data:          data,
+			})
+		}
+	}
+	return result
+}
+
+// refresh re-executes a hot query ahead of its expiry. The refresh
+// outlives the request triggering it but keeps its values, like the
+// authentication the resolvers depend on.
+func (h *Handler) refresh(key string, entry *responseCacheEntry, op *operation, params graphql.Params) {
+	params.Context = detach(params.Context)
+	if result := h.executeCached(key, op, params); len(result.Errors) > 0 {
+		h.responseCache.refreshFailed(entry)
+	}
+}
+
+func (e *responseCacheEntry) result() *graphql.Result {
+	var data interface{}
+	if err := json.Unmarshal(e.data, &data); err != nil {
+		return nil
+	}
+	return &graphql.Result{Data: data}
+}
+
+// execute runs the operation and shadows it when configured.
+func (h *Handler) execute(op *operation, params graphql.Params) *graphql.Result {
+	start := time.Now()
+	result := h.do(op, params)
+	h.shadow(params.Context, op, params, result, time.Since(start))
+	return result
+}
+
+// ResponseCachePurge selects cached responses to purge. The zero value
+// selects all of them.
+type ResponseCachePurge struct {
+	// OperationName selects the responses of the named operations.
+	OperationName string `json:"operationName,omitempty"`
+	// Tag selects the responses tagged with AddCacheTags.
+	Tag string `json:"tag,omitempty"`
+	// Variables selects the responses executed with at least these
+	// variable values.
+	Variables map[string]interface{} `json:"variables,omitempty"`
+}
+
+func (f ResponseCachePurge) matches(entry *responseCacheEntry) bool {
+	if f.OperationName != "" && f.OperationName != entry.operationName {
+		return false
+	}
+	if f.Tag != "" && !entry.tags[f.Tag] {
+		return false
+	}
+	for name, value := range f.Variables {
+		actual, ok := entry.variables[name]
+		if !ok || !reflect.DeepEqual(actual, value) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalized converts the variable values to their JSON decoded form, as
+// the cached variables are.
+func (f ResponseCachePurge) normalized() ResponseCachePurge {
+	if len(f.Variables) == 0 {
+		return f
+	}
+	encoded, err := json.Marshal(f.Variables)
+	if err != nil {
+		return f
+	}
+	var variables map[string]interface{}
+	if err := json.Unmarshal(encoded, &variables); err != nil {
+		return f
+	}
+	f.Variables = variables
+	return f
+}
+
+// sweep removes the entries that can no longer be served, even stale, and
+// returns their count.
+func (c *responseCache) sweep(now time.Time) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	swept := 0
+	for _, element := range c.entries {
+		if now.After(element.Value.(*responseCacheEntry).expires.Add(c.stale)) {
+			c.remove(element)
+			swept++
+		}
+	}
+	return swept
+}
@@ -0,0 +1,260 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+func publicScope(ctx context.Context, r *http.Request) (string, bool) {
+	return "public", true
+}
+
+func TestHandler_ResponseCacheScope(t *testing.T) {
+	var executions int32
+	h := New(&Config{
+		Schema:           newCountingSchema(t, &executions),
+		ResponseCacheTTL: time.Minute,
+		ResponseCacheKeyFn: func(ctx context.Context, r *http.Request) (string, bool) {
+			user := r.Header.Get("X-User")
+			return user, user != ""
+		},
+	})
+	query := func(user string) {
+		req, _ := http.NewRequest("GET", "/graphql?query={product}", nil)
+		req.Header.Set("X-User", user)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	query("alice")
+	query("alice")
+	query("bob")
+	query("")
+	query("")
+	if executions := atomic.LoadInt32(&executions); executions != 4 {
+		t.Fatalf("expected 4 executions, got %d", executions)
+	}
+}
+
+func TestHandler_ResponseCacheRequiresKeyFn(t *testing.T) {
+	var executions int32
+	h := New(&Config{Schema: newCountingSchema(t, &executions), ResponseCacheTTL: time.Minute})
+
+	queryProduct(h, "1")
+	queryProduct(h, "1")
+	if executions := atomic.LoadInt32(&executions); executions != 2 {
+		t.Fatalf("expected 2 executions, got %d", executions)
+	}
+}
+
+func TestHandler_ResponseCacheMaxEntries(t *testing.T) {
+	var executions int32
+	h := New(&Config{
+		Schema:                  newCountingSchema(t, &executions),
+		ResponseCacheTTL:        time.Minute,
+		ResponseCacheKeyFn:      publicScope,
+		ResponseCacheMaxEntries: 2,
+	})
+
+	queryProduct(h, "1")
+	queryProduct(h, "2")
+	queryProduct(h, "1")
+	queryProduct(h, "3")
+	queryProduct(h, "1")
+	if executions := atomic.LoadInt32(&executions); executions != 3 {
+		t.Fatalf("expected 3 executions, got %d", executions)
+	}
+	queryProduct(h, "2")
+	if executions := atomic.LoadInt32(&executions); executions != 4 {
+		t.Fatalf("expected the least recently used response evicted, got %d executions", executions)
+	}
+}
+
+func TestHandler_ResponseCacheStaleIfError(t *testing.T) {
+	var failing int32
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"price": &graphql.Field{
+					Type: graphql.Int,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						if atomic.LoadInt32(&failing) == 1 {
+							return nil, errors.New("pricing service unavailable")
+						}
+						return 42, nil
+					},
+				},
+			},
+		}),
+	})
+	h := New(&Config{
+		Schema:                    &schema,
+		ResponseCacheTTL:          10 * time.Millisecond,
+		ResponseCacheKeyFn:        publicScope,
+		ResponseCacheStaleIfError: time.Minute,
+	})
+	query := func() map[string]interface{} {
+		req, _ := http.NewRequest("GET", "/graphql?query={price}", nil)
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		var result map[string]interface{}
+		json.Unmarshal(resp.Body.Bytes(), &result)
+		return result
+	}
+
+	query()
+	time.Sleep(20 * time.Millisecond)
+	atomic.StoreInt32(&failing, 1)
+
+	expected := map[string]interface{}{
+		"data":       map[string]interface{}{"price": float64(42)},
+		"extensions": map[string]interface{}{"servedStale": true},
+	}
+	if result := query(); !reflect.DeepEqual(result, expected) {
+		t.Fatalf("expected the stale response, got %v", result)
+	}
+}
+
+func TestHandler_ResponseCacheRefreshAhead(t *testing.T) {
+	var executions int32
+	h := New(&Config{
+		Schema:                    newCountingSchema(t, &executions),
+		ResponseCacheTTL:          400 * time.Millisecond,
+		ResponseCacheKeyFn:        publicScope,
+		ResponseCacheRefreshAhead: 300 * time.Millisecond,
+		ResponseCacheRefreshHits:  2,
+	})
+	start := time.Now()
+
+	queryProduct(h, "1")
+	time.Sleep(120 * time.Millisecond)
+	queryProduct(h, "1")
+	queryProduct(h, "1")
+	for atomic.LoadInt32(&executions) != 2 {
+		if time.Since(start) > 300*time.Millisecond {
+			t.Fatal("expected the hot response to be refreshed")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	time.Sleep(450*time.Millisecond - time.Since(start))
+	queryProduct(h, "1")
+	if executions := atomic.LoadInt32(&executions); executions != 2 {
+		t.Fatalf("expected the refreshed response to be served, got %d executions", executions)
+	}
+}
+
+type userKey struct{}
+
+func TestHandler_ResponseCacheRefreshFailure(t *testing.T) {
+	var executions int32
+	users := make(chan interface{}, 3)
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"price": &graphql.Field{
+					Type: graphql.Int,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						users <- p.Context.Value(userKey{})
+						if atomic.AddInt32(&executions, 1) == 2 {
+							return nil, errors.New("pricing service unavailable")
+						}
+						return 42, nil
+					},
+				},
+			},
+		}),
+	})
+	h := New(&Config{
+		Schema:                    &schema,
+		ResponseCacheTTL:          time.Minute,
+		ResponseCacheKeyFn:        publicScope,
+		ResponseCacheRefreshAhead: time.Hour,
+		ResponseCacheRefreshHits:  1,
+	})
+	query := func() {
+		req, _ := http.NewRequest("GET", "/graphql?query={price}", nil)
+		req = req.WithContext(context.WithValue(req.Context(), userKey{}, "alice"))
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	query()
+	<-users
+	query()
+	if user := <-users; user != "alice" {
+		t.Fatalf("expected the refresh to keep the request values, got user %v", user)
+	}
+	for atomic.LoadInt32(&executions) < 2 {
+		time.Sleep(time.Millisecond)
+	}
+	time.Sleep(10 * time.Millisecond)
+
+	query()
+	select {
+	case <-users:
+	case <-time.After(time.Second):
+		t.Fatal("expected the failed refresh to be retried")
+	}
+}
+
+func TestCacheKey(t *testing.T) {
+	key, err := CacheKey(&RequestOptions{
+		Query:     `query Product($id: Int, $lang: String) { product(id: $id) }`,
+		Variables: map[string]interface{}{"id": 1, "lang": "en"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var variables map[string]interface{}
+	json.Unmarshal([]byte(`{"lang":"en","id":1}`), &variables)
+	same, _ := CacheKey(&RequestOptions{
+		Query: `
+			# formatting differs
+			query Product($id: Int, $lang: String) {
+				product(id: $id)
+			}`,
+		Variables: variables,
+	})
+	if same != key {
+		t.Fatalf("expected the same key, got %s and %s", key, same)
+	}
+
+	other, _ := CacheKey(&RequestOptions{
+		Query:     `query Product($id: Int, $lang: String) { product(id: $id) }`,
+		Variables: map[string]interface{}{"id": 2, "lang": "en"},
+	})
+	if other == key {
+		t.Fatal("expected other variables to change the key")
+	}
+	if _, err := CacheKey(&RequestOptions{Query: "{"}); err == nil {
+		t.Fatal("expected an error for an invalid query")
+	}
+}
+
+func TestResponseCache_Sweep(t *testing.T) {
+	c := newResponseCache(&Config{
+		ResponseCacheTTL:          time.Minute,
+		ResponseCacheStaleIfError: time.Minute,
+		ResponseCacheKeyFn:        publicScope,
+	})
+	c.set(&responseCacheEntry{key: "a"})
+	c.set(&responseCacheEntry{key: "b"})
+	c.entries["a"].Value.(*responseCacheEntry).expires = time.Now().Add(-2 * time.Minute)
+
+	if swept := c.sweep(time.Now()); swept != 1 {
+		t.Errorf("expected 1 swept entry, got %d", swept)
+	}
+	if _, ok := c.entries["b"]; !ok || len(c.entries) != 1 {
+		t.Errorf("expected only b to remain")
+	}
+}
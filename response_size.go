@@ -0,0 +1,26 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// guardResponseSize replaces the responses whose serialized size is over
+// Config.MaxResponseBytes by an error response, returning it serialized with
+// its status and content type.
+func (h *Handler) guardResponseSize(ctx context.Context, r *http.Request, strict bool, size int) (*graphql.Result, []byte, int, string) {
+	if h.maxResponseBytes <= 0 || size <= h.maxResponseBytes {
+		return nil, nil, 0, ""
+	}
+	reqErr := validationError(strict, CodeResponseTooLarge, "Response of "+strconv.Itoa(size)+" bytes exceeds the maximum of "+strconv.Itoa(h.maxResponseBytes))
+	h.emit(ctx, EventResponseTooLarge, SeverityWarn, reqErr.message, map[string]interface{}{
+		"http.response.body.size": size,
+	})
+	result := &graphql.Result{Errors: []gqlerrors.FormattedError{reqErr.formatted()}}
+	tooLarge, contentType := h.serialize(r, result, JSONSerializer{OmitNullData: h.omitRequestErrorData(r)})
+	return result, tooLarge, reqErr.status, contentType
+}
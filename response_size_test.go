@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_MaxResponseBytes(t *testing.T) {
+	var callbackResult *graphql.Result
+	events := &eventRecorder{}
+	h := New(&Config{
+		Schema:           &testutil.StarWarsSchema,
+		MaxResponseBytes: 64,
+		EventEmitter:     events,
+		ResultCallbackFn: func(ctx context.Context, params *graphql.Params, result *graphql.Result, responseBody []byte) {
+			callbackResult = result
+		},
+	})
+	query := func(q string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", "/graphql?query="+q, nil)
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		return resp
+	}
+
+	if resp := query("{hero{name}}"); resp.Body.String() != `{"data":{"hero":{"name":"R2-D2"}}}` {
+		t.Errorf("unexpected response %s", resp.Body.String())
+	}
+
+	resp := query("{hero{name+friends{name}}}")
+	expected := `{"data":null,"errors":[{"message":"Response of 115 bytes exceeds the maximum of 64","locations":[],"extensions":{"code":"RESPONSE_TOO_LARGE"}}]}`
+	if resp.Code != http.StatusOK || resp.Body.String() != expected {
+		t.Errorf("unexpected response %d %s", resp.Code, resp.Body.String())
+	}
+	if !strings.HasPrefix(resp.Header().Get("Content-Type"), "application/json") {
+		t.Errorf("unexpected Content-Type %q", resp.Header().Get("Content-Type"))
+	}
+	if callbackResult == nil || len(callbackResult.Errors) != 1 || callbackResult.Errors[0].Extensions["code"] != CodeResponseTooLarge {
+		t.Errorf("unexpected callback result %+v", callbackResult)
+	}
+	if event := events.last(); event.Name != EventResponseTooLarge || event.Attributes["http.response.body.size"] != 115 {
+		t.Errorf("unexpected event %+v", event)
+	}
+}
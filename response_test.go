@@ -0,0 +1,53 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_ResponseFromResolvers(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   "Query",
+			Fields: graphql.Fields{"ok": &graphql.Field{Type: graphql.Boolean}},
+		}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Mutation",
+			Fields: graphql.Fields{
+				"login": &graphql.Field{
+					Type: graphql.Boolean,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						response := ResponseFromContext(p.Context)
+						response.SetCookie(&http.Cookie{Name: "session", Value: "abc", HttpOnly: true})
+						if err := response.SetHeader("Content-Type", "text/plain"); err == nil {
+							t.Error("expected the Content-Type to be protected")
+						}
+						response.SetHeader("X-Login", "1")
+						response.SetStatus(http.StatusCreated)
+						return true, nil
+					},
+				},
+			},
+		}),
+	})
+	h := New(&Config{Schema: &schema})
+
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"mutation { login }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	if resp.Code != http.StatusCreated {
+		t.Fatalf("expected 201, got %d", resp.Code)
+	}
+	if cookie := resp.Header().Get("Set-Cookie"); cookie != "session=abc; HttpOnly" {
+		t.Fatalf("unexpected cookie %q", cookie)
+	}
+	if resp.Header().Get("X-Login") != "1" || !strings.HasPrefix(resp.Header().Get("Content-Type"), "application/json") {
+		t.Fatalf("unexpected headers %v", resp.Header())
+	}
+}
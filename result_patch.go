@@ -0,0 +1,190 @@
+package handler
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// PatchOperation is a JSON Patch (RFC 6902) operation.
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value"`
+}
+
+// MarshalJSON omits the value of remove operations, keeping null values of
+// the others.
+func (o PatchOperation) MarshalJSON() ([]byte, error) {
+	if o.Op == "remove" {
+		return json.Marshal(map[string]string{"op": o.Op, "path": o.Path})
+	}
+	type operation PatchOperation
+	return json.Marshal(operation(o))
+}
+
+// resultStore remembers the most recent query results by hash, for the
+// polling clients to get patches against them.
+type resultStore struct {
+	mu      sync.Mutex
+	size    int
+	order   *list.List
+	results map[string]*list.Element
+}
+
+type storedResult struct {
+	hash string
+	data []byte
+}
+
+func newResultStore(size int) *resultStore {
+	if size <= 0 {
+		return nil
+	}
+	return &resultStore{size: size, order: list.New(), results: make(map[string]*list.Element)}
+}
+
+func (s *resultStore) get(hash string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	element, ok := s.results[hash]
+	if !ok {
+		return nil, false
+	}
+	s.order.MoveToFront(element)
+	return element.Value.(*storedResult).data, true
+}
+
+func (s *resultStore) add(hash string, data []byte) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if element, ok := s.results[hash]; ok {
+		s.order.MoveToFront(element)
+		return
+	}
+	s.results[hash] = s.order.PushFront(&storedResult{hash: hash, data: data})
+	for s.order.Len() > s.size {
+		oldest := s.order.Back()
+		s.order.Remove(oldest)
+		delete(s.results, oldest.Value.(*storedResult).hash)
+	}
+}
+
+// patchResult reports the hash of a query result in the "resultHash"
+// extension, and replaces its data with a patch when the client sent the
+// hash of a previous result in the "resultPatch" request extension and the
+// patch is smaller.
+func (h *Handler) patchResult(op *operation, opts *RequestOptions, result *graphql.Result) {
+	if h.resultPatches == nil || op == nil || op.Type() != ast.OperationTypeQuery || len(result.Errors) > 0 {
+		return
+	}
+	data, err := json.Marshal(result.Data)
+	if err != nil {
+		return
+	}
+	sum := sha256.Sum256(data)
+	hash := hex.EncodeToString(sum[:])
+	h.resultPatches.add(hash, data)
+	setExtension(result, "resultHash", hash)
+
+	base := baseResultHash(opts)
+	if base == "" {
+		return
+	}
+	baseData, ok := h.resultPatches.get(base)
+	if !ok {
+		return
+	}
+	var baseValue, value interface{}
+	json.Unmarshal(baseData, &baseValue)
+	json.Unmarshal(data, &value)
+	patch := diffPatch("", baseValue, value, []PatchOperation{})
+	encoded, err := json.Marshal(patch)
+	if err != nil || len(encoded) >= len(data) {
+		return
+	}
+	result.Data = nil
+	setExtension(result, "resultPatch", map[string]interface{}{
+		"baseHash": base,
+		"patch":    patch,
+	})
+}
+
+// baseResultHash returns the hash of the result the client holds.
+func baseResultHash(opts *RequestOptions) string {
+	resultPatch, _ := opts.Extensions["resultPatch"].(map[string]interface{})
+	base, _ := resultPatch["baseHash"].(string)
+	return base
+}
+
+// diffPatch appends the operations transforming the decoded JSON value a
+// into b.
+func diffPatch(path string, a, b interface{}, patch []PatchOperation) []PatchOperation {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok {
+			break
+		}
+		keys := make([]string, 0, len(a)+len(b))
+		for k := range a {
+			keys = append(keys, k)
+		}
+		for k := range b {
+			if _, ok := a[k]; !ok {
+				keys = append(keys, k)
+			}
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			child := path + "/" + escapePointer(k)
+			av, inA := a[k]
+			bv, inB := b[k]
+			switch {
+			case !inB:
+				patch = append(patch, PatchOperation{Op: "remove", Path: child})
+			case !inA:
+				patch = append(patch, PatchOperation{Op: "add", Path: child, Value: bv})
+			default:
+				patch = diffPatch(child, av, bv, patch)
+			}
+		}
+		return patch
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok {
+			break
+		}
+		common := len(a)
+		if len(b) < common {
+			common = len(b)
+		}
+		for i := 0; i < common; i++ {
+			patch = diffPatch(path+"/"+strconv.Itoa(i), a[i], b[i], patch)
+		}
+		for i := len(a) - 1; i >= common; i-- {
+			patch = append(patch, PatchOperation{Op: "remove", Path: path + "/" + strconv.Itoa(i)})
+		}
+		for i := common; i < len(b); i++ {
+			patch = append(patch, PatchOperation{Op: "add", Path: path + "/-", Value: b[i]})
+		}
+		return patch
+	}
+	if reflect.DeepEqual(a, b) {
+		return patch
+	}
+	return append(patch, PatchOperation{Op: "replace", Path: path, Value: b})
+}
+
+func escapePointer(token string) string {
+	return strings.Replace(strings.Replace(token, "~", "~0", -1), "/", "~1", -1)
+}
@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestDiffPatch(t *testing.T) {
+	var a, b interface{}
+	json.Unmarshal([]byte(`{"hero":{"name":"R2-D2","friends":[{"name":"Luke"},{"name":"Han"}],"a/b":1}}`), &a)
+	json.Unmarshal([]byte(`{"hero":{"name":"C-3PO","friends":[{"name":"Luke"}],"appearsIn":null}}`), &b)
+
+	patch, _ := json.Marshal(diffPatch("", a, b, []PatchOperation{}))
+	expected := `[{"op":"remove","path":"/hero/a~1b"},{"op":"add","path":"/hero/appearsIn","value":null},` +
+		`{"op":"remove","path":"/hero/friends/1"},{"op":"replace","path":"/hero/name","value":"C-3PO"}]`
+	if string(patch) != expected {
+		t.Fatalf("unexpected patch %s", patch)
+	}
+}
+
+func TestHandler_ResultPatches(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema, ResultPatches: 10})
+	query := "/graphql?query=" + url.QueryEscape("{hero{name friends{name}}}")
+
+	req, _ := http.NewRequest("GET", query, nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	var first struct {
+		Extensions struct {
+			ResultHash string `json:"resultHash"`
+		} `json:"extensions"`
+	}
+	json.Unmarshal(resp.Body.Bytes(), &first)
+	if first.Extensions.ResultHash == "" {
+		t.Fatalf("expected a result hash, got %s", resp.Body.String())
+	}
+
+	extensions := `{"resultPatch":{"baseHash":"` + first.Extensions.ResultHash + `"}}`
+	req, _ = http.NewRequest("GET", query+"&extensions="+url.QueryEscape(extensions), nil)
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	var second map[string]interface{}
+	json.Unmarshal(resp.Body.Bytes(), &second)
+	expected := map[string]interface{}{
+		"data": nil,
+		"extensions": map[string]interface{}{
+			"resultHash": first.Extensions.ResultHash,
+			"resultPatch": map[string]interface{}{
+				"baseHash": first.Extensions.ResultHash,
+				"patch":    []interface{}{},
+			},
+		},
+	}
+	if !reflect.DeepEqual(second, expected) {
+		t.Fatalf("expected an empty patch, got %s", resp.Body.String())
+	}
+}
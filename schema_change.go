@@ -0,0 +1,186 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+const defaultSchemaWebhookTimeout = 10 * time.Second
+
+// SchemaChange summarizes a schema swapped with SetSchema, as posted to
+// Config.SchemaWebhookURLs.
+type SchemaChange struct {
+	Hash         string    `json:"hash"`
+	PreviousHash string    `json:"previousHash"`
+	Timestamp    time.Time `json:"timestamp"`
+	// Added, Removed and Changed list schema coordinates: "Type" for types,
+	// "Type.field" for fields and enum values, and "Type.field(arg:)" for
+	// arguments.
+	Added   []string `json:"added"`
+	Removed []string `json:"removed"`
+	Changed []string `json:"changed"`
+}
+
+// schema returns the schema operations are executed against.
+func (h *Handler) schema() *graphql.Schema {
+	h.schemaMu.RLock()
+	defer h.schemaMu.RUnlock()
+	return h.Schema
+}
+
+// SetSchema swaps the schema new operations are executed against, and posts
+// the SchemaChange to Config.SchemaWebhookURLs when it differs from the
+// previous one. The webhooks are called in the background and their
+// failures ignored.
+func (h *Handler) SetSchema(schema *graphql.Schema) SchemaChange {
+	if h.fieldAuth != nil {
+		h.fieldAuth.apply(schema)
+	}
+	if h.debugErrors != nil {
+		h.debugErrors.apply(schema)
+	}
+	if h.tracer != nil || h.traceResolvers || h.apolloTracingEnabled() {
+		instrumentSchema(schema)
+	}
+	h.schemaMu.Lock()
+	previous := h.Schema
+	h.Schema = schema
+	h.schemaMu.Unlock()
+
+	change := diffSchemas(previous, schema)
+	if change.Hash != change.PreviousHash {
+		h.emit(context.Background(), EventSchemaChange, SeverityInfo, "", map[string]interface{}{
+			"graphql.schema.hash":          change.Hash,
+			"graphql.schema.previous_hash": change.PreviousHash,
+			"graphql.schema.added":         len(change.Added),
+			"graphql.schema.removed":       len(change.Removed),
+			"graphql.schema.changed":       len(change.Changed),
+		})
+	}
+	if change.Hash != change.PreviousHash && len(h.schemaWebhookURLs) > 0 {
+		go h.notifySchemaChange(change)
+	}
+	return change
+}
+
+func (h *Handler) notifySchemaChange(change SchemaChange) {
+	ctx := context.Background()
+	defer h.recoverCallback(ctx, "schema webhook")
+	body, err := json.Marshal(change)
+	if err != nil {
+		h.log(ctx, LevelWarn, "schema change serialization failed", "error", err.Error())
+		return
+	}
+	client := h.schemaWebhookClient
+	if client == nil {
+		client = &http.Client{Timeout: defaultSchemaWebhookTimeout}
+	}
+	for _, url := range h.schemaWebhookURLs {
+		resp, err := client.Post(url, "application/json", bytes.NewReader(body))
+		if err != nil {
+			h.log(ctx, LevelWarn, "schema webhook failed", "url", url, "error", err.Error())
+			continue
+		}
+		resp.Body.Close()
+	}
+}
+
+func diffSchemas(previous, schema *graphql.Schema) SchemaChange {
+	before, after := schemaSignatures(previous), schemaSignatures(schema)
+	change := SchemaChange{
+		Hash:         hashSignatures(after),
+		PreviousHash: hashSignatures(before),
+		Timestamp:    time.Now(),
+		Added:        []string{},
+		Removed:      []string{},
+		Changed:      []string{},
+	}
+	for coordinate, signature := range after {
+		if previous, ok := before[coordinate]; !ok {
+			change.Added = append(change.Added, coordinate)
+		} else if previous != signature {
+			change.Changed = append(change.Changed, coordinate)
+		}
+	}
+	for coordinate := range before {
+		if _, ok := after[coordinate]; !ok {
+			change.Removed = append(change.Removed, coordinate)
+		}
+	}
+	sort.Strings(change.Added)
+	sort.Strings(change.Removed)
+	sort.Strings(change.Changed)
+	return change
+}
+
+// schemaSignatures maps the coordinates of the schema to their signatures,
+// skipping the introspection types.
+func schemaSignatures(schema *graphql.Schema) map[string]string {
+	signatures := make(map[string]string)
+	if schema == nil {
+		return signatures
+	}
+	for name, t := range schema.TypeMap() {
+		if strings.HasPrefix(name, "__") {
+			continue
+		}
+		switch t := t.(type) {
+		case *graphql.Object:
+			signatures[name] = "type"
+			for fieldName, field := range t.Fields() {
+				addFieldSignatures(signatures, name+"."+fieldName, field)
+			}
+		case *graphql.Interface:
+			signatures[name] = "interface"
+			for fieldName, field := range t.Fields() {
+				addFieldSignatures(signatures, name+"."+fieldName, field)
+			}
+		case *graphql.InputObject:
+			signatures[name] = "input"
+			for fieldName, field := range t.Fields() {
+				signatures[name+"."+fieldName] = field.Type.String()
+			}
+		case *graphql.Union:
+			var members []string
+			for _, member := range t.Types() {
+				members = append(members, member.Name())
+			}
+			sort.Strings(members)
+			signatures[name] = "union " + strings.Join(members, " | ")
+		case *graphql.Enum:
+			signatures[name] = "enum"
+			for _, value := range t.Values() {
+				signatures[name+"."+value.Name] = "value"
+			}
+		default:
+			signatures[name] = "scalar"
+		}
+	}
+	return signatures
+}
+
+func addFieldSignatures(signatures map[string]string, coordinate string, field *graphql.FieldDefinition) {
+	signatures[coordinate] = field.Type.String()
+	for _, arg := range field.Args {
+		signatures[coordinate+"("+arg.Name()+":)"] = arg.Type.String()
+	}
+}
+
+func hashSignatures(signatures map[string]string) string {
+	lines := make([]string, 0, len(signatures))
+	for coordinate, signature := range signatures {
+		lines = append(lines, coordinate+" "+signature)
+	}
+	sort.Strings(lines)
+	sum := sha256.Sum256([]byte(strings.Join(lines, "\n")))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestHandler_SetSchemaNotifiesWebhooks(t *testing.T) {
+	changes := make(chan SchemaChange, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var change SchemaChange
+		if err := json.NewDecoder(r.Body).Decode(&change); err != nil {
+			t.Error(err)
+		}
+		changes <- change
+	}))
+	defer server.Close()
+
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"name": &graphql.Field{Type: graphql.String},
+				"age":  &graphql.Field{Type: graphql.Int},
+			},
+		}),
+	})
+	updated, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"name": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.String),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return "updated", nil
+					},
+				},
+				"email": &graphql.Field{Type: graphql.String},
+			},
+		}),
+	})
+	h := New(&Config{Schema: &schema, SchemaWebhookURLs: []string{server.URL}})
+
+	if change := h.SetSchema(&schema); change.Hash != change.PreviousHash {
+		t.Errorf("expected the same schema to keep its hash, got %+v", change)
+	}
+
+	change := h.SetSchema(&updated)
+	if change.Hash == change.PreviousHash {
+		t.Fatalf("expected the hash to change")
+	}
+	if !reflect.DeepEqual(change.Added, []string{"Query.email"}) ||
+		!reflect.DeepEqual(change.Removed, []string{"Int", "Query.age"}) ||
+		!reflect.DeepEqual(change.Changed, []string{"Query.name"}) {
+		t.Errorf("unexpected change %+v", change)
+	}
+
+	select {
+	case posted := <-changes:
+		if posted.Hash != change.Hash || !reflect.DeepEqual(posted.Added, change.Added) {
+			t.Errorf("unexpected posted change %+v", posted)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the webhook to be called")
+	}
+	select {
+	case posted := <-changes:
+		t.Errorf("expected a single notification, got %+v", posted)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	req, _ := http.NewRequest("GET", "/graphql?query={name}", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if expected := `{"data":{"name":"updated"}}`; resp.Body.String() != expected {
+		t.Errorf("expected %s, got %s", expected, resp.Body.String())
+	}
+}
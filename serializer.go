@@ -0,0 +1,92 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// ResponseSerializer writes the body of the GraphQL responses, e.g. to wrap
+// them in an envelope or strip some of their fields.
+type ResponseSerializer interface {
+	// ContentType returns the media type of the responses, the negotiated
+	// JSON one when empty.
+	ContentType() string
+	Serialize(w io.Writer, result *graphql.Result) error
+}
+
+// JSONSerializer is the default ResponseSerializer.
+type JSONSerializer struct {
+	Pretty bool
+	// OmitNullData leaves the data entry out of the responses without data.
+	OmitNullData bool
+}
+
+// resultWithoutData is a result serialized without its data entry.
+type resultWithoutData struct {
+	Errors     []gqlerrors.FormattedError `json:"errors,omitempty"`
+	Extensions map[string]interface{}     `json:"extensions,omitempty"`
+}
+
+func (s JSONSerializer) ContentType() string {
+	return ""
+}
+
+func (s JSONSerializer) Serialize(w io.Writer, result *graphql.Result) error {
+	var response interface{} = result
+	if s.OmitNullData && result.Data == nil {
+		response = resultWithoutData{Errors: result.Errors, Extensions: result.Extensions}
+	}
+	var buff []byte
+	var err error
+	if s.Pretty {
+		buff, err = json.MarshalIndent(response, "", "\t")
+	} else {
+		buff, err = json.Marshal(response)
+	}
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(buff)
+	return err
+}
+
+// serialize returns the body of the response and its content type, using
+// Config.ResponseSerializer or else the given serializer, and falling back to
+// JSON when it fails. JSON bodies are transcoded to the response charset.
+func (h *Handler) serialize(r *http.Request, result *graphql.Result, serializer ResponseSerializer) ([]byte, string) {
+	if h.responseSerializer != nil {
+		serializer = h.responseSerializer
+	}
+	var buff bytes.Buffer
+	if err := serializer.Serialize(&buff, result); err != nil {
+		h.log(r.Context(), LevelWarn, "response serialization failed, falling back to JSON", "serializer", fmt.Sprintf("%T", serializer), "error", err.Error())
+		buff.Reset()
+		serializer = JSONSerializer{}
+		serializer.Serialize(&buff, result)
+	}
+	contentType := serializer.ContentType()
+	if contentType != "" {
+		return buff.Bytes(), contentType
+	}
+	body := buff.Bytes()
+	if h.transcodeFn != nil {
+		body = h.transcodeFn(body)
+	}
+	return body, h.responseContentType(r)
+}
+
+// prettyResponse reports whether the response is indented, as requested with
+// the "pretty" query parameter, e.g. ?pretty=1, or else by Config.Pretty.
+func (h *Handler) prettyResponse(r *http.Request) bool {
+	if pretty, err := strconv.ParseBool(r.URL.Query().Get("pretty")); err == nil {
+		return pretty
+	}
+	return h.pretty
+}
@@ -0,0 +1,71 @@
+package handler
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+type envelopeSerializer struct{}
+
+func (envelopeSerializer) ContentType() string {
+	return "application/vnd.example+json"
+}
+
+func (envelopeSerializer) Serialize(w io.Writer, result *graphql.Result) error {
+	return json.NewEncoder(w).Encode(map[string]interface{}{"ok": !result.HasErrors(), "payload": result.Data})
+}
+
+func TestHandler_ResponseSerializer(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema, ResponseSerializer: envelopeSerializer{}})
+
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	expected := `{"ok":true,"payload":{"hero":{"name":"R2-D2"}}}` + "\n"
+	if resp.Body.String() != expected {
+		t.Fatalf("expected the envelope, got %s", resp.Body.String())
+	}
+	if contentType := resp.Header().Get("Content-Type"); contentType != "application/vnd.example+json" {
+		t.Fatalf("unexpected Content-Type %q", contentType)
+	}
+}
+
+func TestJSONSerializer(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema, ResponseSerializer: JSONSerializer{}, Pretty: true})
+
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+
+	expected := `{"data":{"hero":{"name":"R2-D2"}}}`
+	if resp.Body.String() != expected || resp.Header().Get("Content-Type") != "application/json; charset=utf-8" {
+		t.Fatalf("expected compact JSON, got %s %v", resp.Body.String(), resp.Header())
+	}
+}
+
+func TestHandler_PrettyQueryParameter(t *testing.T) {
+	tests := []struct {
+		pretty   bool
+		target   string
+		expected string
+	}{
+		{false, "/graphql?query={hero{name}}&pretty=1", "{\n\t\"data\": {\n\t\t\"hero\": {\n\t\t\t\"name\": \"R2-D2\"\n\t\t}\n\t}\n}"},
+		{true, "/graphql?query={hero{name}}&pretty=0", `{"data":{"hero":{"name":"R2-D2"}}}`},
+		{true, "/graphql?query={hero{name}}&pretty=maybe", "{\n\t\"data\": {\n\t\t\"hero\": {\n\t\t\t\"name\": \"R2-D2\"\n\t\t}\n\t}\n}"},
+	}
+	for _, test := range tests {
+		req, _ := http.NewRequest("GET", test.target, nil)
+		resp := httptest.NewRecorder()
+		New(&Config{Schema: &testutil.StarWarsSchema, Pretty: test.pretty}).ServeHTTP(resp, req)
+		if resp.Body.String() != test.expected {
+			t.Fatalf("unexpected body for %s: %s", test.target, resp.Body.String())
+		}
+	}
+}
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// serverTiming reports the duration of the phases of a request in the
+// Server-Timing header.
+type serverTiming struct {
+	w       http.ResponseWriter
+	metrics []string
+}
+
+// newServerTiming returns nil when the header is disabled, all methods being
+// no-ops on nil.
+func (h *Handler) newServerTiming(w http.ResponseWriter) *serverTiming {
+	if !h.serverTiming {
+		return nil
+	}
+	return &serverTiming{w: w}
+}
+
+// add reports a phase which started at start and just ended. The header is
+// updated right away so that it is sent whichever way the request ends.
+func (t *serverTiming) add(name string, start time.Time) {
+	if t == nil {
+		return
+	}
+	duration := float64(time.Since(start)) / float64(time.Millisecond)
+	t.metrics = append(t.metrics, fmt.Sprintf("%s;dur=%.3f", name, duration))
+	t.w.Header().Set("Server-Timing", strings.Join(t.metrics, ", "))
+}
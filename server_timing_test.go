@@ -0,0 +1,27 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_ServerTiming(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+
+	resp := httptest.NewRecorder()
+	New(&Config{Schema: &testutil.StarWarsSchema, ServerTiming: true}).ServeHTTP(resp, req)
+	expected := regexp.MustCompile(`^parse;dur=[0-9.]+, persisted;dur=[0-9.]+, execute;dur=[0-9.]+$`)
+	if header := resp.Header().Get("Server-Timing"); !expected.MatchString(header) {
+		t.Fatalf("unexpected Server-Timing header %q", header)
+	}
+
+	resp = httptest.NewRecorder()
+	New(&Config{Schema: &testutil.StarWarsSchema}).ServeHTTP(resp, req)
+	if header := resp.Header().Get("Server-Timing"); header != "" {
+		t.Fatalf("expected no Server-Timing header, got %q", header)
+	}
+}
@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// ShadowConfig re-executes a sample of query operations against a second
+// schema or upstream, to validate a refactor under real traffic. Mutations
+// and subscriptions are never shadowed.
+type ShadowConfig struct {
+	// Schema the sampled operations are re-executed against.
+	Schema *graphql.Schema
+	// ExecuteFn, when set, replaces the execution against Schema, e.g. to
+	// forward the operation to an upstream service.
+	ExecuteFn func(ctx context.Context, params graphql.Params) *graphql.Result
+	// SampleRate is the fraction of operations, between 0 and 1, shadowed.
+	SampleRate float64
+	// ReportFn receives the comparison of every shadowed operation.
+	ReportFn func(ctx context.Context, report ShadowReport)
+	// MaxConcurrent bounds the shadow executions in progress, 10 by
+	// default: the sampled operations are not shadowed while it is reached.
+	MaxConcurrent int
+	// Timeout bounds each shadow execution, 10 seconds by default.
+	Timeout time.Duration
+}
+
+const (
+	defaultShadowMaxConcurrent = 10
+	defaultShadowTimeout       = 10 * time.Second
+)
+
+func newShadowSlots(s *ShadowConfig) chan struct{} {
+	if s == nil {
+		return nil
+	}
+	max := s.MaxConcurrent
+	if max <= 0 {
+		max = defaultShadowMaxConcurrent
+	}
+	return make(chan struct{}, max)
+}
+
+// ShadowReport compares the primary and shadow executions of an operation.
+type ShadowReport struct {
+	OperationName   string
+	Query           string
+	Variables       map[string]interface{}
+	PrimaryResponse []byte
+	ShadowResponse  []byte
+	PrimaryDuration time.Duration
+	ShadowDuration  time.Duration
+	// Diffs lists the paths of the response where the results differ.
+	Diffs []string
+}
+
+// Match reports whether both executions returned the same response.
+func (r ShadowReport) Match() bool {
+	return len(r.Diffs) == 0
+}
+
+// detachedContext keeps the values of a request context with the
+// cancellation of another, e.g. none for work outliving the request.
+type detachedContext struct {
+	context.Context
+	values context.Context
+}
+
+func detach(ctx context.Context) context.Context {
+	return detachedContext{Context: context.Background(), values: ctx}
+}
+
+func (c detachedContext) Value(key interface{}) interface{} {
+	return c.values.Value(key)
+}
+
+// shadow asynchronously re-executes a sampled query operation and reports
+// the differences with the primary result.
+func (h *Handler) shadow(ctx context.Context, op *operation, params graphql.Params, primary *graphql.Result, primaryDuration time.Duration) {
+	s := h.shadowConfig
+	if s == nil || s.ReportFn == nil || op == nil || op.Type() != ast.OperationTypeQuery {
+		return
+	}
+	if s.SampleRate <= 0 || rand.Float64() >= s.SampleRate {
+		return
+	}
+
+	// snapshot the primary response before it gets altered further
+	primaryJSON, err := json.Marshal(primary)
+	if err != nil {
+		h.log(ctx, LevelWarn, "shadow traffic skipped, the primary response does not serialize", "error", err.Error())
+		return
+	}
+
+	select {
+	case h.shadowSlots <- struct{}{}:
+	default:
+		h.log(ctx, LevelDebug, "shadow traffic skipped, too many shadow executions in progress", "operation_name", op.Name())
+		return
+	}
+	timeout := s.Timeout
+	if timeout <= 0 {
+		timeout = defaultShadowTimeout
+	}
+	ctx, cancel := context.WithTimeout(detach(ctx), timeout)
+	params.Context = ctx
+	go func() {
+		defer func() { <-h.shadowSlots }()
+		defer cancel()
+		defer h.recoverCallback(ctx, "shadow traffic")
+		start := time.Now()
+		var shadow *graphql.Result
+		if s.ExecuteFn != nil {
+			shadow = s.ExecuteFn(ctx, params)
+		} else if s.Schema != nil {
+			params.Schema = *s.Schema
+			shadow = graphql.Do(params)
+		} else {
+			return
+		}
+		shadowDuration := time.Since(start)
+
+		shadowJSON, _ := json.Marshal(shadow)
+		var primaryValue, shadowValue interface{}
+		json.Unmarshal(primaryJSON, &primaryValue)
+		json.Unmarshal(shadowJSON, &shadowValue)
+
+		s.ReportFn(ctx, ShadowReport{
+			OperationName:   op.Name(),
+			Query:           params.RequestString,
+			Variables:       params.VariableValues,
+			PrimaryResponse: primaryJSON,
+			ShadowResponse:  shadowJSON,
+			PrimaryDuration: primaryDuration,
+			ShadowDuration:  shadowDuration,
+			Diffs:           diffJSON("", primaryValue, shadowValue, nil),
+		})
+	}()
+}
+
+// diffJSON appends the paths where two decoded JSON values differ.
+func diffJSON(path string, a, b interface{}, diffs []string) []string {
+	switch a := a.(type) {
+	case map[string]interface{}:
+		b, ok := b.(map[string]interface{})
+		if !ok {
+			return append(diffs, pathOrRoot(path))
+		}
+		keys := make(map[string]bool, len(a)+len(b))
+		for k := range a {
+			keys[k] = true
+		}
+		for k := range b {
+			keys[k] = true
+		}
+		sorted := make([]string, 0, len(keys))
+		for k := range keys {
+			sorted = append(sorted, k)
+		}
+		sort.Strings(sorted)
+		for _, k := range sorted {
+			diffs = diffJSON(path+"."+k, a[k], b[k], diffs)
+		}
+		return diffs
+	case []interface{}:
+		b, ok := b.([]interface{})
+		if !ok || len(a) != len(b) {
+			return append(diffs, pathOrRoot(path))
+		}
+		for i := range a {
+			diffs = diffJSON(fmt.Sprintf("%s[%d]", path, i), a[i], b[i], diffs)
+		}
+		return diffs
+	default:
+		if !reflect.DeepEqual(a, b) {
+			return append(diffs, pathOrRoot(path))
+		}
+		return diffs
+	}
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "."
+	}
+	return path
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_Shadow_ReportsDiffs(t *testing.T) {
+	reports := make(chan ShadowReport, 1)
+	h := New(&Config{
+		Schema: &testutil.StarWarsSchema,
+		Shadow: &ShadowConfig{
+			SampleRate: 1,
+			ExecuteFn: func(ctx context.Context, params graphql.Params) *graphql.Result {
+				return &graphql.Result{Data: map[string]interface{}{
+					"hero": map[string]interface{}{"name": "Luke Skywalker"},
+				}}
+			},
+			ReportFn: func(ctx context.Context, report ShadowReport) {
+				reports <- report
+			},
+		},
+	})
+
+	req, _ := http.NewRequest("GET", "/graphql?query=query+Hero{hero{name}}", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	select {
+	case report := <-reports:
+		if report.OperationName != "Hero" {
+			t.Fatalf("unexpected operation name %q", report.OperationName)
+		}
+		if report.Match() || !reflect.DeepEqual(report.Diffs, []string{".data.hero.name"}) {
+			t.Fatalf("unexpected diffs %v", report.Diffs)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("shadow report was not emitted")
+	}
+}
+
+func TestHandler_Shadow_SkipsMutations(t *testing.T) {
+	called := make(chan struct{}, 1)
+	h := New(&Config{
+		Schema: newSubscriptionSchema(t),
+		Shadow: &ShadowConfig{
+			SampleRate: 1,
+			Schema:     newSubscriptionSchema(t),
+			ReportFn: func(ctx context.Context, report ShadowReport) {
+				called <- struct{}{}
+			},
+		},
+	})
+
+	req, _ := http.NewRequest("GET", "/graphql?query=subscription{counter}", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	select {
+	case <-called:
+		t.Fatalf("only queries should be shadowed")
+	case <-time.After(50 * time.Millisecond):
+	}
+}
+
+func TestHandler_Shadow_MaxConcurrentAndTimeout(t *testing.T) {
+	executions := make(chan error, 2)
+	reports := make(chan ShadowReport, 2)
+	h := New(&Config{
+		Schema: &testutil.StarWarsSchema,
+		Shadow: &ShadowConfig{
+			SampleRate:    1,
+			MaxConcurrent: 1,
+			Timeout:       50 * time.Millisecond,
+			ExecuteFn: func(ctx context.Context, params graphql.Params) *graphql.Result {
+				<-ctx.Done()
+				executions <- ctx.Err()
+				return &graphql.Result{}
+			},
+			ReportFn: func(ctx context.Context, report ShadowReport) {
+				reports <- report
+			},
+		},
+	})
+
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	select {
+	case err := <-executions:
+		if err != context.DeadlineExceeded {
+			t.Fatalf("expected the shadow execution to time out, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatalf("shadow execution did not time out")
+	}
+	<-reports
+	select {
+	case <-executions:
+		t.Fatalf("expected the second operation not to be shadowed")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	req, _ := http.NewRequest("GET", "/graphql?query={hero{name}}", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	select {
+	case <-executions:
+	case <-time.After(time.Second):
+		t.Fatalf("expected the slot to be released")
+	}
+}
@@ -0,0 +1,140 @@
+package handler
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SLO declares the service level objective of an operation: the fraction of
+// its executions that must succeed within a latency.
+type SLO struct {
+	// Latency bounds the duration of good executions, any duration being
+	// good when zero.
+	Latency time.Duration
+	// Objective is the fraction of good executions targeted, e.g. 0.999.
+	Objective float64
+	// Window is the sliding window the burn rate is measured over, one hour
+	// by default.
+	Window time.Duration
+	// BurnRateThreshold is the burn rate from which the operation burns its
+	// error budget too fast, 1 by default, i.e. exhausting it by the end of
+	// the window.
+	BurnRateThreshold float64
+}
+
+// SLOBurn reports an operation whose burn rate reached its threshold.
+type SLOBurn struct {
+	OperationName string
+	SLO           SLO
+	Executions    int
+	Bad           int
+	// BurnRate is the rate of bad executions over the error budget.
+	BurnRate float64
+}
+
+// SLOBurnFn is called when the burn rate of an operation reaches its
+// threshold, and again only once it went back under it.
+type SLOBurnFn func(ctx context.Context, burn SLOBurn)
+
+const (
+	defaultSLOWindow = time.Hour
+	sloBuckets       = 10
+)
+
+// sloTracker measures the executions of the operations with an SLO over
+// their windows, in buckets of a tenth of the window.
+type sloTracker struct {
+	slos   map[string]SLO
+	mu     sync.Mutex
+	states map[string]*sloState
+}
+
+type sloState struct {
+	buckets [sloBuckets]sloBucket
+	burning bool
+}
+
+type sloBucket struct {
+	epoch      int64
+	executions int
+	bad        int
+}
+
+func newSLOTracker(slos map[string]SLO) *sloTracker {
+	if len(slos) == 0 {
+		return nil
+	}
+	t := &sloTracker{slos: make(map[string]SLO, len(slos)), states: make(map[string]*sloState)}
+	for name, slo := range slos {
+		if slo.Window <= 0 {
+			slo.Window = defaultSLOWindow
+		}
+		if slo.BurnRateThreshold <= 0 {
+			slo.BurnRateThreshold = 1
+		}
+		t.slos[name] = slo
+	}
+	return t
+}
+
+// record counts an execution of the operation, and returns the burn to report
+// when its burn rate just reached the threshold.
+func (t *sloTracker) record(name string, duration time.Duration, failed bool, now time.Time) (SLOBurn, bool) {
+	slo, ok := t.slos[name]
+	if !ok {
+		return SLOBurn{}, false
+	}
+	bad := failed || (slo.Latency > 0 && duration > slo.Latency)
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	s, ok := t.states[name]
+	if !ok {
+		s = &sloState{}
+		t.states[name] = s
+	}
+
+	epoch := now.UnixNano() / int64(slo.Window/sloBuckets)
+	bucket := &s.buckets[epoch%sloBuckets]
+	if bucket.epoch != epoch {
+		*bucket = sloBucket{epoch: epoch}
+	}
+	bucket.executions++
+	if bad {
+		bucket.bad++
+	}
+
+	burn := SLOBurn{OperationName: name, SLO: slo}
+	for _, bucket := range s.buckets {
+		if epoch-bucket.epoch < sloBuckets {
+			burn.Executions += bucket.executions
+			burn.Bad += bucket.bad
+		}
+	}
+	budget := 1 - slo.Objective
+	if budget <= 0 {
+		budget = 1
+	}
+	burn.BurnRate = float64(burn.Bad) / float64(burn.Executions) / budget
+
+	if burn.BurnRate < slo.BurnRateThreshold {
+		s.burning = false
+		return SLOBurn{}, false
+	}
+	if s.burning {
+		return SLOBurn{}, false
+	}
+	s.burning = true
+	return burn, true
+}
+
+// recordSLO measures an execution against the SLO of its operation.
+func (h *Handler) recordSLO(ctx context.Context, op *operation, duration time.Duration, failed bool) {
+	if h.slos == nil || op == nil || op.Name() == "" {
+		return
+	}
+	if burn, ok := h.slos.record(op.Name(), duration, failed, time.Now()); ok && h.sloBurnFn != nil {
+		h.sloBurnFn(ctx, burn)
+	}
+}
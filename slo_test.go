@@ -0,0 +1,68 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestSLOTracker_BurnRate(t *testing.T) {
+	tracker := newSLOTracker(map[string]SLO{
+		"Hero": {Latency: 100 * time.Millisecond, Objective: 0.9, Window: time.Minute, BurnRateThreshold: 2},
+	})
+	now := time.Unix(0, 0)
+
+	for i := 0; i < 8; i++ {
+		if _, burning := tracker.record("Hero", time.Millisecond, false, now); burning {
+			t.Fatal("expected good executions not to burn")
+		}
+	}
+	if _, burning := tracker.record("Hero", time.Second, false, now); burning {
+		t.Fatal("expected a burn rate under the threshold")
+	}
+	burn, burning := tracker.record("Hero", time.Millisecond, true, now)
+	if !burning || burn.Executions != 10 || burn.Bad != 2 || burn.BurnRate < 1.99 {
+		t.Fatalf("expected the burn to be reported, got %+v", burn)
+	}
+	if _, burning := tracker.record("Hero", time.Millisecond, true, now); burning {
+		t.Fatal("expected the burn to be reported once")
+	}
+
+	// the bad executions leave the window
+	for i := 0; i < 10; i++ {
+		tracker.record("Hero", time.Millisecond, false, now.Add(2*time.Minute))
+	}
+	if _, burning := tracker.record("Hero", time.Millisecond, true, now.Add(2*time.Minute)); burning {
+		t.Fatal("expected the old executions to be forgotten")
+	}
+}
+
+func TestHandler_SLOBurnFn(t *testing.T) {
+	var burns []SLOBurn
+	h := New(&Config{
+		Schema: &testutil.StarWarsSchema,
+		SLOs:   map[string]SLO{"Hero": {Objective: 0.99}},
+		SLOBurnFn: func(ctx context.Context, burn SLOBurn) {
+			burns = append(burns, burn)
+		},
+	})
+	query := func(query string) {
+		req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape(query), nil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	query("query Hero { hero { name } }")
+	query("query Other { hero { unknown } }")
+	if len(burns) != 0 {
+		t.Fatalf("expected no burn, got %v", burns)
+	}
+	query("query Hero { hero { unknown } }")
+	if len(burns) != 1 || burns[0].OperationName != "Hero" || burns[0].Bad != 1 {
+		t.Fatalf("expected the Hero burn, got %+v", burns)
+	}
+}
@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MetricsEmitter receives the counters and timings of the handler, tagged
+// with the operation:
+//
+//	graphql.requests, graphql.request.duration{operation_type, operation_name, status, client_name, client_version}
+//	graphql.errors{code}
+//	graphql.resolver.duration{field}, with Config.TraceResolvers
+type MetricsEmitter interface {
+	Count(name string, value int64, tags map[string]string)
+	Timing(name string, value time.Duration, tags map[string]string)
+}
+
+// StatsD is a MetricsEmitter sending the metrics over UDP to a StatsD
+// server or, with DogStatsD tags, to a Datadog agent. Plain StatsD does not
+// support tags, so their values are appended to the metric names, sorted by
+// tag name.
+type StatsD struct {
+	prefix    string
+	dogStatsD bool
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// NewStatsD returns a StatsD sending the metrics to the address, e.g.
+// "127.0.0.1:8125", with their names prefixed by the prefix when not empty.
+func NewStatsD(address, prefix string, dogStatsD bool) (*StatsD, error) {
+	conn, err := net.Dial("udp", address)
+	if err != nil {
+		return nil, err
+	}
+	if prefix != "" && !strings.HasSuffix(prefix, ".") {
+		prefix += "."
+	}
+	return &StatsD{prefix: prefix, dogStatsD: dogStatsD, conn: conn}, nil
+}
+
+// Count sends a counter.
+func (s *StatsD) Count(name string, value int64, tags map[string]string) {
+	s.send(name, strconv.FormatInt(value, 10), "c", tags)
+}
+
+// Timing sends a timing, in milliseconds.
+func (s *StatsD) Timing(name string, value time.Duration, tags map[string]string) {
+	s.send(name, strconv.FormatFloat(float64(value)/float64(time.Millisecond), 'f', -1, 64), "ms", tags)
+}
+
+// Close closes the connection to the server.
+func (s *StatsD) Close() error {
+	return s.conn.Close()
+}
+
+func (s *StatsD) send(name, value, metricType string, tags map[string]string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	// the metrics are lost rather than slowing the requests down
+	s.conn.Write([]byte(s.format(name, value, metricType, tags)))
+}
+
+func (s *StatsD) format(name, value, metricType string, tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for key := range tags {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var b strings.Builder
+	b.WriteString(s.prefix)
+	b.WriteString(name)
+	if !s.dogStatsD {
+		for _, key := range keys {
+			if tags[key] != "" {
+				b.WriteByte('.')
+				b.WriteString(sanitizeStatsD(tags[key]))
+			}
+		}
+	}
+	b.WriteByte(':')
+	b.WriteString(value)
+	b.WriteByte('|')
+	b.WriteString(metricType)
+	if s.dogStatsD && len(keys) > 0 {
+		b.WriteString("|#")
+		for i, key := range keys {
+			if i > 0 {
+				b.WriteByte(',')
+			}
+			b.WriteString(sanitizeStatsD(key))
+			b.WriteByte(':')
+			b.WriteString(sanitizeStatsD(tags[key]))
+		}
+	}
+	return b.String()
+}
+
+// sanitizeStatsD replaces the characters with a meaning in the StatsD
+// protocol.
+func sanitizeStatsD(value string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ':', '|', ',', '#', '@', '.', ' ', '\n':
+			return '_'
+		}
+		return r
+	}, value)
+}
+
+func (h *Handler) emitRequestMetrics(op *operation, client ClientInfo, status int, duration time.Duration) {
+	if h.metricsEmitter == nil {
+		return
+	}
+	tags := map[string]string{"status": strconv.Itoa(status)}
+	if op != nil {
+		tags["operation_type"] = op.Type()
+		tags["operation_name"] = op.Name()
+	}
+	if client.Name != "" {
+		tags["client_name"] = client.Name
+		tags["client_version"] = client.Version
+	}
+	h.metricsEmitter.Count("graphql.requests", 1, tags)
+	h.metricsEmitter.Timing("graphql.request.duration", duration, tags)
+}
@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestStatsD_format(t *testing.T) {
+	tags := map[string]string{"operation_type": "query", "operation_name": "Names", "status": "200"}
+	statsd := &StatsD{prefix: "api."}
+	if got := statsd.format("graphql.requests", "1", "c", tags); got != "api.graphql.requests.Names.query.200:1|c" {
+		t.Errorf("unexpected StatsD metric %q", got)
+	}
+	dogStatsD := &StatsD{dogStatsD: true}
+	if got := dogStatsD.format("graphql.errors", "1", "c", map[string]string{"code": "a|b"}); got != "graphql.errors:1|c|#code:a_b" {
+		t.Errorf("unexpected DogStatsD metric %q", got)
+	}
+}
+
+func TestHandler_MetricsEmitter(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	statsd, err := NewStatsD(conn.LocalAddr().String(), "api", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer statsd.Close()
+
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   "Query",
+			Fields: graphql.Fields{"name": &graphql.Field{Type: graphql.String}},
+		}),
+	})
+	h := New(&Config{Schema: &schema, MetricsEmitter: statsd})
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"query Names { name }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	var metrics []string
+	buf := make([]byte, 1024)
+	for len(metrics) < 2 {
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		metrics = append(metrics, string(buf[:n]))
+	}
+	if metrics[0] != "api.graphql.requests:1|c|#operation_name:Names,operation_type:query,status:200" {
+		t.Errorf("unexpected counter %q", metrics[0])
+	}
+	if !strings.HasPrefix(metrics[1], "api.graphql.request.duration:") || !strings.HasSuffix(metrics[1], "|ms|#operation_name:Names,operation_type:query,status:200") {
+		t.Errorf("unexpected timing %q", metrics[1])
+	}
+}
@@ -0,0 +1,156 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// ContentTypeGraphQLResponse is the media type of the GraphQL over HTTP
+// specification. Responses to clients accepting it use the status codes of
+// the specification.
+const ContentTypeGraphQLResponse = "application/graphql-response+json"
+
+// strictStatusCodes reports whether the response status reflects request
+// errors instead of being always 200.
+func (h *Handler) strictStatusCodes(r *http.Request) bool {
+	return h.statusCodes || acceptsGraphQLResponse(r)
+}
+
+func acceptsGraphQLResponse(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Accept"), ContentTypeGraphQLResponse)
+}
+
+// responseContentType is the media type of the JSON responses.
+func (h *Handler) responseContentType(r *http.Request) string {
+	if acceptsGraphQLResponse(r) {
+		return ContentTypeGraphQLResponse + "; charset=" + h.responseCharset
+	}
+	return "application/json; charset=" + h.responseCharset
+}
+
+// allowedMethods is the Allow header of 405 responses.
+const allowedMethods = "GET, HEAD, POST, OPTIONS"
+
+func allowedMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// supportedContentType reports whether the body of a POST request can be
+// read. Requests without a Content-Type are read as JSON unless strict.
+func supportedContentType(r *http.Request, strict bool) bool {
+	if r.Method != http.MethodPost {
+		return true
+	}
+	switch requestContentType(r) {
+	case ContentTypeJSON, ContentTypeGraphQL, ContentTypeFormURLEncoded:
+		return true
+	case "":
+		return !strict
+	}
+	return false
+}
+
+func requestContentType(r *http.Request) string {
+	return requestMediaType(r).Type
+}
+
+// unsupportedContentTypeMessage describes a 415 response.
+func unsupportedContentTypeMessage(r *http.Request) string {
+	supported := ContentTypeJSON + ", " + ContentTypeGraphQL + " or " + ContentTypeFormURLEncoded
+	if contentType := requestContentType(r); contentType != "" {
+		return "Unsupported Content-Type \"" + contentType + "\", expected " + supported
+	}
+	return "Missing Content-Type, expected " + supported
+}
+
+// requestStatus rejects the operations that cannot be executed for the
+// request method, only queries being executable with GET and HEAD. It
+// returns 0 when the request can be executed.
+func requestStatus(r *http.Request, op *operation) int {
+	if op != nil && (r.Method == http.MethodGet || r.Method == http.MethodHead) && op.Type() != ast.OperationTypeQuery {
+		return http.StatusMethodNotAllowed
+	}
+	return 0
+}
+
+// resultStatus is 200 for executed operations, even when errors nulled their
+// data, and 400 when the document failed to parse or validate, or the
+// variables failed to coerce. Those errors are raised before execution, so
+// unlike field errors they have no path.
+func resultStatus(result *graphql.Result) int {
+	if isRequestError(result) {
+		return http.StatusBadRequest
+	}
+	return http.StatusOK
+}
+
+// isRequestError reports whether the result holds the errors preventing the
+// execution of the operation.
+func isRequestError(result *graphql.Result) bool {
+	if result.Data != nil || len(result.Errors) == 0 {
+		return false
+	}
+	for _, err := range result.Errors {
+		if len(err.Path) > 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// omitRequestErrorData reports whether the responses to request errors omit
+// the data entry, as the spec requires.
+func (h *Handler) omitRequestErrorData(r *http.Request) bool {
+	return h.requestErrorsWithoutData || h.strictStatusCodes(r)
+}
+
+// requestError rejects a request before its execution.
+type requestError struct {
+	status  int
+	code    ErrorCode
+	message string
+	// retryable marks the errors the same request may succeed after, in the
+	// "retryable" extension.
+	retryable bool
+}
+
+func newRequestError(status int, code ErrorCode, message string) *requestError {
+	return &requestError{status: status, code: code, message: message}
+}
+
+func (e *requestError) Error() string {
+	return e.message
+}
+
+// formatted returns the error with its code in its extensions.
+func (e *requestError) formatted() gqlerrors.FormattedError {
+	formatted := gqlerrors.NewFormattedError(e.message)
+	formatted.Extensions = map[string]interface{}{"code": e.code}
+	if e.retryable {
+		formatted.Extensions["retryable"] = true
+	}
+	return formatted
+}
+
+// writeRequestError writes a GraphQL response holding a single error.
+func (h *Handler) writeRequestError(w http.ResponseWriter, r *http.Request, err *requestError) {
+	h.emitRejection(r.Context(), err)
+	if ow, ok := w.(*observedWriter); ok {
+		ow.rejected = true
+	}
+	result := &graphql.Result{
+		Errors: []gqlerrors.FormattedError{err.formatted()},
+	}
+	addRequestID(r.Context(), result.Errors)
+	buff, contentType := h.serialize(r, result, JSONSerializer{OmitNullData: h.omitRequestErrorData(r)})
+	w.Header().Set("Content-Type", contentType)
+	h.writeBody(w, r, err.status, buff)
+}
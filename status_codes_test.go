@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_StatusCodes(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema, StatusCodes: true})
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"executed", "GET", "/graphql?query=" + url.QueryEscape("{hero{name}}"), "", "", http.StatusOK},
+		{"parse error", "GET", "/graphql?query=" + url.QueryEscape("{hero{"), "", "", http.StatusBadRequest},
+		{"validation error", "GET", "/graphql?query=" + url.QueryEscape("{unknown}"), "", "", http.StatusBadRequest},
+		{"variables error", "POST", "/graphql", "application/json", `{"query":"query($id: String!) { human(id: $id) { name } }"}`, http.StatusBadRequest},
+		{"mutation with GET", "GET", "/graphql?query=" + url.QueryEscape("mutation { hero { name } }"), "", "", http.StatusMethodNotAllowed},
+		{"unsupported body", "POST", "/graphql", "text/plain", "{hero{name}}", http.StatusUnsupportedMediaType},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			req, _ := http.NewRequest(test.method, test.target, strings.NewReader(test.body))
+			if test.contentType != "" {
+				req.Header.Set("Content-Type", test.contentType)
+			}
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+			if resp.Code != test.status {
+				t.Fatalf("expected status %d, got %d: %s", test.status, resp.Code, resp.Body.String())
+			}
+		})
+	}
+}
+
+func TestHandler_StatusCodes_NullDataExecuted(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"boom": &graphql.Field{
+					Type: graphql.NewNonNull(graphql.String),
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return nil, errors.New("boom")
+					},
+				},
+			},
+		}),
+	})
+	req, _ := http.NewRequest("GET", "/graphql?query={boom}", nil)
+	resp := httptest.NewRecorder()
+	New(&Config{Schema: &schema, StatusCodes: true}).ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), `"data":null`) {
+		t.Fatalf("expected 200 for an executed operation, got %d %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestHandler_StatusCodes_GraphQLResponseMediaType(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema})
+
+	req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape("{unknown}"), nil)
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected legacy clients to get 200, got %d", resp.Code)
+	}
+
+	req.Header.Set("Accept", ContentTypeGraphQLResponse)
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %d", resp.Code)
+	}
+	if contentType := resp.Header().Get("Content-Type"); !strings.HasPrefix(contentType, ContentTypeGraphQLResponse) {
+		t.Fatalf("unexpected Content-Type %q", contentType)
+	}
+}
+
+func TestHandler_MethodNotAllowed(t *testing.T) {
+	req, _ := http.NewRequest("PUT", "/graphql?query="+url.QueryEscape("{hero{name}}"), nil)
+
+	resp := httptest.NewRecorder()
+	New(&Config{Schema: &testutil.StarWarsSchema}).ServeHTTP(resp, req)
+	if resp.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("expected 405, got %d", resp.Code)
+	}
+	if allow := resp.Header().Get("Allow"); allow != "GET, HEAD, POST, OPTIONS" {
+		t.Fatalf("unexpected Allow header %q", allow)
+	}
+
+	resp = httptest.NewRecorder()
+	New(&Config{Schema: &testutil.StarWarsSchema, AllowAnyMethod: true}).ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK || !strings.Contains(resp.Body.String(), "R2-D2") {
+		t.Fatalf("expected the legacy behavior, got %d %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestHandler_StrictContentType(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema, StrictContentType: true})
+
+	for _, contentType := range []string{"", "text/plain"} {
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{hero{name}}"}`))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		resp := httptest.NewRecorder()
+		h.ServeHTTP(resp, req)
+		if resp.Code != http.StatusUnsupportedMediaType || !strings.Contains(resp.Body.String(), "Content-Type") {
+			t.Fatalf("expected 415 for %q, got %d %s", contentType, resp.Code, resp.Body.String())
+		}
+	}
+
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{hero{name}}"}`))
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", resp.Code)
+	}
+}
+
+func TestHandler_StrictContentTypeBeforePersistedQuery(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema, StrictContentType: true})
+
+	body := `{"query":"` + heroNameQuery + `","extensions":{"persistedQuery":{"version":1,"sha256Hash":"` + heroNameHash + `"}}}`
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(body))
+	req.Header.Set("Content-Type", "text/plain")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if resp.Code != http.StatusUnsupportedMediaType {
+		t.Fatalf("expected 415, got %d", resp.Code)
+	}
+
+	if resp := persistedQueryRequest(h, heroNameHash, ""); strings.Contains(resp.Body.String(), "R2-D2") {
+		t.Fatal("expected the rejected request not to register its persisted query")
+	}
+}
+
+func TestHandler_GetMutations(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape("mutation { hero { name } }"), nil)
+
+	resp := httptest.NewRecorder()
+	New(&Config{Schema: &testutil.StarWarsSchema}).ServeHTTP(resp, req)
+	if resp.Code != http.StatusMethodNotAllowed || resp.Header().Get("Allow") != "POST" {
+		t.Fatalf("expected 405, got %d", resp.Code)
+	}
+
+	resp = httptest.NewRecorder()
+	New(&Config{Schema: &testutil.StarWarsSchema, GetMutationsGraphQLError: true}).ServeHTTP(resp, req)
+	expected := `{"data":null,"errors":[{"message":"mutation operations can only be sent with POST","locations":[],"extensions":{"code":"METHOD_NOT_ALLOWED"}}]}`
+	if resp.Code != http.StatusOK || resp.Body.String() != expected {
+		t.Fatalf("expected a GraphQL error, got %d %s", resp.Code, resp.Body.String())
+	}
+}
+
+func TestHandler_Head(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema})
+	target := "/graphql?query=" + url.QueryEscape("{hero{name}}")
+
+	get, _ := http.NewRequest("GET", target, nil)
+	getResp := httptest.NewRecorder()
+	h.ServeHTTP(getResp, get)
+
+	head, _ := http.NewRequest("HEAD", target, nil)
+	headResp := httptest.NewRecorder()
+	h.ServeHTTP(headResp, head)
+
+	if headResp.Code != http.StatusOK || headResp.Body.Len() != 0 {
+		t.Fatalf("expected 200 without body, got %d %s", headResp.Code, headResp.Body.String())
+	}
+	for _, name := range []string{"Content-Type", "Content-Length"} {
+		if headResp.Header().Get(name) != getResp.Header().Get(name) {
+			t.Fatalf("expected the %s of GET, got %q", name, headResp.Header().Get(name))
+		}
+	}
+}
+
+func TestHandler_RequestErrorsWithoutData(t *testing.T) {
+	req, _ := http.NewRequest("GET", "/graphql?query="+url.QueryEscape("{unknown}"), nil)
+
+	resp := httptest.NewRecorder()
+	New(&Config{Schema: &testutil.StarWarsSchema}).ServeHTTP(resp, req)
+	if !strings.HasPrefix(resp.Body.String(), `{"data":null,`) {
+		t.Fatalf("expected the legacy null data, got %s", resp.Body.String())
+	}
+
+	for _, config := range []*Config{
+		{Schema: &testutil.StarWarsSchema, RequestErrorsWithoutData: true},
+		{Schema: &testutil.StarWarsSchema, StatusCodes: true},
+	} {
+		resp := httptest.NewRecorder()
+		New(config).ServeHTTP(resp, req)
+		if !strings.HasPrefix(resp.Body.String(), `{"errors":[`) || strings.Contains(resp.Body.String(), `"data"`) {
+			t.Fatalf("expected only errors, got %s", resp.Body.String())
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package handler
+
+import (
+	"html/template"
+	"net/http"
+	"strings"
+
+	"github.com/graphql-go/graphql"
+)
+
+// StatusPageData is the data of the status page template.
+type StatusPageData struct {
+	// Endpoint is the path of the GraphQL endpoint.
+	Endpoint string
+	// Errors are the errors of the request, if it held an operation.
+	Errors []string
+}
+
+// defaultStatusPageTemplate describes the endpoint to the humans browsing
+// it.
+var defaultStatusPageTemplate = template.Must(template.New("StatusPage").Parse(`<!DOCTYPE html>
+<html>
+<head>
+  <meta charset="utf-8">
+  <title>GraphQL endpoint</title>
+  <style>
+    body { font-family: sans-serif; max-width: 40em; margin: 4em auto; color: #333; }
+    code { background: #eee; padding: 0.1em 0.3em; }
+  </style>
+</head>
+<body>
+  <h1>GraphQL endpoint</h1>
+  <p>
+    This URL serves a GraphQL API. Send queries with <code>POST {{.Endpoint}}</code>
+    and a JSON body like <code>{"query": "{ __typename }"}</code>, or with
+    <code>GET {{.Endpoint}}?query=...</code>.
+  </p>
+  {{if .Errors}}<ul>{{range .Errors}}<li>{{.}}</li>{{end}}</ul>{{end}}
+</body>
+</html>
+`))
+
+// prefersHTML reports whether the request comes from a browser rather than
+// a GraphQL client.
+func prefersHTML(r *http.Request) bool {
+	acceptHeader := r.Header.Get("Accept")
+	_, raw := r.URL.Query()["raw"]
+	return !raw && !strings.Contains(acceptHeader, "application/json") && strings.Contains(acceptHeader, "text/html")
+}
+
+// renderStatusPage renders the status page of the endpoint.
+func (h *Handler) renderStatusPage(w http.ResponseWriter, r *http.Request, params graphql.Params, result *graphql.Result) {
+	t := h.statusPageTemplate
+	if t == nil {
+		t = defaultStatusPageTemplate
+	}
+	data := StatusPageData{Endpoint: r.URL.Path}
+	if params.RequestString != "" {
+		for _, err := range result.Errors {
+			data.Errors = append(data.Errors, err.Message)
+		}
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if err := t.Execute(w, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+	}
+}
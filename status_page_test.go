@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_StatusPage(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema, StatusPage: true})
+
+	req, _ := http.NewRequest("GET", "/graphql", nil)
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+	resp := httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if !strings.HasPrefix(resp.Header().Get("Content-Type"), "text/html") || !strings.Contains(resp.Body.String(), "POST /graphql") || strings.Contains(resp.Body.String(), "<li>") {
+		t.Fatalf("expected the status page, got %s", resp.Body.String())
+	}
+
+	req.Header.Set("Accept", "application/json")
+	resp = httptest.NewRecorder()
+	h.ServeHTTP(resp, req)
+	if !strings.HasPrefix(resp.Header().Get("Content-Type"), "application/json") {
+		t.Fatalf("expected a JSON response, got %s", resp.Body.String())
+	}
+}
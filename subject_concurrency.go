@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"context"
+	"sync"
+)
+
+// SubjectIDFn extracts the subject, typically the user, on whose behalf a
+// request runs from its context.
+type SubjectIDFn func(ctx context.Context) string
+
+// subjectLimiter bounds the number of concurrent mutations per subject.
+type subjectLimiter struct {
+	max   int
+	queue bool
+
+	mu       sync.Mutex
+	subjects map[string]*subjectSlots
+}
+
+type subjectSlots struct {
+	slots chan struct{}
+	users int
+}
+
+func newSubjectLimiter(max int, queue bool) *subjectLimiter {
+	if max <= 0 {
+		return nil
+	}
+	return &subjectLimiter{max: max, queue: queue, subjects: make(map[string]*subjectSlots)}
+}
+
+// acquire reserves a slot for the subject, waiting for one when queueing
+// until the context is done. It returns false when no slot was reserved.
+func (l *subjectLimiter) acquire(ctx context.Context, subject string) bool {
+	l.mu.Lock()
+	s, ok := l.subjects[subject]
+	if !ok {
+		s = &subjectSlots{slots: make(chan struct{}, l.max)}
+		l.subjects[subject] = s
+	}
+	s.users++
+	l.mu.Unlock()
+
+	select {
+	case s.slots <- struct{}{}:
+		return true
+	default:
+	}
+	if l.queue {
+		select {
+		case s.slots <- struct{}{}:
+			return true
+		case <-ctx.Done():
+		}
+	}
+	l.leave(subject, s)
+	return false
+}
+
+func (l *subjectLimiter) release(subject string) {
+	l.mu.Lock()
+	s := l.subjects[subject]
+	l.mu.Unlock()
+	<-s.slots
+	l.leave(subject, s)
+}
+
+func (l *subjectLimiter) leave(subject string, s *subjectSlots) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if s.users--; s.users == 0 {
+		delete(l.subjects, subject)
+	}
+}
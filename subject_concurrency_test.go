@@ -0,0 +1,112 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+type subjectKey struct{}
+
+func newRedeemSchema(t *testing.T, entered chan<- string, release <-chan struct{}) *graphql.Schema {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   "Query",
+			Fields: graphql.Fields{"ok": &graphql.Field{Type: graphql.Boolean}},
+		}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Mutation",
+			Fields: graphql.Fields{
+				"redeem": &graphql.Field{
+					Type: graphql.Boolean,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						entered <- p.Context.Value(subjectKey{}).(string)
+						<-release
+						return true, nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &schema
+}
+
+func redeem(h *Handler, subject string) *httptest.ResponseRecorder {
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"mutation { redeem }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	resp := httptest.NewRecorder()
+	h.ContextHandler(context.WithValue(context.Background(), subjectKey{}, subject), resp, req)
+	return resp
+}
+
+func TestHandler_MaxConcurrentMutationsPerSubject(t *testing.T) {
+	entered := make(chan string, 2)
+	release := make(chan struct{})
+	h := New(&Config{
+		Schema: newRedeemSchema(t, entered, release),
+		SubjectIDFn: func(ctx context.Context) string {
+			return ctx.Value(subjectKey{}).(string)
+		},
+		MaxConcurrentMutationsPerSubject: 1,
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- redeem(h, "alice") }()
+	<-entered
+
+	if resp := redeem(h, "alice"); resp.Code != http.StatusTooManyRequests {
+		t.Fatalf("expected the concurrent mutation to be rejected, got %d", resp.Code)
+	}
+
+	go func() { done <- redeem(h, "bob") }()
+	if subject := <-entered; subject != "bob" {
+		t.Fatalf("expected another subject to run, got %s", subject)
+	}
+	close(release)
+	for i := 0; i < 2; i++ {
+		if resp := <-done; resp.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.Code)
+		}
+	}
+}
+
+func TestHandler_QueueSubjectMutations(t *testing.T) {
+	entered := make(chan string, 2)
+	release := make(chan struct{})
+	h := New(&Config{
+		Schema: newRedeemSchema(t, entered, release),
+		SubjectIDFn: func(ctx context.Context) string {
+			return ctx.Value(subjectKey{}).(string)
+		},
+		MaxConcurrentMutationsPerSubject: 1,
+		QueueSubjectMutations:            true,
+	})
+
+	done := make(chan *httptest.ResponseRecorder)
+	go func() { done <- redeem(h, "alice") }()
+	<-entered
+	go func() { done <- redeem(h, "alice") }()
+
+	select {
+	case <-entered:
+		t.Fatal("expected the second mutation to wait")
+	case <-done:
+		t.Fatal("expected the second mutation to wait")
+	default:
+	}
+	release <- struct{}{}
+	<-entered
+	close(release)
+	for i := 0; i < 2; i++ {
+		if resp := <-done; resp.Code != http.StatusOK {
+			t.Fatalf("expected 200, got %d", resp.Code)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+)
+
+// TracerName is the instrumentation name the handler gets its Tracer with.
+const TracerName = "github.com/alanleite/go-graphql-handler"
+
+// SpanKind is the kind of a Span, numbered like the OpenTelemetry ones.
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+)
+
+// TracerProvider provides the Tracer of the handler, shaped like the
+// OpenTelemetry one so that an adapter of a trace.TracerProvider is a few
+// lines long.
+type TracerProvider interface {
+	Tracer(name string) Tracer
+}
+
+// Tracer starts spans, children of the span of the context.
+type Tracer interface {
+	Start(ctx context.Context, name string, kind SpanKind, attributes map[string]interface{}) (context.Context, Span)
+}
+
+// Span is a traced unit of work.
+type Span interface {
+	SetName(name string)
+	SetAttributes(attributes map[string]interface{})
+	// RecordError records the error and marks the span as failed.
+	RecordError(err error)
+	End()
+}
+
+// instrumentedKey holds the handler instrumenting a request, for the
+// tracing extension.
+type instrumentedKey struct{}
+
+// startRequestSpan starts the server span of a request, named after its
+// operation once parsed.
+func (h *Handler) startRequestSpan(ctx context.Context, r *http.Request) (context.Context, Span) {
+	ctx, span := h.tracer.Start(ctx, "GraphQL Operation", SpanKindServer, map[string]interface{}{
+		"http.request.method": r.Method,
+		"url.path":            r.URL.Path,
+	})
+	return ctx, span
+}
+
+func endRequestSpan(span Span, status int) {
+	span.SetAttributes(map[string]interface{}{"http.response.status_code": status})
+	if status >= http.StatusInternalServerError {
+		span.RecordError(errors.New(http.StatusText(status)))
+	}
+	span.End()
+}
+
+// spanName names the span of an operation like the OpenTelemetry GraphQL
+// semantic conventions: its type followed by its name when it has one.
+func spanName(op *operation) string {
+	if name := op.Name(); name != "" {
+		return op.Type() + " " + name
+	}
+	return op.Type()
+}
+
+func operationAttributes(op *operation) map[string]interface{} {
+	attributes := map[string]interface{}{
+		"graphql.operation.type": op.Type(),
+		"graphql.document.hash":  op.fingerprint(),
+	}
+	if name := op.Name(); name != "" {
+		attributes["graphql.operation.name"] = name
+	}
+	return attributes
+}
+
+// tracingExtension traces the parsing, validation and execution of the
+// operations in child spans of the request span, and times the resolvers
+// with Config.TraceResolvers, for the requests instrumented by a handler. It
+// collects the timings of the Apollo Tracing extension too.
+type tracingExtension struct{}
+
+var (
+	tracedSchemasMu sync.Mutex
+	tracedSchemas   = make(map[*graphql.Schema]bool)
+)
+
+// instrumentSchema adds the tracing extension to the schema, once whatever
+// the handlers sharing it.
+func instrumentSchema(schema *graphql.Schema) {
+	if schema == nil {
+		return
+	}
+	tracedSchemasMu.Lock()
+	defer tracedSchemasMu.Unlock()
+	if tracedSchemas[schema] {
+		return
+	}
+	tracedSchemas[schema] = true
+	schema.AddExtensions(tracingExtension{})
+}
+
+func (tracingExtension) Init(ctx context.Context, p *graphql.Params) context.Context {
+	return ctx
+}
+
+func (tracingExtension) Name() string {
+	return "tracing"
+}
+
+// startSpan starts a child span of the context, returning the context
+// unchanged so that the phases are siblings.
+func startSpan(ctx context.Context, name string, attributes map[string]interface{}) Span {
+	h, ok := ctx.Value(instrumentedKey{}).(*Handler)
+	if !ok || h.tracer == nil {
+		return nil
+	}
+	_, span := h.tracer.Start(ctx, name, SpanKindInternal, attributes)
+	return span
+}
+
+func (tracingExtension) ParseDidStart(ctx context.Context) (context.Context, graphql.ParseFinishFunc) {
+	span := startSpan(ctx, "graphql.parse", nil)
+	endPhase := apolloTracingFromContext(ctx).startParsing()
+	return ctx, func(err error) {
+		endPhase()
+		if span == nil {
+			return
+		}
+		if err != nil {
+			span.RecordError(err)
+		}
+		span.End()
+	}
+}
+
+func (tracingExtension) ValidationDidStart(ctx context.Context) (context.Context, graphql.ValidationFinishFunc) {
+	span := startSpan(ctx, "graphql.validate", nil)
+	endPhase := apolloTracingFromContext(ctx).startValidation()
+	return ctx, func(errs []gqlerrors.FormattedError) {
+		endPhase()
+		if span == nil {
+			return
+		}
+		if len(errs) > 0 {
+			span.RecordError(errors.New(errs[0].Message))
+		}
+		span.End()
+	}
+}
+
+func (tracingExtension) ExecutionDidStart(ctx context.Context) (context.Context, graphql.ExecutionFinishFunc) {
+	span := startSpan(ctx, "graphql.execute", nil)
+	return ctx, func(result *graphql.Result) {
+		if span == nil {
+			return
+		}
+		if result != nil && len(result.Errors) > 0 {
+			span.SetAttributes(map[string]interface{}{"graphql.errors.count": len(result.Errors)})
+			span.RecordError(errors.New(result.Errors[0].Message))
+		}
+		span.End()
+	}
+}
+
+func (tracingExtension) ResolveFieldDidStart(ctx context.Context, info *graphql.ResolveInfo) (context.Context, graphql.ResolveFieldFinishFunc) {
+	h, ok := ctx.Value(instrumentedKey{}).(*Handler)
+	if !ok || !h.traceResolvers && apolloTracingFromContext(ctx) == nil {
+		return ctx, func(interface{}, error) {}
+	}
+	return ctx, h.timeResolver(ctx, info)
+}
+
+func (tracingExtension) HasResult() bool {
+	return false
+}
+
+func (tracingExtension) GetResult(context.Context) interface{} {
+	return nil
+}
+
+func newTracer(provider TracerProvider) Tracer {
+	if provider == nil {
+		return nil
+	}
+	return provider.Tracer(TracerName)
+}
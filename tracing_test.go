@@ -0,0 +1,111 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/graphql-go/graphql"
+)
+
+type recordedSpan struct {
+	name       string
+	kind       SpanKind
+	parent     *recordedSpan
+	attributes map[string]interface{}
+	err        error
+	ended      bool
+}
+
+type spanKey struct{}
+
+type spanRecorder struct {
+	mu    sync.Mutex
+	spans []*recordedSpan
+}
+
+func (r *spanRecorder) Tracer(name string) Tracer {
+	return r
+}
+
+func (r *spanRecorder) Start(ctx context.Context, name string, kind SpanKind, attributes map[string]interface{}) (context.Context, Span) {
+	parent, _ := ctx.Value(spanKey{}).(*recordedSpan)
+	span := &recordedSpan{name: name, kind: kind, parent: parent, attributes: map[string]interface{}{}}
+	span.SetAttributes(attributes)
+	r.mu.Lock()
+	r.spans = append(r.spans, span)
+	r.mu.Unlock()
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func (s *recordedSpan) SetName(name string) {
+	s.name = name
+}
+
+func (s *recordedSpan) SetAttributes(attributes map[string]interface{}) {
+	for key, value := range attributes {
+		s.attributes[key] = value
+	}
+}
+
+func (s *recordedSpan) RecordError(err error) {
+	s.err = err
+}
+
+func (s *recordedSpan) End() {
+	s.ended = true
+}
+
+func TestHandler_TracerProvider(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   "Query",
+			Fields: graphql.Fields{"name": &graphql.Field{Type: graphql.String}},
+		}),
+	})
+	recorder := &spanRecorder{}
+	h := New(&Config{Schema: &schema, TracerProvider: recorder})
+	New(&Config{Schema: &schema, TracerProvider: recorder})
+
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"query Names { name }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(recorder.spans) != 4 {
+		t.Fatalf("expected a request span and 3 phase spans, got %d", len(recorder.spans))
+	}
+	server := recorder.spans[0]
+	if server.name != "query Names" || server.kind != SpanKindServer || !server.ended {
+		t.Errorf("unexpected server span %+v", server)
+	}
+	for key, value := range map[string]interface{}{
+		"graphql.operation.name":    "Names",
+		"graphql.operation.type":    "query",
+		"http.request.method":       "POST",
+		"http.response.status_code": http.StatusOK,
+	} {
+		if server.attributes[key] != value {
+			t.Errorf("unexpected %s attribute %v", key, server.attributes[key])
+		}
+	}
+	if hash, _ := server.attributes["graphql.document.hash"].(string); len(hash) != 64 {
+		t.Errorf("unexpected document hash %q", hash)
+	}
+	for i, name := range []string{"graphql.parse", "graphql.validate", "graphql.execute"} {
+		span := recorder.spans[i+1]
+		if span.name != name || span.parent != server || span.kind != SpanKindInternal || !span.ended || span.err != nil {
+			t.Errorf("unexpected %s span %+v", name, span)
+		}
+	}
+
+	recorder.spans = nil
+	req, _ = http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"{ unknown }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if len(recorder.spans) != 3 || recorder.spans[2].name != "graphql.validate" || recorder.spans[2].err == nil {
+		t.Errorf("expected the validation span to record the error")
+	}
+}
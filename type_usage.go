@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// TypeUsage reports which concrete types an operation requests behind an
+// interface or union, to inform schema splitting and federation entity
+// design.
+type TypeUsage struct {
+	OperationName string `json:"operationName"`
+	AbstractType  string `json:"abstractType"`
+	// Executions counts the executions of the operation selecting the
+	// abstract type.
+	Executions int `json:"executions"`
+	// TypeConditions counts the executions selecting each type condition, of
+	// inline fragments or fragment spreads, on the abstract type.
+	TypeConditions map[string]int `json:"typeConditions"`
+	// SharedFields counts the executions selecting fields on the abstract
+	// type itself, other than __typename.
+	SharedFields int `json:"sharedFields"`
+}
+
+// maxTypeUsageOperations bounds the operations the type usage is tracked
+// for, as operation names come from clients.
+const maxTypeUsageOperations = 1000
+
+type typeUsageStats struct {
+	mu         sync.Mutex
+	operations map[string]map[string]*TypeUsage
+}
+
+func newTypeUsageStats(enabled bool) *typeUsageStats {
+	if !enabled {
+		return nil
+	}
+	return &typeUsageStats{operations: make(map[string]map[string]*TypeUsage)}
+}
+
+type abstractSelection struct {
+	conditions map[string]bool
+	shared     bool
+}
+
+// abstractSelections returns the type conditions and shared fields the
+// operation selects on every abstract type.
+func abstractSelections(schema *graphql.Schema, op *operation) map[string]*abstractSelection {
+	selections := make(map[string]*abstractSelection)
+	op.walkFields(schema, func(parent graphql.Type, field *ast.Field, def *graphql.FieldDefinition, depth int) {
+		if def == nil || field.SelectionSet == nil {
+			return
+		}
+		var name string
+		switch t := graphql.GetNamed(def.Type).(type) {
+		case *graphql.Interface:
+			name = t.Name()
+		case *graphql.Union:
+			name = t.Name()
+		default:
+			return
+		}
+		selection, ok := selections[name]
+		if !ok {
+			selection = &abstractSelection{conditions: make(map[string]bool)}
+			selections[name] = selection
+		}
+		op.collectConditions(name, field.SelectionSet, selection, map[string]bool{})
+	})
+	return selections
+}
+
+func (o *operation) collectConditions(abstract string, set *ast.SelectionSet, selection *abstractSelection, spreads map[string]bool) {
+	if set == nil {
+		return
+	}
+	for _, s := range set.Selections {
+		var condition *ast.Named
+		var fragmentSet *ast.SelectionSet
+		var spread string
+		switch s := s.(type) {
+		case *ast.Field:
+			if s.Name != nil && s.Name.Value != "__typename" {
+				selection.shared = true
+			}
+			continue
+		case *ast.InlineFragment:
+			condition, fragmentSet = s.TypeCondition, s.SelectionSet
+		case *ast.FragmentSpread:
+			if s.Name == nil || spreads[s.Name.Value] {
+				continue
+			}
+			fragment, ok := o.fragments[s.Name.Value]
+			if !ok {
+				continue
+			}
+			spread = s.Name.Value
+			condition, fragmentSet = fragment.TypeCondition, fragment.SelectionSet
+		}
+		if condition != nil && condition.Name != nil && condition.Name.Value != abstract {
+			selection.conditions[condition.Name.Value] = true
+			continue
+		}
+		if spread != "" {
+			spreads[spread] = true
+		}
+		o.collectConditions(abstract, fragmentSet, selection, spreads)
+		delete(spreads, spread)
+	}
+}
+
+// recordTypeUsage counts the abstract types and type conditions the
+// operation selects, when Config.TypeUsageStats is set.
+func (h *Handler) recordTypeUsage(op *operation) {
+	if h.typeUsage == nil || op == nil {
+		return
+	}
+	selections := abstractSelections(h.schema(), op)
+	if len(selections) == 0 {
+		return
+	}
+
+	s := h.typeUsage
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	usages, ok := s.operations[op.Name()]
+	if !ok {
+		if len(s.operations) >= maxTypeUsageOperations {
+			return
+		}
+		usages = make(map[string]*TypeUsage)
+		s.operations[op.Name()] = usages
+	}
+	for abstract, selection := range selections {
+		usage, ok := usages[abstract]
+		if !ok {
+			usage = &TypeUsage{OperationName: op.Name(), AbstractType: abstract, TypeConditions: make(map[string]int)}
+			usages[abstract] = usage
+		}
+		usage.Executions++
+		for condition := range selection.conditions {
+			usage.TypeConditions[condition]++
+		}
+		if selection.shared {
+			usage.SharedFields++
+		}
+	}
+}
+
+// TypeUsage reports the type usage of the operations executed, sorted by
+// operation name and abstract type.
+func (h *Handler) TypeUsage() []TypeUsage {
+	if h.typeUsage == nil {
+		return nil
+	}
+	s := h.typeUsage
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var usages []TypeUsage
+	for _, operation := range s.operations {
+		for _, usage := range operation {
+			copied := *usage
+			copied.TypeConditions = make(map[string]int, len(usage.TypeConditions))
+			for condition, count := range usage.TypeConditions {
+				copied.TypeConditions[condition] = count
+			}
+			usages = append(usages, copied)
+		}
+	}
+	sort.Slice(usages, func(i, j int) bool {
+		if usages[i].OperationName != usages[j].OperationName {
+			return usages[i].OperationName < usages[j].OperationName
+		}
+		return usages[i].AbstractType < usages[j].AbstractType
+	})
+	return usages
+}
+
+// TypeUsageHandler serves the TypeUsage in JSON, for an internal route.
+func (h *Handler) TypeUsageHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		usages := h.TypeUsage()
+		if usages == nil {
+			usages = []TypeUsage{}
+		}
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		json.NewEncoder(w).Encode(map[string][]TypeUsage{"types": usages})
+	})
+}
@@ -0,0 +1,44 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestHandler_TypeUsage(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema, TypeUsageStats: true})
+	query := func(q string) {
+		body, _ := json.Marshal(map[string]string{"query": q})
+		req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(string(body)))
+		req.Header.Set("Content-Type", "application/json")
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	query(`query Hero { hero { name ... on Human { homePlanet } ...droid } } fragment droid on Droid { primaryFunction }`)
+	query(`query Hero { hero { __typename ... on Character { ... on Human { homePlanet } } } }`)
+	query(`query Human { human(id: "1000") { name } }`)
+
+	usages := h.TypeUsage()
+	if len(usages) != 1 {
+		t.Fatalf("unexpected usages %+v", usages)
+	}
+	usage := usages[0]
+	if usage.OperationName != "Hero" || usage.AbstractType != "Character" || usage.Executions != 2 || usage.SharedFields != 1 ||
+		usage.TypeConditions["Human"] != 2 || usage.TypeConditions["Droid"] != 1 || len(usage.TypeConditions) != 2 {
+		t.Errorf("unexpected usage %+v", usage)
+	}
+
+	req, _ := http.NewRequest("GET", "/graphql/type-usage", nil)
+	resp := httptest.NewRecorder()
+	h.TypeUsageHandler().ServeHTTP(resp, req)
+	var served struct {
+		Types []TypeUsage `json:"types"`
+	}
+	if err := json.Unmarshal(resp.Body.Bytes(), &served); err != nil || len(served.Types) != 1 {
+		t.Errorf("unexpected response %s", resp.Body.String())
+	}
+}
@@ -0,0 +1,324 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// DefaultUsageReportingEndpoint is the Apollo Studio usage reporting
+// endpoint.
+const DefaultUsageReportingEndpoint = "https://usage-reporting.api.apollographql.com/api/ingress/traces"
+
+const defaultUsageReportingInterval = 20 * time.Second
+
+// ApolloUsageReporting reports the usage of the operations to Apollo
+// Studio: the requests, their latency and errors by operation signature and
+// client, uploaded in the background every Interval, 20 seconds by default.
+// The janitor of the handler uploads them, see Handler.StartJanitor:
+// without it running, only Handler.FlushUsageReport uploads them, and the
+// handler logs a warning on the first usage recorded.
+type ApolloUsageReporting struct {
+	APIKey string
+	// GraphRef identifies the graph and its variant, e.g. "my-graph@current".
+	GraphRef string
+	// Endpoint defaults to DefaultUsageReportingEndpoint, and Client to
+	// http.DefaultClient.
+	Endpoint string
+	Client   *http.Client
+	Interval time.Duration
+}
+
+// usageReporter aggregates the usage of the operations between two reports.
+type usageReporter struct {
+	config   ApolloUsageReporting
+	hostname string
+
+	mu    sync.Mutex
+	stats map[string]map[usageStatsContext]*usageStats
+
+	// warnStopped warns once about the usage recorded without uploads
+	warnStopped sync.Once
+}
+
+type usageStatsContext struct {
+	clientName    string
+	clientVersion string
+}
+
+// usageStats are the QueryLatencyStats of an operation signature and client.
+type usageStats struct {
+	latencies          [usageLatencyBuckets]int64
+	requests           uint64
+	requestsWithErrors uint64
+	persistedHits      uint64
+	errors             uint64
+}
+
+const usageLatencyBuckets = 384
+
+func newUsageReporter(config *ApolloUsageReporting) *usageReporter {
+	if config == nil {
+		return nil
+	}
+	hostname, _ := os.Hostname()
+	u := &usageReporter{config: *config, hostname: hostname, stats: make(map[string]map[usageStatsContext]*usageStats)}
+	if u.config.Endpoint == "" {
+		u.config.Endpoint = DefaultUsageReportingEndpoint
+	}
+	if u.config.Client == nil {
+		u.config.Client = http.DefaultClient
+	}
+	if u.config.Interval <= 0 {
+		u.config.Interval = defaultUsageReportingInterval
+	}
+	return u
+}
+
+// usageLatencyBucket returns the bucket of a duration in the Apollo
+// histograms, whose bucket i ends at 1.1^i microseconds.
+func usageLatencyBucket(duration time.Duration) int {
+	bucket := math.Ceil(math.Log(float64(duration.Nanoseconds())/1000) / math.Log(1.1))
+	if math.IsNaN(bucket) || bucket <= 0 {
+		return 0
+	}
+	if bucket >= usageLatencyBuckets {
+		return usageLatencyBuckets - 1
+	}
+	return int(bucket)
+}
+
+// recordUsage records the usage of a request, warning once when the
+// janitor uploading the reports is not running.
+func (h *Handler) recordUsage(ctx context.Context, op *operation, client ClientInfo, duration time.Duration, errors int, persisted bool) {
+	u := h.usageReporter
+	if u == nil {
+		return
+	}
+	if !h.janitor.running() {
+		u.warnStopped.Do(func() {
+			h.log(ctx, LevelWarn, "usage reports are not uploaded, the janitor is not running")
+		})
+	}
+	u.record(op, client, duration, errors, persisted)
+}
+
+func (u *usageReporter) record(op *operation, client ClientInfo, duration time.Duration, errors int, persisted bool) {
+	if u == nil || op == nil {
+		return
+	}
+	name := op.Name()
+	if name == "" {
+		name = "-"
+	}
+	key := "# " + name + "\n" + op.signature()
+	statsContext := usageStatsContext{clientName: client.Name, clientVersion: client.Version}
+
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	contexts, ok := u.stats[key]
+	if !ok {
+		contexts = make(map[usageStatsContext]*usageStats)
+		u.stats[key] = contexts
+	}
+	stats, ok := contexts[statsContext]
+	if !ok {
+		stats = &usageStats{}
+		contexts[statsContext] = stats
+	}
+	stats.latencies[usageLatencyBucket(duration)]++
+	stats.requests++
+	if errors > 0 {
+		stats.requestsWithErrors++
+		stats.errors += uint64(errors)
+	}
+	if persisted {
+		stats.persistedHits++
+	}
+}
+
+// flush uploads the usage aggregated since the previous report, if any.
+func (u *usageReporter) flush(ctx context.Context, schemaID string) error {
+	u.mu.Lock()
+	stats := u.stats
+	u.stats = make(map[string]map[usageStatsContext]*usageStats)
+	u.mu.Unlock()
+	if len(stats) == 0 {
+		return nil
+	}
+
+	var body bytes.Buffer
+	gz := gzip.NewWriter(&body)
+	gz.Write(u.report(stats, schemaID, time.Now()))
+	gz.Close()
+	req, err := http.NewRequest(http.MethodPost, u.config.Endpoint, &body)
+	if err != nil {
+		return err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/protobuf")
+	req.Header.Set("Content-Encoding", "gzip")
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("X-Api-Key", u.config.APIKey)
+	req.Header.Set("User-Agent", "go-graphql-handler")
+	resp, err := u.config.Client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("usage report rejected with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// report encodes the Report message of the Apollo reports.proto.
+func (u *usageReporter) report(stats map[string]map[usageStatsContext]*usageStats, schemaID string, end time.Time) []byte {
+	var header protoBuffer
+	header.stringField(12, u.config.GraphRef)
+	header.stringField(5, u.hostname)
+	header.stringField(6, "go-graphql-handler")
+	header.stringField(8, runtime.Version())
+	header.stringField(9, runtime.GOOS+" "+runtime.GOARCH)
+	header.stringField(11, schemaID)
+
+	var report protoBuffer
+	report.messageField(1, header.b)
+	var timestamp protoBuffer
+	timestamp.uint64Field(1, uint64(end.Unix()))
+	timestamp.uint64Field(2, uint64(end.Nanosecond()))
+	report.messageField(2, timestamp.b)
+
+	var operations uint64
+	for key, contexts := range stats {
+		var tracesAndStats protoBuffer
+		for client, s := range contexts {
+			operations += s.requests
+			tracesAndStats.messageField(2, s.contextualized(client))
+		}
+		var entry protoBuffer
+		entry.stringField(1, key)
+		entry.messageField(2, tracesAndStats.b)
+		report.messageField(5, entry.b)
+	}
+	report.uint64Field(6, operations)
+	return report.b
+}
+
+// contextualized encodes the ContextualizedStats message of the stats.
+func (s *usageStats) contextualized(client usageStatsContext) []byte {
+	var statsContext protoBuffer
+	statsContext.stringField(2, client.clientName)
+	statsContext.stringField(3, client.clientVersion)
+
+	var rootErrors protoBuffer
+	rootErrors.uint64Field(4, s.errors)
+	rootErrors.uint64Field(5, s.requestsWithErrors)
+
+	var latency protoBuffer
+	latency.packedSint64Field(13, encodeUsageHistogram(s.latencies[:]))
+	latency.uint64Field(2, s.requests)
+	latency.uint64Field(4, s.persistedHits)
+	if len(rootErrors.b) > 0 {
+		latency.messageField(7, rootErrors.b)
+	}
+	latency.uint64Field(8, s.requestsWithErrors)
+
+	var contextualized protoBuffer
+	contextualized.messageField(1, statsContext.b)
+	contextualized.messageField(2, latency.b)
+	return contextualized.b
+}
+
+// encodeUsageHistogram encodes the histogram like Apollo: the trailing empty
+// buckets dropped, and runs of empty buckets replaced by their negated
+// length.
+func encodeUsageHistogram(buckets []int64) []int64 {
+	var encoded []int64
+	var zeros int64
+	for _, count := range buckets {
+		if count == 0 {
+			zeros++
+			continue
+		}
+		if zeros == 1 {
+			encoded = append(encoded, 0)
+		} else if zeros > 1 {
+			encoded = append(encoded, -zeros)
+		}
+		encoded = append(encoded, count)
+		zeros = 0
+	}
+	return encoded
+}
+
+// protoBuffer encodes protocol buffers messages, the fields with a default
+// value omitted.
+type protoBuffer struct {
+	b []byte
+}
+
+func (p *protoBuffer) varint(v uint64) {
+	for v >= 0x80 {
+		p.b = append(p.b, byte(v)|0x80)
+		v >>= 7
+	}
+	p.b = append(p.b, byte(v))
+}
+
+func (p *protoBuffer) key(field int, wireType int) {
+	p.varint(uint64(field)<<3 | uint64(wireType))
+}
+
+func (p *protoBuffer) uint64Field(field int, v uint64) {
+	if v == 0 {
+		return
+	}
+	p.key(field, 0)
+	p.varint(v)
+}
+
+func (p *protoBuffer) bytesField(field int, b []byte) {
+	p.key(field, 2)
+	p.varint(uint64(len(b)))
+	p.b = append(p.b, b...)
+}
+
+func (p *protoBuffer) stringField(field int, s string) {
+	if s == "" {
+		return
+	}
+	p.bytesField(field, []byte(s))
+}
+
+func (p *protoBuffer) messageField(field int, message []byte) {
+	p.bytesField(field, message)
+}
+
+func (p *protoBuffer) packedSint64Field(field int, values []int64) {
+	if len(values) == 0 {
+		return
+	}
+	var packed protoBuffer
+	for _, v := range values {
+		packed.varint(uint64(v<<1) ^ uint64(v>>63))
+	}
+	p.bytesField(field, packed.b)
+}
+
+// FlushUsageReport uploads the usage aggregated since the last report to
+// Apollo Studio, e.g. before shutting down. The janitor uploads it
+// periodically otherwise.
+func (h *Handler) FlushUsageReport(ctx context.Context) error {
+	if h.usageReporter == nil {
+		return nil
+	}
+	return h.usageReporter.flush(ctx, hashSignatures(schemaSignatures(h.schema())))
+}
@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+func TestOperationSignature(t *testing.T) {
+	for query, expected := range map[string]string{
+		`{ b a }`: `query{a b}`,
+		`query Q($id: ID!, $after: String = "x") { user(id: $id, first: 10) { renamed: name ...F ... on User { id } } } fragment F on User { email(format: "html") } fragment Unused on User { id }`: `fragment F on User{email(format:"")}query Q($after:String="",$id:ID!){user(first:0,id:$id){name...F...on User{id}}}`,
+		`mutation M { update(input: {name: "x"}, ids: [1, 2], enabled: true, role: ADMIN) @log { ok } }`:                                                                                             `mutation M{update(enabled:true,ids:[],input:{},role:ADMIN)@log{ok}}`,
+	} {
+		op, err := parseOperation(query, "")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if signature := op.signature(); signature != expected {
+			t.Errorf("unexpected signature of %s:\n%s\nexpected\n%s", query, signature, expected)
+		}
+	}
+}
+
+func TestEncodeUsageHistogram(t *testing.T) {
+	buckets := make([]int64, 10)
+	buckets[1], buckets[3], buckets[7] = 2, 1, 5
+	if encoded := encodeUsageHistogram(buckets); !reflect.DeepEqual(encoded, []int64{0, 2, 0, 1, -3, 5}) {
+		t.Errorf("unexpected encoding %v", encoded)
+	}
+	if bucket := usageLatencyBucket(time.Millisecond); bucket != 73 {
+		t.Errorf("unexpected bucket %d", bucket)
+	}
+	if usageLatencyBucket(0) != 0 || usageLatencyBucket(math.MaxInt64) != usageLatencyBuckets-1 {
+		t.Errorf("expected the buckets to be bounded")
+	}
+}
+
+func TestHandler_ApolloUsageReporting(t *testing.T) {
+	var report []byte
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header
+		gz, _ := gzip.NewReader(r.Body)
+		report, _ = ioutil.ReadAll(gz)
+	}))
+	defer server.Close()
+
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   "Query",
+			Fields: graphql.Fields{"name": &graphql.Field{Type: graphql.String}},
+		}),
+	})
+	h := New(&Config{
+		Schema:               &schema,
+		ApolloUsageReporting: &ApolloUsageReporting{APIKey: "service:key", GraphRef: "graph@current", Endpoint: server.URL},
+	})
+	if err := h.FlushUsageReport(context.Background()); err != nil || report != nil {
+		t.Fatalf("expected no empty report, got %v", err)
+	}
+
+	req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(`{"query":"query Names { name }"}`))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ClientNameHeader, "ios")
+	req.Header.Set(ClientVersionHeader, "1.2.3")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+
+	if err := h.FlushUsageReport(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if header.Get("X-Api-Key") != "service:key" || header.Get("Content-Type") != "application/protobuf" {
+		t.Errorf("unexpected headers %v", header)
+	}
+	for _, expected := range []string{"graph@current", "# Names\nquery Names{name}", "ios", "1.2.3"} {
+		if !bytes.Contains(report, []byte(expected)) {
+			t.Errorf("expected %q in the report %q", expected, report)
+		}
+	}
+
+	report = nil
+	h.FlushUsageReport(context.Background())
+	if report != nil {
+		t.Errorf("expected the usage to be reset by the report")
+	}
+}
+
+func TestHandler_ApolloUsageReportingWithoutJanitor(t *testing.T) {
+	schema, _ := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name:   "Query",
+			Fields: graphql.Fields{"name": &graphql.Field{Type: graphql.String}},
+		}),
+	})
+	var warnings []string
+	h := New(&Config{
+		Schema:               &schema,
+		ApolloUsageReporting: &ApolloUsageReporting{APIKey: "service:key", GraphRef: "graph@current", Endpoint: "http://127.0.0.1:0"},
+		Logger: LoggerFunc(func(ctx context.Context, level LogLevel, msg string, args ...interface{}) {
+			if level == LevelWarn {
+				warnings = append(warnings, msg)
+			}
+		}),
+	})
+	for i := 0; i < 2; i++ {
+		req, _ := http.NewRequest("GET", "/graphql?query={name}", nil)
+		h.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	if len(warnings) != 1 || !strings.Contains(warnings[0], "janitor") {
+		t.Fatalf("expected a single warning, got %v", warnings)
+	}
+
+	h.StartJanitor()
+	defer h.StopJanitor()
+	warnings = nil
+	req, _ := http.NewRequest("GET", "/graphql?query={name}", nil)
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	if len(warnings) != 0 {
+		t.Errorf("unexpected warnings %v", warnings)
+	}
+}
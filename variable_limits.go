@@ -0,0 +1,63 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+)
+
+// checkVariables rejects the variables over Config.MaxVariablesBytes, their
+// JSON size, MaxVariablesKeys, their number of object keys at any depth, or
+// MaxVariablesDepth, their nesting of objects and lists.
+func (h *Handler) checkVariables(variables map[string]interface{}) *requestError {
+	if len(variables) == 0 {
+		return nil
+	}
+	if h.maxVariablesBytes > 0 {
+		encoded, err := json.Marshal(variables)
+		if err != nil {
+			return newRequestError(http.StatusBadRequest, CodeInvalidRequest, "Invalid variables: "+err.Error())
+		}
+		if len(encoded) > h.maxVariablesBytes {
+			return newRequestError(http.StatusBadRequest, CodeVariablesTooLarge, "Variables of "+strconv.Itoa(len(encoded))+" bytes exceed the maximum of "+strconv.Itoa(h.maxVariablesBytes))
+		}
+	}
+	if h.maxVariablesKeys <= 0 && h.maxVariablesDepth <= 0 {
+		return nil
+	}
+	keys, depth := measureVariables(variables, 1)
+	if h.maxVariablesKeys > 0 && keys > h.maxVariablesKeys {
+		return newRequestError(http.StatusBadRequest, CodeVariablesTooLarge, "Variables with "+strconv.Itoa(keys)+" keys exceed the maximum of "+strconv.Itoa(h.maxVariablesKeys))
+	}
+	if h.maxVariablesDepth > 0 && depth > h.maxVariablesDepth {
+		return newRequestError(http.StatusBadRequest, CodeVariablesTooLarge, "Variables nested "+strconv.Itoa(depth)+" levels deep exceed the maximum depth of "+strconv.Itoa(h.maxVariablesDepth))
+	}
+	return nil
+}
+
+// measureVariables returns the number of object keys within the value and
+// its nesting depth, the value being at the given depth.
+func measureVariables(value interface{}, depth int) (keys, maxDepth int) {
+	maxDepth = depth - 1
+	switch value := value.(type) {
+	case map[string]interface{}:
+		keys, maxDepth = len(value), depth
+		for _, v := range value {
+			k, d := measureVariables(v, depth+1)
+			keys += k
+			if d > maxDepth {
+				maxDepth = d
+			}
+		}
+	case []interface{}:
+		maxDepth = depth
+		for _, v := range value {
+			k, d := measureVariables(v, depth+1)
+			keys += k
+			if d > maxDepth {
+				maxDepth = d
+			}
+		}
+	}
+	return keys, maxDepth
+}
@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/graphql-go/graphql/testutil"
+)
+
+func TestMeasureVariables(t *testing.T) {
+	keys, depth := measureVariables(map[string]interface{}{
+		"id": "1",
+		"input": map[string]interface{}{
+			"tags":  []interface{}{"a", map[string]interface{}{"b": 1}},
+			"count": 2,
+		},
+	}, 1)
+	if keys != 5 || depth != 4 {
+		t.Errorf("unexpected keys %d and depth %d", keys, depth)
+	}
+}
+
+func TestHandler_VariablesLimits(t *testing.T) {
+	h := New(&Config{Schema: &testutil.StarWarsSchema, MaxVariablesBytes: 64, MaxVariablesKeys: 3, MaxVariablesDepth: 2})
+	tests := []struct {
+		name      string
+		variables string
+		message   string
+	}{
+		{"within limits", `{"id":"1000"}`, ""},
+		{"too large", `{"id":"` + strings.Repeat("1", 64) + `"}`, "Variables of 73 bytes exceed the maximum of 64"},
+		{"too many keys", `{"id":"1000","a":1,"b":2,"c":3}`, "Variables with 4 keys exceed the maximum of 3"},
+		{"too deep", `{"id":"1000","a":[[1]]}`, "Variables nested 3 levels deep exceed the maximum depth of 2"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			body := `{"query":"query ($id: String!) { human(id: $id) { name } }","variables":` + test.variables + `}`
+			req, _ := http.NewRequest("POST", "/graphql", strings.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			resp := httptest.NewRecorder()
+			h.ServeHTTP(resp, req)
+			if test.message == "" {
+				if resp.Code != http.StatusOK {
+					t.Errorf("unexpected response %d %s", resp.Code, resp.Body.String())
+				}
+				return
+			}
+			if resp.Code != http.StatusBadRequest || !strings.Contains(resp.Body.String(), test.message) || !strings.Contains(resp.Body.String(), string(CodeVariablesTooLarge)) {
+				t.Errorf("unexpected response %d %s", resp.Code, resp.Body.String())
+			}
+		})
+	}
+}
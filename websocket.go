@@ -0,0 +1,617 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+const (
+	// ProtocolGraphQLTransportWS is the graphql-transport-ws subprotocol
+	// spoken by the graphql-ws library.
+	ProtocolGraphQLTransportWS = "graphql-transport-ws"
+	// ProtocolGraphQLWS is the legacy subprotocol of the
+	// subscriptions-transport-ws library.
+	ProtocolGraphQLWS = "graphql-ws"
+)
+
+// graphql-transport-ws message types
+const (
+	wsConnectionInit = "connection_init"
+	wsConnectionAck  = "connection_ack"
+	wsPing           = "ping"
+	wsPong           = "pong"
+	wsSubscribe      = "subscribe"
+	wsNext           = "next"
+	wsError          = "error"
+	wsComplete       = "complete"
+)
+
+// legacy graphql-ws message types
+const (
+	wsLegacyConnectionError     = "connection_error"
+	wsLegacyConnectionTerminate = "connection_terminate"
+	wsLegacyKeepAlive           = "ka"
+	wsLegacyStart               = "start"
+	wsLegacyStop                = "stop"
+	wsLegacyData                = "data"
+)
+
+// graphql-transport-ws close codes
+const (
+	wsCloseBadRequest          = 4400
+	wsCloseUnauthorized        = 4401
+	wsCloseForbidden           = 4403
+	wsCloseSubprotocol         = 4406
+	wsCloseInitTimeout         = 4408
+	wsCloseSubscriberExists    = 4409
+	wsCloseTooManyInitRequests = 4429
+)
+
+const (
+	wsConnectionInitTimeout = 10 * time.Second
+	wsLegacyKeepAlivePeriod = 25 * time.Second
+)
+
+// OnWebSocketInitFn is called with the connection_init payload of a
+// WebSocket connection before acknowledging it. The values of the returned
+// context, when not nil, are available to all the operations of the
+// connection, which are cancelled with it. Returning an error rejects the
+// connection, with the close code of a *WebSocketCloseError or 4403
+// Forbidden otherwise.
+type OnWebSocketInitFn func(ctx context.Context, payload map[string]interface{}) (context.Context, error)
+
+// WebSocketCloseError closes a WebSocket connection with a specific code.
+type WebSocketCloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *WebSocketCloseError) Error() string {
+	return e.Reason
+}
+
+type wsMessage struct {
+	ID      string          `json:"id,omitempty"`
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// wsConnection is a single GraphQL over WebSocket connection.
+type wsConnection struct {
+	h      *Handler
+	r      *http.Request
+	conn   *websocket.Conn
+	ctx    context.Context
+	cancel context.CancelFunc
+	legacy bool
+
+	writeMu sync.Mutex
+
+	// authenticated reports whether the upgrade request passed the
+	// Config.APIKeyFn, the connection_init payload being checked otherwise.
+	authenticated bool
+
+	mu           sync.Mutex
+	initialized  bool
+	operationCtx context.Context
+	operations   map[string]context.CancelFunc
+}
+
+func (h *Handler) websocketProtocols() []string {
+	if h.legacySubscriptions {
+		return []string{ProtocolGraphQLTransportWS, ProtocolGraphQLWS}
+	}
+	return []string{ProtocolGraphQLTransportWS}
+}
+
+// serveWebSocket upgrades the request and serves GraphQL subscriptions over
+// the negotiated subprotocol until the connection is closed.
+func (h *Handler) serveWebSocket(ctx context.Context, w http.ResponseWriter, r *http.Request) {
+	ip := clientIP(r)
+	if ok, status := h.wsConnections.acquire(ip); !ok {
+		http.Error(w, http.StatusText(status), status)
+		return
+	}
+	defer h.wsConnections.release(ip)
+
+	// browsers cannot set headers on WebSocket requests, the API key may
+	// then be sent in the connection_init payload
+	ctx, authErr := h.authenticateAPIKey(ctx, r)
+
+	upgrader := websocket.Upgrader{
+		Subprotocols: h.websocketProtocols(),
+	}
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// the upgrader already replied with an HTTP error
+		return
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	c := &wsConnection{
+		h:             h,
+		r:             r,
+		conn:          conn,
+		ctx:           ctx,
+		cancel:        cancel,
+		legacy:        conn.Subprotocol() == ProtocolGraphQLWS,
+		authenticated: authErr == nil,
+		operationCtx:  ctx,
+		operations:    make(map[string]context.CancelFunc),
+	}
+	if conn.Subprotocol() == "" {
+		c.close(wsCloseSubprotocol, "Subprotocol not acceptable")
+		return
+	}
+	c.serve()
+}
+
+func (c *wsConnection) serve() {
+	defer c.stopAll()
+
+	if c.h.wsPingInterval > 0 {
+		c.extendReadDeadline()
+		c.conn.SetPongHandler(func(string) error {
+			c.extendReadDeadline()
+			return nil
+		})
+		go c.ping()
+	}
+
+	initTimer := time.AfterFunc(wsConnectionInitTimeout, func() {
+		c.mu.Lock()
+		initialized := c.initialized
+		c.mu.Unlock()
+		if !initialized {
+			c.close(wsCloseInitTimeout, "Connection initialisation timeout")
+		}
+	})
+	defer initTimer.Stop()
+
+	for {
+		var msg wsMessage
+		if err := c.conn.ReadJSON(&msg); err != nil {
+			if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+				// the client stopped answering pings
+				return
+			}
+			if _, ok := err.(*websocket.CloseError); !ok && c.ctx.Err() == nil {
+				c.close(wsCloseBadRequest, "Invalid message received")
+			}
+			return
+		}
+		c.extendReadDeadline()
+		if !c.handle(msg) {
+			return
+		}
+	}
+}
+
+// handle processes a client message, returning false once the connection
+// must not be read from anymore.
+func (c *wsConnection) handle(msg wsMessage) bool {
+	if msg.Type == wsConnectionInit {
+		c.mu.Lock()
+		initialized := c.initialized
+		c.initialized = true
+		c.mu.Unlock()
+		if initialized {
+			c.close(wsCloseTooManyInitRequests, "Too many initialisation requests")
+			return false
+		}
+		if !c.init(msg.Payload) {
+			return false
+		}
+		c.write(wsMessage{Type: wsConnectionAck})
+		if c.legacy {
+			go c.keepAlive()
+		}
+		return true
+	}
+
+	c.mu.Lock()
+	initialized := c.initialized
+	c.mu.Unlock()
+
+	switch {
+	case !c.legacy && msg.Type == wsPing:
+		c.write(wsMessage{Type: wsPong, Payload: msg.Payload})
+	case !c.legacy && msg.Type == wsPong:
+	case c.legacy && msg.Type == wsLegacyConnectionTerminate:
+		c.close(websocket.CloseNormalClosure, "")
+		return false
+	case !initialized:
+		if c.legacy {
+			c.write(wsMessage{Type: wsLegacyConnectionError, Payload: marshalPayload(gqlerrors.NewFormattedError("Unauthorized"))})
+		}
+		c.close(wsCloseUnauthorized, "Unauthorized")
+		return false
+	case (!c.legacy && msg.Type == wsSubscribe) || (c.legacy && msg.Type == wsLegacyStart):
+		if msg.ID == "" {
+			c.close(wsCloseBadRequest, "Invalid message received")
+			return false
+		}
+		return c.subscribe(msg)
+	case (!c.legacy && msg.Type == wsComplete) || (c.legacy && msg.Type == wsLegacyStop):
+		c.stop(msg.ID)
+	default:
+		c.close(wsCloseBadRequest, fmt.Sprintf("Invalid message type %q", msg.Type))
+		return false
+	}
+	return true
+}
+
+// init authenticates the connection with the payload when the upgrade
+// request was not, and runs the OnWebSocketInit hook, closing the
+// connection when either rejects the payload.
+func (c *wsConnection) init(payload json.RawMessage) bool {
+	if c.authenticated && c.h.onWebSocketInit == nil {
+		return true
+	}
+
+	var initPayload map[string]interface{}
+	if len(payload) > 0 {
+		if err := json.Unmarshal(payload, &initPayload); err != nil {
+			c.close(wsCloseBadRequest, "Invalid message received")
+			return false
+		}
+	}
+
+	if !c.authenticated {
+		ctx, reqErr := c.h.authenticateAPIKey(c.operationCtx, payloadRequest(c.r, initPayload))
+		if reqErr != nil {
+			c.h.emitRejection(c.ctx, reqErr)
+			if c.legacy {
+				c.write(wsMessage{Type: wsLegacyConnectionError, Payload: marshalPayload(gqlerrors.NewFormattedError("Unauthorized"))})
+			}
+			c.close(wsCloseUnauthorized, "Unauthorized")
+			return false
+		}
+		c.mu.Lock()
+		c.operationCtx = ctx
+		c.mu.Unlock()
+	}
+	if c.h.onWebSocketInit == nil {
+		return true
+	}
+
+	ctx, err := c.h.onWebSocketInit(c.operationCtx, initPayload)
+	if err != nil {
+		code, reason := wsCloseForbidden, "Forbidden"
+		if closeErr, ok := err.(*WebSocketCloseError); ok {
+			code, reason = closeErr.Code, closeErr.Reason
+		}
+		if c.legacy {
+			c.write(wsMessage{Type: wsLegacyConnectionError, Payload: marshalPayload(gqlerrors.NewFormattedError(reason))})
+		}
+		c.close(code, reason)
+		return false
+	}
+	if ctx != nil {
+		// the operations are cancelled with the connection, whatever
+		// context the hook returned, only its values being kept
+		c.mu.Lock()
+		c.operationCtx = detachedContext{Context: c.ctx, values: ctx}
+		c.mu.Unlock()
+	}
+	return true
+}
+
+// payloadRequest copies the upgrade request with the string entries of the
+// connection_init payload as headers, e.g. {"X-API-Key": "..."}, for the
+// Config.APIKeyFn.
+func payloadRequest(r *http.Request, payload map[string]interface{}) *http.Request {
+	clone := r.WithContext(r.Context())
+	clone.Header = r.Header.Clone()
+	for key, value := range payload {
+		if value, ok := value.(string); ok {
+			clone.Header.Set(key, value)
+		}
+	}
+	return clone
+}
+
+func (c *wsConnection) subscribe(msg wsMessage) bool {
+	var opts RequestOptions
+	if err := json.Unmarshal(msg.Payload, &opts); err != nil {
+		c.close(wsCloseBadRequest, "Invalid message received")
+		return false
+	}
+
+	c.mu.Lock()
+	if _, exists := c.operations[msg.ID]; exists {
+		c.mu.Unlock()
+		c.close(wsCloseSubscriberExists, fmt.Sprintf("Subscriber for %s already exists", msg.ID))
+		return false
+	}
+	ctx, cancel := context.WithCancel(c.operationCtx)
+	c.operations[msg.ID] = cancel
+	c.mu.Unlock()
+
+	go c.execute(ctx, msg.ID, &opts)
+	return true
+}
+
+func (c *wsConnection) execute(ctx context.Context, id string, opts *RequestOptions) {
+	defer c.stop(id)
+	// a panic would crash the process outside of the HTTP handler
+	defer func() {
+		if r := recover(); r != nil {
+			c.h.emitPanic(ctx, r)
+			c.reject(ctx, id, newRequestError(http.StatusInternalServerError, CodeInternalServerError, "Internal server error"))
+		}
+	}()
+
+	opts, err := persistedQueryCheck(c.h.persistedQueries, opts)
+	if reqErr, ok := err.(*requestError); ok {
+		c.reject(ctx, id, reqErr)
+		return
+	}
+	if err != nil {
+		c.reject(ctx, id, newRequestError(http.StatusOK, CodePersistedQueryNotFound, "PersistedQueryNotFound"))
+		return
+	}
+
+	if reqErr := c.h.checkVariables(opts.Variables); reqErr != nil {
+		c.reject(ctx, id, reqErr)
+		return
+	}
+	ctx, reqErr := c.h.checkExtensions(ctx, c.r, opts)
+	if reqErr != nil {
+		c.reject(ctx, id, reqErr)
+		return
+	}
+	if reqErr := c.h.checkDocument(opts.Query, false); reqErr != nil {
+		c.reject(ctx, id, reqErr)
+		return
+	}
+
+	op, err := parseOperation(opts.Query, opts.OperationName)
+	if err != nil {
+		c.sendErrors(id, gqlerrors.FormatErrors(err))
+		return
+	}
+	if op.Type() != ast.OperationTypeSubscription && !c.h.webSocketOperations {
+		c.reject(ctx, id, newRequestError(http.StatusOK, CodeOperationNotSupported, op.Type()+" operations are not supported over WebSocket"))
+		return
+	}
+	if reqErr := c.h.checkClientPolicy(ctx, nil, c.r); reqErr != nil {
+		c.reject(ctx, id, reqErr)
+		return
+	}
+	if reqErr := c.h.checkRateLimit(ctx, nil, c.r, op); reqErr != nil {
+		c.reject(ctx, id, reqErr)
+		return
+	}
+	if reqErr := c.h.checkReadOnly(op); reqErr != nil {
+		c.reject(ctx, id, reqErr)
+		return
+	}
+	if reqErr := c.h.checkAllowedOperation(ctx, c.r, op); reqErr != nil {
+		c.reject(ctx, id, reqErr)
+		return
+	}
+	if reqErr := c.h.checkAuthorization(ctx, c.r, opts); reqErr != nil {
+		c.reject(ctx, id, reqErr)
+		return
+	}
+	if reqErr := c.h.checkIntrospection(ctx, c.r, op, false); reqErr != nil {
+		c.reject(ctx, id, reqErr)
+		return
+	}
+	if reqErr := c.h.checkLimits(op, false); reqErr != nil && c.h.featureEnabled(ctx, FeatureOperationLimits) {
+		c.reject(ctx, id, reqErr)
+		return
+	}
+	if _, reqErr := c.h.checkCost(op, opts.Variables, false); reqErr != nil && c.h.featureEnabled(ctx, FeatureCostLimit) {
+		c.reject(ctx, id, reqErr)
+		return
+	}
+
+	params := graphql.Params{
+		Schema:         *c.h.schema(),
+		RequestString:  opts.Query,
+		VariableValues: opts.Variables,
+		OperationName:  opts.OperationName,
+		Context:        ctx,
+	}
+	if c.h.rootObjectFn != nil {
+		params.RootObject = c.h.rootObjectFn(ctx, c.r)
+	}
+	c.h.recordDataAccess(ctx, c.r, op, opts)
+	c.h.recordTypeUsage(op)
+
+	if op.Type() != ast.OperationTypeSubscription {
+		c.executeSingle(ctx, id, op, params)
+		return
+	}
+
+	first, failed := true, false
+	for result := range graphql.Subscribe(params) {
+		if ctx.Err() != nil || failed {
+			// drain the channel so the executor can return
+			continue
+		}
+		if first && result.Data == nil && result.HasErrors() {
+			c.sendErrors(id, c.h.formatErrors(ctx, result.Errors))
+			failed = true
+			continue
+		}
+		first = false
+		result.Errors = c.h.formatErrors(ctx, result.Errors)
+		messageType := wsNext
+		if c.legacy {
+			messageType = wsLegacyData
+		}
+		c.write(wsMessage{ID: id, Type: messageType, Payload: marshalPayload(result)})
+	}
+	if ctx.Err() == nil && !failed {
+		c.write(wsMessage{ID: id, Type: wsComplete})
+	}
+}
+
+// executeSingle executes a query or mutation, sending its result followed by
+// the completion of the operation.
+func (c *wsConnection) executeSingle(ctx context.Context, id string, op *operation, params graphql.Params) {
+	if op.Type() == ast.OperationTypeMutation && c.h.subjectMutations != nil && c.h.subjectIDFn != nil {
+		if subject := c.h.subjectIDFn(ctx); subject != "" {
+			if !c.h.subjectMutations.acquire(ctx, subject) {
+				c.reject(ctx, id, newRequestError(http.StatusTooManyRequests, CodeTooManyConcurrentMutations, "Too many concurrent mutations"))
+				return
+			}
+			defer c.h.subjectMutations.release(subject)
+		}
+	}
+
+	recordCircuit, reqErr := c.h.checkCircuit(nil, op)
+	if reqErr != nil {
+		c.reject(ctx, id, reqErr)
+		return
+	}
+	release, reqErr := c.h.acquireOperationSlot(ctx, nil)
+	if reqErr != nil {
+		c.reject(ctx, id, reqErr)
+		return
+	}
+	start := time.Now()
+	result := c.h.execute(op, params)
+	release()
+	recordCircuit(circuitFailed(result))
+	c.h.finishResult(ctx, result)
+	c.h.countErrors(ctx, result)
+	c.h.recordAudit(ctx, c.r, op, params.VariableValues, resultStatus(result), result, time.Since(start))
+	if ctx.Err() != nil {
+		return
+	}
+	if isRequestError(result) {
+		c.sendErrors(id, result.Errors)
+		return
+	}
+	messageType := wsNext
+	if c.legacy {
+		messageType = wsLegacyData
+	}
+	c.write(wsMessage{ID: id, Type: messageType, Payload: marshalPayload(result)})
+	c.write(wsMessage{ID: id, Type: wsComplete})
+}
+
+// reject sends the error rejecting an operation.
+func (c *wsConnection) reject(ctx context.Context, id string, err *requestError) {
+	c.h.emitRejection(ctx, err)
+	errs := []gqlerrors.FormattedError{err.formatted()}
+	addRequestID(ctx, errs)
+	c.sendErrors(id, errs)
+}
+
+// sendErrors reports errors that prevented an operation from executing.
+func (c *wsConnection) sendErrors(id string, errs []gqlerrors.FormattedError) {
+	if c.legacy {
+		c.write(wsMessage{ID: id, Type: wsError, Payload: marshalPayload(errs[0])})
+		c.write(wsMessage{ID: id, Type: wsComplete})
+		return
+	}
+	c.write(wsMessage{ID: id, Type: wsError, Payload: marshalPayload(errs)})
+}
+
+func (c *wsConnection) stop(id string) {
+	c.mu.Lock()
+	cancel, ok := c.operations[id]
+	delete(c.operations, id)
+	c.mu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+func (c *wsConnection) stopAll() {
+	c.mu.Lock()
+	operations := c.operations
+	c.operations = make(map[string]context.CancelFunc)
+	c.mu.Unlock()
+	for _, cancel := range operations {
+		cancel()
+	}
+}
+
+// extendReadDeadline closes the connection when the client does not answer
+// the next ping within the pong timeout.
+func (c *wsConnection) extendReadDeadline() {
+	if c.h.wsPingInterval > 0 && c.h.wsPongTimeout > 0 {
+		c.conn.SetReadDeadline(time.Now().Add(c.h.wsPingInterval + c.h.wsPongTimeout))
+	}
+}
+
+// ping sends ping control frames to detect dead connections.
+func (c *wsConnection) ping() {
+	ticker := time.NewTicker(c.h.wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			if err := c.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(time.Second)); err != nil {
+				c.cancel()
+				return
+			}
+		}
+	}
+}
+
+func (c *wsConnection) keepAlive() {
+	period := wsLegacyKeepAlivePeriod
+	if c.h.wsPingInterval > 0 {
+		period = c.h.wsPingInterval
+	}
+	c.write(wsMessage{Type: wsLegacyKeepAlive})
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+			c.write(wsMessage{Type: wsLegacyKeepAlive})
+		}
+	}
+}
+
+func (c *wsConnection) write(msg wsMessage) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.ctx.Err() != nil {
+		return
+	}
+	if err := c.conn.WriteJSON(msg); err != nil {
+		c.cancel()
+	}
+}
+
+func (c *wsConnection) close(code int, reason string) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.ctx.Err() != nil {
+		return
+	}
+	c.conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(code, reason), time.Now().Add(time.Second))
+	c.cancel()
+	c.conn.Close()
+}
+
+func marshalPayload(v interface{}) json.RawMessage {
+	payload, _ := json.Marshal(v)
+	return payload
+}
@@ -0,0 +1,60 @@
+package handler
+
+import (
+	"net"
+	"net/http"
+	"sync"
+)
+
+// connectionLimiter bounds the number of concurrent WebSocket connections,
+// in total and per client IP.
+type connectionLimiter struct {
+	maxTotal int
+	maxPerIP int
+
+	mu    sync.Mutex
+	total int
+	perIP map[string]int
+}
+
+func newConnectionLimiter(maxTotal int, maxPerIP int) *connectionLimiter {
+	return &connectionLimiter{
+		maxTotal: maxTotal,
+		maxPerIP: maxPerIP,
+		perIP:    make(map[string]int),
+	}
+}
+
+// acquire reserves a connection slot for the IP, returning the HTTP status
+// to reject the connection with when no slot is available.
+func (l *connectionLimiter) acquire(ip string) (bool, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.maxTotal > 0 && l.total >= l.maxTotal {
+		return false, http.StatusServiceUnavailable
+	}
+	if l.maxPerIP > 0 && l.perIP[ip] >= l.maxPerIP {
+		return false, http.StatusTooManyRequests
+	}
+	l.total++
+	l.perIP[ip]++
+	return true, 0
+}
+
+func (l *connectionLimiter) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.total--
+	if l.perIP[ip]--; l.perIP[ip] <= 0 {
+		delete(l.perIP, ip)
+	}
+}
+
+// clientIP returns the IP address of the peer of the request.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
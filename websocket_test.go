@@ -0,0 +1,323 @@
+package handler
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+	"github.com/graphql-go/graphql"
+)
+
+func newSubscriptionSchema(t *testing.T) *graphql.Schema {
+	schema, err := graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"hello": &graphql.Field{
+					Type: graphql.String,
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return "world", nil
+					},
+				},
+			},
+		}),
+		Subscription: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Subscription",
+			Fields: graphql.Fields{
+				"counter": &graphql.Field{
+					Type: graphql.Int,
+					Subscribe: func(p graphql.ResolveParams) (interface{}, error) {
+						c := make(chan interface{})
+						go func() {
+							defer close(c)
+							for i := 1; i <= 3; i++ {
+								select {
+								case <-p.Context.Done():
+									return
+								case c <- i:
+								}
+							}
+						}()
+						return c, nil
+					},
+					Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+						return p.Source, nil
+					},
+				},
+			},
+		}),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &schema
+}
+
+func dialWebSocket(t *testing.T, h *Handler, protocol string) *websocket.Conn {
+	server := httptest.NewServer(h)
+	t.Cleanup(server.Close)
+
+	dialer := websocket.Dialer{Subprotocols: []string{protocol}}
+	conn, _, err := dialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func readMessage(t *testing.T, conn *websocket.Conn) wsMessage {
+	var msg wsMessage
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("read: %v", err)
+	}
+	return msg
+}
+
+func TestWebSocket_Subscription(t *testing.T) {
+	h := New(&Config{Schema: newSubscriptionSchema(t), Subscriptions: true})
+	conn := dialWebSocket(t, h, ProtocolGraphQLTransportWS)
+
+	conn.WriteJSON(wsMessage{Type: wsConnectionInit})
+	if msg := readMessage(t, conn); msg.Type != wsConnectionAck {
+		t.Fatalf("expected connection_ack, got %v", msg.Type)
+	}
+
+	conn.WriteJSON(wsMessage{ID: "1", Type: wsSubscribe, Payload: []byte(`{"query":"subscription { counter }"}`)})
+	for i := 1; i <= 3; i++ {
+		msg := readMessage(t, conn)
+		if msg.Type != wsNext || msg.ID != "1" {
+			t.Fatalf("expected next for 1, got %+v", msg)
+		}
+		if expected := `{"data":{"counter":` + string(rune('0'+i)) + `}}`; string(msg.Payload) != expected {
+			t.Fatalf("expected %s, got %s", expected, msg.Payload)
+		}
+	}
+	if msg := readMessage(t, conn); msg.Type != wsComplete || msg.ID != "1" {
+		t.Fatalf("expected complete for 1, got %+v", msg)
+	}
+}
+
+func TestWebSocket_SubscribeBeforeInit(t *testing.T) {
+	h := New(&Config{Schema: newSubscriptionSchema(t), Subscriptions: true})
+	conn := dialWebSocket(t, h, ProtocolGraphQLTransportWS)
+
+	conn.WriteJSON(wsMessage{ID: "1", Type: wsSubscribe, Payload: []byte(`{"query":"subscription { counter }"}`)})
+	_, _, err := conn.ReadMessage()
+	if closeErr, ok := err.(*websocket.CloseError); !ok || closeErr.Code != wsCloseUnauthorized {
+		t.Fatalf("expected close %v, got %v", wsCloseUnauthorized, err)
+	}
+}
+
+func TestWebSocket_LegacyProtocol(t *testing.T) {
+	h := New(&Config{Schema: newSubscriptionSchema(t), Subscriptions: true, LegacySubscriptionsProtocol: true})
+	conn := dialWebSocket(t, h, ProtocolGraphQLWS)
+	if conn.Subprotocol() != ProtocolGraphQLWS {
+		t.Fatalf("expected legacy subprotocol, got %q", conn.Subprotocol())
+	}
+
+	conn.WriteJSON(wsMessage{Type: wsConnectionInit})
+	if msg := readMessage(t, conn); msg.Type != wsConnectionAck {
+		t.Fatalf("expected connection_ack, got %v", msg.Type)
+	}
+	if msg := readMessage(t, conn); msg.Type != wsLegacyKeepAlive {
+		t.Fatalf("expected ka, got %v", msg.Type)
+	}
+
+	conn.WriteJSON(wsMessage{ID: "1", Type: wsLegacyStart, Payload: []byte(`{"query":"subscription { counter }"}`)})
+	for i := 1; i <= 3; i++ {
+		if msg := readMessage(t, conn); msg.Type != wsLegacyData || msg.ID != "1" {
+			t.Fatalf("expected data for 1, got %+v", msg)
+		}
+	}
+	if msg := readMessage(t, conn); msg.Type != wsComplete {
+		t.Fatalf("expected complete, got %+v", msg)
+	}
+}
+
+func TestWebSocket_LegacyProtocolDisabled(t *testing.T) {
+	h := New(&Config{Schema: newSubscriptionSchema(t), Subscriptions: true})
+	conn := dialWebSocket(t, h, ProtocolGraphQLWS)
+
+	_, _, err := conn.ReadMessage()
+	if closeErr, ok := err.(*websocket.CloseError); !ok || closeErr.Code != wsCloseSubprotocol {
+		t.Fatalf("expected close %v, got %v", wsCloseSubprotocol, err)
+	}
+}
+
+func TestWebSocket_OnWebSocketInit(t *testing.T) {
+	type tokenKey struct{}
+	schema := newSubscriptionSchema(t)
+	schema.SubscriptionType().AddFieldConfig("token", &graphql.Field{
+		Type: graphql.String,
+		Subscribe: func(p graphql.ResolveParams) (interface{}, error) {
+			return p.Context.Value(tokenKey{}), nil
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return p.Source, nil
+		},
+	})
+	h := New(&Config{
+		Schema:        schema,
+		Subscriptions: true,
+		OnWebSocketInit: func(ctx context.Context, payload map[string]interface{}) (context.Context, error) {
+			token, _ := payload["token"].(string)
+			if token == "" {
+				return nil, &WebSocketCloseError{Code: wsCloseUnauthorized, Reason: "missing token"}
+			}
+			return context.WithValue(ctx, tokenKey{}, token), nil
+		},
+	})
+
+	conn := dialWebSocket(t, h, ProtocolGraphQLTransportWS)
+	conn.WriteJSON(wsMessage{Type: wsConnectionInit, Payload: []byte(`{"token":"secret"}`)})
+	if msg := readMessage(t, conn); msg.Type != wsConnectionAck {
+		t.Fatalf("expected connection_ack, got %v", msg.Type)
+	}
+	conn.WriteJSON(wsMessage{ID: "1", Type: wsSubscribe, Payload: []byte(`{"query":"subscription { token }"}`)})
+	if msg := readMessage(t, conn); string(msg.Payload) != `{"data":{"token":"secret"}}` {
+		t.Fatalf("expected the context from the init hook, got %s", msg.Payload)
+	}
+
+	conn = dialWebSocket(t, h, ProtocolGraphQLTransportWS)
+	conn.WriteJSON(wsMessage{Type: wsConnectionInit})
+	_, _, err := conn.ReadMessage()
+	if closeErr, ok := err.(*websocket.CloseError); !ok || closeErr.Code != wsCloseUnauthorized || closeErr.Text != "missing token" {
+		t.Fatalf("expected close %v, got %v", wsCloseUnauthorized, err)
+	}
+}
+
+func TestWebSocket_OnWebSocketInitUnrelatedContext(t *testing.T) {
+	type tokenKey struct{}
+	cancelled := make(chan interface{}, 1)
+	schema := newSubscriptionSchema(t)
+	schema.SubscriptionType().AddFieldConfig("forever", &graphql.Field{
+		Type: graphql.String,
+		Subscribe: func(p graphql.ResolveParams) (interface{}, error) {
+			c := make(chan interface{})
+			go func() {
+				c <- p.Context.Value(tokenKey{})
+				<-p.Context.Done()
+				cancelled <- p.Context.Value(tokenKey{})
+			}()
+			return c, nil
+		},
+		Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+			return p.Source, nil
+		},
+	})
+	h := New(&Config{
+		Schema:        schema,
+		Subscriptions: true,
+		OnWebSocketInit: func(ctx context.Context, payload map[string]interface{}) (context.Context, error) {
+			return context.WithValue(context.Background(), tokenKey{}, "secret"), nil
+		},
+	})
+
+	conn := dialWebSocket(t, h, ProtocolGraphQLTransportWS)
+	conn.WriteJSON(wsMessage{Type: wsConnectionInit})
+	readMessage(t, conn)
+	conn.WriteJSON(wsMessage{ID: "1", Type: wsSubscribe, Payload: []byte(`{"query":"subscription { forever }"}`)})
+	if msg := readMessage(t, conn); string(msg.Payload) != `{"data":{"forever":"secret"}}` {
+		t.Fatalf("expected the values of the hook context, got %s", msg.Payload)
+	}
+	conn.Close()
+	select {
+	case token := <-cancelled:
+		if token != "secret" {
+			t.Errorf("unexpected token %v", token)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected closing the connection to cancel the operation")
+	}
+}
+
+func TestWebSocket_MaxConnectionsPerIP(t *testing.T) {
+	h := New(&Config{Schema: newSubscriptionSchema(t), Subscriptions: true, WebSocketMaxConnectionsPerIP: 1})
+	server := httptest.NewServer(h)
+	defer server.Close()
+	url := "ws" + strings.TrimPrefix(server.URL, "http")
+	dialer := websocket.Dialer{Subprotocols: []string{ProtocolGraphQLTransportWS}}
+
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	_, resp, err := dialer.Dial(url, nil)
+	if err == nil || resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected second connection to be rejected, got %v", err)
+	}
+
+	conn.Close()
+	time.Sleep(50 * time.Millisecond)
+	conn, _, err = dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("expected a slot after closing the first connection: %v", err)
+	}
+	conn.Close()
+}
+
+func TestWebSocket_PongTimeout(t *testing.T) {
+	h := New(&Config{
+		Schema:                newSubscriptionSchema(t),
+		Subscriptions:         true,
+		WebSocketPingInterval: 20 * time.Millisecond,
+		WebSocketPongTimeout:  20 * time.Millisecond,
+	})
+	conn := dialWebSocket(t, h, ProtocolGraphQLTransportWS)
+	conn.WriteJSON(wsMessage{Type: wsConnectionInit})
+
+	// the client does not answer pings while it is not reading
+	time.Sleep(200 * time.Millisecond)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	for {
+		_, _, err := conn.ReadMessage()
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			t.Fatalf("expected the server to close the connection")
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+func TestWebSocket_InvalidPersistedQuery(t *testing.T) {
+	h := New(&Config{Schema: newSubscriptionSchema(t), Subscriptions: true})
+	conn := dialWebSocket(t, h, ProtocolGraphQLTransportWS)
+
+	conn.WriteJSON(wsMessage{Type: wsConnectionInit})
+	readMessage(t, conn)
+
+	payload := `{"query":"subscription { counter }","extensions":{"persistedQuery":{"sha256Hash":1}}}`
+	conn.WriteJSON(wsMessage{ID: "1", Type: wsSubscribe, Payload: []byte(payload)})
+	if msg := readMessage(t, conn); msg.Type != wsError || msg.ID != "1" {
+		t.Fatalf("expected error for 1, got %+v", msg)
+	}
+}
+
+func TestWebSocket_Operations(t *testing.T) {
+	h := New(&Config{Schema: newSubscriptionSchema(t), Subscriptions: true, WebSocketOperations: true})
+	conn := dialWebSocket(t, h, ProtocolGraphQLTransportWS)
+
+	conn.WriteJSON(wsMessage{Type: wsConnectionInit})
+	readMessage(t, conn)
+
+	conn.WriteJSON(wsMessage{ID: "1", Type: wsSubscribe, Payload: []byte(`{"query":"{ hello }"}`)})
+	if msg := readMessage(t, conn); msg.Type != wsNext || string(msg.Payload) != `{"data":{"hello":"world"}}` {
+		t.Fatalf("expected the query result, got %+v", msg)
+	}
+	if msg := readMessage(t, conn); msg.Type != wsComplete || msg.ID != "1" {
+		t.Fatalf("expected complete for 1, got %+v", msg)
+	}
+
+	conn.WriteJSON(wsMessage{ID: "2", Type: wsSubscribe, Payload: []byte(`{"query":"{ unknown }"}`)})
+	if msg := readMessage(t, conn); msg.Type != wsError || msg.ID != "2" {
+		t.Fatalf("expected an error for 2, got %+v", msg)
+	}
+}